@@ -497,6 +497,7 @@ type IngesterServiceClient interface {
 	MergeProfilesStacktraces(ctx context.Context, opts ...grpc.CallOption) (IngesterService_MergeProfilesStacktracesClient, error)
 	MergeProfilesLabels(ctx context.Context, opts ...grpc.CallOption) (IngesterService_MergeProfilesLabelsClient, error)
 	MergeProfilesPprof(ctx context.Context, opts ...grpc.CallOption) (IngesterService_MergeProfilesPprofClient, error)
+	Cardinality(ctx context.Context, in *v1.CardinalityRequest, opts ...grpc.CallOption) (*v1.CardinalityResponse, error)
 }
 
 type ingesterServiceClient struct {
@@ -654,6 +655,15 @@ func (x *ingesterServiceMergeProfilesPprofClient) Recv() (*MergeProfilesPprofRes
 	return m, nil
 }
 
+func (c *ingesterServiceClient) Cardinality(ctx context.Context, in *v1.CardinalityRequest, opts ...grpc.CallOption) (*v1.CardinalityResponse, error) {
+	out := new(v1.CardinalityResponse)
+	err := c.cc.Invoke(ctx, "/ingester.v1.IngesterService/Cardinality", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // IngesterServiceServer is the server API for IngesterService service.
 // All implementations must embed UnimplementedIngesterServiceServer
 // for forward compatibility
@@ -667,6 +677,7 @@ type IngesterServiceServer interface {
 	MergeProfilesStacktraces(IngesterService_MergeProfilesStacktracesServer) error
 	MergeProfilesLabels(IngesterService_MergeProfilesLabelsServer) error
 	MergeProfilesPprof(IngesterService_MergeProfilesPprofServer) error
+	Cardinality(context.Context, *v1.CardinalityRequest) (*v1.CardinalityResponse, error)
 	mustEmbedUnimplementedIngesterServiceServer()
 }
 
@@ -701,6 +712,9 @@ func (UnimplementedIngesterServiceServer) MergeProfilesLabels(IngesterService_Me
 func (UnimplementedIngesterServiceServer) MergeProfilesPprof(IngesterService_MergeProfilesPprofServer) error {
 	return status.Errorf(codes.Unimplemented, "method MergeProfilesPprof not implemented")
 }
+func (UnimplementedIngesterServiceServer) Cardinality(context.Context, *v1.CardinalityRequest) (*v1.CardinalityResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Cardinality not implemented")
+}
 func (UnimplementedIngesterServiceServer) mustEmbedUnimplementedIngesterServiceServer() {}
 
 // UnsafeIngesterServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -900,6 +914,24 @@ func (x *ingesterServiceMergeProfilesPprofServer) Recv() (*MergeProfilesPprofReq
 	return m, nil
 }
 
+func _IngesterService_Cardinality_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(v1.CardinalityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IngesterServiceServer).Cardinality(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ingester.v1.IngesterService/Cardinality",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IngesterServiceServer).Cardinality(ctx, req.(*v1.CardinalityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // IngesterService_ServiceDesc is the grpc.ServiceDesc for IngesterService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -931,6 +963,10 @@ var IngesterService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Flush",
 			Handler:    _IngesterService_Flush_Handler,
 		},
+		{
+			MethodName: "Cardinality",
+			Handler:    _IngesterService_Cardinality_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -38,6 +38,7 @@ type IngesterServiceClient interface {
 	MergeProfilesStacktraces(context.Context) *connect_go.BidiStreamForClient[v12.MergeProfilesStacktracesRequest, v12.MergeProfilesStacktracesResponse]
 	MergeProfilesLabels(context.Context) *connect_go.BidiStreamForClient[v12.MergeProfilesLabelsRequest, v12.MergeProfilesLabelsResponse]
 	MergeProfilesPprof(context.Context) *connect_go.BidiStreamForClient[v12.MergeProfilesPprofRequest, v12.MergeProfilesPprofResponse]
+	Cardinality(context.Context, *connect_go.Request[v11.CardinalityRequest]) (*connect_go.Response[v11.CardinalityResponse], error)
 }
 
 // NewIngesterServiceClient constructs a client for the ingester.v1.IngesterService service. By
@@ -95,6 +96,11 @@ func NewIngesterServiceClient(httpClient connect_go.HTTPClient, baseURL string,
 			baseURL+"/ingester.v1.IngesterService/MergeProfilesPprof",
 			opts...,
 		),
+		cardinality: connect_go.NewClient[v11.CardinalityRequest, v11.CardinalityResponse](
+			httpClient,
+			baseURL+"/ingester.v1.IngesterService/Cardinality",
+			opts...,
+		),
 	}
 }
 
@@ -109,6 +115,7 @@ type ingesterServiceClient struct {
 	mergeProfilesStacktraces *connect_go.Client[v12.MergeProfilesStacktracesRequest, v12.MergeProfilesStacktracesResponse]
 	mergeProfilesLabels      *connect_go.Client[v12.MergeProfilesLabelsRequest, v12.MergeProfilesLabelsResponse]
 	mergeProfilesPprof       *connect_go.Client[v12.MergeProfilesPprofRequest, v12.MergeProfilesPprofResponse]
+	cardinality              *connect_go.Client[v11.CardinalityRequest, v11.CardinalityResponse]
 }
 
 // Push calls ingester.v1.IngesterService.Push.
@@ -156,6 +163,11 @@ func (c *ingesterServiceClient) MergeProfilesPprof(ctx context.Context) *connect
 	return c.mergeProfilesPprof.CallBidiStream(ctx)
 }
 
+// Cardinality calls ingester.v1.IngesterService.Cardinality.
+func (c *ingesterServiceClient) Cardinality(ctx context.Context, req *connect_go.Request[v11.CardinalityRequest]) (*connect_go.Response[v11.CardinalityResponse], error) {
+	return c.cardinality.CallUnary(ctx, req)
+}
+
 // IngesterServiceHandler is an implementation of the ingester.v1.IngesterService service.
 type IngesterServiceHandler interface {
 	Push(context.Context, *connect_go.Request[v1.PushRequest]) (*connect_go.Response[v1.PushResponse], error)
@@ -167,6 +179,7 @@ type IngesterServiceHandler interface {
 	MergeProfilesStacktraces(context.Context, *connect_go.BidiStream[v12.MergeProfilesStacktracesRequest, v12.MergeProfilesStacktracesResponse]) error
 	MergeProfilesLabels(context.Context, *connect_go.BidiStream[v12.MergeProfilesLabelsRequest, v12.MergeProfilesLabelsResponse]) error
 	MergeProfilesPprof(context.Context, *connect_go.BidiStream[v12.MergeProfilesPprofRequest, v12.MergeProfilesPprofResponse]) error
+	Cardinality(context.Context, *connect_go.Request[v11.CardinalityRequest]) (*connect_go.Response[v11.CardinalityResponse], error)
 }
 
 // NewIngesterServiceHandler builds an HTTP handler from the service implementation. It returns the
@@ -221,6 +234,11 @@ func NewIngesterServiceHandler(svc IngesterServiceHandler, opts ...connect_go.Ha
 		svc.MergeProfilesPprof,
 		opts...,
 	))
+	mux.Handle("/ingester.v1.IngesterService/Cardinality", connect_go.NewUnaryHandler(
+		"/ingester.v1.IngesterService/Cardinality",
+		svc.Cardinality,
+		opts...,
+	))
 	return "/ingester.v1.IngesterService/", mux
 }
 
@@ -262,3 +280,7 @@ func (UnimplementedIngesterServiceHandler) MergeProfilesLabels(context.Context,
 func (UnimplementedIngesterServiceHandler) MergeProfilesPprof(context.Context, *connect_go.BidiStream[v12.MergeProfilesPprofRequest, v12.MergeProfilesPprofResponse]) error {
 	return connect_go.NewError(connect_go.CodeUnimplemented, errors.New("ingester.v1.IngesterService.MergeProfilesPprof is not implemented"))
 }
+
+func (UnimplementedIngesterServiceHandler) Cardinality(context.Context, *connect_go.Request[v11.CardinalityRequest]) (*connect_go.Response[v11.CardinalityResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("ingester.v1.IngesterService.Cardinality is not implemented"))
+}
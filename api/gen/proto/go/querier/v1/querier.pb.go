@@ -950,7 +950,7 @@ var file_querier_v1_querier_proto_rawDesc = []byte{
 	0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x28, 0x0a, 0x06,
 	0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x74,
 	0x79, 0x70, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x06,
-	0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x32, 0x99, 0x05, 0x0a, 0x0e, 0x51, 0x75, 0x65, 0x72, 0x69,
+	0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x32, 0xe7, 0x05, 0x0a, 0x0e, 0x51, 0x75, 0x65, 0x72, 0x69,
 	0x65, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x53, 0x0a, 0x0c, 0x50, 0x72, 0x6f,
 	0x66, 0x69, 0x6c, 0x65, 0x54, 0x79, 0x70, 0x65, 0x73, 0x12, 0x1f, 0x2e, 0x71, 0x75, 0x65, 0x72,
 	0x69, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x54, 0x79,
@@ -992,18 +992,23 @@ var file_querier_v1_querier_proto_rawDesc = []byte{
 	0x71, 0x75, 0x65, 0x72, 0x69, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x69, 0x66, 0x66, 0x52,
 	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x18, 0x2e, 0x71, 0x75, 0x65, 0x72, 0x69, 0x65, 0x72,
 	0x2e, 0x76, 0x31, 0x2e, 0x44, 0x69, 0x66, 0x66, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
-	0x22, 0x00, 0x42, 0xa8, 0x01, 0x0a, 0x0e, 0x63, 0x6f, 0x6d, 0x2e, 0x71, 0x75, 0x65, 0x72, 0x69,
-	0x65, 0x72, 0x2e, 0x76, 0x31, 0x42, 0x0c, 0x51, 0x75, 0x65, 0x72, 0x69, 0x65, 0x72, 0x50, 0x72,
-	0x6f, 0x74, 0x6f, 0x50, 0x01, 0x5a, 0x3f, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
-	0x6d, 0x2f, 0x67, 0x72, 0x61, 0x66, 0x61, 0x6e, 0x61, 0x2f, 0x70, 0x68, 0x6c, 0x61, 0x72, 0x65,
-	0x2f, 0x61, 0x70, 0x69, 0x2f, 0x67, 0x65, 0x6e, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x67,
-	0x6f, 0x2f, 0x71, 0x75, 0x65, 0x72, 0x69, 0x65, 0x72, 0x2f, 0x76, 0x31, 0x3b, 0x71, 0x75, 0x65,
-	0x72, 0x69, 0x65, 0x72, 0x76, 0x31, 0xa2, 0x02, 0x03, 0x51, 0x58, 0x58, 0xaa, 0x02, 0x0a, 0x51,
-	0x75, 0x65, 0x72, 0x69, 0x65, 0x72, 0x2e, 0x56, 0x31, 0xca, 0x02, 0x0a, 0x51, 0x75, 0x65, 0x72,
-	0x69, 0x65, 0x72, 0x5c, 0x56, 0x31, 0xe2, 0x02, 0x16, 0x51, 0x75, 0x65, 0x72, 0x69, 0x65, 0x72,
-	0x5c, 0x56, 0x31, 0x5c, 0x47, 0x50, 0x42, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0xea,
-	0x02, 0x0b, 0x51, 0x75, 0x65, 0x72, 0x69, 0x65, 0x72, 0x3a, 0x3a, 0x56, 0x31, 0x62, 0x06, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x22, 0x00, 0x12, 0x4c, 0x0a, 0x0b, 0x43, 0x61, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x6c, 0x69, 0x74,
+	0x79, 0x12, 0x1c, 0x2e, 0x74, 0x79, 0x70, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x61, 0x72,
+	0x64, 0x69, 0x6e, 0x61, 0x6c, 0x69, 0x74, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x1d, 0x2e, 0x74, 0x79, 0x70, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x61, 0x72, 0x64, 0x69,
+	0x6e, 0x61, 0x6c, 0x69, 0x74, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00,
+	0x42, 0xa8, 0x01, 0x0a, 0x0e, 0x63, 0x6f, 0x6d, 0x2e, 0x71, 0x75, 0x65, 0x72, 0x69, 0x65, 0x72,
+	0x2e, 0x76, 0x31, 0x42, 0x0c, 0x51, 0x75, 0x65, 0x72, 0x69, 0x65, 0x72, 0x50, 0x72, 0x6f, 0x74,
+	0x6f, 0x50, 0x01, 0x5a, 0x3f, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
+	0x67, 0x72, 0x61, 0x66, 0x61, 0x6e, 0x61, 0x2f, 0x70, 0x68, 0x6c, 0x61, 0x72, 0x65, 0x2f, 0x61,
+	0x70, 0x69, 0x2f, 0x67, 0x65, 0x6e, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x67, 0x6f, 0x2f,
+	0x71, 0x75, 0x65, 0x72, 0x69, 0x65, 0x72, 0x2f, 0x76, 0x31, 0x3b, 0x71, 0x75, 0x65, 0x72, 0x69,
+	0x65, 0x72, 0x76, 0x31, 0xa2, 0x02, 0x03, 0x51, 0x58, 0x58, 0xaa, 0x02, 0x0a, 0x51, 0x75, 0x65,
+	0x72, 0x69, 0x65, 0x72, 0x2e, 0x56, 0x31, 0xca, 0x02, 0x0a, 0x51, 0x75, 0x65, 0x72, 0x69, 0x65,
+	0x72, 0x5c, 0x56, 0x31, 0xe2, 0x02, 0x16, 0x51, 0x75, 0x65, 0x72, 0x69, 0x65, 0x72, 0x5c, 0x56,
+	0x31, 0x5c, 0x47, 0x50, 0x42, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0xea, 0x02, 0x0b,
+	0x51, 0x75, 0x65, 0x72, 0x69, 0x65, 0x72, 0x3a, 0x3a, 0x56, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
 }
 
 var (
@@ -1039,9 +1044,11 @@ var file_querier_v1_querier_proto_goTypes = []interface{}{
 	(*v1.Series)(nil),                      // 16: types.v1.Series
 	(*v1.LabelValuesRequest)(nil),          // 17: types.v1.LabelValuesRequest
 	(*v1.LabelNamesRequest)(nil),           // 18: types.v1.LabelNamesRequest
-	(*v1.LabelValuesResponse)(nil),         // 19: types.v1.LabelValuesResponse
-	(*v1.LabelNamesResponse)(nil),          // 20: types.v1.LabelNamesResponse
-	(*v11.Profile)(nil),                    // 21: google.v1.Profile
+	(*v1.CardinalityRequest)(nil),          // 19: types.v1.CardinalityRequest
+	(*v1.LabelValuesResponse)(nil),         // 20: types.v1.LabelValuesResponse
+	(*v1.LabelNamesResponse)(nil),          // 21: types.v1.LabelNamesResponse
+	(*v11.Profile)(nil),                    // 22: google.v1.Profile
+	(*v1.CardinalityResponse)(nil),         // 23: types.v1.CardinalityResponse
 }
 var file_querier_v1_querier_proto_depIdxs = []int32{
 	14, // 0: querier.v1.ProfileTypesResponse.profile_types:type_name -> types.v1.ProfileType
@@ -1061,16 +1068,18 @@ var file_querier_v1_querier_proto_depIdxs = []int32{
 	11, // 14: querier.v1.QuerierService.SelectMergeProfile:input_type -> querier.v1.SelectMergeProfileRequest
 	12, // 15: querier.v1.QuerierService.SelectSeries:input_type -> querier.v1.SelectSeriesRequest
 	6,  // 16: querier.v1.QuerierService.Diff:input_type -> querier.v1.DiffRequest
-	1,  // 17: querier.v1.QuerierService.ProfileTypes:output_type -> querier.v1.ProfileTypesResponse
-	19, // 18: querier.v1.QuerierService.LabelValues:output_type -> types.v1.LabelValuesResponse
-	20, // 19: querier.v1.QuerierService.LabelNames:output_type -> types.v1.LabelNamesResponse
-	3,  // 20: querier.v1.QuerierService.Series:output_type -> querier.v1.SeriesResponse
-	5,  // 21: querier.v1.QuerierService.SelectMergeStacktraces:output_type -> querier.v1.SelectMergeStacktracesResponse
-	21, // 22: querier.v1.QuerierService.SelectMergeProfile:output_type -> google.v1.Profile
-	13, // 23: querier.v1.QuerierService.SelectSeries:output_type -> querier.v1.SelectSeriesResponse
-	7,  // 24: querier.v1.QuerierService.Diff:output_type -> querier.v1.DiffResponse
-	17, // [17:25] is the sub-list for method output_type
-	9,  // [9:17] is the sub-list for method input_type
+	19, // 17: querier.v1.QuerierService.Cardinality:input_type -> types.v1.CardinalityRequest
+	1,  // 18: querier.v1.QuerierService.ProfileTypes:output_type -> querier.v1.ProfileTypesResponse
+	20, // 19: querier.v1.QuerierService.LabelValues:output_type -> types.v1.LabelValuesResponse
+	21, // 20: querier.v1.QuerierService.LabelNames:output_type -> types.v1.LabelNamesResponse
+	3,  // 21: querier.v1.QuerierService.Series:output_type -> querier.v1.SeriesResponse
+	5,  // 22: querier.v1.QuerierService.SelectMergeStacktraces:output_type -> querier.v1.SelectMergeStacktracesResponse
+	22, // 23: querier.v1.QuerierService.SelectMergeProfile:output_type -> google.v1.Profile
+	13, // 24: querier.v1.QuerierService.SelectSeries:output_type -> querier.v1.SelectSeriesResponse
+	7,  // 25: querier.v1.QuerierService.Diff:output_type -> querier.v1.DiffResponse
+	23, // 26: querier.v1.QuerierService.Cardinality:output_type -> types.v1.CardinalityResponse
+	18, // [18:27] is the sub-list for method output_type
+	9,  // [9:18] is the sub-list for method input_type
 	9,  // [9:9] is the sub-list for extension type_name
 	9,  // [9:9] is the sub-list for extension extendee
 	0,  // [0:9] is the sub-list for field type_name
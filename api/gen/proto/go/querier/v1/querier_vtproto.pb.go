@@ -380,6 +380,7 @@ type QuerierServiceClient interface {
 	SelectMergeProfile(ctx context.Context, in *SelectMergeProfileRequest, opts ...grpc.CallOption) (*v11.Profile, error)
 	SelectSeries(ctx context.Context, in *SelectSeriesRequest, opts ...grpc.CallOption) (*SelectSeriesResponse, error)
 	Diff(ctx context.Context, in *DiffRequest, opts ...grpc.CallOption) (*DiffResponse, error)
+	Cardinality(ctx context.Context, in *v1.CardinalityRequest, opts ...grpc.CallOption) (*v1.CardinalityResponse, error)
 }
 
 type querierServiceClient struct {
@@ -462,6 +463,15 @@ func (c *querierServiceClient) Diff(ctx context.Context, in *DiffRequest, opts .
 	return out, nil
 }
 
+func (c *querierServiceClient) Cardinality(ctx context.Context, in *v1.CardinalityRequest, opts ...grpc.CallOption) (*v1.CardinalityResponse, error) {
+	out := new(v1.CardinalityResponse)
+	err := c.cc.Invoke(ctx, "/querier.v1.QuerierService/Cardinality", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // QuerierServiceServer is the server API for QuerierService service.
 // All implementations must embed UnimplementedQuerierServiceServer
 // for forward compatibility
@@ -474,6 +484,7 @@ type QuerierServiceServer interface {
 	SelectMergeProfile(context.Context, *SelectMergeProfileRequest) (*v11.Profile, error)
 	SelectSeries(context.Context, *SelectSeriesRequest) (*SelectSeriesResponse, error)
 	Diff(context.Context, *DiffRequest) (*DiffResponse, error)
+	Cardinality(context.Context, *v1.CardinalityRequest) (*v1.CardinalityResponse, error)
 	mustEmbedUnimplementedQuerierServiceServer()
 }
 
@@ -505,6 +516,9 @@ func (UnimplementedQuerierServiceServer) SelectSeries(context.Context, *SelectSe
 func (UnimplementedQuerierServiceServer) Diff(context.Context, *DiffRequest) (*DiffResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Diff not implemented")
 }
+func (UnimplementedQuerierServiceServer) Cardinality(context.Context, *v1.CardinalityRequest) (*v1.CardinalityResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Cardinality not implemented")
+}
 func (UnimplementedQuerierServiceServer) mustEmbedUnimplementedQuerierServiceServer() {}
 
 // UnsafeQuerierServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -662,6 +676,24 @@ func _QuerierService_Diff_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _QuerierService_Cardinality_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(v1.CardinalityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuerierServiceServer).Cardinality(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/querier.v1.QuerierService/Cardinality",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuerierServiceServer).Cardinality(ctx, req.(*v1.CardinalityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // QuerierService_ServiceDesc is the grpc.ServiceDesc for QuerierService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -701,6 +733,10 @@ var QuerierService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Diff",
 			Handler:    _QuerierService_Diff_Handler,
 		},
+		{
+			MethodName: "Cardinality",
+			Handler:    _QuerierService_Cardinality_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "querier/v1/querier.proto",
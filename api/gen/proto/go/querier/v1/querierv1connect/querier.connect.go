@@ -37,6 +37,7 @@ type QuerierServiceClient interface {
 	SelectMergeProfile(context.Context, *connect_go.Request[v1.SelectMergeProfileRequest]) (*connect_go.Response[v12.Profile], error)
 	SelectSeries(context.Context, *connect_go.Request[v1.SelectSeriesRequest]) (*connect_go.Response[v1.SelectSeriesResponse], error)
 	Diff(context.Context, *connect_go.Request[v1.DiffRequest]) (*connect_go.Response[v1.DiffResponse], error)
+	Cardinality(context.Context, *connect_go.Request[v11.CardinalityRequest]) (*connect_go.Response[v11.CardinalityResponse], error)
 }
 
 // NewQuerierServiceClient constructs a client for the querier.v1.QuerierService service. By
@@ -89,6 +90,11 @@ func NewQuerierServiceClient(httpClient connect_go.HTTPClient, baseURL string, o
 			baseURL+"/querier.v1.QuerierService/Diff",
 			opts...,
 		),
+		cardinality: connect_go.NewClient[v11.CardinalityRequest, v11.CardinalityResponse](
+			httpClient,
+			baseURL+"/querier.v1.QuerierService/Cardinality",
+			opts...,
+		),
 	}
 }
 
@@ -102,6 +108,7 @@ type querierServiceClient struct {
 	selectMergeProfile     *connect_go.Client[v1.SelectMergeProfileRequest, v12.Profile]
 	selectSeries           *connect_go.Client[v1.SelectSeriesRequest, v1.SelectSeriesResponse]
 	diff                   *connect_go.Client[v1.DiffRequest, v1.DiffResponse]
+	cardinality            *connect_go.Client[v11.CardinalityRequest, v11.CardinalityResponse]
 }
 
 // ProfileTypes calls querier.v1.QuerierService.ProfileTypes.
@@ -144,6 +151,11 @@ func (c *querierServiceClient) Diff(ctx context.Context, req *connect_go.Request
 	return c.diff.CallUnary(ctx, req)
 }
 
+// Cardinality calls querier.v1.QuerierService.Cardinality.
+func (c *querierServiceClient) Cardinality(ctx context.Context, req *connect_go.Request[v11.CardinalityRequest]) (*connect_go.Response[v11.CardinalityResponse], error) {
+	return c.cardinality.CallUnary(ctx, req)
+}
+
 // QuerierServiceHandler is an implementation of the querier.v1.QuerierService service.
 type QuerierServiceHandler interface {
 	ProfileTypes(context.Context, *connect_go.Request[v1.ProfileTypesRequest]) (*connect_go.Response[v1.ProfileTypesResponse], error)
@@ -154,6 +166,7 @@ type QuerierServiceHandler interface {
 	SelectMergeProfile(context.Context, *connect_go.Request[v1.SelectMergeProfileRequest]) (*connect_go.Response[v12.Profile], error)
 	SelectSeries(context.Context, *connect_go.Request[v1.SelectSeriesRequest]) (*connect_go.Response[v1.SelectSeriesResponse], error)
 	Diff(context.Context, *connect_go.Request[v1.DiffRequest]) (*connect_go.Response[v1.DiffResponse], error)
+	Cardinality(context.Context, *connect_go.Request[v11.CardinalityRequest]) (*connect_go.Response[v11.CardinalityResponse], error)
 }
 
 // NewQuerierServiceHandler builds an HTTP handler from the service implementation. It returns the
@@ -203,6 +216,11 @@ func NewQuerierServiceHandler(svc QuerierServiceHandler, opts ...connect_go.Hand
 		svc.Diff,
 		opts...,
 	))
+	mux.Handle("/querier.v1.QuerierService/Cardinality", connect_go.NewUnaryHandler(
+		"/querier.v1.QuerierService/Cardinality",
+		svc.Cardinality,
+		opts...,
+	))
 	return "/querier.v1.QuerierService/", mux
 }
 
@@ -240,3 +258,7 @@ func (UnimplementedQuerierServiceHandler) SelectSeries(context.Context, *connect
 func (UnimplementedQuerierServiceHandler) Diff(context.Context, *connect_go.Request[v1.DiffRequest]) (*connect_go.Response[v1.DiffResponse], error) {
 	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("querier.v1.QuerierService.Diff is not implemented"))
 }
+
+func (UnimplementedQuerierServiceHandler) Cardinality(context.Context, *connect_go.Request[v11.CardinalityRequest]) (*connect_go.Response[v11.CardinalityResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("querier.v1.QuerierService.Cardinality is not implemented"))
+}
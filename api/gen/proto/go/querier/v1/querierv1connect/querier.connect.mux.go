@@ -59,4 +59,9 @@ func RegisterQuerierServiceHandler(mux *mux.Router, svc QuerierServiceHandler, o
 		svc.Diff,
 		opts...,
 	))
+	mux.Handle("/querier.v1.QuerierService/Cardinality", connect_go.NewUnaryHandler(
+		"/querier.v1.QuerierService/Cardinality",
+		svc.Cardinality,
+		opts...,
+	))
 }
@@ -9,6 +9,7 @@ import (
 	errors "errors"
 	connect_go "github.com/bufbuild/connect-go"
 	v1 "github.com/grafana/phlare/api/gen/proto/go/ingester/v1"
+	v11 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
 	http "net/http"
 	strings "strings"
 )
@@ -30,6 +31,7 @@ type StoreGatewayServiceClient interface {
 	MergeProfilesStacktraces(context.Context) *connect_go.BidiStreamForClient[v1.MergeProfilesStacktracesRequest, v1.MergeProfilesStacktracesResponse]
 	MergeProfilesLabels(context.Context) *connect_go.BidiStreamForClient[v1.MergeProfilesLabelsRequest, v1.MergeProfilesLabelsResponse]
 	MergeProfilesPprof(context.Context) *connect_go.BidiStreamForClient[v1.MergeProfilesPprofRequest, v1.MergeProfilesPprofResponse]
+	Cardinality(context.Context, *connect_go.Request[v11.CardinalityRequest]) (*connect_go.Response[v11.CardinalityResponse], error)
 }
 
 // NewStoreGatewayServiceClient constructs a client for the storegateway.v1.StoreGatewayService
@@ -57,6 +59,11 @@ func NewStoreGatewayServiceClient(httpClient connect_go.HTTPClient, baseURL stri
 			baseURL+"/storegateway.v1.StoreGatewayService/MergeProfilesPprof",
 			opts...,
 		),
+		cardinality: connect_go.NewClient[v11.CardinalityRequest, v11.CardinalityResponse](
+			httpClient,
+			baseURL+"/storegateway.v1.StoreGatewayService/Cardinality",
+			opts...,
+		),
 	}
 }
 
@@ -65,6 +72,7 @@ type storeGatewayServiceClient struct {
 	mergeProfilesStacktraces *connect_go.Client[v1.MergeProfilesStacktracesRequest, v1.MergeProfilesStacktracesResponse]
 	mergeProfilesLabels      *connect_go.Client[v1.MergeProfilesLabelsRequest, v1.MergeProfilesLabelsResponse]
 	mergeProfilesPprof       *connect_go.Client[v1.MergeProfilesPprofRequest, v1.MergeProfilesPprofResponse]
+	cardinality              *connect_go.Client[v11.CardinalityRequest, v11.CardinalityResponse]
 }
 
 // MergeProfilesStacktraces calls storegateway.v1.StoreGatewayService.MergeProfilesStacktraces.
@@ -82,12 +90,18 @@ func (c *storeGatewayServiceClient) MergeProfilesPprof(ctx context.Context) *con
 	return c.mergeProfilesPprof.CallBidiStream(ctx)
 }
 
+// Cardinality calls storegateway.v1.StoreGatewayService.Cardinality.
+func (c *storeGatewayServiceClient) Cardinality(ctx context.Context, req *connect_go.Request[v11.CardinalityRequest]) (*connect_go.Response[v11.CardinalityResponse], error) {
+	return c.cardinality.CallUnary(ctx, req)
+}
+
 // StoreGatewayServiceHandler is an implementation of the storegateway.v1.StoreGatewayService
 // service.
 type StoreGatewayServiceHandler interface {
 	MergeProfilesStacktraces(context.Context, *connect_go.BidiStream[v1.MergeProfilesStacktracesRequest, v1.MergeProfilesStacktracesResponse]) error
 	MergeProfilesLabels(context.Context, *connect_go.BidiStream[v1.MergeProfilesLabelsRequest, v1.MergeProfilesLabelsResponse]) error
 	MergeProfilesPprof(context.Context, *connect_go.BidiStream[v1.MergeProfilesPprofRequest, v1.MergeProfilesPprofResponse]) error
+	Cardinality(context.Context, *connect_go.Request[v11.CardinalityRequest]) (*connect_go.Response[v11.CardinalityResponse], error)
 }
 
 // NewStoreGatewayServiceHandler builds an HTTP handler from the service implementation. It returns
@@ -112,6 +126,11 @@ func NewStoreGatewayServiceHandler(svc StoreGatewayServiceHandler, opts ...conne
 		svc.MergeProfilesPprof,
 		opts...,
 	))
+	mux.Handle("/storegateway.v1.StoreGatewayService/Cardinality", connect_go.NewUnaryHandler(
+		"/storegateway.v1.StoreGatewayService/Cardinality",
+		svc.Cardinality,
+		opts...,
+	))
 	return "/storegateway.v1.StoreGatewayService/", mux
 }
 
@@ -129,3 +148,7 @@ func (UnimplementedStoreGatewayServiceHandler) MergeProfilesLabels(context.Conte
 func (UnimplementedStoreGatewayServiceHandler) MergeProfilesPprof(context.Context, *connect_go.BidiStream[v1.MergeProfilesPprofRequest, v1.MergeProfilesPprofResponse]) error {
 	return connect_go.NewError(connect_go.CodeUnimplemented, errors.New("storegateway.v1.StoreGatewayService.MergeProfilesPprof is not implemented"))
 }
+
+func (UnimplementedStoreGatewayServiceHandler) Cardinality(context.Context, *connect_go.Request[v11.CardinalityRequest]) (*connect_go.Response[v11.CardinalityResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("storegateway.v1.StoreGatewayService.Cardinality is not implemented"))
+}
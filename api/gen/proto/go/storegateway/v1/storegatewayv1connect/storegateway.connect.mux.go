@@ -34,4 +34,9 @@ func RegisterStoreGatewayServiceHandler(mux *mux.Router, svc StoreGatewayService
 		svc.MergeProfilesPprof,
 		opts...,
 	))
+	mux.Handle("/storegateway.v1.StoreGatewayService/Cardinality", connect_go.NewUnaryHandler(
+		"/storegateway.v1.StoreGatewayService/Cardinality",
+		svc.Cardinality,
+		opts...,
+	))
 }
@@ -517,6 +517,362 @@ func (x *LabelNamesResponse) GetNames() []string {
 	return nil
 }
 
+type CardinalityRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The ID of the profile type of the series, all the profile types are
+	// analysed if empty.
+	ProfileTypeID string `protobuf:"bytes,1,opt,name=profile_typeID,json=profileTypeID,proto3" json:"profile_typeID,omitempty"`
+	// Milliseconds unix timestamp
+	Start int64 `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	// Milliseconds unix timestamp
+	End int64 `protobuf:"varint,3,opt,name=end,proto3" json:"end,omitempty"`
+	// The number of values reported per label name, by decreasing number of
+	// series. All the values are reported if negative.
+	Limit int64 `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	// The interval of the series churn, in milliseconds.
+	Step int64 `protobuf:"varint,5,opt,name=step,proto3" json:"step,omitempty"`
+}
+
+func (x *CardinalityRequest) Reset() {
+	*x = CardinalityRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_types_v1_types_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CardinalityRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CardinalityRequest) ProtoMessage() {}
+
+func (x *CardinalityRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_types_v1_types_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CardinalityRequest.ProtoReflect.Descriptor instead.
+func (*CardinalityRequest) Descriptor() ([]byte, []int) {
+	return file_types_v1_types_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CardinalityRequest) GetProfileTypeID() string {
+	if x != nil {
+		return x.ProfileTypeID
+	}
+	return ""
+}
+
+func (x *CardinalityRequest) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *CardinalityRequest) GetEnd() int64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *CardinalityRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *CardinalityRequest) GetStep() int64 {
+	if x != nil {
+		return x.Step
+	}
+	return 0
+}
+
+type CardinalityResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The number of series.
+	Series int64 `protobuf:"varint,1,opt,name=series,proto3" json:"series,omitempty"`
+	// The labels, by decreasing number of series then by name.
+	Labels []*LabelCardinality `protobuf:"bytes,2,rep,name=labels,proto3" json:"labels,omitempty"`
+	Churn  []*SeriesChurn      `protobuf:"bytes,3,rep,name=churn,proto3" json:"churn,omitempty"`
+}
+
+func (x *CardinalityResponse) Reset() {
+	*x = CardinalityResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_types_v1_types_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CardinalityResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CardinalityResponse) ProtoMessage() {}
+
+func (x *CardinalityResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_types_v1_types_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CardinalityResponse.ProtoReflect.Descriptor instead.
+func (*CardinalityResponse) Descriptor() ([]byte, []int) {
+	return file_types_v1_types_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *CardinalityResponse) GetSeries() int64 {
+	if x != nil {
+		return x.Series
+	}
+	return 0
+}
+
+func (x *CardinalityResponse) GetLabels() []*LabelCardinality {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *CardinalityResponse) GetChurn() []*SeriesChurn {
+	if x != nil {
+		return x.Churn
+	}
+	return nil
+}
+
+type LabelCardinality struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// The number of series with the label.
+	Series int64 `protobuf:"varint,2,opt,name=series,proto3" json:"series,omitempty"`
+	// The number of distinct values of the label.
+	Values    int64                    `protobuf:"varint,3,opt,name=values,proto3" json:"values,omitempty"`
+	TopValues []*LabelValueCardinality `protobuf:"bytes,4,rep,name=top_values,json=topValues,proto3" json:"top_values,omitempty"`
+}
+
+func (x *LabelCardinality) Reset() {
+	*x = LabelCardinality{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_types_v1_types_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LabelCardinality) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LabelCardinality) ProtoMessage() {}
+
+func (x *LabelCardinality) ProtoReflect() protoreflect.Message {
+	mi := &file_types_v1_types_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LabelCardinality.ProtoReflect.Descriptor instead.
+func (*LabelCardinality) Descriptor() ([]byte, []int) {
+	return file_types_v1_types_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *LabelCardinality) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *LabelCardinality) GetSeries() int64 {
+	if x != nil {
+		return x.Series
+	}
+	return 0
+}
+
+func (x *LabelCardinality) GetValues() int64 {
+	if x != nil {
+		return x.Values
+	}
+	return 0
+}
+
+func (x *LabelCardinality) GetTopValues() []*LabelValueCardinality {
+	if x != nil {
+		return x.TopValues
+	}
+	return nil
+}
+
+type LabelValueCardinality struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Value  string `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Series int64  `protobuf:"varint,2,opt,name=series,proto3" json:"series,omitempty"`
+}
+
+func (x *LabelValueCardinality) Reset() {
+	*x = LabelValueCardinality{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_types_v1_types_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LabelValueCardinality) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LabelValueCardinality) ProtoMessage() {}
+
+func (x *LabelValueCardinality) ProtoReflect() protoreflect.Message {
+	mi := &file_types_v1_types_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LabelValueCardinality.ProtoReflect.Descriptor instead.
+func (*LabelValueCardinality) Descriptor() ([]byte, []int) {
+	return file_types_v1_types_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *LabelValueCardinality) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *LabelValueCardinality) GetSeries() int64 {
+	if x != nil {
+		return x.Series
+	}
+	return 0
+}
+
+// SeriesChurn is the number of series in the interval starting at the
+// timestamp.
+type SeriesChurn struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Milliseconds unix timestamp
+	Timestamp int64 `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// The number of series with profiles in the interval.
+	Active int64 `protobuf:"varint,2,opt,name=active,proto3" json:"active,omitempty"`
+	// The number of series whose first profile is in the interval.
+	Created int64 `protobuf:"varint,3,opt,name=created,proto3" json:"created,omitempty"`
+	// The number of series whose last profile is in the interval.
+	Ended int64 `protobuf:"varint,4,opt,name=ended,proto3" json:"ended,omitempty"`
+}
+
+func (x *SeriesChurn) Reset() {
+	*x = SeriesChurn{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_types_v1_types_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SeriesChurn) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SeriesChurn) ProtoMessage() {}
+
+func (x *SeriesChurn) ProtoReflect() protoreflect.Message {
+	mi := &file_types_v1_types_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SeriesChurn.ProtoReflect.Descriptor instead.
+func (*SeriesChurn) Descriptor() ([]byte, []int) {
+	return file_types_v1_types_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *SeriesChurn) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *SeriesChurn) GetActive() int64 {
+	if x != nil {
+		return x.Active
+	}
+	return 0
+}
+
+func (x *SeriesChurn) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *SeriesChurn) GetEnded() int64 {
+	if x != nil {
+		return x.Ended
+	}
+	return 0
+}
+
 var File_types_v1_types_proto protoreflect.FileDescriptor
 
 var file_types_v1_types_proto_rawDesc = []byte{
@@ -563,17 +919,56 @@ var file_types_v1_types_proto_rawDesc = []byte{
 	0x61, 0x74, 0x63, 0x68, 0x65, 0x72, 0x73, 0x22, 0x2a, 0x0a, 0x12, 0x4c, 0x61, 0x62, 0x65, 0x6c,
 	0x4e, 0x61, 0x6d, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a,
 	0x05, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x6e, 0x61,
-	0x6d, 0x65, 0x73, 0x42, 0x98, 0x01, 0x0a, 0x0c, 0x63, 0x6f, 0x6d, 0x2e, 0x74, 0x79, 0x70, 0x65,
-	0x73, 0x2e, 0x76, 0x31, 0x42, 0x0a, 0x54, 0x79, 0x70, 0x65, 0x73, 0x50, 0x72, 0x6f, 0x74, 0x6f,
-	0x50, 0x01, 0x5a, 0x3b, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67,
-	0x72, 0x61, 0x66, 0x61, 0x6e, 0x61, 0x2f, 0x70, 0x68, 0x6c, 0x61, 0x72, 0x65, 0x2f, 0x61, 0x70,
-	0x69, 0x2f, 0x67, 0x65, 0x6e, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x67, 0x6f, 0x2f, 0x74,
-	0x79, 0x70, 0x65, 0x73, 0x2f, 0x76, 0x31, 0x3b, 0x74, 0x79, 0x70, 0x65, 0x73, 0x76, 0x31, 0xa2,
-	0x02, 0x03, 0x54, 0x58, 0x58, 0xaa, 0x02, 0x08, 0x54, 0x79, 0x70, 0x65, 0x73, 0x2e, 0x56, 0x31,
-	0xca, 0x02, 0x08, 0x54, 0x79, 0x70, 0x65, 0x73, 0x5c, 0x56, 0x31, 0xe2, 0x02, 0x14, 0x54, 0x79,
-	0x70, 0x65, 0x73, 0x5c, 0x56, 0x31, 0x5c, 0x47, 0x50, 0x42, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61,
-	0x74, 0x61, 0xea, 0x02, 0x09, 0x54, 0x79, 0x70, 0x65, 0x73, 0x3a, 0x3a, 0x56, 0x31, 0x62, 0x06,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x6d, 0x65, 0x73, 0x22, 0x8d, 0x01, 0x0a, 0x12, 0x43, 0x61, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x6c,
+	0x69, 0x74, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x25, 0x0a, 0x0e, 0x70, 0x72,
+	0x6f, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x49, 0x44, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0d, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x54, 0x79, 0x70, 0x65, 0x49,
+	0x44, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x65, 0x6e, 0x64, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x65, 0x6e, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d,
+	0x69, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x12,
+	0x12, 0x0a, 0x04, 0x73, 0x74, 0x65, 0x70, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x73,
+	0x74, 0x65, 0x70, 0x22, 0x8e, 0x01, 0x0a, 0x13, 0x43, 0x61, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x6c,
+	0x69, 0x74, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73,
+	0x65, 0x72, 0x69, 0x65, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x65, 0x72,
+	0x69, 0x65, 0x73, 0x12, 0x32, 0x0a, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x18, 0x02, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x74, 0x79, 0x70, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c,
+	0x61, 0x62, 0x65, 0x6c, 0x43, 0x61, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x6c, 0x69, 0x74, 0x79, 0x52,
+	0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x12, 0x2b, 0x0a, 0x05, 0x63, 0x68, 0x75, 0x72, 0x6e,
+	0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x74, 0x79, 0x70, 0x65, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x43, 0x68, 0x75, 0x72, 0x6e, 0x52, 0x05, 0x63,
+	0x68, 0x75, 0x72, 0x6e, 0x22, 0x96, 0x01, 0x0a, 0x10, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x43, 0x61,
+	0x72, 0x64, 0x69, 0x6e, 0x61, 0x6c, 0x69, 0x74, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x16, 0x0a,
+	0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73,
+	0x65, 0x72, 0x69, 0x65, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x12, 0x3e, 0x0a,
+	0x0a, 0x74, 0x6f, 0x70, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x1f, 0x2e, 0x74, 0x79, 0x70, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x61, 0x62,
+	0x65, 0x6c, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x43, 0x61, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x6c, 0x69,
+	0x74, 0x79, 0x52, 0x09, 0x74, 0x6f, 0x70, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x22, 0x45, 0x0a,
+	0x15, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x43, 0x61, 0x72, 0x64, 0x69,
+	0x6e, 0x61, 0x6c, 0x69, 0x74, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x16, 0x0a, 0x06,
+	0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x65,
+	0x72, 0x69, 0x65, 0x73, 0x22, 0x73, 0x0a, 0x0b, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x43, 0x68,
+	0x75, 0x72, 0x6e, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x63, 0x74, 0x69, 0x76, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x06, 0x61, 0x63, 0x74, 0x69, 0x76, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x72, 0x65,
+	0x61, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x63, 0x72, 0x65, 0x61,
+	0x74, 0x65, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x6e, 0x64, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x05, 0x65, 0x6e, 0x64, 0x65, 0x64, 0x42, 0x98, 0x01, 0x0a, 0x0c, 0x63, 0x6f,
+	0x6d, 0x2e, 0x74, 0x79, 0x70, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x42, 0x0a, 0x54, 0x79, 0x70, 0x65,
+	0x73, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01, 0x5a, 0x3b, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x72, 0x61, 0x66, 0x61, 0x6e, 0x61, 0x2f, 0x70, 0x68, 0x6c,
+	0x61, 0x72, 0x65, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x67, 0x65, 0x6e, 0x2f, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x2f, 0x67, 0x6f, 0x2f, 0x74, 0x79, 0x70, 0x65, 0x73, 0x2f, 0x76, 0x31, 0x3b, 0x74, 0x79,
+	0x70, 0x65, 0x73, 0x76, 0x31, 0xa2, 0x02, 0x03, 0x54, 0x58, 0x58, 0xaa, 0x02, 0x08, 0x54, 0x79,
+	0x70, 0x65, 0x73, 0x2e, 0x56, 0x31, 0xca, 0x02, 0x08, 0x54, 0x79, 0x70, 0x65, 0x73, 0x5c, 0x56,
+	0x31, 0xe2, 0x02, 0x14, 0x54, 0x79, 0x70, 0x65, 0x73, 0x5c, 0x56, 0x31, 0x5c, 0x47, 0x50, 0x42,
+	0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0xea, 0x02, 0x09, 0x54, 0x79, 0x70, 0x65, 0x73,
+	0x3a, 0x3a, 0x56, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -588,27 +983,35 @@ func file_types_v1_types_proto_rawDescGZIP() []byte {
 	return file_types_v1_types_proto_rawDescData
 }
 
-var file_types_v1_types_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_types_v1_types_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
 var file_types_v1_types_proto_goTypes = []interface{}{
-	(*LabelPair)(nil),           // 0: types.v1.LabelPair
-	(*ProfileType)(nil),         // 1: types.v1.ProfileType
-	(*Labels)(nil),              // 2: types.v1.Labels
-	(*Series)(nil),              // 3: types.v1.Series
-	(*Point)(nil),               // 4: types.v1.Point
-	(*LabelValuesRequest)(nil),  // 5: types.v1.LabelValuesRequest
-	(*LabelValuesResponse)(nil), // 6: types.v1.LabelValuesResponse
-	(*LabelNamesRequest)(nil),   // 7: types.v1.LabelNamesRequest
-	(*LabelNamesResponse)(nil),  // 8: types.v1.LabelNamesResponse
+	(*LabelPair)(nil),             // 0: types.v1.LabelPair
+	(*ProfileType)(nil),           // 1: types.v1.ProfileType
+	(*Labels)(nil),                // 2: types.v1.Labels
+	(*Series)(nil),                // 3: types.v1.Series
+	(*Point)(nil),                 // 4: types.v1.Point
+	(*LabelValuesRequest)(nil),    // 5: types.v1.LabelValuesRequest
+	(*LabelValuesResponse)(nil),   // 6: types.v1.LabelValuesResponse
+	(*LabelNamesRequest)(nil),     // 7: types.v1.LabelNamesRequest
+	(*LabelNamesResponse)(nil),    // 8: types.v1.LabelNamesResponse
+	(*CardinalityRequest)(nil),    // 9: types.v1.CardinalityRequest
+	(*CardinalityResponse)(nil),   // 10: types.v1.CardinalityResponse
+	(*LabelCardinality)(nil),      // 11: types.v1.LabelCardinality
+	(*LabelValueCardinality)(nil), // 12: types.v1.LabelValueCardinality
+	(*SeriesChurn)(nil),           // 13: types.v1.SeriesChurn
 }
 var file_types_v1_types_proto_depIdxs = []int32{
-	0, // 0: types.v1.Labels.labels:type_name -> types.v1.LabelPair
-	0, // 1: types.v1.Series.labels:type_name -> types.v1.LabelPair
-	4, // 2: types.v1.Series.points:type_name -> types.v1.Point
-	3, // [3:3] is the sub-list for method output_type
-	3, // [3:3] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	0,  // 0: types.v1.Labels.labels:type_name -> types.v1.LabelPair
+	0,  // 1: types.v1.Series.labels:type_name -> types.v1.LabelPair
+	4,  // 2: types.v1.Series.points:type_name -> types.v1.Point
+	11, // 3: types.v1.CardinalityResponse.labels:type_name -> types.v1.LabelCardinality
+	13, // 4: types.v1.CardinalityResponse.churn:type_name -> types.v1.SeriesChurn
+	12, // 5: types.v1.LabelCardinality.top_values:type_name -> types.v1.LabelValueCardinality
+	6,  // [6:6] is the sub-list for method output_type
+	6,  // [6:6] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_types_v1_types_proto_init() }
@@ -725,6 +1128,66 @@ func file_types_v1_types_proto_init() {
 				return nil
 			}
 		}
+		file_types_v1_types_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CardinalityRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_types_v1_types_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CardinalityResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_types_v1_types_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LabelCardinality); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_types_v1_types_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LabelValueCardinality); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_types_v1_types_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SeriesChurn); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -732,7 +1195,7 @@ func file_types_v1_types_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_types_v1_types_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   9,
+			NumMessages:   14,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
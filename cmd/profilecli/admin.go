@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/phlare/pkg/tenantdeletion"
+)
+
+type adminParams struct {
+	*phlareClient
+}
+
+func addAdminParams(cmd commander) *adminParams {
+	params := &adminParams{}
+	params.phlareClient = addPhlareClient(cmd)
+	return params
+}
+
+func (p *adminParams) tenantDeletion(ctx context.Context, method, path string) (*tenantdeletion.Status, error) {
+	if p.TenantID == "" {
+		return nil, errors.New("the tenant ID is required")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(p.URL, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var status tenantdeletion.Status
+	if err = json.Unmarshal(body, &status); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the tenant deletion status")
+	}
+	return &status, nil
+}
+
+func adminDeleteTenant(ctx context.Context, params *adminParams) error {
+	status, err := params.tenantDeletion(ctx, http.MethodPost, "/tenant-deletion/delete_tenant")
+	if err != nil {
+		return err
+	}
+	level.Info(logger).Log("msg", "tenant marked for deletion", "tenant", status.TenantID, "deletion_time", status.DeletionTime)
+	return writeTenantDeletionStatus(ctx, status)
+}
+
+func adminDeleteTenantStatus(ctx context.Context, params *adminParams) error {
+	status, err := params.tenantDeletion(ctx, http.MethodGet, "/tenant-deletion/delete_tenant_status")
+	if err != nil {
+		return err
+	}
+	return writeTenantDeletionStatus(ctx, status)
+}
+
+func writeTenantDeletionStatus(ctx context.Context, status *tenantdeletion.Status) error {
+	return writeJSON(ctx, status)
+}
@@ -1,19 +1,30 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/go-kit/log/level"
 	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/segmentio/parquet-go"
 
+	ingestv1 "github.com/grafana/phlare/api/gen/proto/go/ingester/v1"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
 	"github.com/grafana/phlare/pkg/objstore/providers/filesystem"
 	"github.com/grafana/phlare/pkg/phlaredb"
 	"github.com/grafana/phlare/pkg/phlaredb/block"
+	"github.com/grafana/phlare/pkg/phlaredb/symdb"
+	"github.com/grafana/phlare/pkg/phlaredb/tsdb/index"
 )
 
 func fileInfo(f *block.File) string {
@@ -74,3 +85,362 @@ func blocksList(ctx context.Context) error {
 
 	return nil
 }
+
+func blocksInspect(ctx context.Context, dir string) error {
+	meta, err := block.ReadFromDir(dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the block meta")
+	}
+	out := output(ctx)
+
+	fmt.Fprintln(out, "Block:")
+	table := tablewriter.NewWriter(out)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.AppendBulk([][]string{
+		{"ID", meta.ULID.String()},
+		{"Version", strconv.Itoa(int(meta.Version))},
+		{"MinTime", meta.MinTime.Time().Format(time.RFC3339)},
+		{"MaxTime", meta.MaxTime.Time().Format(time.RFC3339)},
+		{"Duration", meta.MaxTime.Time().Sub(meta.MinTime.Time()).String()},
+		{"Source", string(meta.Source)},
+		{"Compaction level", strconv.Itoa(meta.Compaction.Level)},
+		{"Series", strconv.FormatUint(meta.Stats.NumSeries, 10)},
+		{"Profiles", strconv.FormatUint(meta.Stats.NumProfiles, 10)},
+		{"Samples", strconv.FormatUint(meta.Stats.NumSamples, 10)},
+	})
+	table.Render()
+
+	fmt.Fprintln(out, "Files:")
+	table = tablewriter.NewWriter(out)
+	table.SetHeader([]string{"File", "Size", "Content"})
+	for i := range meta.Files {
+		f := &meta.Files[i]
+		table.Append([]string{f.RelPath, humanize.Bytes(f.SizeBytes), fileInfo(f)})
+	}
+	table.Render()
+
+	if err = inspectIndex(ctx, filepath.Join(dir, block.IndexFilename)); err != nil {
+		return err
+	}
+	if meta.Version >= block.MetaVersion2 {
+		if err = inspectSymbols(ctx, filepath.Join(dir, symdb.DefaultDirName, symdb.IndexFileName)); err != nil {
+			return err
+		}
+	}
+	return inspectRowGroups(ctx, dir, meta)
+}
+
+func inspectIndex(ctx context.Context, path string) error {
+	r, err := index.NewFileReader(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the index")
+	}
+	defer r.Close()
+	out := output(ctx)
+
+	countSeries := func(name string, values ...string) (int, error) {
+		p, err := r.Postings(name, nil, values...)
+		if err != nil {
+			return 0, err
+		}
+		var n int
+		for p.Next() {
+			n++
+		}
+		return n, p.Err()
+	}
+
+	profileTypes, err := r.LabelValues(phlaremodel.LabelNameProfileType)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Profile types:")
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Profile type", "Series"})
+	for _, t := range profileTypes {
+		n, err := countSeries(phlaremodel.LabelNameProfileType, t)
+		if err != nil {
+			return err
+		}
+		table.Append([]string{t, strconv.Itoa(n)})
+	}
+	table.Render()
+
+	names, err := r.LabelNames()
+	if err != nil {
+		return err
+	}
+	type cardinality struct {
+		name           string
+		values, series int
+	}
+	labels := make([]cardinality, 0, len(names))
+	for _, name := range names {
+		values, err := r.LabelValues(name)
+		if err != nil {
+			return err
+		}
+		n, err := countSeries(name, values...)
+		if err != nil {
+			return err
+		}
+		labels = append(labels, cardinality{name: name, values: len(values), series: n})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].values != labels[j].values {
+			return labels[i].values > labels[j].values
+		}
+		return labels[i].name < labels[j].name
+	})
+	fmt.Fprintln(out, "Label cardinality:")
+	table = tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Label", "Values", "Series"})
+	for _, l := range labels {
+		table.Append([]string{l.name, strconv.Itoa(l.values), strconv.Itoa(l.series)})
+	}
+	table.Render()
+	return nil
+}
+
+func inspectSymbols(ctx context.Context, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the symbols index")
+	}
+	idx, err := symdb.OpenIndexFile(b)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the symbols index")
+	}
+
+	type mappingStats struct {
+		chunks             int
+		stacktraces, nodes uint64
+		maxDepth           uint32
+		size               int64
+	}
+	var names []uint64
+	mappings := make(map[uint64]*mappingStats)
+	for _, h := range idx.StacktraceChunkHeaders.Entries {
+		m, ok := mappings[h.MappingName]
+		if !ok {
+			m = new(mappingStats)
+			mappings[h.MappingName] = m
+			names = append(names, h.MappingName)
+		}
+		m.chunks++
+		m.stacktraces += uint64(h.Stacktraces)
+		m.nodes += uint64(h.StacktraceNodes)
+		m.size += h.Size
+		if h.StacktraceMaxDepth > m.maxDepth {
+			m.maxDepth = h.StacktraceMaxDepth
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	out := output(ctx)
+	fmt.Fprintf(out, "Symbols (format version %d):\n", idx.Header.Version)
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Mapping", "Chunks", "Stacktraces", "Nodes", "Max depth", "Size"})
+	for _, name := range names {
+		m := mappings[name]
+		table.Append([]string{
+			strconv.FormatUint(name, 10),
+			strconv.Itoa(m.chunks),
+			strconv.FormatUint(m.stacktraces, 10),
+			strconv.FormatUint(m.nodes, 10),
+			strconv.FormatUint(uint64(m.maxDepth), 10),
+			humanize.Bytes(uint64(m.size)),
+		})
+	}
+	table.Render()
+	return nil
+}
+
+func inspectRowGroups(ctx context.Context, dir string, meta *block.Meta) error {
+	out := output(ctx)
+	fmt.Fprintln(out, "Row groups:")
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"File", "Row group", "Rows", "Size"})
+	for _, f := range meta.Files {
+		if f.Parquet == nil {
+			continue
+		}
+		pf, closer, err := openParquetFile(filepath.Join(dir, f.RelPath))
+		if err != nil {
+			return err
+		}
+		for i, rg := range pf.Metadata().RowGroups {
+			table.Append([]string{f.RelPath, strconv.Itoa(i), strconv.FormatInt(rg.NumRows, 10), humanize.Bytes(uint64(rg.TotalByteSize))})
+		}
+		if err = closer.Close(); err != nil {
+			return err
+		}
+	}
+	table.Render()
+	return nil
+}
+
+func openParquetFile(path string) (*parquet.File, *os.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	stats, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	pf, err := parquet.OpenFile(f, stats.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, errors.Wrapf(err, "failed to open %s", path)
+	}
+	return pf, f, nil
+}
+
+func blocksVerify(ctx context.Context, dirs []string, repair bool, repairDir string) error {
+	out := output(ctx)
+	var broken int
+	for _, dir := range dirs {
+		var (
+			problems []phlaredb.BlockProblem
+			err      error
+		)
+		if repair {
+			var found []phlaredb.BlockProblem
+			if found, err = phlaredb.VerifyBlock(ctx, dir); err == nil && len(found) > 0 {
+				for _, p := range found {
+					fmt.Fprintf(out, "%s: %s\n", dir, p)
+				}
+				dstDir := repairDir
+				if dstDir == "" {
+					dstDir = filepath.Dir(filepath.Clean(dir))
+				}
+				var meta *block.Meta
+				if meta, problems, err = phlaredb.RepairBlock(ctx, logger, dir, dstDir); err == nil {
+					repaired := filepath.Join(dstDir, meta.ULID.String())
+					level.Info(logger).Log("msg", "block repaired, the original block can be removed", "block", dir, "repaired", repaired, "problems_left", len(problems))
+					dir = repaired
+				}
+			}
+		} else {
+			problems, err = phlaredb.VerifyBlock(ctx, dir)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to verify the block %s", dir)
+		}
+		for _, p := range problems {
+			fmt.Fprintf(out, "%s: %s\n", dir, p)
+		}
+		if len(problems) > 0 {
+			broken++
+		}
+	}
+	if broken > 0 {
+		return errors.Errorf("%d of %d blocks have problems", broken, len(dirs))
+	}
+	level.Info(logger).Log("msg", "no problem found", "blocks", len(dirs))
+	return nil
+}
+
+type blocksQueryParams struct {
+	From        string
+	To          string
+	ProfileType string
+	Query       string
+	Output      string
+}
+
+func addBlocksQueryParams(cmd commander) *blocksQueryParams {
+	params := &blocksQueryParams{}
+	cmd.Flag("from", "Beginning of the query, the beginning of the block by default.").StringVar(&params.From)
+	cmd.Flag("to", "End of the query, the end of the block by default.").StringVar(&params.To)
+	cmd.Flag("profile-type", "Profile type to query.").Default("process_cpu:cpu:nanoseconds:cpu:nanoseconds").StringVar(&params.ProfileType)
+	cmd.Flag("query", "Label selector to query.").Default("{}").StringVar(&params.Query)
+	cmd.Flag("output", "How to output the result, examples: console, pprof=./my.pprof").Default("console").StringVar(&params.Output)
+	return params
+}
+
+// blocksQuery merges the profiles of a local block matching the query, without
+// any server.
+func blocksQuery(ctx context.Context, dir string, params *blocksQueryParams) error {
+	meta, err := block.ReadFromDir(dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the block meta")
+	}
+	profileType, err := phlaremodel.ParseProfileTypeSelector(params.ProfileType)
+	if err != nil {
+		return err
+	}
+	// The block bounds are in milliseconds, while the profiles timestamps
+	// are in nanoseconds: the profiles of the last millisecond are only
+	// selected up to the next one.
+	from, to := meta.MinTime, meta.MaxTime+1
+	if params.From != "" {
+		t, err := parseTime(params.From)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse from")
+		}
+		from = model.TimeFromUnixNano(t.UnixNano())
+	}
+	if params.To != "" {
+		t, err := parseTime(params.To)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse to")
+		}
+		to = model.TimeFromUnixNano(t.UnixNano())
+	}
+	if to < from {
+		return errors.New("from cannot be after to")
+	}
+
+	bucket, err := filesystem.NewBucket(filepath.Dir(filepath.Clean(dir)))
+	if err != nil {
+		return err
+	}
+	bq := phlaredb.NewBlockQuerier(ctx, bucket)
+	defer bq.Close()
+	bq.AddBlockQuerierByMeta(meta)
+	queriers := bq.Queriers()
+	if err = queriers.Open(ctx); err != nil {
+		return errors.Wrap(err, "failed to open the block")
+	}
+
+	level.Info(logger).Log("msg", "query aggregated profile from local block", "block", meta.ULID, "from", from.Time(), "to", to.Time(), "query", params.Query, "type", params.ProfileType)
+
+	q := queriers[0]
+	profiles, err := q.SelectMatchingProfiles(ctx, &ingestv1.SelectProfilesRequest{
+		LabelSelector: params.Query,
+		Type:          profileType,
+		Start:         int64(from),
+		End:           int64(to),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to select profiles")
+	}
+	p, err := q.MergePprof(ctx, profiles)
+	if err != nil {
+		return errors.Wrap(err, "failed to merge profiles")
+	}
+	phlaremodel.SetProfileMetadata(p, profileType)
+	p.TimeNanos = to.UnixNano()
+
+	if params.Output == outputConsole {
+		fmt.Fprintln(output(ctx), p.String())
+		return nil
+	}
+
+	if strings.HasPrefix(params.Output, outputPprof) {
+		filePath := strings.TrimPrefix(params.Output, outputPprof)
+		if filePath == "" {
+			return errors.New("no file path specified after pprof=")
+		}
+		var buf bytes.Buffer
+		if err = p.WriteUncompressed(&buf); err != nil {
+			return errors.Wrap(err, "failed to marshal profile")
+		}
+		return writePprofFile(filePath, buf.Bytes())
+	}
+
+	return errors.Errorf("unknown output %s", params.Output)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	phlarecontext "github.com/grafana/phlare/pkg/phlare/context"
+	"github.com/grafana/phlare/pkg/phlaredb"
+	"github.com/grafana/phlare/pkg/phlaredb/block"
+	"github.com/grafana/phlare/pkg/pprof/testhelper"
+)
+
+type noLimit struct{}
+
+func (noLimit) AllowProfile(model.Fingerprint, phlaremodel.Labels, int64) error { return nil }
+
+func (noLimit) Stop() {}
+
+// writeTestBlock writes a block of CPU profiles, one per second, of the
+// streams a and b, and returns its directory.
+func writeTestBlock(t *testing.T) string {
+	t.Helper()
+	dataPath := t.TempDir()
+	ctx := phlarecontext.WithRegistry(context.Background(), prometheus.NewRegistry())
+	head, err := phlaredb.NewHead(ctx, phlaredb.Config{DataPath: dataPath}, noLimit{})
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		p := testhelper.NewProfileBuilder(time.Second.Nanoseconds()*int64(i)).
+			CPUProfile().
+			WithLabels("stream", []string{"a", "b"}[i%2])
+		p.ForStacktraceString("foo", "main").AddSamples(1)
+		p.ForStacktraceString("bar", "main").AddSamples(2)
+		require.NoError(t, head.Ingest(ctx, p.Profile, p.UUID, p.Labels...))
+	}
+	require.NoError(t, head.Flush(ctx))
+	require.NoError(t, head.Move())
+	dirs, err := filepath.Glob(filepath.Join(dataPath, "local", "*"))
+	require.NoError(t, err)
+	require.Len(t, dirs, 1)
+	return dirs[0]
+}
+
+func Test_blocksQuery(t *testing.T) {
+	dir := writeTestBlock(t)
+	out := t.TempDir()
+
+	for _, tc := range []struct {
+		name     string
+		params   blocksQueryParams
+		expected int64 // Sum of the sample values.
+		err      bool
+	}{
+		{
+			name:     "all profiles",
+			params:   blocksQueryParams{Query: "{}"},
+			expected: 30,
+		},
+		{
+			name:     "label selector",
+			params:   blocksQueryParams{Query: `{stream="a"}`},
+			expected: 15,
+		},
+		{
+			name:     "time range",
+			params:   blocksQueryParams{Query: "{}", From: "1970-01-01T00:00:02Z", To: "1970-01-01T00:00:05Z"},
+			expected: 12,
+		},
+		{
+			name:     "outside the block",
+			params:   blocksQueryParams{Query: "{}", From: "2000-01-01T00:00:00Z", To: "2000-01-01T01:00:00Z"},
+			expected: 0,
+		},
+		{
+			name:   "invalid profile type",
+			params: blocksQueryParams{Query: "{}", ProfileType: "foo"},
+			err:    true,
+		},
+		{
+			name:   "from after to",
+			params: blocksQueryParams{Query: "{}", From: "1689341454", To: "1689341453"},
+			err:    true,
+		},
+		{
+			name:   "unknown output",
+			params: blocksQueryParams{Query: "{}", Output: outputJSON},
+			err:    true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			params := tc.params
+			if params.ProfileType == "" {
+				params.ProfileType = "process_cpu:cpu:nanoseconds:cpu:nanoseconds"
+			}
+			path := filepath.Join(out, tc.name+".pprof")
+			if params.Output == "" {
+				params.Output = outputPprof + path
+			}
+			err := blocksQuery(context.Background(), dir, &params)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			f, err := os.Open(path)
+			require.NoError(t, err)
+			defer f.Close()
+			p, err := profile.Parse(f)
+			require.NoError(t, err)
+			var total int64
+			for _, s := range p.Sample {
+				total += s.Value[0]
+			}
+			require.Equal(t, tc.expected, total)
+		})
+	}
+}
+
+func Test_blocksInspect(t *testing.T) {
+	dir := writeTestBlock(t)
+	meta, err := block.ReadFromDir(dir)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, blocksInspect(withOutput(context.Background(), &buf), dir))
+	require.Contains(t, buf.String(), meta.ULID.String())
+	for _, f := range meta.Files {
+		require.Contains(t, buf.String(), f.RelPath)
+	}
+
+	require.Error(t, blocksInspect(context.Background(), t.TempDir()))
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log/level"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+)
+
+type cardinalityParams struct {
+	*phlareClient
+	From        string
+	To          string
+	ProfileType string
+	Limit       int
+	Step        time.Duration
+	Output      string
+}
+
+func addCardinalityParams(cmd commander) *cardinalityParams {
+	params := &cardinalityParams{}
+	params.phlareClient = addPhlareClient(cmd)
+	cmd.Flag("from", "Beginning of the analysis.").Default("now-24h").StringVar(&params.From)
+	cmd.Flag("to", "End of the analysis.").Default("now").StringVar(&params.To)
+	cmd.Flag("profile-type", "Profile type to analyse, all the profile types if empty.").Default("").StringVar(&params.ProfileType)
+	cmd.Flag("limit", "Number of top values to show per label name.").Default("10").IntVar(&params.Limit)
+	cmd.Flag("step", "Interval of the series churn, 0 for 24 intervals.").Default("0").DurationVar(&params.Step)
+	cmd.Flag("output", "How to output the result, examples: console, json").Default(outputConsole).StringVar(&params.Output)
+	return params
+}
+
+func (p *cardinalityParams) request() (*typesv1.CardinalityRequest, error) {
+	from, err := parseTime(p.From)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse from")
+	}
+	to, err := parseTime(p.To)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse to")
+	}
+	return &typesv1.CardinalityRequest{
+		ProfileTypeID: p.ProfileType,
+		Start:         from.UnixMilli(),
+		End:           to.UnixMilli(),
+		Limit:         int64(p.Limit),
+		Step:          p.Step.Milliseconds(),
+	}, nil
+}
+
+func cardinality(ctx context.Context, params *cardinalityParams) error {
+	req, err := params.request()
+	if err != nil {
+		return err
+	}
+	level.Info(logger).Log("msg", "query the label cardinality from profile store", "url", params.URL, "profile_type", params.ProfileType)
+	resp, err := params.phlareClient.queryClient().Cardinality(ctx, connect.NewRequest(req))
+	if err != nil {
+		return errors.Wrap(err, "failed to query")
+	}
+	res := resp.Msg
+
+	switch params.Output {
+	case outputConsole:
+		out := output(ctx)
+		fmt.Fprintf(out, "Series: %d\n\n", res.Series)
+		table := tablewriter.NewWriter(out)
+		table.SetHeader([]string{"Label", "Series", "Values", "Top values"})
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAutoWrapText(false)
+		for _, l := range res.Labels {
+			top := make([]string, 0, len(l.TopValues))
+			for _, v := range l.TopValues {
+				top = append(top, fmt.Sprintf("%s (%d)", v.Value, v.Series))
+			}
+			table.Append([]string{l.Name, strconv.FormatInt(l.Series, 10), strconv.FormatInt(l.Values, 10), strings.Join(top, ", ")})
+		}
+		table.Render()
+		fmt.Fprintln(out)
+
+		table = tablewriter.NewWriter(out)
+		table.SetHeader([]string{"Time", "Active", "Created", "Ended"})
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		for _, c := range res.Churn {
+			table.Append([]string{
+				time.UnixMilli(c.Timestamp).UTC().Format(time.RFC3339),
+				strconv.FormatInt(c.Active, 10),
+				strconv.FormatInt(c.Created, 10),
+				strconv.FormatInt(c.Ended, 10),
+			})
+		}
+		table.Render()
+		return nil
+	case outputJSON:
+		return writeJSON(ctx, res)
+	}
+	return errors.Errorf("unknown output %s", params.Output)
+}
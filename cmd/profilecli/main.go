@@ -44,18 +44,50 @@ func main() {
 	blocksListCmd := blocksCmd.Command("list", "List blocks.")
 	blocksListCmd.Flag("restore-missing-meta", "").Default("false").BoolVar(&cfg.blocks.restoreMissingMeta)
 
+	blocksInspectCmd := blocksCmd.Command("inspect", "Inspect a block's metadata, index, symbols and row groups.")
+	blocksInspectDir := blocksInspectCmd.Arg("block", "Block directory path.").Required().ExistingDir()
+
+	blocksVerifyCmd := blocksCmd.Command("verify", "Verify the integrity of blocks.")
+	blocksVerifyDirs := blocksVerifyCmd.Arg("block", "Block directory paths.").Required().ExistingDirs()
+	blocksVerifyRepair := blocksVerifyCmd.Flag("repair", "Write a repaired copy of the blocks with problems, with a rebuilt meta and without their broken profiles row groups.").Default("false").Bool()
+	blocksVerifyRepairDir := blocksVerifyCmd.Flag("repair-dir", "Directory to write the repaired blocks to. Defaults to the directory of each block.").String()
+
+	blocksQueryCmd := blocksCmd.Command("query", "Request merged profile from a local block.")
+	blocksQueryDir := blocksQueryCmd.Arg("block", "Block directory path.").Required().ExistingDir()
+	blocksQueryParams := addBlocksQueryParams(blocksQueryCmd)
+
 	parquetCmd := app.Command("parquet", "Operate on a Parquet file.")
 	parquetInspectCmd := parquetCmd.Command("inspect", "Inspect a parquet file's structure.")
 	parquetInspectFiles := parquetInspectCmd.Arg("file", "parquet file path").Required().ExistingFiles()
 
 	queryCmd := app.Command("query", "Query profile store.")
 	queryParams := addQueryParams(queryCmd)
-	queryOutput := queryCmd.Flag("output", "How to output the result, examples: console, raw, pprof=./my.pprof").Default("console").String()
+	queryOutput := queryCmd.Flag("output", "How to output the result, examples: console, raw, json, folded (merge and flamegraph only), pprof=./my.pprof, speedscope=./my.json, firefox=./my.json (merge only)").Default("console").String()
 	queryMergeCmd := queryCmd.Command("merge", "Request merged profile.")
+	querySeriesCmd := queryCmd.Command("series", "Request the series matching the query.")
+	querySeriesLabelNames := querySeriesCmd.Flag("label-names", "Only return these labels of the series.").Strings()
+	queryLabelNamesCmd := queryCmd.Command("label-names", "Request the label names of the series matching the query.")
+	queryLabelValuesCmd := queryCmd.Command("label-values", "Request the values of a label of the series matching the query.")
+	queryLabelValuesName := queryLabelValuesCmd.Arg("name", "Label name.").Required().String()
+	queryProfileTypesCmd := queryCmd.Command("profile-types", "Request the profile types.")
+	queryTopCmd := queryCmd.Command("top", "Request the functions with the highest self value in the merged profile.")
+	queryTopLimit := queryTopCmd.Flag("limit", "Number of functions to show, 0 for all.").Default("20").Int()
+	queryTopMaxNodes := queryTopCmd.Flag("max-nodes", "Maximum number of nodes of the merged flame graph, 0 for the server default.").Default("0").Int64()
+	queryFlameGraphCmd := queryCmd.Command("flamegraph", "Request merged flame graph.")
+	queryFlameGraphMaxNodes := queryFlameGraphCmd.Flag("max-nodes", "Maximum number of nodes of the flame graph, 0 for the server default.").Default("0").Int64()
+
+	cardinalityCmd := app.Command("cardinality", "Analyse the label cardinality of the series: series by label name, top values and series churn.")
+	cardinalityParams := addCardinalityParams(cardinalityCmd)
 
 	uploadCmd := app.Command("upload", "Upload profile(s).")
 	uploadParams := addUploadParams(uploadCmd)
 
+	adminCmd := app.Command("admin", "Administrative operations.")
+	adminDeleteTenantCmd := adminCmd.Command("delete-tenant", "Mark the tenant for deletion: its ingestion is stopped and all its data is removed.")
+	adminDeleteTenantParams := addAdminParams(adminDeleteTenantCmd)
+	adminDeleteTenantStatusCmd := adminCmd.Command("delete-tenant-status", "Show the deletion status of the tenant.")
+	adminDeleteTenantStatusParams := addAdminParams(adminDeleteTenantStatusCmd)
+
 	canaryExporterCmd := app.Command("canary-exporter", "Run the canary exporter.")
 	canaryExporterParams := addCanaryExporterParams(canaryExporterCmd)
 
@@ -70,6 +102,12 @@ func main() {
 	switch parsedCmd {
 	case blocksListCmd.FullCommand():
 		os.Exit(checkError(blocksList(ctx)))
+	case blocksInspectCmd.FullCommand():
+		os.Exit(checkError(blocksInspect(ctx, *blocksInspectDir)))
+	case blocksVerifyCmd.FullCommand():
+		os.Exit(checkError(blocksVerify(ctx, *blocksVerifyDirs, *blocksVerifyRepair, *blocksVerifyRepairDir)))
+	case blocksQueryCmd.FullCommand():
+		os.Exit(checkError(blocksQuery(ctx, *blocksQueryDir, blocksQueryParams)))
 	case parquetInspectCmd.FullCommand():
 		for _, file := range *parquetInspectFiles {
 			if err := parquetInspect(ctx, file); err != nil {
@@ -80,10 +118,46 @@ func main() {
 		if err := queryMerge(ctx, queryParams, *queryOutput); err != nil {
 			os.Exit(checkError(err))
 		}
+	case querySeriesCmd.FullCommand():
+		if err := querySeries(ctx, queryParams, *querySeriesLabelNames, *queryOutput); err != nil {
+			os.Exit(checkError(err))
+		}
+	case queryLabelNamesCmd.FullCommand():
+		if err := queryLabelNames(ctx, queryParams, *queryOutput); err != nil {
+			os.Exit(checkError(err))
+		}
+	case queryLabelValuesCmd.FullCommand():
+		if err := queryLabelValues(ctx, queryParams, *queryLabelValuesName, *queryOutput); err != nil {
+			os.Exit(checkError(err))
+		}
+	case queryProfileTypesCmd.FullCommand():
+		if err := queryProfileTypes(ctx, queryParams, *queryOutput); err != nil {
+			os.Exit(checkError(err))
+		}
+	case queryTopCmd.FullCommand():
+		if err := queryTop(ctx, queryParams, *queryTopLimit, *queryTopMaxNodes, *queryOutput); err != nil {
+			os.Exit(checkError(err))
+		}
+	case queryFlameGraphCmd.FullCommand():
+		if err := queryFlameGraph(ctx, queryParams, *queryFlameGraphMaxNodes, *queryOutput); err != nil {
+			os.Exit(checkError(err))
+		}
+	case cardinalityCmd.FullCommand():
+		if err := cardinality(ctx, cardinalityParams); err != nil {
+			os.Exit(checkError(err))
+		}
 	case uploadCmd.FullCommand():
 		if err := upload(ctx, uploadParams); err != nil {
 			os.Exit(checkError(err))
 		}
+	case adminDeleteTenantCmd.FullCommand():
+		if err := adminDeleteTenant(ctx, adminDeleteTenantParams); err != nil {
+			os.Exit(checkError(err))
+		}
+	case adminDeleteTenantStatusCmd.FullCommand():
+		if err := adminDeleteTenantStatus(ctx, adminDeleteTenantStatusParams); err != nil {
+			os.Exit(checkError(err))
+		}
 	case canaryExporterCmd.FullCommand():
 		if err := newCanaryExporter(canaryExporterParams).run(ctx); err != nil {
 			os.Exit(checkError(err))
@@ -3,28 +3,18 @@ package main
 import (
 	"context"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/dustin/go-humanize"
 	"github.com/olekukonko/tablewriter"
-	"github.com/segmentio/parquet-go"
 )
 
 func parquetInspect(ctx context.Context, path string) error {
-	f, err := os.Open(path)
+	pf, f, err := openParquetFile(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	stats, err := f.Stat()
-	if err != nil {
-		return err
-	}
-	pf, err := parquet.OpenFile(f, stats.Size())
-	if err != nil {
-		return err
-	}
 	out := output(ctx)
 	fmt.Fprintln(out, "schema:", pf.Schema())
 	meta := pf.Metadata()
@@ -3,9 +3,11 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -17,17 +19,26 @@ import (
 	"github.com/k0kubun/pp/v3"
 	"github.com/klauspost/compress/gzip"
 	"github.com/mattn/go-isatty"
+	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
 
 	querierv1 "github.com/grafana/phlare/api/gen/proto/go/querier/v1"
 	"github.com/grafana/phlare/api/gen/proto/go/querier/v1/querierv1connect"
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/pprof"
 )
 
 const (
-	outputConsole = "console"
-	outputRaw     = "raw"
-	outputPprof   = "pprof="
+	outputConsole    = "console"
+	outputRaw        = "raw"
+	outputJSON       = "json"
+	outputCollapsed  = "collapsed"
+	outputFolded     = "folded"
+	outputPprof      = "pprof="
+	outputSpeedscope = "speedscope="
+	outputFirefox    = "firefox="
 )
 
 func parseTime(s string) (time.Time, error) {
@@ -117,6 +128,15 @@ func (p *queryParams) parseFromTo() (from time.Time, to time.Time, err error) {
 	return from, to, nil
 }
 
+// matchers returns the label selector as series matchers, none when the
+// selector matches every series.
+func (p *queryParams) matchers() []string {
+	if q := strings.TrimSpace(p.Query); q != "" && q != "{}" {
+		return []string{q}
+	}
+	return nil
+}
+
 func addQueryParams(queryCmd commander) *queryParams {
 	var (
 		params = &queryParams{}
@@ -151,10 +171,6 @@ func queryMerge(ctx context.Context, params *queryParams, outputFlag string) (er
 		return errors.Wrap(err, "failed to query")
 	}
 
-	mypp := pp.New()
-	mypp.SetColoringEnabled(isatty.IsTerminal(os.Stdout.Fd()))
-	mypp.SetExportedOnly(true)
-
 	if outputFlag == outputConsole {
 		buf, err := resp.Msg.MarshalVT()
 		if err != nil {
@@ -172,10 +188,14 @@ func queryMerge(ctx context.Context, params *queryParams, outputFlag string) (er
 	}
 
 	if outputFlag == outputRaw {
-		mypp.Print(resp.Msg)
+		printRaw(resp.Msg)
 		return nil
 	}
 
+	if outputFlag == outputFolded {
+		return phlaremodel.TreeFromProfile(resp.Msg, 0).WriteFolded(output(ctx))
+	}
+
 	if strings.HasPrefix(outputFlag, outputPprof) {
 		filePath := strings.TrimPrefix(outputFlag, outputPprof)
 		if filePath == "" {
@@ -185,23 +205,325 @@ func queryMerge(ctx context.Context, params *queryParams, outputFlag string) (er
 		if err != nil {
 			return errors.Wrap(err, "failed to marshal protobuf")
 		}
+		return writePprofFile(filePath, buf)
+	}
 
-		// open new file, fail when the file already exists
-		f, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	for prefix, marshal := range map[string]func() ([]byte, error){
+		outputSpeedscope: func() ([]byte, error) { return pprof.MarshalSpeedscope(resp.Msg, params.ProfileType) },
+		outputFirefox:    func() ([]byte, error) { return pprof.MarshalFirefoxProfile(resp.Msg) },
+	} {
+		if !strings.HasPrefix(outputFlag, prefix) {
+			continue
+		}
+		filePath := strings.TrimPrefix(outputFlag, prefix)
+		if filePath == "" {
+			return errors.Errorf("no file path specified after %s", prefix)
+		}
+		buf, err := marshal()
 		if err != nil {
-			return errors.Wrap(err, "failed to create pprof file")
+			return errors.Wrap(err, "failed to convert the profile")
+		}
+		return writeNewFile(filePath, buf)
+	}
+
+	return errors.Errorf("unknown output %s", outputFlag)
+}
+
+func querySeries(ctx context.Context, params *queryParams, labelNames []string, outputFlag string) error {
+	level.Info(logger).Log("msg", "query series from profile store", "url", params.URL, "query", params.Query, "label_names", strings.Join(labelNames, ","))
+
+	resp, err := params.phlareClient.queryClient().Series(ctx, connect.NewRequest(&querierv1.SeriesRequest{
+		Matchers:   params.matchers(),
+		LabelNames: labelNames,
+	}))
+	if err != nil {
+		return errors.Wrap(err, "failed to query")
+	}
+
+	switch outputFlag {
+	case outputConsole:
+		for _, s := range resp.Msg.LabelsSet {
+			fmt.Fprintln(output(ctx), phlaremodel.LabelPairsString(s.Labels))
+		}
+		return nil
+	case outputJSON:
+		series := make([]map[string]string, 0, len(resp.Msg.LabelsSet))
+		for _, s := range resp.Msg.LabelsSet {
+			m := make(map[string]string, len(s.Labels))
+			for _, l := range s.Labels {
+				m[l.Name] = l.Value
+			}
+			series = append(series, m)
+		}
+		return writeJSON(ctx, series)
+	case outputRaw:
+		printRaw(resp.Msg)
+		return nil
+	}
+	return errors.Errorf("unknown output %s", outputFlag)
+}
+
+func queryLabelNames(ctx context.Context, params *queryParams, outputFlag string) error {
+	level.Info(logger).Log("msg", "query label names from profile store", "url", params.URL, "query", params.Query)
+
+	resp, err := params.phlareClient.queryClient().LabelNames(ctx, connect.NewRequest(&typesv1.LabelNamesRequest{
+		Matchers: params.matchers(),
+	}))
+	if err != nil {
+		return errors.Wrap(err, "failed to query")
+	}
+	return writeStrings(ctx, resp.Msg, resp.Msg.Names, outputFlag)
+}
+
+func queryLabelValues(ctx context.Context, params *queryParams, name string, outputFlag string) error {
+	level.Info(logger).Log("msg", "query label values from profile store", "url", params.URL, "query", params.Query, "name", name)
+
+	resp, err := params.phlareClient.queryClient().LabelValues(ctx, connect.NewRequest(&typesv1.LabelValuesRequest{
+		Name:     name,
+		Matchers: params.matchers(),
+	}))
+	if err != nil {
+		return errors.Wrap(err, "failed to query")
+	}
+	return writeStrings(ctx, resp.Msg, resp.Msg.Names, outputFlag)
+}
+
+func queryProfileTypes(ctx context.Context, params *queryParams, outputFlag string) error {
+	level.Info(logger).Log("msg", "query profile types from profile store", "url", params.URL)
+
+	resp, err := params.phlareClient.queryClient().ProfileTypes(ctx, connect.NewRequest(&querierv1.ProfileTypesRequest{}))
+	if err != nil {
+		return errors.Wrap(err, "failed to query")
+	}
+
+	switch outputFlag {
+	case outputConsole:
+		table := tablewriter.NewWriter(output(ctx))
+		table.SetHeader([]string{"ID", "Name", "Sample type", "Sample unit", "Period type", "Period unit"})
+		for _, t := range resp.Msg.ProfileTypes {
+			table.Append([]string{t.ID, t.Name, t.SampleType, t.SampleUnit, t.PeriodType, t.PeriodUnit})
+		}
+		table.Render()
+		return nil
+	case outputJSON:
+		ids := make([]string, 0, len(resp.Msg.ProfileTypes))
+		for _, t := range resp.Msg.ProfileTypes {
+			ids = append(ids, t.ID)
+		}
+		return writeJSON(ctx, ids)
+	case outputRaw:
+		printRaw(resp.Msg)
+		return nil
+	}
+	return errors.Errorf("unknown output %s", outputFlag)
+}
+
+// selectMergeStacktraces queries the merged stack traces of the profiles
+// matching the query parameters, as a tree.
+func selectMergeStacktraces(ctx context.Context, params *queryParams, maxNodes int64) (*phlaremodel.Tree, error) {
+	from, to, err := params.parseFromTo()
+	if err != nil {
+		return nil, err
+	}
+
+	level.Info(logger).Log("msg", "query merged stacktraces from profile store", "url", params.URL, "from", from, "to", to, "query", params.Query, "type", params.ProfileType)
+
+	req := &querierv1.SelectMergeStacktracesRequest{
+		ProfileTypeID: params.ProfileType,
+		Start:         from.UnixMilli(),
+		End:           to.UnixMilli(),
+		LabelSelector: params.Query,
+	}
+	if maxNodes > 0 {
+		req.MaxNodes = &maxNodes
+	}
+	resp, err := params.phlareClient.queryClient().SelectMergeStacktraces(ctx, connect.NewRequest(req))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query")
+	}
+
+	m := phlaremodel.NewFlameGraphMerger()
+	if resp.Msg.Flamegraph != nil {
+		m.MergeFlameGraph(resp.Msg.Flamegraph)
+	}
+	return m.Tree(), nil
+}
+
+type topFunction struct {
+	Name  string `json:"name"`
+	Self  int64  `json:"self"`
+	Total int64  `json:"total"`
+}
+
+// topFunctions returns the functions of the tree ordered by self value, the
+// total of a function accounting for each stack trace it appears in once,
+// even when it calls itself recursively.
+func topFunctions(t *phlaremodel.Tree) []*topFunction {
+	functions := make(map[string]*topFunction)
+	get := func(name string) *topFunction {
+		f, ok := functions[name]
+		if !ok {
+			f = &topFunction{Name: name}
+			functions[name] = f
+		}
+		return f
+	}
+	seen := make(map[string]struct{})
+	t.IterateStacks(func(name string, self int64, stack []string) {
+		get(name).Self += self
+		for _, n := range stack {
+			if _, ok := seen[n]; !ok {
+				seen[n] = struct{}{}
+				get(n).Total += self
+			}
+		}
+		for n := range seen {
+			delete(seen, n)
+		}
+	})
+	top := make([]*topFunction, 0, len(functions))
+	for _, f := range functions {
+		top = append(top, f)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Self != top[j].Self {
+			return top[i].Self > top[j].Self
 		}
-		defer runutil.CloseWithErrCapture(&err, f, "failed to close pprof file")
+		if top[i].Total != top[j].Total {
+			return top[i].Total > top[j].Total
+		}
+		return top[i].Name < top[j].Name
+	})
+	return top
+}
 
-		gzipWriter := gzip.NewWriter(f)
-		defer runutil.CloseWithErrCapture(&err, gzipWriter, "failed to close pprof gzip writer")
+func queryTop(ctx context.Context, params *queryParams, limit int, maxNodes int64, outputFlag string) error {
+	t, err := selectMergeStacktraces(ctx, params, maxNodes)
+	if err != nil {
+		return err
+	}
+	top := topFunctions(t)
+	if limit > 0 && len(top) > limit {
+		top = top[:limit]
+	}
 
-		if _, err := io.Copy(gzipWriter, bytes.NewReader(buf)); err != nil {
-			return errors.Wrap(err, "failed to write pprof")
+	switch outputFlag {
+	case outputConsole:
+		total := t.Total()
+		percent := func(v int64) string {
+			if total == 0 {
+				return "0.00%"
+			}
+			return fmt.Sprintf("%.2f%%", float64(v)*100/float64(total))
 		}
+		table := tablewriter.NewWriter(output(ctx))
+		table.SetHeader([]string{"Self", "Self%", "Total", "Total%", "Function"})
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		for _, f := range top {
+			table.Append([]string{
+				strconv.FormatInt(f.Self, 10),
+				percent(f.Self),
+				strconv.FormatInt(f.Total, 10),
+				percent(f.Total),
+				f.Name,
+			})
+		}
+		table.Render()
+		return nil
+	case outputJSON:
+		return writeJSON(ctx, top)
+	}
+	return errors.Errorf("unknown output %s", outputFlag)
+}
+
+type collapsedStack struct {
+	Stack []string `json:"stack"`
+	Value int64    `json:"value"`
+}
+
+func queryFlameGraph(ctx context.Context, params *queryParams, maxNodes int64, outputFlag string) error {
+	t, err := selectMergeStacktraces(ctx, params, maxNodes)
+	if err != nil {
+		return err
+	}
 
+	switch outputFlag {
+	case outputConsole:
+		fmt.Fprint(output(ctx), t.String())
 		return nil
+	case outputCollapsed, outputFolded:
+		return t.WriteFolded(output(ctx))
+	case outputJSON:
+		stacks := make([]collapsedStack, 0)
+		t.IterateStacks(func(_ string, self int64, stack []string) {
+			stacks = append(stacks, collapsedStack{Stack: append([]string(nil), stack...), Value: self})
+		})
+		return writeJSON(ctx, stacks)
+	}
+	return errors.Errorf("unknown output %s", outputFlag)
+}
+
+// writePprofFile writes the gzipped profile to a new file, it fails when the
+// file already exists.
+func writePprofFile(filePath string, buf []byte) (err error) {
+	f, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to create pprof file")
+	}
+	defer runutil.CloseWithErrCapture(&err, f, "failed to close pprof file")
+
+	gzipWriter := gzip.NewWriter(f)
+	defer runutil.CloseWithErrCapture(&err, gzipWriter, "failed to close pprof gzip writer")
+
+	if _, err := io.Copy(gzipWriter, bytes.NewReader(buf)); err != nil {
+		return errors.Wrap(err, "failed to write pprof")
+	}
+	return nil
+}
+
+// writeNewFile writes the data to a new file, it fails when the file already
+// exists.
+func writeNewFile(filePath string, buf []byte) (err error) {
+	f, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to create file")
+	}
+	defer runutil.CloseWithErrCapture(&err, f, "failed to close file")
+
+	if _, err := f.Write(buf); err != nil {
+		return errors.Wrap(err, "failed to write file")
 	}
+	return nil
+}
 
+func writeStrings(ctx context.Context, msg interface{}, values []string, outputFlag string) error {
+	switch outputFlag {
+	case outputConsole:
+		for _, v := range values {
+			fmt.Fprintln(output(ctx), v)
+		}
+		return nil
+	case outputJSON:
+		if values == nil {
+			values = []string{}
+		}
+		return writeJSON(ctx, values)
+	case outputRaw:
+		printRaw(msg)
+		return nil
+	}
 	return errors.Errorf("unknown output %s", outputFlag)
 }
+
+func writeJSON(ctx context.Context, v interface{}) error {
+	enc := json.NewEncoder(output(ctx))
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func printRaw(msg interface{}) {
+	mypp := pp.New()
+	mypp.SetColoringEnabled(isatty.IsTerminal(os.Stdout.Fd()))
+	mypp.SetExportedOnly(true)
+	mypp.Print(msg)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/stretchr/testify/require"
+
+	profilev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
+	querierv1 "github.com/grafana/phlare/api/gen/proto/go/querier/v1"
+	"github.com/grafana/phlare/api/gen/proto/go/querier/v1/querierv1connect"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/pprof/testhelper"
+)
+
+func newTree(stacks ...func(t *phlaremodel.Tree)) *phlaremodel.Tree {
+	t := new(phlaremodel.Tree)
+	for _, s := range stacks {
+		s(t)
+	}
+	return t
+}
+
+func stack(v int64, names ...string) func(t *phlaremodel.Tree) {
+	return func(t *phlaremodel.Tree) { t.InsertStack(v, names...) }
+}
+
+func Test_topFunctions(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		tree     *phlaremodel.Tree
+		expected []*topFunction
+	}{
+		{
+			name:     "empty",
+			tree:     new(phlaremodel.Tree),
+			expected: []*topFunction{},
+		},
+		{
+			name: "single stack",
+			tree: newTree(stack(3, "main", "foo")),
+			expected: []*topFunction{
+				{Name: "foo", Self: 3, Total: 3},
+				{Name: "main", Self: 0, Total: 3},
+			},
+		},
+		{
+			name: "recursive",
+			tree: newTree(
+				stack(1, "main", "a", "b", "a"),
+				stack(2, "main", "a", "a", "a"),
+				stack(4, "main", "b"),
+			),
+			expected: []*topFunction{
+				{Name: "b", Self: 4, Total: 5},
+				{Name: "a", Self: 3, Total: 3},
+				{Name: "main", Self: 0, Total: 7},
+			},
+		},
+		{
+			name: "ties ordered by total then name",
+			tree: newTree(
+				stack(1, "main", "b"),
+				stack(1, "main", "a"),
+				stack(1, "c", "d"),
+			),
+			expected: []*topFunction{
+				{Name: "a", Self: 1, Total: 1},
+				{Name: "b", Self: 1, Total: 1},
+				{Name: "d", Self: 1, Total: 1},
+				{Name: "main", Self: 0, Total: 2},
+				{Name: "c", Self: 0, Total: 1},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, topFunctions(tc.tree))
+		})
+	}
+}
+
+type fakeQuerier struct {
+	querierv1connect.UnimplementedQuerierServiceHandler
+	tree    *phlaremodel.Tree
+	profile *profilev1.Profile
+}
+
+func (q *fakeQuerier) SelectMergeStacktraces(_ context.Context, req *connect.Request[querierv1.SelectMergeStacktracesRequest]) (*connect.Response[querierv1.SelectMergeStacktracesResponse], error) {
+	return connect.NewResponse(&querierv1.SelectMergeStacktracesResponse{
+		Flamegraph: phlaremodel.NewFlameGraph(q.tree, req.Msg.GetMaxNodes()),
+	}), nil
+}
+
+func (q *fakeQuerier) SelectMergeProfile(context.Context, *connect.Request[querierv1.SelectMergeProfileRequest]) (*connect.Response[profilev1.Profile], error) {
+	return connect.NewResponse(q.profile), nil
+}
+
+// newTestQueryParams returns the parameters of the queries to a querier
+// serving the given tree and profile.
+func newTestQueryParams(t *testing.T, q *fakeQuerier) *queryParams {
+	mux := http.NewServeMux()
+	mux.Handle(querierv1connect.NewQuerierServiceHandler(q))
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return &queryParams{
+		phlareClient: &phlareClient{URL: s.URL},
+		From:         "now-1h",
+		To:           "now",
+		ProfileType:  "process_cpu:cpu:nanoseconds:cpu:nanoseconds",
+		Query:        "{}",
+	}
+}
+
+func Test_queryTop(t *testing.T) {
+	params := newTestQueryParams(t, &fakeQuerier{tree: newTree(
+		stack(1, "main", "a", "b", "a"),
+		stack(2, "main", "a", "a", "a"),
+		stack(4, "main", "b"),
+	)})
+
+	var buf bytes.Buffer
+	ctx := withOutput(context.Background(), &buf)
+	require.NoError(t, queryTop(ctx, params, 2, 0, outputJSON))
+	var top []*topFunction
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &top))
+	require.Equal(t, []*topFunction{
+		{Name: "b", Self: 4, Total: 5},
+		{Name: "a", Self: 3, Total: 3},
+	}, top)
+
+	buf.Reset()
+	require.NoError(t, queryTop(ctx, params, 0, 0, outputConsole))
+	require.Contains(t, buf.String(), "57.14%")
+	require.Contains(t, buf.String(), "main")
+
+	require.Error(t, queryTop(ctx, params, 0, 0, outputRaw))
+}
+
+func Test_queryFlameGraph(t *testing.T) {
+	params := newTestQueryParams(t, &fakeQuerier{tree: newTree(
+		stack(1, "main", "a"),
+		stack(2, "main", "b"),
+	)})
+
+	var buf bytes.Buffer
+	ctx := withOutput(context.Background(), &buf)
+	require.NoError(t, queryFlameGraph(ctx, params, 0, outputFolded))
+	require.Equal(t, "main;a 1\nmain;b 2\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, queryFlameGraph(ctx, params, 0, outputJSON))
+	var stacks []collapsedStack
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &stacks))
+	require.Equal(t, []collapsedStack{
+		{Stack: []string{"main", "a"}, Value: 1},
+		{Stack: []string{"main", "b"}, Value: 2},
+	}, stacks)
+
+	require.Error(t, queryFlameGraph(ctx, params, 0, outputRaw))
+}
+
+func Test_queryMerge(t *testing.T) {
+	p := testhelper.NewProfileBuilder(0).CPUProfile()
+	p.ForStacktraceString("foo", "main").AddSamples(1)
+	p.ForStacktraceString("bar", "main").AddSamples(2)
+	params := newTestQueryParams(t, &fakeQuerier{profile: p.Profile})
+	ctx := withOutput(context.Background(), new(bytes.Buffer))
+	dir := t.TempDir()
+
+	for _, tc := range []struct {
+		output   string
+		expected string
+	}{
+		{output: outputSpeedscope, expected: `"$schema":"https://www.speedscope.app/file-format-schema.json"`},
+		{output: outputFirefox, expected: `"meta":`},
+	} {
+		tc := tc
+		t.Run(strings.TrimSuffix(tc.output, "="), func(t *testing.T) {
+			path := filepath.Join(dir, strings.TrimSuffix(tc.output, "=")+".json")
+			require.NoError(t, queryMerge(ctx, params, tc.output+path))
+			b, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.True(t, json.Valid(b))
+			require.Contains(t, string(b), tc.expected)
+			require.Contains(t, string(b), `"main"`)
+
+			// The existing files are not overwritten.
+			require.Error(t, queryMerge(ctx, params, tc.output+path))
+			// The file path is required.
+			require.Error(t, queryMerge(ctx, params, tc.output))
+		})
+	}
+}
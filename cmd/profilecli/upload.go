@@ -1,15 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"os"
+	"time"
 
 	"github.com/bufbuild/connect-go"
 	"github.com/go-kit/log/level"
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 
 	pushv1 "github.com/grafana/phlare/api/gen/proto/go/push/v1"
 	"github.com/grafana/phlare/api/gen/proto/go/push/v1/pushv1connect"
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
 	"github.com/grafana/phlare/pkg/model"
 	"github.com/grafana/phlare/pkg/pprof"
 )
@@ -21,10 +25,18 @@ func (c *phlareClient) pusherClient() pushv1connect.PusherServiceClient {
 	)
 }
 
+const (
+	uploadFormatPprof      = "pprof"
+	uploadFormatFolded     = "folded"
+	uploadFormatPerfScript = "perf-script"
+)
+
 type uploadParams struct {
 	*phlareClient
 	paths       []string
 	extraLabels map[string]string
+	format      string
+	profileType string
 }
 
 func addUploadParams(cmd commander) *uploadParams {
@@ -37,6 +49,8 @@ func addUploadParams(cmd commander) *uploadParams {
 
 	cmd.Arg("path", "Path(s) to profile(s) to upload").Required().ExistingFilesVar(&params.paths)
 	cmd.Flag("extra-labels", "Add additional labels to the profile(s)").Default("job=profilecli-upload").StringMapVar(&params.extraLabels)
+	cmd.Flag("format", "Format of the profile(s): pprof (including Chrome .cpuprofile and Speedscope JSON), folded (e.g. \"main;foo;bar 42\") or perf-script (the output of perf script).").Default(uploadFormatPprof).EnumVar(&params.format, uploadFormatPprof, uploadFormatFolded, uploadFormatPerfScript)
+	cmd.Flag("profile-type", "Profile type of the folded profile(s).").Default("process_cpu:samples:count:cpu:nanoseconds").StringVar(&params.profileType)
 	return params
 }
 
@@ -48,6 +62,13 @@ func upload(ctx context.Context, params *uploadParams) (err error) {
 		lblStrings = append(lblStrings, key, value)
 	}
 
+	var profileType *typesv1.ProfileType
+	if params.format == uploadFormatFolded {
+		if profileType, err = model.ParseProfileTypeSelector(params.profileType); err != nil {
+			return err
+		}
+	}
+
 	var (
 		lbl        = model.LabelsFromStrings(lblStrings...)
 		series     = make([]*pushv1.RawProfileSeries, len(params.paths))
@@ -61,13 +82,44 @@ func upload(ctx context.Context, params *uploadParams) (err error) {
 			return nil
 		}
 
+		if profileType != nil {
+			tree, err := model.ParseFolded(bytes.NewReader(data))
+			if err != nil {
+				return errors.Wrapf(err, "failed to parse %s", path)
+			}
+			if data, err = tree.Pprof(profileType, time.Now().UnixNano()).MarshalVT(); err != nil {
+				return err
+			}
+			if lbl.Get(model.LabelNameProfileName) == "" {
+				lblBuilder.Set(model.LabelNameProfileName, profileType.Name)
+			}
+		}
+		if params.format == uploadFormatPerfScript {
+			p, err := pprof.ParsePerfScript(bytes.NewReader(data))
+			if err != nil {
+				return errors.Wrapf(err, "failed to parse %s", path)
+			}
+			if data, err = p.MarshalVT(); err != nil {
+				return err
+			}
+			if lbl.Get(model.LabelNameProfileName) == "" {
+				lblBuilder.Set(model.LabelNameProfileName, pprof.PerfScriptProfileName)
+			}
+		}
+
 		profile, err := pprof.RawFromBytes(data)
 		if err != nil {
 			return err
 		}
+		// The JSON profiles are converted by RawFromBytes.
+		if pprof.IsJSON(data) {
+			if data, err = profile.MarshalVT(); err != nil {
+				return err
+			}
+		}
 
 		// detect name if no name has been set
-		if lbl.Get(model.LabelNameProfileName) == "" {
+		if lblBuilder.Labels().Get(model.LabelNameProfileName) == "" {
 			name := "unknown"
 			for _, t := range profile.Profile.SampleType {
 				if sid := int(t.Type); sid < len(profile.StringTable) {
@@ -77,6 +129,9 @@ func upload(ctx context.Context, params *uploadParams) (err error) {
 					} else if s == "alloc_space" || s == "inuse_space" {
 						name = "memory"
 						break
+					} else if s == "wall" {
+						name = "wall"
+						break
 					} else {
 						level.Debug(logger).Log("msg", "unspecific/unknown profile sample type", "profile", s)
 					}
@@ -42,7 +42,7 @@ func Test_Relabeling(t *testing.T) {
 	for _, scrapeCfg := range cfg.ScrapeConfigs {
 		scrapeCfg.ScrapeInterval = model.Duration(15 * time.Second)
 		scrapeCfg.ScrapeTimeout = model.Duration(30 * time.Second)
-		groups = append(groups, agent.NewTargetGroup(context.Background(), "test", scrapeCfg, nil, "test", log.NewLogfmtLogger(os.Stdout)))
+		groups = append(groups, agent.NewTargetGroup(context.Background(), "test", scrapeCfg, nil, log.NewLogfmtLogger(os.Stdout)))
 	}
 
 	for _, ty := range []string{"memory", "cpu", "block", "mutex", "goroutine"} {
@@ -2,6 +2,10 @@ package agent
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
 	"sync"
 
 	"github.com/go-kit/log"
@@ -11,6 +15,7 @@ import (
 
 	agentv1 "github.com/grafana/phlare/api/gen/proto/go/agent/v1"
 	"github.com/grafana/phlare/api/gen/proto/go/push/v1/pushv1connect"
+	"github.com/grafana/phlare/pkg/agent/scrape"
 )
 
 type Agent struct {
@@ -23,6 +28,7 @@ type Agent struct {
 	manager              *discovery.Manager
 	jobs                 map[string]discovery.Configs
 	groups               map[string]*TargetGroup
+	clients              fanout
 	pusherClientProvider PusherClientProvider
 
 	mtx sync.Mutex
@@ -35,11 +41,28 @@ type TargetManager interface {
 
 type PusherClientProvider func() pushv1connect.PusherServiceClient
 
+// New creates a new agent. Scraped profiles are pushed to the client returned
+// by pusherClientProvider, and to every additional client configured.
 func New(config *Config, logger log.Logger, pusherClientProvider PusherClientProvider) (*Agent, error) {
+	defaultClientConfig := config.ClientConfig
+	defaultClientConfig.Name = defaultClientName
+	defaultClientConfig.QueueConfig.applyDefaults()
+	clients := fanout{newClient(&defaultClientConfig, pusherClientProvider, logger)}
+	for _, cfg := range config.ClientConfigs {
+		provider, err := newHTTPPusherClientProvider(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("client %s: %w", cfg.Name, err)
+		}
+		clients = append(clients, newClient(cfg, provider, logger))
+	}
+
 	a := &Agent{
-		Config:               config,
-		logger:               logger,
-		pusherClientProvider: pusherClientProvider,
+		Config:  config,
+		logger:  logger,
+		clients: clients,
+		pusherClientProvider: func() pushv1connect.PusherServiceClient {
+			return clients
+		},
 	}
 	a.Service = services.NewBasicService(nil, a.running, nil)
 	jobs := map[string]discovery.Configs{}
@@ -61,6 +84,9 @@ func (a *Agent) running(ctx context.Context) error {
 	if err := a.manager.ApplyConfig(a.jobs); err != nil {
 		return nil
 	}
+	for _, c := range a.clients {
+		go c.run(ctx)
+	}
 
 	for {
 		select {
@@ -72,7 +98,7 @@ func (a *Agent) running(ctx context.Context) error {
 					a.groups[jobName].sync(groups)
 					continue
 				}
-				newGroup := NewTargetGroup(ctx, jobName, jobConfig(jobName, a.Config), a.pusherClientProvider, a.Config.ClientConfig.TenantID, a.logger)
+				newGroup := NewTargetGroup(ctx, jobName, jobConfig(jobName, a.Config), a.pusherClientProvider, a.logger)
 				a.groups[jobName] = newGroup
 				newGroup.sync(groups)
 
@@ -109,6 +135,52 @@ func (a *Agent) DroppedTargets() []*Target {
 	return result
 }
 
+// ScrapesLocalCPUProfile reports whether the agent scrapes the CPU profile of
+// a target listening on the given port of this host.
+func (a *Agent) ScrapesLocalCPUProfile(port int) bool {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	for _, targets := range a.ActiveTargets() {
+		for _, t := range targets {
+			if t.Labels().Get(scrape.ProfileName) != pprofProcessCPU {
+				continue
+			}
+			u := t.URL()
+			if u.Port() == strconv.Itoa(port) && isLocalHost(u.Hostname()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isLocalHost reports whether the host is a name or an address of this host.
+func isLocalHost(host string) bool {
+	if host == "" || host == "localhost" {
+		return true
+	}
+	if name, err := os.Hostname(); err == nil && host == name {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() || ip.IsUnspecified() {
+		return true
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if n, ok := addr.(*net.IPNet); ok && n.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 func jobConfig(jobName string, config *Config) ScrapeConfig {
 	for _, cfg := range config.ScrapeConfigs {
 		if cfg.JobName == jobName {
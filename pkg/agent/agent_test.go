@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/phlare/pkg/agent/scrape"
+)
+
+func TestAgent_ScrapesLocalCPUProfile(t *testing.T) {
+	target := func(address, profileType string) *Target {
+		lbls := labels.FromStrings(
+			model.AddressLabel, address,
+			model.SchemeLabel, "http",
+			scrape.ProfileName, profileType,
+		)
+		return &Target{Target: scrape.NewTarget(lbls, lbls, nil), labels: lbls}
+	}
+	for _, tc := range []struct {
+		name     string
+		targets  []*Target
+		expected bool
+	}{
+		{name: "no targets"},
+		{
+			name:     "local cpu",
+			targets:  []*Target{target("127.0.0.1:4100", pprofProcessCPU)},
+			expected: true,
+		},
+		{
+			name:     "localhost cpu",
+			targets:  []*Target{target("localhost:4100", pprofProcessCPU)},
+			expected: true,
+		},
+		{
+			name:    "local memory",
+			targets: []*Target{target("127.0.0.1:4100", "memory")},
+		},
+		{
+			name:    "other port",
+			targets: []*Target{target("127.0.0.1:4200", pprofProcessCPU)},
+		},
+		{
+			name:    "other host",
+			targets: []*Target{target("192.0.2.1:4100", pprofProcessCPU)},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := &TargetGroup{activeTargets: map[uint64]*Target{}}
+			for i, target := range tc.targets {
+				g.activeTargets[uint64(i)] = target
+			}
+			a := &Agent{groups: map[string]*TargetGroup{"default": g}}
+			require.Equal(t, tc.expected, a.ScrapesLocalCPUProfile(4100))
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/parca-dev/parca/pkg/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// podAnnotationPrefix is the prefix of the labels discovered from the
+// Kubernetes pod annotations used to configure the scraping of each profile
+// type, e.g. profiles.grafana.com/cpu.scrape: "true".
+const podAnnotationPrefix = model.MetaLabelPrefix + "kubernetes_pod_annotation_profiles_grafana_com_"
+
+const (
+	annotationScrape = "scrape"
+	annotationPort   = "port"
+	annotationPath   = "path"
+)
+
+// profileTypeAliases are the short profile type names accepted in annotations.
+var profileTypeAliases = map[string]string{
+	"cpu": pprofProcessCPU,
+}
+
+// profilingConfigFromAnnotations returns the profiling config of a target with
+// the settings of its pod annotations applied:
+//
+//   - profiles.grafana.com/<type>.scrape enables or disables the profile type.
+//   - profiles.grafana.com/<type>.port overrides the port to scrape the profile type from.
+//   - profiles.grafana.com/<type>.path overrides the path to scrape the profile type from.
+//
+// Profile types without annotations follow the scrape config. The ports to
+// use are returned by profile type.
+func profilingConfigFromAnnotations(lset labels.Labels, cfg *config.ProfilingConfig) (*config.ProfilingConfig, map[string]string) {
+	var (
+		res   *config.ProfilingConfig
+		ports map[string]string
+	)
+	for _, l := range lset {
+		if !strings.HasPrefix(l.Name, podAnnotationPrefix) {
+			continue
+		}
+		profileType, key, ok := parseProfileAnnotation(strings.TrimPrefix(l.Name, podAnnotationPrefix))
+		if !ok {
+			continue
+		}
+		if _, ok := cfg.PprofConfig[profileType]; !ok {
+			continue
+		}
+		if res == nil {
+			res = copyProfilingConfig(cfg)
+		}
+		pcfg := res.PprofConfig[profileType]
+		switch key {
+		case annotationScrape:
+			enabled := l.Value == "true"
+			pcfg.Enabled = &enabled
+		case annotationPath:
+			pcfg.Path = l.Value
+		case annotationPort:
+			if ports == nil {
+				ports = make(map[string]string)
+			}
+			ports[profileType] = l.Value
+		}
+	}
+	if res == nil {
+		return cfg, nil
+	}
+	return res, ports
+}
+
+// parseProfileAnnotation splits the sanitized annotation name into the
+// profile type and the setting, e.g. process_cpu_scrape.
+func parseProfileAnnotation(name string) (profileType, key string, ok bool) {
+	idx := strings.LastIndexByte(name, '_')
+	if idx <= 0 {
+		return "", "", false
+	}
+	profileType, key = name[:idx], name[idx+1:]
+	switch key {
+	case annotationScrape, annotationPort, annotationPath:
+	default:
+		return "", "", false
+	}
+	if alias, ok := profileTypeAliases[profileType]; ok {
+		profileType = alias
+	}
+	return profileType, key, true
+}
+
+func copyProfilingConfig(cfg *config.ProfilingConfig) *config.ProfilingConfig {
+	res := &config.ProfilingConfig{
+		PprofConfig: make(config.PprofConfig, len(cfg.PprofConfig)),
+		PprofPrefix: cfg.PprofPrefix,
+	}
+	for profileType, pcfg := range cfg.PprofConfig {
+		c := *pcfg
+		if pcfg.Enabled != nil {
+			enabled := *pcfg.Enabled
+			c.Enabled = &enabled
+		}
+		res.PprofConfig[profileType] = &c
+	}
+	return res
+}
+
+// withPort returns the label set with the port of the address replaced. An
+// error is returned if the port is not a valid port number.
+func withPort(lset labels.Labels, port string) (labels.Labels, error) {
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return nil, fmt.Errorf("invalid port annotation %q", port)
+	}
+	addr := lset.Get(model.AddressLabel)
+	if addr == "" {
+		return lset, nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return labels.NewBuilder(lset).Set(model.AddressLabel, net.JoinHostPort(host, port)).Labels(), nil
+}
@@ -57,10 +57,15 @@ func (a *Agent) GetTargets(ctx context.Context, req *agentv1.GetTargetsRequest)
 		tDropped := a.DroppedTargets()
 		resp.DroppedTargets = make([]*agentv1.Target, 0, len(tDropped))
 		for _, t := range tDropped {
+			var lastErr string
+			if err := t.LastError(); err != nil {
+				lastErr = err.Error()
+			}
 			resp.DroppedTargets = append(resp.DroppedTargets, &agentv1.Target{
 				Labels:           t.Labels().Map(),
 				DiscoveredLabels: t.Target.DiscoveredLabels().Map(),
 				ScrapeUrl:        t.URL().String(),
+				LastError:        lastErr,
 			})
 		}
 	}
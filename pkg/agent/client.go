@@ -0,0 +1,169 @@
+package agent
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/backoff"
+	commonconfig "github.com/prometheus/common/config"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+
+	pushv1 "github.com/grafana/phlare/api/gen/proto/go/push/v1"
+	"github.com/grafana/phlare/api/gen/proto/go/push/v1/pushv1connect"
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+	"github.com/grafana/phlare/pkg/tenant"
+	"github.com/grafana/phlare/pkg/util"
+)
+
+const defaultClientName = "default"
+
+// client pushes profiles to a single endpoint. Each client has its own queue,
+// so that a slow or unavailable endpoint doesn't prevent others from receiving
+// profiles.
+type client struct {
+	cfg    *ClientConfig
+	pusher PusherClientProvider
+	logger log.Logger
+	queue  chan *pushv1.PushRequest
+}
+
+func newClient(cfg *ClientConfig, pusher PusherClientProvider, logger log.Logger) *client {
+	return &client{
+		cfg:    cfg,
+		pusher: pusher,
+		logger: log.With(logger, "client", cfg.Name),
+		queue:  make(chan *pushv1.PushRequest, cfg.QueueConfig.Capacity),
+	}
+}
+
+// newHTTPPusherClientProvider returns a pusher for the endpoint of the client
+// configuration.
+func newHTTPPusherClientProvider(cfg *ClientConfig) (PusherClientProvider, error) {
+	httpClient, err := commonconfig.NewClientFromConfig(cfg.Client, cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	httpClient.Transport = util.WrapWithInstrumentedHTTPTransport(httpClient.Transport)
+	pusher := pushv1connect.NewPusherServiceClient(httpClient,
+		cfg.URL.String(),
+		connect.WithInterceptors(tenant.NewAuthInterceptor(true)),
+	)
+	return func() pushv1connect.PusherServiceClient { return pusher }, nil
+}
+
+// enqueue applies the write relabel rules of the client to the request and
+// queues it. The request is dropped if the queue is full.
+func (c *client) enqueue(req *pushv1.PushRequest) {
+	if req = c.relabel(req); req == nil {
+		return
+	}
+	select {
+	case c.queue <- req:
+	default:
+		level.Warn(c.logger).Log("msg", "push queue is full, dropping profiles", "series", len(req.Series))
+	}
+}
+
+func (c *client) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-c.queue:
+			c.push(ctx, req)
+		}
+	}
+}
+
+func (c *client) push(ctx context.Context, req *pushv1.PushRequest) {
+	if c.cfg.TenantID != "" {
+		ctx = tenant.InjectTenantID(ctx, c.cfg.TenantID)
+	}
+	bo := backoff.New(ctx, backoff.Config{
+		MinBackoff: c.cfg.QueueConfig.MinBackoff,
+		MaxBackoff: c.cfg.QueueConfig.MaxBackoff,
+		MaxRetries: c.cfg.QueueConfig.MaxRetries,
+	})
+	for bo.Ongoing() {
+		_, err := c.pusher().Push(ctx, connect.NewRequest(req))
+		if err == nil {
+			return
+		}
+		if !isRetryable(err) {
+			level.Error(c.logger).Log("msg", "push failed", "err", err)
+			return
+		}
+		level.Warn(c.logger).Log("msg", "push failed, retrying", "retries", bo.NumRetries(), "err", err)
+		bo.Wait()
+	}
+	if ctx.Err() == nil {
+		level.Error(c.logger).Log("msg", "push failed, dropping profiles", "err", bo.Err())
+	}
+}
+
+func isRetryable(err error) bool {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return true
+	}
+	switch connectErr.Code() {
+	case connect.CodeInvalidArgument,
+		connect.CodeUnauthenticated,
+		connect.CodePermissionDenied,
+		connect.CodeFailedPrecondition,
+		connect.CodeUnimplemented:
+		return false
+	}
+	return true
+}
+
+// relabel returns a copy of the request with the client write relabel rules
+// applied to each series. Dropped series are removed, and nil is returned if
+// all series are dropped.
+func (c *client) relabel(req *pushv1.PushRequest) *pushv1.PushRequest {
+	if len(c.cfg.WriteRelabelConfigs) == 0 {
+		return req
+	}
+	res := &pushv1.PushRequest{
+		Series: make([]*pushv1.RawProfileSeries, 0, len(req.Series)),
+	}
+	for _, series := range req.Series {
+		lbls := make([]labels.Label, 0, len(series.Labels))
+		for _, l := range series.Labels {
+			lbls = append(lbls, labels.Label{Name: l.Name, Value: l.Value})
+		}
+		lset, keep := relabel.Process(labels.New(lbls...), c.cfg.WriteRelabelConfigs...)
+		if !keep {
+			continue
+		}
+		relabeled := &pushv1.RawProfileSeries{
+			Labels:  make([]*typesv1.LabelPair, 0, len(lset)),
+			Samples: series.Samples,
+		}
+		for _, l := range lset {
+			relabeled.Labels = append(relabeled.Labels, &typesv1.LabelPair{
+				Name:  l.Name,
+				Value: l.Value,
+			})
+		}
+		res.Series = append(res.Series, relabeled)
+	}
+	if len(res.Series) == 0 {
+		return nil
+	}
+	return res
+}
+
+// fanout queues push requests to every client.
+type fanout []*client
+
+func (f fanout) Push(_ context.Context, req *connect.Request[pushv1.PushRequest]) (*connect.Response[pushv1.PushResponse], error) {
+	for _, c := range f {
+		c.enqueue(req.Msg)
+	}
+	return connect.NewResponse(&pushv1.PushResponse{}), nil
+}
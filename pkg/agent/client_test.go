@@ -0,0 +1,135 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/stretchr/testify/require"
+
+	pushv1 "github.com/grafana/phlare/api/gen/proto/go/push/v1"
+	"github.com/grafana/phlare/api/gen/proto/go/push/v1/pushv1connect"
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+	"github.com/grafana/phlare/pkg/tenant"
+)
+
+type fakePusher struct {
+	pushv1connect.UnimplementedPusherServiceHandler
+
+	mtx      sync.Mutex
+	failures int
+	tenants  []string
+	requests []*pushv1.PushRequest
+}
+
+func (f *fakePusher) Push(ctx context.Context, req *connect.Request[pushv1.PushRequest]) (*connect.Response[pushv1.PushResponse], error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return nil, connect.NewError(connect.CodeUnavailable, nil)
+	}
+	tenantID, _ := tenant.ExtractTenantIDFromContext(ctx)
+	f.tenants = append(f.tenants, tenantID)
+	f.requests = append(f.requests, req.Msg)
+	return connect.NewResponse(&pushv1.PushResponse{}), nil
+}
+
+func (f *fakePusher) received() ([]string, []*pushv1.PushRequest) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.tenants, f.requests
+}
+
+func newTestClient(cfg *ClientConfig, pusher *fakePusher) *client {
+	cfg.QueueConfig.applyDefaults()
+	cfg.QueueConfig.MinBackoff = time.Millisecond
+	cfg.QueueConfig.MaxBackoff = time.Millisecond
+	return newClient(cfg, func() pushv1connect.PusherServiceClient { return pusher }, log.NewNopLogger())
+}
+
+func series(lbls ...string) *pushv1.RawProfileSeries {
+	s := &pushv1.RawProfileSeries{
+		Samples: []*pushv1.RawSample{{RawProfile: []byte("profile")}},
+	}
+	for i := 0; i < len(lbls); i += 2 {
+		s.Labels = append(s.Labels, &typesv1.LabelPair{Name: lbls[i], Value: lbls[i+1]})
+	}
+	return s
+}
+
+func TestClient_Fanout(t *testing.T) {
+	var (
+		prod    = &fakePusher{}
+		staging = &fakePusher{failures: 2}
+		clients = fanout{
+			newTestClient(&ClientConfig{Name: "prod", TenantID: "prod"}, prod),
+			newTestClient(&ClientConfig{
+				Name:     "staging",
+				TenantID: "staging",
+				WriteRelabelConfigs: []*relabel.Config{
+					{
+						SourceLabels: model.LabelNames{"env"},
+						Regex:        relabel.MustNewRegexp("dev"),
+						Action:       relabel.Keep,
+					},
+					{
+						Regex:  relabel.MustNewRegexp("team"),
+						Action: relabel.LabelDrop,
+					},
+				},
+			}, staging),
+		}
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, c := range clients {
+		go c.run(ctx)
+	}
+
+	_, err := clients.Push(ctx, connect.NewRequest(&pushv1.PushRequest{
+		Series: []*pushv1.RawProfileSeries{
+			series("__name__", "cpu", "env", "dev", "team", "a"),
+			series("__name__", "cpu", "env", "prod", "team", "b"),
+		},
+	}))
+	require.NoError(t, err)
+	_, err = clients.Push(ctx, connect.NewRequest(&pushv1.PushRequest{
+		Series: []*pushv1.RawProfileSeries{
+			series("__name__", "cpu", "env", "prod", "team", "b"),
+		},
+	}))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, prodReqs := prod.received()
+		_, stagingReqs := staging.received()
+		return len(prodReqs) == 2 && len(stagingReqs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	tenants, reqs := prod.received()
+	require.Equal(t, []string{"prod", "prod"}, tenants)
+	require.Len(t, reqs[0].Series, 2)
+	require.Len(t, reqs[1].Series, 1)
+
+	tenants, reqs = staging.received()
+	require.Equal(t, []string{"staging"}, tenants)
+	require.Equal(t, []*pushv1.RawProfileSeries{
+		series("__name__", "cpu", "env", "dev"),
+	}, reqs[0].Series)
+}
+
+func TestClient_QueueFull(t *testing.T) {
+	pusher := &fakePusher{}
+	c := newTestClient(&ClientConfig{Name: "test", QueueConfig: QueueConfig{Capacity: 1}}, pusher)
+	req := &pushv1.PushRequest{Series: []*pushv1.RawProfileSeries{series("__name__", "cpu")}}
+
+	c.enqueue(req)
+	c.enqueue(req)
+	require.Len(t, c.queue, 1)
+}
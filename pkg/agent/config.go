@@ -23,6 +23,8 @@ import (
 type Config struct {
 	ScrapeConfigs []*ScrapeConfig `yaml:"scrape_configs,omitempty"`
 	ClientConfig  ClientConfig    `yaml:"client,omitempty"`
+	// ClientConfigs are additional endpoints the scraped profiles are pushed to.
+	ClientConfigs []*ClientConfig `yaml:"clients,omitempty"`
 }
 
 // RegisterFlags with prefix registers flags where every name is prefixed by
@@ -30,6 +32,7 @@ type Config struct {
 func (c *ClientConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.Var(&c.URL, prefix+"client.url", "URL of log server.")
 	f.StringVar(&c.TenantID, prefix+"client.tenant-id", tenant.DefaultTenantID, "Tenant ID to use when pushing profiles to Phlare (default: anonymous).")
+	c.QueueConfig.RegisterFlagsWithPrefix(prefix+"client.queue.", f)
 	// Default backoff schedule: 0.5s, 1s, 2s, 4s, 8s, 16s, 32s, 64s, 128s, 256s(4.267m) For a total time of 511.5s(8.5m) before logs are lost
 	// f.IntVar(&c.BackoffConfig.MaxRetries, prefix+"client.max-retries", MaxRetries, "Maximum number of retires when sending batches (deprecated).")
 	// f.DurationVar(&c.BackoffConfig.MinBackoff, prefix+"client.min-backoff", MinBackoff, "Initial backoff time between retries (deprecated).")
@@ -47,27 +50,88 @@ func (c *Config) Validate() error {
 			return err
 		}
 	}
+	names := map[string]struct{}{defaultClientName: {}}
+	for _, cfg := range c.ClientConfigs {
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+		if _, ok := names[cfg.Name]; ok {
+			return fmt.Errorf("client: duplicate name %q", cfg.Name)
+		}
+		names[cfg.Name] = struct{}{}
+	}
 	return nil
 }
 
 type ClientConfig struct {
+	// Name identifies the client in logs, it defaults to the URL.
+	Name      string `yaml:"name,omitempty" doc:"description=Name of the client in the logs, it defaults to the URL and must be unique across the clients."`
 	URL       flagext.URLValue
 	BatchWait time.Duration
 	BatchSize int
 	Client    commonconfig.HTTPClientConfig `yaml:",inline"`
 	// The tenant ID to use when pushing profiles to Phlare (default to anonymous).
 	TenantID string `yaml:"tenant_id"`
-	// todo add backoff config
-	// BackoffConfig backoff.Config                `yaml:"backoff_config"`
+	// WriteRelabelConfigs are applied to the labels of each profile series
+	// before pushing, series can be dropped or kept.
+	WriteRelabelConfigs []*relabel.Config `yaml:"write_relabel_configs,omitempty" doc:"description=Relabeling applied to the labels of each profile series before it is pushed to this client, series can be dropped or kept."`
+	QueueConfig         QueueConfig       `yaml:"queue_config,omitempty"`
 }
 
 func (c *ClientConfig) Validate() error {
 	if c.URL.String() == "" {
 		return fmt.Errorf("client: url is empty")
 	}
+	if c.Name == "" {
+		c.Name = c.URL.String()
+	}
+	if c.TenantID == "" {
+		c.TenantID = tenant.DefaultTenantID
+	}
+	c.QueueConfig.applyDefaults()
 	return c.Client.Validate()
 }
 
+// QueueConfig configures the queue of profiles waiting to be pushed to a client.
+type QueueConfig struct {
+	Capacity   int           `yaml:"capacity"`
+	MinBackoff time.Duration `yaml:"min_backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	MaxRetries int           `yaml:"max_retries"`
+}
+
+const (
+	defaultQueueCapacity   = 100
+	defaultQueueMinBackoff = 500 * time.Millisecond
+	defaultQueueMaxBackoff = 30 * time.Second
+	defaultQueueMaxRetries = 5
+)
+
+// RegisterFlagsWithPrefix registers flags where every name is prefixed by prefix.
+func (c *QueueConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.IntVar(&c.Capacity, prefix+"capacity", defaultQueueCapacity, "Maximum number of push requests waiting to be sent. Profiles are dropped when the queue is full.")
+	f.DurationVar(&c.MinBackoff, prefix+"min-backoff", defaultQueueMinBackoff, "Initial backoff time between retries of a failed push.")
+	f.DurationVar(&c.MaxBackoff, prefix+"max-backoff", defaultQueueMaxBackoff, "Maximum backoff time between retries of a failed push.")
+	f.IntVar(&c.MaxRetries, prefix+"max-retries", defaultQueueMaxRetries, "Maximum number of retries of a failed push before profiles are dropped.")
+}
+
+// applyDefaults sets the default values of the settings left empty, as flags
+// are not registered for clients configured with a list.
+func (c *QueueConfig) applyDefaults() {
+	if c.Capacity <= 0 {
+		c.Capacity = defaultQueueCapacity
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = defaultQueueMinBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultQueueMaxBackoff
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = defaultQueueMaxRetries
+	}
+}
+
 type ScrapeConfig struct {
 	JobName                string                       `yaml:"job_name"`
 	Params                 url.Values                   `yaml:"params,omitempty"`
@@ -75,8 +139,13 @@ type ScrapeConfig struct {
 	ScrapeTimeout          model.Duration               `yaml:"scrape_timeout,omitempty"`
 	Scheme                 string                       `yaml:"scheme,omitempty"`
 	RelabelConfigs         []*relabel.Config            `yaml:"relabel_configs,omitempty"`
+	ProfileRelabelConfigs  []*ProfileRelabelConfig      `yaml:"profile_relabel_configs,omitempty"`
 	ServiceDiscoveryConfig ServiceDiscoveryConfig       `yaml:",inline"`
 	ProfilingConfig        *parcaconfig.ProfilingConfig `yaml:"profiling_config,omitempty"`
+	// DeltaProfiles enables client-side delta computation for cumulative
+	// profiles (memory, mutex and block) that are not scraped using the
+	// seconds parameter. The previous scrape of each target is kept in memory.
+	DeltaProfiles bool `yaml:"delta_profiles,omitempty"`
 
 	HTTPClientConfig commonconfig.HTTPClientConfig `yaml:",inline"`
 }
@@ -131,6 +200,12 @@ func (c *ScrapeConfig) Validate() error {
 		return fmt.Errorf("scrape timeout must be larger scrape to interval for: %v", c.JobName)
 	}
 
+	for _, cfg := range c.ProfileRelabelConfigs {
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("%v: %w", c.JobName, err)
+		}
+	}
+
 	if cfg, ok := c.ProfilingConfig.PprofConfig[pprofProcessCPU]; ok {
 		if *cfg.Enabled && c.ScrapeInterval < model.Duration(time.Second*2) {
 			return fmt.Errorf("%v scrape_interval must be at least 2 seconds in %v", pprofProcessCPU, c.JobName)
@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/pprof/profile"
+)
+
+const (
+	pprofMemory = "memory"
+	pprofMutex  = "mutex"
+	pprofBlock  = "block"
+)
+
+// IsCumulative returns true if the profile type is reported as a cumulative
+// profile by the Go runtime, and therefore needs a delta to be computed.
+func IsCumulative(profileType string) bool {
+	switch profileType {
+	case pprofMemory, pprofMutex, pprofBlock:
+		return true
+	}
+	return false
+}
+
+// deltaSampleTypes returns, for each sample type of the profile, whether it
+// holds cumulative values. For memory profiles, only the alloc_* sample types
+// are cumulative while the inuse_* ones are a snapshot.
+func deltaSampleTypes(profileType string, sampleTypes []*profile.ValueType) []bool {
+	res := make([]bool, len(sampleTypes))
+	for i, st := range sampleTypes {
+		if profileType == pprofMemory {
+			res[i] = strings.HasPrefix(st.Type, "alloc_")
+			continue
+		}
+		res[i] = true
+	}
+	return res
+}
+
+// DeltaProfiler computes delta profiles on the client side, by keeping the
+// previous scrape of a target and subtracting it from the current one.
+type DeltaProfiler struct {
+	profileType string
+
+	mtx  sync.Mutex
+	prev *profile.Profile
+}
+
+// NewDeltaProfiler returns a DeltaProfiler for the given profile type, e.g. memory.
+func NewDeltaProfiler(profileType string) *DeltaProfiler {
+	return &DeltaProfiler{profileType: profileType}
+}
+
+// Delta returns the delta between the given cumulative pprof profile and the
+// previously seen one. The first profile seen, or the first profile after a
+// reset of the counters (e.g. a process restart), is used as the new base:
+// cumulative values are zeroed while non-cumulative values are kept as is.
+// A nil result means there is nothing to push.
+func (d *DeltaProfiler) Delta(b []byte) ([]byte, error) {
+	cur, err := profile.ParseData(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profile: %w", err)
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	delta, err := d.computeDelta(cur)
+	if err != nil {
+		return nil, err
+	}
+	d.prev = cur
+	if len(delta.Sample) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	if err := delta.Write(&buf); err != nil {
+		return nil, fmt.Errorf("failed to write delta profile: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *DeltaProfiler) computeDelta(cur *profile.Profile) (*profile.Profile, error) {
+	cumulative := deltaSampleTypes(d.profileType, cur.SampleType)
+	if d.prev != nil {
+		// An error means profiles are not compatible (e.g. sample types
+		// changed), and negative values mean that counters went backward:
+		// in both cases, the target has most likely been restarted.
+		delta, err := subtract(cur, d.prev, cumulative)
+		if err == nil && !hasNegativeValues(delta) {
+			return delta, nil
+		}
+	}
+	// There is no base to compute the delta from.
+	base := cur.Copy()
+	ratios := make([]float64, len(cumulative))
+	for i, c := range cumulative {
+		if !c {
+			ratios[i] = 1
+		}
+	}
+	scale(base, ratios)
+	return base, nil
+}
+
+// subtract returns cur - prev for every cumulative sample type.
+func subtract(cur, prev *profile.Profile, cumulative []bool) (*profile.Profile, error) {
+	prev = prev.Copy()
+	ratios := make([]float64, len(cumulative))
+	for i, c := range cumulative {
+		if c {
+			ratios[i] = -1
+		}
+	}
+	scale(prev, ratios)
+	delta, err := profile.Merge([]*profile.Profile{cur, prev})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute delta profile: %w", err)
+	}
+	// Merge keeps the oldest timestamp and sums durations.
+	delta.TimeNanos = cur.TimeNanos
+	delta.DurationNanos = cur.DurationNanos
+	if prev.TimeNanos > 0 && cur.TimeNanos > prev.TimeNanos {
+		delta.DurationNanos = cur.TimeNanos - prev.TimeNanos
+	}
+	return delta, nil
+}
+
+// scale multiplies sample values by the given ratios, and removes samples
+// with only zero values. Unlike profile.ScaleN, samples are kept as long as
+// one of their values is not zero, whether it has been scaled or not.
+func scale(p *profile.Profile, ratios []float64) {
+	samples := p.Sample[:0]
+	for _, s := range p.Sample {
+		keep := false
+		for i, v := range s.Value {
+			s.Value[i] = int64(float64(v) * ratios[i])
+			keep = keep || s.Value[i] != 0
+		}
+		if keep {
+			samples = append(samples, s)
+		}
+	}
+	p.Sample = samples
+}
+
+func hasNegativeValues(p *profile.Profile) bool {
+	for _, s := range p.Sample {
+		for _, v := range s.Value {
+			if v < 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+)
+
+func newMemoryProfile(t *testing.T, ts int64, values map[string][]int64) []byte {
+	t.Helper()
+	fns := map[string]*profile.Function{}
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "alloc_objects", Unit: "count"},
+			{Type: "alloc_space", Unit: "bytes"},
+			{Type: "inuse_objects", Unit: "count"},
+			{Type: "inuse_space", Unit: "bytes"},
+		},
+		PeriodType: &profile.ValueType{Type: "space", Unit: "bytes"},
+		TimeNanos:  ts,
+	}
+	for name, v := range values {
+		fn := &profile.Function{ID: uint64(len(fns) + 1), Name: name}
+		fns[name] = fn
+		loc := &profile.Location{ID: fn.ID, Line: []profile.Line{{Function: fn}}}
+		p.Function = append(p.Function, fn)
+		p.Location = append(p.Location, loc)
+		p.Sample = append(p.Sample, &profile.Sample{Location: []*profile.Location{loc}, Value: v})
+	}
+	var buf bytes.Buffer
+	require.NoError(t, p.Write(&buf))
+	return buf.Bytes()
+}
+
+func sampleValues(t *testing.T, b []byte) map[string][]int64 {
+	t.Helper()
+	p, err := profile.ParseData(b)
+	require.NoError(t, err)
+	res := map[string][]int64{}
+	for _, s := range p.Sample {
+		res[s.Location[0].Line[0].Function.Name] = s.Value
+	}
+	return res
+}
+
+func TestDeltaProfiler(t *testing.T) {
+	d := NewDeltaProfiler(pprofMemory)
+
+	// The first profile is the base: only in-use values are kept.
+	b, err := d.Delta(newMemoryProfile(t, 1, map[string][]int64{
+		"foo": {10, 100, 1, 10},
+		"bar": {5, 50, 0, 0},
+	}))
+	require.NoError(t, err)
+	require.Equal(t, map[string][]int64{
+		"foo": {0, 0, 1, 10},
+	}, sampleValues(t, b))
+
+	b, err = d.Delta(newMemoryProfile(t, 2, map[string][]int64{
+		"foo": {15, 150, 2, 20},
+		"bar": {5, 50, 0, 0},
+		"baz": {1, 10, 1, 10},
+	}))
+	require.NoError(t, err)
+	require.Equal(t, map[string][]int64{
+		"foo": {5, 50, 2, 20},
+		"baz": {1, 10, 1, 10},
+	}, sampleValues(t, b))
+
+	// Counters went backward: the profile becomes the new base.
+	b, err = d.Delta(newMemoryProfile(t, 3, map[string][]int64{
+		"foo": {1, 10, 1, 10},
+	}))
+	require.NoError(t, err)
+	require.Equal(t, map[string][]int64{
+		"foo": {0, 0, 1, 10},
+	}, sampleValues(t, b))
+
+	// Nothing changed.
+	b, err = d.Delta(newMemoryProfile(t, 4, map[string][]int64{
+		"foo": {1, 10, 0, 0},
+	}))
+	require.NoError(t, err)
+	require.Nil(t, b)
+}
+
+func TestDeltaProfiler_Mutex(t *testing.T) {
+	d := NewDeltaProfiler(pprofMutex)
+	p := func(ts int64, v ...int64) []byte {
+		return newMemoryProfile(t, ts, map[string][]int64{"foo": v})
+	}
+
+	b, err := d.Delta(p(1, 1, 1, 1, 1))
+	require.NoError(t, err)
+	require.Nil(t, b)
+
+	b, err = d.Delta(p(2, 3, 3, 3, 3))
+	require.NoError(t, err)
+	require.Equal(t, map[string][]int64{"foo": {2, 2, 2, 2}}, sampleValues(t, b))
+}
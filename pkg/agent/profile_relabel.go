@@ -0,0 +1,269 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+
+	googlev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
+	"github.com/grafana/phlare/pkg/pprof"
+	"github.com/grafana/phlare/pkg/slices"
+)
+
+// ProfileRelabelAction is the action to be performed on a scraped profile.
+type ProfileRelabelAction string
+
+const (
+	// ProfileDrop drops the profile if the concatenated source label values match the regex.
+	ProfileDrop ProfileRelabelAction = "drop"
+	// ProfileKeep drops the profile if the concatenated source label values do not match the regex.
+	ProfileKeep ProfileRelabelAction = "keep"
+	// ProfileLabelDrop removes the sample labels whose key matches the regex.
+	ProfileLabelDrop ProfileRelabelAction = "labeldrop"
+	// ProfileDropFrames removes the frames whose function name matches the regex.
+	ProfileDropFrames ProfileRelabelAction = "dropframes"
+	// ProfileTruncateStacks keeps at most max_frames frames of each stack, starting from the leaf.
+	ProfileTruncateStacks ProfileRelabelAction = "truncatestacks"
+	// ProfileDropSamples removes the samples whose value is lower than min_value.
+	ProfileDropSamples ProfileRelabelAction = "dropsamples"
+)
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (a *ProfileRelabelAction) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	switch act := ProfileRelabelAction(strings.ToLower(s)); act {
+	case ProfileDrop, ProfileKeep, ProfileLabelDrop, ProfileDropFrames, ProfileTruncateStacks, ProfileDropSamples:
+		*a = act
+		return nil
+	}
+	return fmt.Errorf("unknown profile relabel action %q", s)
+}
+
+// DefaultProfileRelabelConfig is the default profile relabel configuration.
+var DefaultProfileRelabelConfig = ProfileRelabelConfig{
+	SourceLabels: model.LabelNames{model.MetricNameLabel},
+	Separator:    ";",
+	Regex:        relabel.MustNewRegexp("(.*)"),
+}
+
+// ProfileRelabelConfig configures a processing step applied to scraped
+// profiles before they are pushed.
+type ProfileRelabelConfig struct {
+	// A list of target labels from which values are taken and concatenated
+	// with the configured separator in order. Used by drop and keep.
+	SourceLabels model.LabelNames `yaml:"source_labels,flow,omitempty"`
+	// Separator is the string between concatenated values from the source labels.
+	Separator string `yaml:"separator,omitempty"`
+	// Regex against which the concatenated source labels, the sample label
+	// keys or the function names are matched, depending on the action.
+	Regex relabel.Regexp `yaml:"regex,omitempty"`
+	// MaxFrames is the maximum number of frames kept by truncatestacks.
+	MaxFrames int `yaml:"max_frames,omitempty"`
+	// SampleType is the sample type whose value is compared by dropsamples.
+	// If empty, a sample is dropped only if all of its values are lower than min_value.
+	SampleType string `yaml:"sample_type,omitempty"`
+	// MinValue is the threshold under which dropsamples drops a sample.
+	MinValue int64 `yaml:"min_value,omitempty"`
+	// Action to perform.
+	Action ProfileRelabelAction `yaml:"action"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *ProfileRelabelConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultProfileRelabelConfig
+	type plain ProfileRelabelConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+func (c *ProfileRelabelConfig) Validate() error {
+	if c.Regex.Regexp == nil {
+		c.Regex = relabel.MustNewRegexp("")
+	}
+	switch c.Action {
+	case "":
+		return fmt.Errorf("profile relabel action cannot be empty")
+	case ProfileTruncateStacks:
+		if c.MaxFrames <= 0 {
+			return fmt.Errorf("profile relabel configuration for %s action requires a positive 'max_frames' value", c.Action)
+		}
+	case ProfileDropSamples:
+		if c.MinValue <= 0 {
+			return fmt.Errorf("profile relabel configuration for %s action requires a positive 'min_value' value", c.Action)
+		}
+	}
+	return nil
+}
+
+// keepProfile returns false if the profile of a target with the given labels
+// is dropped by one of the configurations. It is evaluated when the targets
+// are built, so that the dropped profiles are not scraped.
+func keepProfile(lset labels.Labels, cfgs []*ProfileRelabelConfig) bool {
+	values := make([]string, 0, 4)
+	for _, cfg := range cfgs {
+		if cfg.Action != ProfileDrop && cfg.Action != ProfileKeep {
+			continue
+		}
+		values = values[:0]
+		for _, ln := range cfg.SourceLabels {
+			values = append(values, lset.Get(string(ln)))
+		}
+		match := cfg.Regex.MatchString(strings.Join(values, cfg.Separator))
+		if (cfg.Action == ProfileDrop && match) || (cfg.Action == ProfileKeep && !match) {
+			return false
+		}
+	}
+	return true
+}
+
+// relabelProfile applies the profile relabel configurations rewriting the
+// samples to the raw pprof profile of a target, drop and keep are applied to
+// the targets by keepProfile. It returns nil if no samples are left.
+func relabelProfile(b []byte, cfgs []*ProfileRelabelConfig) ([]byte, error) {
+	if !rewritesSamples(cfgs) {
+		return b, nil
+	}
+	p, err := pprof.RawFromBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profile: %w", err)
+	}
+	defer p.Close()
+
+	for _, cfg := range cfgs {
+		switch cfg.Action {
+		case ProfileLabelDrop:
+			dropSampleLabels(p.Profile, cfg.Regex)
+		case ProfileDropFrames:
+			dropFrames(p.Profile, cfg.Regex)
+		case ProfileTruncateStacks:
+			truncateStacks(p.Profile, cfg.MaxFrames)
+		case ProfileDropSamples:
+			dropSamples(p.Profile, cfg.SampleType, cfg.MinValue)
+		}
+	}
+	if len(p.Sample) == 0 {
+		return nil, nil
+	}
+	removeUnusedLocations(p.Profile)
+
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("failed to write profile: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// rewritesSamples returns true if one of the configurations rewrites the
+// samples of the profiles.
+func rewritesSamples(cfgs []*ProfileRelabelConfig) bool {
+	for _, cfg := range cfgs {
+		if cfg.Action != ProfileDrop && cfg.Action != ProfileKeep {
+			return true
+		}
+	}
+	return false
+}
+
+func dropSampleLabels(p *googlev1.Profile, regex relabel.Regexp) {
+	for _, s := range p.Sample {
+		s.Label = slices.RemoveInPlace(s.Label, func(l *googlev1.Label, _ int) bool {
+			return regex.MatchString(p.StringTable[l.Key])
+		})
+	}
+}
+
+func dropFrames(p *googlev1.Profile, regex relabel.Regexp) {
+	functions := make(map[uint64]bool, len(p.Function))
+	for _, fn := range p.Function {
+		functions[fn.Id] = regex.MatchString(p.StringTable[fn.Name])
+	}
+	// A location is dropped if any of its lines (inlined functions included) matches.
+	dropped := make(map[uint64]struct{})
+	for _, loc := range p.Location {
+		for _, line := range loc.Line {
+			if functions[line.FunctionId] {
+				dropped[loc.Id] = struct{}{}
+				break
+			}
+		}
+	}
+	if len(dropped) == 0 {
+		return
+	}
+	p.Sample = slices.RemoveInPlace(p.Sample, func(s *googlev1.Sample, _ int) bool {
+		s.LocationId = slices.RemoveInPlace(s.LocationId, func(id uint64, _ int) bool {
+			_, ok := dropped[id]
+			return ok
+		})
+		return len(s.LocationId) == 0
+	})
+}
+
+func truncateStacks(p *googlev1.Profile, maxFrames int) {
+	for _, s := range p.Sample {
+		// Locations are ordered from the leaf to the root.
+		if len(s.LocationId) > maxFrames {
+			s.LocationId = s.LocationId[:maxFrames]
+		}
+	}
+}
+
+func dropSamples(p *googlev1.Profile, sampleType string, minValue int64) {
+	idx := -1
+	if sampleType != "" {
+		for i, st := range p.SampleType {
+			if p.StringTable[st.Type] == sampleType {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return
+		}
+	}
+	p.Sample = slices.RemoveInPlace(p.Sample, func(s *googlev1.Sample, _ int) bool {
+		if idx >= 0 {
+			return s.Value[idx] < minValue
+		}
+		for _, v := range s.Value {
+			if v >= minValue {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// removeUnusedLocations removes the locations and functions which are not
+// referenced by any sample anymore.
+func removeUnusedLocations(p *googlev1.Profile) {
+	locations := make(map[uint64]struct{}, len(p.Location))
+	for _, s := range p.Sample {
+		for _, id := range s.LocationId {
+			locations[id] = struct{}{}
+		}
+	}
+	functions := make(map[uint64]struct{}, len(p.Function))
+	p.Location = slices.RemoveInPlace(p.Location, func(loc *googlev1.Location, _ int) bool {
+		if _, ok := locations[loc.Id]; !ok {
+			return true
+		}
+		for _, line := range loc.Line {
+			functions[line.FunctionId] = struct{}{}
+		}
+		return false
+	})
+	p.Function = slices.RemoveInPlace(p.Function, func(fn *googlev1.Function, _ int) bool {
+		_, ok := functions[fn.Id]
+		return !ok
+	})
+}
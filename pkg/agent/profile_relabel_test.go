@@ -0,0 +1,167 @@
+package agent
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// newCPUProfile creates a profile with one sample per stack, stacks are
+// given from the root to the leaf, separated by semicolons.
+func newCPUProfile(t *testing.T, stacks map[string]int64) []byte {
+	t.Helper()
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+	}
+	locations := map[string]*profile.Location{}
+	for stack, v := range stacks {
+		s := &profile.Sample{
+			Value:    []int64{v},
+			Label:    map[string][]string{"span_id": {stack}, "handler": {"/api"}},
+			NumLabel: map[string][]int64{},
+		}
+		frames := strings.Split(stack, ";")
+		for i := len(frames) - 1; i >= 0; i-- {
+			loc, ok := locations[frames[i]]
+			if !ok {
+				fn := &profile.Function{ID: uint64(len(p.Function) + 1), Name: frames[i]}
+				loc = &profile.Location{ID: fn.ID, Line: []profile.Line{{Function: fn}}}
+				p.Function = append(p.Function, fn)
+				p.Location = append(p.Location, loc)
+				locations[frames[i]] = loc
+			}
+			s.Location = append(s.Location, loc)
+		}
+		p.Sample = append(p.Sample, s)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, p.Write(&buf))
+	return buf.Bytes()
+}
+
+func stacks(t *testing.T, b []byte) map[string]int64 {
+	t.Helper()
+	p, err := profile.ParseData(b)
+	require.NoError(t, err)
+	res := map[string]int64{}
+	for _, s := range p.Sample {
+		frames := make([]string, 0, len(s.Location))
+		for i := len(s.Location) - 1; i >= 0; i-- {
+			frames = append(frames, s.Location[i].Line[0].Function.Name)
+		}
+		res[strings.Join(frames, ";")] += s.Value[0]
+	}
+	return res
+}
+
+func TestProfileRelabelConfig_Unmarshal(t *testing.T) {
+	var cfgs []*ProfileRelabelConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`
+- action: drop
+  regex: goroutine
+- action: labeldrop
+  regex: span_id
+- action: truncatestacks
+  max_frames: 2
+`), &cfgs))
+	require.Len(t, cfgs, 3)
+	require.Equal(t, ProfileDrop, cfgs[0].Action)
+	require.Equal(t, DefaultProfileRelabelConfig.SourceLabels, cfgs[0].SourceLabels)
+	require.Equal(t, 2, cfgs[2].MaxFrames)
+
+	require.Error(t, yaml.Unmarshal([]byte(`- action: truncatestacks`), &cfgs))
+	require.Error(t, yaml.Unmarshal([]byte(`- action: unknown`), &cfgs))
+}
+
+func TestRelabelProfile(t *testing.T) {
+	input := newCPUProfile(t, map[string]int64{
+		"main;runtime.gcBgMarkWorker;gcDrain": 10,
+		"main;handler;db.Query;syscall":       100,
+		"main;handler;json.Marshal":           1,
+	})
+
+	for _, tc := range []struct {
+		name     string
+		cfgs     []*ProfileRelabelConfig
+		expected map[string]int64
+	}{
+		{
+			name: "drop and keep only",
+			cfgs: []*ProfileRelabelConfig{
+				{Action: ProfileKeep, SourceLabels: DefaultProfileRelabelConfig.SourceLabels, Regex: relabel.MustNewRegexp("memory")},
+			},
+			expected: map[string]int64{
+				"main;runtime.gcBgMarkWorker;gcDrain": 10,
+				"main;handler;db.Query;syscall":       100,
+				"main;handler;json.Marshal":           1,
+			},
+		},
+		{
+			name: "drop frames",
+			cfgs: []*ProfileRelabelConfig{
+				{Action: ProfileDropFrames, Regex: relabel.MustNewRegexp("runtime\\..*|gcDrain")},
+			},
+			expected: map[string]int64{
+				"main":                          10,
+				"main;handler;db.Query;syscall": 100,
+				"main;handler;json.Marshal":     1,
+			},
+		},
+		{
+			name: "truncate stacks",
+			cfgs: []*ProfileRelabelConfig{
+				{Action: ProfileTruncateStacks, MaxFrames: 2},
+			},
+			expected: map[string]int64{
+				"runtime.gcBgMarkWorker;gcDrain": 10,
+				"db.Query;syscall":               100,
+				"handler;json.Marshal":           1,
+			},
+		},
+		{
+			name: "drop samples",
+			cfgs: []*ProfileRelabelConfig{
+				{Action: ProfileDropSamples, SampleType: "cpu", MinValue: 10},
+			},
+			expected: map[string]int64{
+				"main;runtime.gcBgMarkWorker;gcDrain": 10,
+				"main;handler;db.Query;syscall":       100,
+			},
+		},
+		{
+			name: "drop all samples",
+			cfgs: []*ProfileRelabelConfig{
+				{Action: ProfileDropSamples, MinValue: 1000},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := relabelProfile(input, tc.cfgs)
+			require.NoError(t, err)
+			if tc.expected == nil {
+				require.Nil(t, b)
+				return
+			}
+			require.Equal(t, tc.expected, stacks(t, b))
+		})
+	}
+}
+
+func TestRelabelProfile_LabelDrop(t *testing.T) {
+	input := newCPUProfile(t, map[string]int64{"main;handler": 1})
+	b, err := relabelProfile(input, []*ProfileRelabelConfig{
+		{Action: ProfileLabelDrop, Regex: relabel.MustNewRegexp("span_.*")},
+	})
+	require.NoError(t, err)
+	p, err := profile.ParseData(b)
+	require.NoError(t, err)
+	require.Len(t, p.Sample, 1)
+	require.Equal(t, map[string][]string{"handler": {"/api"}}, p.Sample[0].Label)
+}
@@ -230,7 +230,8 @@ func (tg *TargetGroup) TargetsFromGroup(group *targetgroup.Group) ([]*Target, []
 		}
 
 		lset := labels.New(lbls...)
-		lsets := scrape.LabelsByProfiles(lset, tg.config.ProfilingConfig)
+		profilingConfig, ports := profilingConfigFromAnnotations(lset, tg.config.ProfilingConfig)
+		lsets := scrape.LabelsByProfiles(lset, profilingConfig)
 
 		for _, lset := range lsets {
 			var profType string
@@ -239,10 +240,28 @@ func (tg *TargetGroup) TargetsFromGroup(group *targetgroup.Group) ([]*Target, []
 					profType = label.Value
 				}
 			}
+			// dropReason is the reason of a target dropped for an invalid
+			// configuration rather than by the relabeling.
+			var dropReason error
+			if port, ok := ports[profType]; ok {
+				if portLset, err := withPort(lset, port); err != nil {
+					dropReason = err
+				} else {
+					lset = portLset
+				}
+			}
 			lbls, origLabels, err := populateLabels(lset, tg.config)
 			if err != nil {
 				return nil, nil, fmt.Errorf("instance %d in group %s: %s", i, group, err)
 			}
+			if lbls != nil && dropReason != nil {
+				level.Warn(tg.logger).Log("msg", "dropping target", "job", tg.jobName, "target", lbls.String(), "err", dropReason)
+				lbls = nil
+			}
+			// The profiles dropped by the profile relabeling are not scraped at all.
+			if lbls != nil && !keepProfile(lbls, tg.config.ProfileRelabelConfigs) {
+				lbls = nil
+			}
 
 			// The scrape interval and timeout labels are set to the config's values initially,
 			// so whether changed via relabeling or not, they'll exist and hold the correct values
@@ -278,7 +297,6 @@ func (tg *TargetGroup) TargetsFromGroup(group *targetgroup.Group) ([]*Target, []
 				}
 				droppedTargets = append(droppedTargets, &Target{
 					Target:               scrape.NewTarget(lbls, origLabels, params),
-					tenantID:             tg.tenantID,
 					labels:               lbls,
 					scrapeClient:         tg.scrapeClient,
 					pusherClientProvider: tg.pusherClientProvider,
@@ -286,28 +304,40 @@ func (tg *TargetGroup) TargetsFromGroup(group *targetgroup.Group) ([]*Target, []
 					timeout:              timeout,
 					health:               agentv1v1.Health_HEALTH_UNSPECIFIED,
 					logger:               tg.logger,
+					lastError:            dropReason,
 				})
 				continue
 			}
 			if lbls != nil || origLabels != nil {
-				params := tg.config.Params
-				if params == nil {
-					params = url.Values{}
+				// Params are copied as they are modified per target.
+				params := url.Values{}
+				for k, v := range tg.config.Params {
+					params[k] = append([]string(nil), v...)
 				}
 
-				if pcfg, found := tg.config.ProfilingConfig.PprofConfig[profType]; found && pcfg.Delta {
+				var (
+					delta         bool
+					deltaProfiler *DeltaProfiler
+				)
+				if pcfg, found := profilingConfig.PprofConfig[profType]; found && pcfg.Delta {
 					params.Add("seconds", strconv.Itoa(int(interval/time.Second)-1))
+					delta = IsCumulative(profType)
+				} else if tg.config.DeltaProfiles && IsCumulative(profType) {
+					delta = true
+					deltaProfiler = NewDeltaProfiler(profType)
 				}
 				targets = append(targets, &Target{
-					Target:               scrape.NewTarget(lbls, origLabels, params),
-					labels:               lbls,
-					tenantID:             tg.tenantID,
-					scrapeClient:         tg.scrapeClient,
-					pusherClientProvider: tg.pusherClientProvider,
-					interval:             interval,
-					timeout:              timeout,
-					health:               agentv1v1.Health_HEALTH_UNSPECIFIED,
-					logger:               tg.logger,
+					Target:                scrape.NewTarget(lbls, origLabels, params),
+					labels:                lbls,
+					scrapeClient:          tg.scrapeClient,
+					pusherClientProvider:  tg.pusherClientProvider,
+					interval:              interval,
+					timeout:               timeout,
+					health:                agentv1v1.Health_HEALTH_UNSPECIFIED,
+					logger:                tg.logger,
+					delta:                 delta,
+					deltaProfiler:         deltaProfiler,
+					profileRelabelConfigs: tg.config.ProfileRelabelConfigs,
 				})
 			}
 		}
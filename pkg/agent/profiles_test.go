@@ -1,9 +1,11 @@
 package agent
 
 import (
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/go-kit/log"
 	"github.com/parca-dev/parca/pkg/config"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
@@ -460,6 +462,40 @@ func TestTargetGroup_targetsFromGroup(t *testing.T) {
 			wantDropped: 0,
 			wantErr:     false,
 		},
+		{
+			name: "profile type dropped by the profile relabeling",
+			tg: &TargetGroup{
+				jobName: "job",
+				config: ScrapeConfig{
+					ScrapeTimeout:  model.Duration(10 * time.Minute),
+					ScrapeInterval: model.Duration(time.Minute),
+					ProfilingConfig: &config.ProfilingConfig{
+						PprofConfig: config.PprofConfig{
+							pprofProcessCPU: &config.PprofProfilingConfig{
+								Enabled: trueValue(),
+							},
+							pprofMemory: &config.PprofProfilingConfig{
+								Enabled: trueValue(),
+							},
+						},
+					},
+					ProfileRelabelConfigs: []*ProfileRelabelConfig{
+						{Action: ProfileDrop, SourceLabels: DefaultProfileRelabelConfig.SourceLabels, Separator: ";", Regex: relabel.MustNewRegexp("process_cpu")},
+					},
+				},
+			},
+			group: &targetgroup.Group{
+				Targets: []model.LabelSet{
+					{
+						model.AddressLabel:               "localhost:9000",
+						phlaremodel.LabelNameServiceName: "svc",
+					},
+				},
+			},
+			wantTargets: 1,
+			wantDropped: 1,
+			wantErr:     false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -475,6 +511,79 @@ func TestTargetGroup_targetsFromGroup(t *testing.T) {
 	}
 }
 
+func TestTargetGroup_targetsFromGroupAnnotations(t *testing.T) {
+	cfg := ScrapeConfig{
+		JobName: "job",
+		ProfilingConfig: &config.ProfilingConfig{
+			PprofConfig: config.PprofConfig{
+				pprofMutex: &config.PprofProfilingConfig{
+					Enabled: falseValue(),
+				},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+	tg := &TargetGroup{jobName: "job", config: cfg, logger: log.NewNopLogger()}
+
+	targets, dropped, err := tg.TargetsFromGroup(&targetgroup.Group{
+		Targets: []model.LabelSet{
+			{
+				model.AddressLabel:                       "10.0.0.1:8080",
+				phlaremodel.LabelNameServiceName:         "annotated",
+				podAnnotationPrefix + "cpu_scrape":       "true",
+				podAnnotationPrefix + "cpu_port":         "6060",
+				podAnnotationPrefix + "memory_scrape":    "false",
+				podAnnotationPrefix + "goroutine_scrape": "false",
+				podAnnotationPrefix + "block_scrape":     "false",
+				podAnnotationPrefix + "mutex_scrape":     "true",
+				podAnnotationPrefix + "mutex_path":       "/custom/mutex",
+			},
+			{
+				model.AddressLabel:               "10.0.0.2:8080",
+				phlaremodel.LabelNameServiceName: "default",
+			},
+			{
+				model.AddressLabel:                       "10.0.0.3:8080",
+				phlaremodel.LabelNameServiceName:         "invalid",
+				podAnnotationPrefix + "cpu_port":         "99999",
+				podAnnotationPrefix + "memory_scrape":    "false",
+				podAnnotationPrefix + "goroutine_scrape": "false",
+				podAnnotationPrefix + "block_scrape":     "false",
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, dropped, 1)
+	require.Equal(t, "http://10.0.0.3:8080/debug/pprof/profile", dropped[0].URL().String())
+	require.EqualError(t, dropped[0].LastError(), `invalid port annotation "99999"`)
+
+	urls := map[string][]string{}
+	for _, target := range targets {
+		svc := target.Labels().Get(phlaremodel.LabelNameServiceName)
+		urls[svc] = append(urls[svc], target.URL().String())
+	}
+	for _, u := range urls {
+		sort.Strings(u)
+	}
+	require.Equal(t, map[string][]string{
+		"annotated": {
+			"http://10.0.0.1:6060/debug/pprof/profile?seconds=9",
+			"http://10.0.0.1:8080/custom/mutex",
+		},
+		"default": {
+			"http://10.0.0.2:8080/debug/pprof/allocs",
+			"http://10.0.0.2:8080/debug/pprof/block",
+			"http://10.0.0.2:8080/debug/pprof/goroutine",
+			"http://10.0.0.2:8080/debug/pprof/profile?seconds=9",
+		},
+	}, urls)
+}
+
+func falseValue() *bool {
+	b := false
+	return &b
+}
+
 func trueValue() *bool {
 	b := true
 	return &b
@@ -25,7 +25,7 @@ import (
 	pushv1 "github.com/grafana/phlare/api/gen/proto/go/push/v1"
 	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
 	"github.com/grafana/phlare/pkg/agent/scrape"
-	"github.com/grafana/phlare/pkg/tenant"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
 )
 
 var (
@@ -34,9 +34,8 @@ var (
 )
 
 type TargetGroup struct {
-	jobName  string
-	config   ScrapeConfig
-	tenantID string
+	jobName string
+	config  ScrapeConfig
 
 	logger               log.Logger
 	scrapeClient         *http.Client
@@ -48,7 +47,7 @@ type TargetGroup struct {
 	droppedTargets []*Target
 }
 
-func NewTargetGroup(ctx context.Context, jobName string, cfg ScrapeConfig, pusherClientProvider PusherClientProvider, tenantID string, logger log.Logger) *TargetGroup {
+func NewTargetGroup(ctx context.Context, jobName string, cfg ScrapeConfig, pusherClientProvider PusherClientProvider, logger log.Logger) *TargetGroup {
 	scrapeClient, err := commonconfig.NewClientFromConfig(cfg.HTTPClientConfig, cfg.JobName)
 	if err != nil {
 		level.Error(logger).Log("msg", "Error creating HTTP client", "err", err)
@@ -62,7 +61,6 @@ func NewTargetGroup(ctx context.Context, jobName string, cfg ScrapeConfig, pushe
 		pusherClientProvider: pusherClientProvider,
 		ctx:                  ctx,
 		activeTargets:        map[uint64]*Target{},
-		tenantID:             tenantID,
 	}
 }
 
@@ -112,7 +110,6 @@ Outer:
 type Target struct {
 	*scrape.Target
 	labels             labels.Labels
-	tenantID           string
 	mtx                sync.RWMutex
 	lastError          error
 	lastScrape         time.Time
@@ -128,6 +125,13 @@ type Target struct {
 	logger            log.Logger
 	interval, timeout time.Duration
 	cancel            context.CancelFunc
+
+	// delta is true when the target pushes delta profiles, which must not be
+	// processed again by the ingester.
+	delta         bool
+	deltaProfiler *DeltaProfiler
+
+	profileRelabelConfigs []*ProfileRelabelConfig
 }
 
 func (t *Target) start(ctx context.Context) {
@@ -193,6 +197,26 @@ func (t *Target) scrape(ctx context.Context) {
 	t.lastScrapeDuration = time.Since(start)
 	t.lastError = nil
 	t.lastScrape = start
+	if t.deltaProfiler != nil {
+		var err error
+		if b, err = t.deltaProfiler.Delta(b); err != nil {
+			level.Error(t.logger).Log("msg", "computing delta profile failed", "target", t.Labels().String(), "err", err)
+			return
+		}
+		if len(b) == 0 {
+			return
+		}
+	}
+	if len(t.profileRelabelConfigs) > 0 {
+		var err error
+		if b, err = relabelProfile(b, t.profileRelabelConfigs); err != nil {
+			level.Error(t.logger).Log("msg", "relabeling profile failed", "target", t.Labels().String(), "err", err)
+			return
+		}
+		if len(b) == 0 {
+			return
+		}
+	}
 	// todo retry strategy
 	req := &pushv1.PushRequest{}
 	series := &pushv1.RawProfileSeries{
@@ -207,18 +231,18 @@ func (t *Target) scrape(ctx context.Context) {
 			Value: l.Value,
 		})
 	}
+	if t.delta {
+		series.Labels = append(series.Labels, &typesv1.LabelPair{
+			Name:  phlaremodel.LabelNameDelta,
+			Value: "false",
+		})
+	}
 	series.Samples = []*pushv1.RawSample{
 		{
 			RawProfile: b,
 		},
 	}
 	req.Series = append(req.Series, series)
-	// Inject the tenant ID into the context.
-	// With a http pusher the interceptor will add the tenant ID to the request headers.
-	// When directly pushing distributors, the tenant ID will already be in the context.
-	if t.tenantID != "" {
-		ctx = tenant.InjectTenantID(ctx, t.tenantID)
-	}
 	if _, err := t.pusherClientProvider().Push(ctx, connect.NewRequest(req)); err != nil {
 		level.Error(t.logger).Log("msg", "push failed", "labels", t.Labels().String(), "err", err)
 	}
@@ -33,6 +33,7 @@ import (
 	"github.com/grafana/phlare/api/gen/proto/go/storegateway/v1/storegatewayv1connect"
 	"github.com/grafana/phlare/api/openapiv2"
 	"github.com/grafana/phlare/pkg/agent"
+	"github.com/grafana/phlare/pkg/deleterequests"
 	"github.com/grafana/phlare/pkg/distributor"
 	"github.com/grafana/phlare/pkg/frontend"
 	"github.com/grafana/phlare/pkg/frontend/frontendpb/frontendpbconnect"
@@ -42,6 +43,7 @@ import (
 	"github.com/grafana/phlare/pkg/scheduler"
 	"github.com/grafana/phlare/pkg/scheduler/schedulerpb/schedulerpbconnect"
 	"github.com/grafana/phlare/pkg/storegateway"
+	"github.com/grafana/phlare/pkg/tenantdeletion"
 	"github.com/grafana/phlare/pkg/util"
 	"github.com/grafana/phlare/pkg/util/gziphandler"
 	"github.com/grafana/phlare/pkg/validation/exporter"
@@ -259,6 +261,19 @@ func (a *API) RegisterStoreGateway(svc *storegateway.StoreGateway) {
 	a.RegisterRoute("/store-gateway/tenant/{tenant}/blocks", http.HandlerFunc(svc.BlocksHandler), false, true, "GET")
 }
 
+// RegisterTenantDeletion registers the endpoints associated with the tenant deletion.
+func (a *API) RegisterTenantDeletion(d *tenantdeletion.Deleter) {
+	a.RegisterRoute("/tenant-deletion/delete_tenant", http.HandlerFunc(d.DeleteTenantHandler), true, true, "POST")
+	a.RegisterRoute("/tenant-deletion/delete_tenant_status", http.HandlerFunc(d.DeleteTenantStatusHandler), true, true, "GET")
+}
+
+// RegisterDeleteRequests registers the endpoints associated with the delete requests.
+func (a *API) RegisterDeleteRequests(d *deleterequests.DeleteRequests) {
+	a.RegisterRoute("/delete-requests", http.HandlerFunc(d.AddHandler), true, true, "POST")
+	a.RegisterRoute("/delete-requests", http.HandlerFunc(d.ListHandler), true, true, "GET")
+	a.RegisterRoute("/delete-requests", http.HandlerFunc(d.CancelHandler), true, true, "DELETE")
+}
+
 // RegisterQueryFrontend registers the endpoints associated with the query frontend.
 func (a *API) RegisterQueryFrontend(frontendSvc *frontend.Frontend) {
 	frontendpbconnect.RegisterFrontendForQuerierHandler(a.server.HTTP, frontendSvc, a.grpcAuthMiddleware)
@@ -0,0 +1,423 @@
+package deleterequests
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
+	"github.com/grafana/phlare/pkg/phlaredb"
+	"github.com/grafana/phlare/pkg/phlaredb/block"
+	"github.com/grafana/phlare/pkg/phlaredb/bucket"
+	"github.com/grafana/phlare/pkg/phlaredb/tombstones"
+	"github.com/grafana/phlare/pkg/tenant"
+	"github.com/grafana/phlare/pkg/util"
+)
+
+type Config struct {
+	ProcessingInterval time.Duration `yaml:"processing_interval" category:"advanced"`
+	ProcessingDelay    time.Duration `yaml:"processing_delay" category:"advanced"`
+	CacheTTL           time.Duration `yaml:"cache_ttl" category:"advanced"`
+	BlockDeletionDelay time.Duration `yaml:"block_deletion_delay" category:"advanced"`
+	DataDir            string        `yaml:"data_dir" category:"advanced"`
+}
+
+// RegisterFlags registers the flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.DurationVar(&cfg.ProcessingInterval, "delete-requests.processing-interval", 15*time.Minute, "How frequently the pending delete requests are processed: the deleted profiles are removed from the blocks in the object storage.")
+	f.DurationVar(&cfg.ProcessingDelay, "delete-requests.processing-delay", 4*time.Hour, "Time to wait after a delete request is created, or after the end of its time range if later, before processing it. It must be long enough for ingesters to ship the blocks of the time range to the object storage.")
+	f.DurationVar(&cfg.CacheTTL, "delete-requests.cache-ttl", time.Minute, "How long ingesters and store-gateways cache the delete requests of a tenant. A new delete request is applied to the queries at most after this period.")
+	f.DurationVar(&cfg.BlockDeletionDelay, "delete-requests.block-deletion-delay", time.Hour, "Time to wait before removing a rewritten block from the object storage. The block is marked for deletion in the meantime: it must be long enough for store-gateways to load the new block and unload the rewritten one, and for the queries reading it to complete.")
+	f.StringVar(&cfg.DataDir, "delete-requests.data-dir", "./data/delete-requests", "Directory to store the blocks rewritten by the delete requests processing. The directory is cleaned up after each block.")
+}
+
+// DeleteRequests records the requests to delete profiles, as tombstones
+// applied at query time, and asynchronously removes the deleted profiles
+// from the blocks in the object storage.
+type DeleteRequests struct {
+	services.Service
+
+	cfg    Config
+	bucket phlareobj.Bucket
+	cache  *tombstones.Cache
+	logger log.Logger
+
+	rewrittenBlocks prometheus.Counter
+	deletedProfiles prometheus.Counter
+	failures        prometheus.Counter
+}
+
+// New creates the delete requests service. The cache is invalidated when
+// the delete requests of a tenant change.
+func New(cfg Config, bkt phlareobj.Bucket, cache *tombstones.Cache, logger log.Logger, reg prometheus.Registerer) *DeleteRequests {
+	d := &DeleteRequests{
+		cfg:    cfg,
+		bucket: bkt,
+		cache:  cache,
+		logger: logger,
+		rewrittenBlocks: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "pyroscope_delete_requests_rewritten_blocks_total",
+			Help: "Total number of blocks rewritten in the object storage by delete requests.",
+		}),
+		deletedProfiles: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "pyroscope_delete_requests_deleted_profiles_total",
+			Help: "Total number of profiles removed from the object storage by delete requests.",
+		}),
+		failures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "pyroscope_delete_requests_processing_failures_total",
+			Help: "Total number of tenants for which the delete requests processing failed.",
+		}),
+	}
+	d.Service = services.NewTimerService(cfg.ProcessingInterval, nil, d.iteration, nil)
+	return d
+}
+
+func (d *DeleteRequests) iteration(ctx context.Context) error {
+	tenants, err := bucket.ListUsers(ctx, d.bucket)
+	if err != nil {
+		level.Error(d.logger).Log("msg", "failed to list tenants", "err", err)
+		return nil
+	}
+	for _, tenantID := range tenants {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err = d.deleteMarkedBlocks(ctx, tenantID); err != nil {
+			d.failures.Inc()
+			level.Error(d.logger).Log("msg", "failed to delete the blocks marked for deletion", "tenant", tenantID, "err", err)
+		}
+		if err = d.processTenant(ctx, tenantID); err != nil {
+			d.failures.Inc()
+			level.Error(d.logger).Log("msg", "failed to process delete requests", "tenant", tenantID, "err", err)
+		}
+	}
+	return nil
+}
+
+func (d *DeleteRequests) processTenant(ctx context.Context, tenantID string) error {
+	all, err := tombstones.List(ctx, d.bucket, tenantID)
+	if err != nil {
+		return err
+	}
+	var pending tombstones.Tombstones
+	for _, t := range all {
+		if !t.Processed() && d.ready(t) {
+			pending = append(pending, t)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	level.Info(d.logger).Log("msg", "processing delete requests", "tenant", tenantID, "requests", len(pending))
+	blocksBucket := phlareobj.NewPrefixedBucket(d.bucket, tenantID+"/phlaredb")
+	blocks, err := listBlocks(ctx, blocksBucket)
+	if err != nil {
+		return err
+	}
+	var metas []*block.Meta
+	superseded := make(map[ulid.ULID]struct{})
+	for _, id := range blocks {
+		meta, err := block.DownloadMeta(ctx, d.logger, blocksBucket, id)
+		if err != nil {
+			if blocksBucket.IsObjNotFoundErr(errors.Cause(err)) {
+				// A partial block, or a block being deleted.
+				continue
+			}
+			return err
+		}
+		for _, p := range meta.Compaction.Parents {
+			superseded[p.ULID] = struct{}{}
+		}
+		metas = append(metas, &meta)
+	}
+	for _, meta := range metas {
+		mark, err := block.ReadDeletionMark(ctx, blocksBucket, meta.ULID)
+		if err != nil {
+			return err
+		}
+		if mark != nil {
+			continue
+		}
+		if _, ok := superseded[meta.ULID]; ok {
+			// The block was rewritten, but not marked for deletion.
+			if err = block.MarkForDeletion(ctx, d.logger, blocksBucket, meta.ULID, deletionMarkDetails); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = d.rewriteBlock(ctx, blocksBucket, tenantID, meta, pending); err != nil {
+			return errors.Wrapf(err, "rewrite block %s", meta.ULID)
+		}
+	}
+
+	now := time.Now().Unix()
+	for _, t := range pending {
+		// Do not restore the requests cancelled in the meantime.
+		current, err := tombstones.Read(ctx, d.bucket, tenantID, t.ID)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			continue
+		}
+		current.ProcessedAt = now
+		if err = tombstones.Write(ctx, d.bucket, tenantID, current); err != nil {
+			return err
+		}
+	}
+	d.cache.Invalidate(tenantID)
+	level.Info(d.logger).Log("msg", "delete requests processed", "tenant", tenantID, "requests", len(pending))
+	return nil
+}
+
+// ready reports whether the delete request can be processed: the blocks
+// holding the profiles it deletes must have been shipped.
+func (d *DeleteRequests) ready(t *tombstones.Tombstone) bool {
+	since := time.Unix(t.CreatedAt, 0)
+	if end := t.EndTime.Time(); end.After(since) {
+		since = end
+	}
+	return time.Since(since) >= d.cfg.ProcessingDelay
+}
+
+const deletionMarkDetails = "rewritten by delete requests"
+
+// rewriteBlock replaces the block with a copy without the deleted profiles.
+// The new block lists the block in its compaction parents, and the block is
+// marked for deletion: store-gateways skip both the superseded blocks and
+// the marked ones, so that the profiles are never read twice.
+func (d *DeleteRequests) rewriteBlock(ctx context.Context, bkt phlareobj.Bucket, tenantID string, meta *block.Meta, ts tombstones.Tombstones) error {
+	if len(ts.ForRange(meta.MinTime, meta.MaxTime)) == 0 {
+		return nil
+	}
+
+	id := meta.ULID
+	dir := filepath.Join(d.cfg.DataDir, tenantID)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			level.Warn(d.logger).Log("msg", "failed to remove the delete requests data", "dir", dir, "err", err)
+		}
+	}()
+	src := filepath.Join(dir, id.String())
+	if err := block.Download(ctx, d.logger, bkt, id, src); err != nil {
+		return err
+	}
+	newMeta, deleted, err := phlaredb.RewriteBlock(ctx, d.logger, src, filepath.Join(dir, "rewritten"), ts)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return nil
+	}
+	if newMeta != nil {
+		if err = block.Upload(ctx, d.logger, bkt, filepath.Join(dir, "rewritten", newMeta.ULID.String())); err != nil {
+			return err
+		}
+	}
+	if err = block.MarkForDeletion(ctx, d.logger, bkt, id, deletionMarkDetails); err != nil {
+		return err
+	}
+	d.rewrittenBlocks.Inc()
+	d.deletedProfiles.Add(float64(deleted))
+	var newID string
+	if newMeta != nil {
+		newID = newMeta.ULID.String()
+	}
+	level.Info(d.logger).Log("msg", "block rewritten", "tenant", tenantID, "block", id, "new_block", newID, "deleted_profiles", deleted)
+	return nil
+}
+
+// deleteMarkedBlocks removes the blocks marked for deletion for longer than
+// the block deletion delay.
+func (d *DeleteRequests) deleteMarkedBlocks(ctx context.Context, tenantID string) error {
+	blocksBucket := phlareobj.NewPrefixedBucket(d.bucket, tenantID+"/phlaredb")
+	blocks, err := listBlocks(ctx, blocksBucket)
+	if err != nil {
+		return err
+	}
+	for _, id := range blocks {
+		mark, err := block.ReadDeletionMark(ctx, blocksBucket, id)
+		if err != nil {
+			return err
+		}
+		if mark == nil || time.Since(time.Unix(mark.DeletionTime, 0)) < d.cfg.BlockDeletionDelay {
+			continue
+		}
+		if err = block.Delete(ctx, d.logger, blocksBucket, id); err != nil {
+			return errors.Wrapf(err, "delete block %s", id)
+		}
+		level.Info(d.logger).Log("msg", "deleted block marked for deletion", "tenant", tenantID, "block", id)
+	}
+	return nil
+}
+
+func listBlocks(ctx context.Context, bkt phlareobj.Bucket) ([]ulid.ULID, error) {
+	var blocks []ulid.ULID
+	err := bkt.Iter(ctx, "", func(name string) error {
+		if id, err := ulid.Parse(strings.TrimSuffix(name, "/")); err == nil {
+			blocks = append(blocks, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "list blocks")
+	}
+	return blocks, nil
+}
+
+// AddHandler creates a delete request for the tenant of the request. The
+// query parameter is the label selector of the series, start and end are
+// the optional bounds of the time range, as unix seconds or RFC3339.
+func (d *DeleteRequests) AddHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := tenant.ExtractTenantIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	start, end := model.Time(0), model.TimeFromUnixNano(now.UnixNano())
+	if s := r.Form.Get("start"); s != "" {
+		t, err := util.ParseTime(s)
+		if err != nil {
+			http.Error(w, "invalid start: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		start = model.Time(t)
+	}
+	if s := r.Form.Get("end"); s != "" {
+		t, err := util.ParseTime(s)
+		if err != nil {
+			http.Error(w, "invalid end: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		end = model.Time(t)
+	}
+	t, err := tombstones.New(r.Form.Get("query"), start, end, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = tombstones.Write(r.Context(), d.bucket, tenantID, t); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	d.cache.Invalidate(tenantID)
+	// The audit log of the delete requests.
+	level.Info(d.logger).Log("msg", "delete request created", "tenant", tenantID, "request_id", t.ID, "query", t.Selector, "start", start, "end", end, "remote_addr", r.RemoteAddr, "user_agent", r.UserAgent())
+	util.WriteJSONResponse(w, newDeleteRequest(t))
+}
+
+// ListHandler lists the delete requests of the tenant of the request.
+func (d *DeleteRequests) ListHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := tenant.ExtractTenantIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	ts, err := tombstones.List(r.Context(), d.bucket, tenantID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	requests := make([]DeleteRequest, 0, len(ts))
+	for _, t := range ts {
+		requests = append(requests, newDeleteRequest(t))
+	}
+	util.WriteJSONResponse(w, requests)
+}
+
+// CancelHandler cancels the delete request request_id of the tenant of the
+// request. Processed requests can't be cancelled.
+func (d *DeleteRequests) CancelHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := tenant.ExtractTenantIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err = r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := r.Form.Get("request_id")
+	if _, err = ulid.Parse(id); err != nil {
+		http.Error(w, "invalid request_id", http.StatusBadRequest)
+		return
+	}
+	t, err := tombstones.Read(r.Context(), d.bucket, tenantID, id)
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	case t == nil:
+		http.Error(w, "delete request not found", http.StatusNotFound)
+		return
+	case t.Processed():
+		http.Error(w, "the delete request has already been processed", http.StatusBadRequest)
+		return
+	}
+	if err = tombstones.Delete(r.Context(), d.bucket, tenantID, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	d.cache.Invalidate(tenantID)
+	level.Info(d.logger).Log("msg", "delete request cancelled", "tenant", tenantID, "request_id", id, "remote_addr", r.RemoteAddr, "user_agent", r.UserAgent())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+const (
+	StateReceived  = "received"
+	StateProcessed = "processed"
+)
+
+// DeleteRequest is the status of a delete request.
+type DeleteRequest struct {
+	RequestID string `json:"request_id"`
+	Query     string `json:"query"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	CreatedAt string `json:"created_at"`
+	// State is one of StateReceived and StateProcessed.
+	State       string `json:"state"`
+	ProcessedAt string `json:"processed_at,omitempty"`
+}
+
+func newDeleteRequest(t *tombstones.Tombstone) DeleteRequest {
+	r := DeleteRequest{
+		RequestID: t.ID,
+		Query:     t.Selector,
+		StartTime: t.StartTime.Time().UTC().Format(time.RFC3339),
+		EndTime:   t.EndTime.Time().UTC().Format(time.RFC3339),
+		CreatedAt: formatUnix(t.CreatedAt),
+		State:     StateReceived,
+	}
+	if t.Processed() {
+		r.State = StateProcessed
+		r.ProcessedAt = formatUnix(t.ProcessedAt)
+	}
+	return r
+}
+
+func formatUnix(t int64) string {
+	return time.Unix(t, 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,128 @@
+package deleterequests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
+	"github.com/grafana/phlare/pkg/phlaredb/block"
+	"github.com/grafana/phlare/pkg/phlaredb/tombstones"
+	"github.com/grafana/phlare/pkg/tenant"
+)
+
+const (
+	blockV1 = "01GR3QABQB6J30Q04K4E6MAKRE"
+	blockV2 = "01H3YE0W63FNXM69N2WVTRBYYK"
+)
+
+func Test_DeleteRequests(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	bkt := phlareobj.NewBucket(objstore.NewInMemBucket())
+	blocksBucket := phlareobj.NewPrefixedBucket(bkt, "foo/phlaredb")
+	for _, id := range []string{blockV1, blockV2} {
+		require.NoError(t, block.Upload(ctx, logger, blocksBucket, filepath.Join("../phlaredb/block/testdata", id)))
+	}
+
+	cache := tombstones.NewCache(bkt, 0)
+	d := New(Config{DataDir: t.TempDir(), BlockDeletionDelay: time.Hour}, bkt, cache, logger, prometheus.NewRegistry())
+	do := func(method string, handler http.HandlerFunc, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/delete-requests?"+form.Encode(), nil).WithContext(tenant.InjectTenantID(ctx, "foo"))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+	list := func() []DeleteRequest {
+		rec := do(http.MethodGet, d.ListHandler, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var requests []DeleteRequest
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &requests))
+		return requests
+	}
+
+	require.Empty(t, list())
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, d.AddHandler, url.Values{"query": {`{}`}}).Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, d.AddHandler, url.Values{"query": {`{__name__="memory"}`}, "start": {"foo"}}).Code)
+
+	// The range of the v2 block only.
+	rec := do(http.MethodPost, d.AddHandler, url.Values{
+		"query": {`{__name__="memory"}`},
+		"start": {"1687869700"},
+		"end":   {"2023-06-27T13:00:00Z"},
+	})
+	require.Equal(t, http.StatusOK, rec.Code)
+	var created DeleteRequest
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.Equal(t, StateReceived, created.State)
+	require.Equal(t, "2023-06-27T12:41:40Z", created.StartTime)
+
+	rec = do(http.MethodPost, d.AddHandler, url.Values{"query": {`{__name__="process_cpu"}`}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	var cancelled DeleteRequest
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &cancelled))
+	require.Len(t, list(), 2)
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, d.CancelHandler, url.Values{"request_id": {cancelled.RequestID}}).Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodDelete, d.CancelHandler, url.Values{"request_id": {cancelled.RequestID}}).Code)
+	require.Equal(t, []DeleteRequest{created}, list())
+
+	// The tombstones are applied at query time right away.
+	ts, err := cache.Get(ctx, "foo")
+	require.NoError(t, err)
+	require.Len(t, ts, 1)
+
+	require.NoError(t, d.iteration(ctx))
+	requests := list()
+	require.Len(t, requests, 1)
+	require.Equal(t, StateProcessed, requests[0].State)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodDelete, d.CancelHandler, url.Values{"request_id": {created.RequestID}}).Code)
+
+	listBlocks := func() []string {
+		var blocks []string
+		require.NoError(t, blocksBucket.Iter(ctx, "", func(name string) error {
+			blocks = append(blocks, strings.TrimSuffix(name, "/"))
+			return nil
+		}))
+		return blocks
+	}
+	// The rewritten block is kept until the deletion delay has passed.
+	blocks := listBlocks()
+	require.Len(t, blocks, 3)
+	require.Contains(t, blocks, blockV1)
+	require.Contains(t, blocks, blockV2)
+	mark, err := block.ReadDeletionMark(ctx, blocksBucket, ulid.MustParse(blockV2))
+	require.NoError(t, err)
+	require.NotNil(t, mark)
+	mark, err = block.ReadDeletionMark(ctx, blocksBucket, ulid.MustParse(blockV1))
+	require.NoError(t, err)
+	require.Nil(t, mark)
+
+	d.cfg.BlockDeletionDelay = 0
+	require.NoError(t, d.iteration(ctx))
+	blocks = listBlocks()
+	require.Len(t, blocks, 2)
+	require.Contains(t, blocks, blockV1)
+	require.NotContains(t, blocks, blockV2)
+	for _, b := range blocks {
+		if b == blockV1 {
+			continue
+		}
+		meta, err := block.DownloadMeta(ctx, logger, blocksBucket, ulid.MustParse(b))
+		require.NoError(t, err)
+		require.Less(t, meta.Stats.NumProfiles, uint64(12))
+		require.NotZero(t, meta.Stats.NumProfiles)
+		require.Equal(t, ulid.MustParse(blockV2), meta.Compaction.Parents[0].ULID)
+	}
+}
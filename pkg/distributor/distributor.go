@@ -35,6 +35,7 @@ import (
 	phlaremodel "github.com/grafana/phlare/pkg/model"
 	"github.com/grafana/phlare/pkg/pprof"
 	"github.com/grafana/phlare/pkg/tenant"
+	"github.com/grafana/phlare/pkg/tenantdeletion"
 	"github.com/grafana/phlare/pkg/usagestats"
 	"github.com/grafana/phlare/pkg/util"
 	"github.com/grafana/phlare/pkg/validation"
@@ -76,6 +77,7 @@ type Distributor struct {
 
 	cfg           Config
 	limits        Limits
+	deletionMarks *tenantdeletion.Marks
 	ingestersRing ring.ReadRing
 	pool          *ring_client.Pool
 
@@ -106,7 +108,7 @@ type Limits interface {
 	MaxLabelNamesPerSeries(tenantID string) int
 }
 
-func New(cfg Config, ingestersRing ring.ReadRing, factory ring_client.PoolFactory, limits Limits, reg prometheus.Registerer, logger log.Logger, clientsOptions ...connect.ClientOption) (*Distributor, error) {
+func New(cfg Config, ingestersRing ring.ReadRing, factory ring_client.PoolFactory, limits Limits, deletionMarks *tenantdeletion.Marks, reg prometheus.Registerer, logger log.Logger, clientsOptions ...connect.ClientOption) (*Distributor, error) {
 	clients := promauto.With(reg).NewGauge(prometheus.GaugeOpts{
 		Namespace: "pyroscope",
 		Name:      "distributor_ingester_clients",
@@ -120,6 +122,7 @@ func New(cfg Config, ingestersRing ring.ReadRing, factory ring_client.PoolFactor
 		metrics:                 newMetrics(reg),
 		healthyInstancesCount:   atomic.NewUint32(0),
 		limits:                  limits,
+		deletionMarks:           deletionMarks,
 		rfStats:                 usagestats.NewInt("distributor_replication_factor"),
 		bytesReceivedStats:      usagestats.NewStatistics("distributor_bytes_received"),
 		bytesReceivedTotalStats: usagestats.NewCounter("distributor_bytes_received_total"),
@@ -176,6 +179,9 @@ func (d *Distributor) Push(ctx context.Context, req *connect.Request[pushv1.Push
 	if err != nil {
 		return nil, connect.NewError(connect.CodeUnauthenticated, err)
 	}
+	if d.deletionMarks.IsMarked(tenantID) {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, tenantdeletion.ErrTenantMarked)
+	}
 	var (
 		keys                       = make([]uint32, 0, len(req.Msg.Series))
 		profiles                   = make([]*profileTracker, 0, len(req.Msg.Series))
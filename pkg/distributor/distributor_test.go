@@ -52,7 +52,7 @@ func Test_ConnectPush(t *testing.T) {
 		{Addr: "foo"},
 	}, 3), func(addr string) (client.PoolClient, error) {
 		return ing, nil
-	}, newOverrides(t), nil, log.NewLogfmtLogger(os.Stdout))
+	}, newOverrides(t), nil, nil, log.NewLogfmtLogger(os.Stdout))
 
 	require.NoError(t, err)
 	mux.Handle(pushv1connect.NewPusherServiceHandler(d, connect.WithInterceptors(tenant.NewAuthInterceptor(true))))
@@ -111,7 +111,7 @@ func Test_Replication(t *testing.T) {
 		{Addr: "3"},
 	}, 3), func(addr string) (client.PoolClient, error) {
 		return ingesters[addr], nil
-	}, newOverrides(t), nil, log.NewLogfmtLogger(os.Stdout))
+	}, newOverrides(t), nil, nil, log.NewLogfmtLogger(os.Stdout))
 	require.NoError(t, err)
 	// only 1 ingester failing should be fine.
 	resp, err := d.Push(ctx, req)
@@ -133,7 +133,7 @@ func Test_Subservices(t *testing.T) {
 		{Addr: "foo"},
 	}, 1), func(addr string) (client.PoolClient, error) {
 		return ing, nil
-	}, newOverrides(t), nil, log.NewLogfmtLogger(os.Stdout))
+	}, newOverrides(t), nil, nil, log.NewLogfmtLogger(os.Stdout))
 
 	require.NoError(t, err)
 	require.NoError(t, d.StartAsync(context.Background()))
@@ -195,7 +195,7 @@ func Test_Limits(t *testing.T) {
 		{Addr: "foo"},
 	}, 3), func(addr string) (client.PoolClient, error) {
 		return ing, nil
-	}, newOverrides(t), nil, log.NewLogfmtLogger(os.Stdout))
+	}, newOverrides(t), nil, nil, log.NewLogfmtLogger(os.Stdout))
 
 	require.NoError(t, err)
 	mux.Handle(pushv1connect.NewPusherServiceHandler(d, connect.WithInterceptors(tenant.NewAuthInterceptor(true))))
@@ -256,7 +256,7 @@ func TestBadPushRequest(t *testing.T) {
 		{Addr: "foo"},
 	}, 3), func(addr string) (client.PoolClient, error) {
 		return ing, nil
-	}, newOverrides(t), nil, log.NewLogfmtLogger(os.Stdout))
+	}, newOverrides(t), nil, nil, log.NewLogfmtLogger(os.Stdout))
 
 	require.NoError(t, err)
 	mux.Handle(pushv1connect.NewPusherServiceHandler(d, connect.WithInterceptors(tenant.NewAuthInterceptor(true))))
@@ -340,6 +340,7 @@ func TestPush_ShuffleSharding(t *testing.T) {
 		},
 		overrides,
 		nil,
+		nil,
 		log.NewLogfmtLogger(os.Stdout),
 	)
 	require.NoError(t, err)
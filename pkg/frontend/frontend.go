@@ -30,6 +30,7 @@ import (
 	"github.com/grafana/dskit/tenant"
 
 	"github.com/grafana/phlare/pkg/frontend/frontendpb"
+	"github.com/grafana/phlare/pkg/querier/partialresponse"
 	"github.com/grafana/phlare/pkg/querier/stats"
 	"github.com/grafana/phlare/pkg/scheduler/schedulerdiscovery"
 	"github.com/grafana/phlare/pkg/util/httpgrpc"
@@ -91,10 +92,14 @@ type Frontend struct {
 	schedulerWorkersWatcher *services.FailureWatcher
 	requests                *requestsInProgress
 	frontendpb.UnimplementedFrontendForQuerierServer
+
+	coalescedRequests prometheus.Counter
+	seriesEstimates   *seriesEstimates
 }
 
 type Limits interface {
 	QuerySplitDuration(string) time.Duration
+	QueryShardingTotalShards(string) int
 	MaxQueryParallelism(string) int
 	MaxQueryLength(tenantID string) time.Duration
 	MaxQueryLookback(tenantID string) time.Duration
@@ -145,6 +150,7 @@ func NewFrontend(cfg Config, limits Limits, log log.Logger, reg prometheus.Regis
 		schedulerWorkers:        schedulerWorkers,
 		schedulerWorkersWatcher: services.NewFailureWatcher(),
 		requests:                newRequestsInProgress(),
+		seriesEstimates:         newSeriesEstimates(),
 	}
 	// Randomize to avoid getting responses from queries sent before restart, which could lead to mixing results
 	// between different queries. Note that frontend verifies the user, so it cannot leak results between tenants.
@@ -158,6 +164,11 @@ func NewFrontend(cfg Config, limits Limits, log log.Logger, reg prometheus.Regis
 		return float64(f.requests.count())
 	})
 
+	f.coalescedRequests = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "pyroscope_query_frontend_coalesced_requests_total",
+		Help: "Number of requests served with the result of an identical in-flight request.",
+	})
+
 	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
 		Name: "pyroscope_query_frontend_connected_schedulers",
 		Help: "Number of schedulers this frontend is connected to.",
@@ -200,6 +211,69 @@ func (f *Frontend) RoundTripGRPC(ctx context.Context, req *httpgrpc.HTTPRequest)
 	}
 	userID := tenant.JoinTenantIDs(tenantIDs)
 
+	if _, ok := coalescedProcedures[req.Url]; !ok {
+		resp, err := f.roundTripGRPC(ctx, userID, req)
+		if err != nil {
+			return nil, err
+		}
+		return f.queryResult(ctx, resp, true), nil
+	}
+
+	key := coalescingKey(userID, req)
+	for {
+		c, leader := f.requests.coalesce(key)
+		if leader {
+			resp, err := f.roundTripGRPC(ctx, userID, req)
+			f.requests.complete(key, c, resp, err)
+			if err != nil {
+				return nil, err
+			}
+			return f.queryResult(ctx, resp, true), nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.done:
+		}
+		if c.err != nil {
+			if ctx.Err() == nil && (errors.Is(c.err, context.Canceled) || errors.Is(c.err, context.DeadlineExceeded)) {
+				// The request has been canceled by the client which issued
+				// it: issue it again, unless another waiter already has.
+				continue
+			}
+			return nil, c.err
+		}
+		f.coalescedRequests.Inc()
+		return f.queryResult(ctx, c.resp, false), nil
+	}
+}
+
+// queryResult returns a copy of the HTTP response of the query result, safe
+// to be modified by the caller while the response is shared with the waiters
+// of a coalesced request. The query statistics are merged into the ones of
+// the context only if merge is set: the waiters of a coalesced request have
+// not executed the query.
+func (f *Frontend) queryResult(ctx context.Context, resp *frontendpb.QueryResultRequest, merge bool) *httpgrpc.HTTPResponse {
+	if merge && stats.ShouldTrackHTTPGRPCResponse(resp.HttpResponse) {
+		stats.FromContext(ctx).Merge(resp.Stats) // Safe if stats is nil.
+	}
+	headers := make([]*httpgrpc.Header, 0, len(resp.HttpResponse.GetHeaders()))
+	for _, h := range resp.HttpResponse.GetHeaders() {
+		headers = append(headers, &httpgrpc.Header{
+			Key:    h.Key,
+			Values: append([]string(nil), h.Values...),
+		})
+	}
+	return &httpgrpc.HTTPResponse{
+		Code:    resp.HttpResponse.GetCode(),
+		Headers: headers,
+		Body:    append([]byte(nil), resp.HttpResponse.GetBody()...),
+	}
+}
+
+func (f *Frontend) roundTripGRPC(ctx context.Context, userID string, req *httpgrpc.HTTPRequest) (*frontendpb.QueryResultRequest, error) {
+	setQueryCostHeader(ctx, req)
+
 	// Propagate trace context in gRPC too - this will be ignored if using HTTP.
 	tracer, span := opentracing.GlobalTracer(), opentracing.SpanFromContext(ctx)
 	if tracer != nil && span != nil {
@@ -267,11 +341,7 @@ enqueueAgain:
 		return nil, ctx.Err()
 
 	case resp := <-freq.response:
-		if stats.ShouldTrackHTTPGRPCResponse(resp.HttpResponse) {
-			stats.FromContext(ctx).Merge(resp.Stats) // Safe if stats is nil.
-		}
-
-		return resp.HttpResponse, nil
+		return resp, nil
 	}
 }
 
@@ -314,17 +384,73 @@ func (f *Frontend) CheckReady(_ context.Context) error {
 	return errors.New(msg)
 }
 
+// coalescedProcedures are the procedures which identical in-flight requests
+// are coalesced onto a single query: dashboards often issue the same query
+// from several panels or users at once.
+var coalescedProcedures = map[string]struct{}{
+	"/querier.v1.QuerierService/SelectMergeStacktraces": {},
+}
+
+// coalescingKey identifies the requests of a tenant with the same procedure
+// and message, e.g. the same selector, profile type and time range, and the
+// same partial response mode.
+func coalescingKey(userID string, req *httpgrpc.HTTPRequest) string {
+	var partial string
+	for _, h := range req.Headers {
+		if http.CanonicalHeaderKey(h.Key) == partialresponse.Header && len(h.Values) > 0 {
+			partial = h.Values[0]
+		}
+	}
+	return userID + "\x00" + req.Url + "\x00" + partial + "\x00" + string(req.Body)
+}
+
+// coalescedRequest is an in-flight request which result is shared by all
+// the identical requests received while it is in progress.
+type coalescedRequest struct {
+	done chan struct{}
+	resp *frontendpb.QueryResultRequest
+	err  error
+}
+
 type requestsInProgress struct {
-	mu       sync.Mutex
-	requests map[uint64]*frontendRequest
+	mu        sync.Mutex
+	requests  map[uint64]*frontendRequest
+	coalesced map[string]*coalescedRequest
 }
 
 func newRequestsInProgress() *requestsInProgress {
 	return &requestsInProgress{
-		requests: map[uint64]*frontendRequest{},
+		requests:  map[uint64]*frontendRequest{},
+		coalesced: map[string]*coalescedRequest{},
 	}
 }
 
+// coalesce returns the in-flight request with the given key. If there is
+// none, a new one is returned along with true: the caller is expected to
+// issue the request and complete it.
+func (r *requestsInProgress) coalesce(key string) (*coalescedRequest, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := r.coalesced[key]; ok {
+		return c, false
+	}
+	c := &coalescedRequest{done: make(chan struct{})}
+	r.coalesced[key] = c
+	return c, true
+}
+
+// complete sets the result of the in-flight request and notifies the
+// requests waiting for it.
+func (r *requestsInProgress) complete(key string, c *coalescedRequest, resp *frontendpb.QueryResultRequest, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.coalesced, key)
+	c.resp, c.err = resp, err
+	close(c.done)
+}
+
 func (r *requestsInProgress) count() int {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -0,0 +1,14 @@
+package frontend
+
+import (
+	"context"
+
+	"github.com/bufbuild/connect-go"
+
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+	"github.com/grafana/phlare/pkg/util/connectgrpc"
+)
+
+func (f *Frontend) Cardinality(ctx context.Context, c *connect.Request[typesv1.CardinalityRequest]) (*connect.Response[typesv1.CardinalityResponse], error) {
+	return connectgrpc.RoundTripUnary[typesv1.CardinalityRequest, typesv1.CardinalityResponse](ctx, f, c)
+}
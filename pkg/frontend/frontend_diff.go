@@ -2,13 +2,30 @@ package frontend
 
 import (
 	"context"
+	"time"
 
 	"github.com/bufbuild/connect-go"
 
 	querierv1 "github.com/grafana/phlare/api/gen/proto/go/querier/v1"
+	"github.com/grafana/phlare/pkg/querier/partialresponse"
+	"github.com/grafana/phlare/pkg/querier/stats"
 	"github.com/grafana/phlare/pkg/util/connectgrpc"
 )
 
 func (f *Frontend) Diff(ctx context.Context, c *connect.Request[querierv1.DiffRequest]) (*connect.Response[querierv1.DiffResponse], error) {
-	return connectgrpc.RoundTripUnary[querierv1.DiffRequest, querierv1.DiffResponse](ctx, f, c)
+	start := time.Now()
+	qs, ctx := stats.ContextWithEmptyStats(ctx)
+	resp, err := connectgrpc.RoundTripUnary[querierv1.DiffRequest, querierv1.DiffResponse](ctx, f, c)
+	warnings, _ := partialresponse.ContextWithWarnings(ctx, c.Header())
+	if err == nil {
+		warnings.MergeHeader(resp.Header())
+	}
+	f.logQueryStats(ctx, "Diff", start, 1, qs, err)
+	if err != nil {
+		return nil, err
+	}
+	out := connect.NewResponse(resp.Msg)
+	qs.SetHeader(out.Header())
+	warnings.SetHeader(out.Header())
+	return out, nil
 }
@@ -3,6 +3,7 @@ package frontend
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/bufbuild/connect-go"
 	"github.com/prometheus/common/model"
@@ -11,11 +12,14 @@ import (
 
 	profilev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
 	querierv1 "github.com/grafana/phlare/api/gen/proto/go/querier/v1"
+	"github.com/grafana/phlare/pkg/querier/partialresponse"
+	"github.com/grafana/phlare/pkg/querier/stats"
 	"github.com/grafana/phlare/pkg/util/connectgrpc"
 	"github.com/grafana/phlare/pkg/validation"
 )
 
 func (f *Frontend) SelectMergeProfile(ctx context.Context, c *connect.Request[querierv1.SelectMergeProfileRequest]) (*connect.Response[profilev1.Profile], error) {
+	start := time.Now()
 	tenantIDs, err := tenant.TenantIDs(ctx)
 	if err != nil {
 		return nil, connect.NewError(http.StatusBadRequest, err)
@@ -29,5 +33,18 @@ func (f *Frontend) SelectMergeProfile(ctx context.Context, c *connect.Request[qu
 	}
 	c.Msg.Start = int64(validated.Start)
 	c.Msg.End = int64(validated.End)
-	return connectgrpc.RoundTripUnary[querierv1.SelectMergeProfileRequest, profilev1.Profile](ctx, f, c)
+	qs, ctx := stats.ContextWithEmptyStats(ctx)
+	resp, err := connectgrpc.RoundTripUnary[querierv1.SelectMergeProfileRequest, profilev1.Profile](ctx, f, c)
+	warnings, _ := partialresponse.ContextWithWarnings(ctx, c.Header())
+	if err == nil {
+		warnings.MergeHeader(resp.Header())
+	}
+	f.logQueryStats(ctx, "SelectMergeProfile", start, 1, qs, err)
+	if err != nil {
+		return nil, err
+	}
+	out := connect.NewResponse(resp.Msg)
+	qs.SetHeader(out.Header())
+	warnings.SetHeader(out.Header())
+	return out, nil
 }
@@ -12,6 +12,8 @@ import (
 
 	querierv1 "github.com/grafana/phlare/api/gen/proto/go/querier/v1"
 	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/querier/partialresponse"
+	"github.com/grafana/phlare/pkg/querier/stats"
 	"github.com/grafana/phlare/pkg/util/connectgrpc"
 	validationutil "github.com/grafana/phlare/pkg/util/validation"
 	"github.com/grafana/phlare/pkg/validation"
@@ -21,6 +23,7 @@ func (f *Frontend) SelectSeries(ctx context.Context,
 	c *connect.Request[querierv1.SelectSeriesRequest]) (
 	*connect.Response[querierv1.SelectSeriesResponse], error,
 ) {
+	start := time.Now()
 	tenantIDs, err := tenant.TenantIDs(ctx)
 	if err != nil {
 		return nil, connect.NewError(http.StatusBadRequest, err)
@@ -36,41 +39,70 @@ func (f *Frontend) SelectSeries(ctx context.Context,
 	c.Msg.Start = int64(validated.Start)
 	c.Msg.End = int64(validated.End)
 
+	qs, ctx := stats.ContextWithEmptyStats(ctx)
+	warnings, _ := partialresponse.ContextWithWarnings(ctx, c.Header())
+	subQueries := 0
 	g, ctx := errgroup.WithContext(ctx)
 	if maxConcurrent := validationutil.SmallestPositiveNonZeroIntPerTenant(tenantIDs, f.limits.MaxQueryParallelism); maxConcurrent > 0 {
 		g.SetLimit(maxConcurrent)
 	}
 
+	shards := validationutil.SmallestPositiveNonZeroIntPerTenant(tenantIDs, f.limits.QueryShardingTotalShards)
+	selectors, err := shardSelectors(c.Msg.LabelSelector, shards)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
 	m := phlaremodel.NewSeriesMerger(false)
 	interval := validationutil.MaxDurationOrZeroPerTenant(tenantIDs, f.limits.QuerySplitDuration)
 	intervals := NewTimeIntervalIterator(time.UnixMilli(c.Msg.Start), time.UnixMilli(c.Msg.End), interval,
 		WithAlignment(time.Second*time.Duration(c.Msg.Step)))
 
+	// The points of the shards of an interval are summed, while the
+	// duplicate points of adjacent intervals are discarded.
+	var shardMergers []*phlaremodel.SeriesMerger
+	series := f.estimateSeries(ctx, c.Header(), c.Msg.ProfileTypeID, c.Msg.LabelSelector)
 	for intervals.Next() {
 		r := intervals.At()
-		g.Go(func() error {
-			req := connectgrpc.CloneRequest(c, &querierv1.SelectSeriesRequest{
-				ProfileTypeID: c.Msg.ProfileTypeID,
-				LabelSelector: c.Msg.LabelSelector,
-				Start:         r.Start.UnixMilli(),
-				End:           r.End.UnixMilli(),
-				GroupBy:       c.Msg.GroupBy,
-				Step:          c.Msg.Step,
+		ctx := withQueryCost(ctx, splitCost(series, r)/float64(len(selectors)))
+		sm := phlaremodel.NewSeriesMerger(true)
+		shardMergers = append(shardMergers, sm)
+		for _, selector := range selectors {
+			selector := selector
+			subQueries++
+			g.Go(func() error {
+				req := connectgrpc.CloneRequest(c, &querierv1.SelectSeriesRequest{
+					ProfileTypeID: c.Msg.ProfileTypeID,
+					LabelSelector: selector,
+					Start:         r.Start.UnixMilli(),
+					End:           r.End.UnixMilli(),
+					GroupBy:       c.Msg.GroupBy,
+					Step:          c.Msg.Step,
+				})
+				resp, err := connectgrpc.RoundTripUnary[
+					querierv1.SelectSeriesRequest,
+					querierv1.SelectSeriesResponse](ctx, f, req)
+				if err != nil {
+					return err
+				}
+				warnings.MergeHeader(resp.Header())
+				sm.MergeSeries(resp.Msg.Series)
+				return nil
 			})
-			resp, err := connectgrpc.RoundTripUnary[
-				querierv1.SelectSeriesRequest,
-				querierv1.SelectSeriesResponse](ctx, f, req)
-			if err != nil {
-				return err
-			}
-			m.MergeSeries(resp.Msg.Series)
-			return nil
-		})
+		}
 	}
 
-	if err = g.Wait(); err != nil {
+	err = g.Wait()
+	f.logQueryStats(ctx, "SelectSeries", start, subQueries, qs, err)
+	if err != nil {
 		return nil, err
 	}
+	for _, sm := range shardMergers {
+		m.MergeSeries(sm.Series())
+	}
 
-	return connect.NewResponse(&querierv1.SelectSeriesResponse{Series: m.Series()}), nil
+	resp := connect.NewResponse(&querierv1.SelectSeriesResponse{Series: m.Series()})
+	qs.SetHeader(resp.Header())
+	warnings.SetHeader(resp.Header())
+	return resp, nil
 }
@@ -181,6 +181,106 @@ func TestFrontendRequestsPerWorkerMetric(t *testing.T) {
 	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics), "pyroscope_query_frontend_workers_enqueued_requests_total"))
 }
 
+func TestFrontendCoalescesIdenticalRequests(t *testing.T) {
+	const (
+		body   = "all fine here"
+		userID = "test"
+	)
+
+	reg := prometheus.NewRegistry()
+	enqueued := atomic.NewInt64(0)
+	f, _ := setupFrontend(t, reg, func(f *Frontend, msg *schedulerpb.FrontendToScheduler) *schedulerpb.SchedulerToFrontend {
+		if msg.Type != schedulerpb.FrontendToSchedulerType_ENQUEUE {
+			return &schedulerpb.SchedulerToFrontend{Status: schedulerpb.SchedulerToFrontendStatus_OK}
+		}
+		enqueued.Inc()
+		go sendResponseWithDelay(f, 200*time.Millisecond, msg.UserID, msg.QueryID, &httpgrpc.HTTPResponse{
+			Code: 200,
+			Body: []byte(body),
+		})
+		return &schedulerpb.SchedulerToFrontend{Status: schedulerpb.SchedulerToFrontendStatus_OK}
+	})
+
+	request := func(body string) *httpgrpc.HTTPRequest {
+		return &httpgrpc.HTTPRequest{
+			Url:  "/querier.v1.QuerierService/SelectMergeStacktraces",
+			Body: []byte(body),
+		}
+	}
+
+	var wg sync.WaitGroup
+	roundTrip := func(userID string, req *httpgrpc.HTTPRequest) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := f.RoundTripGRPC(user.InjectOrgID(context.Background(), userID), req)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte(body), resp.GetBody())
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		roundTrip(userID, request("query"))
+	}
+	roundTrip(userID, request("another query"))
+	roundTrip("another-tenant", request("query"))
+	wg.Wait()
+
+	require.Equal(t, int64(3), enqueued.Load())
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP pyroscope_query_frontend_coalesced_requests_total Number of requests served with the result of an identical in-flight request.
+		# TYPE pyroscope_query_frontend_coalesced_requests_total counter
+		pyroscope_query_frontend_coalesced_requests_total 4
+	`), "pyroscope_query_frontend_coalesced_requests_total"))
+
+	t.Run("the cancellation of the first request does not affect the others", func(t *testing.T) {
+		enqueued.Store(0)
+		ctx, cancel := context.WithCancel(user.InjectOrgID(context.Background(), userID))
+		canceled := make(chan struct{})
+		go func() {
+			defer close(canceled)
+			_, err := f.RoundTripGRPC(ctx, request("canceled query"))
+			assert.ErrorIs(t, err, context.Canceled)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		resp, err := f.RoundTripGRPC(user.InjectOrgID(context.Background(), userID), request("canceled query"))
+		require.NoError(t, err)
+		require.Equal(t, []byte(body), resp.Body)
+		require.Equal(t, int64(2), enqueued.Load())
+		<-canceled
+	})
+}
+
+func TestFrontendQueryResult(t *testing.T) {
+	f := &Frontend{}
+	resp := &frontendpb.QueryResultRequest{
+		HttpResponse: &httpgrpc.HTTPResponse{
+			Code:    200,
+			Headers: []*httpgrpc.Header{{Key: "Content-Type", Values: []string{"application/proto"}}},
+			Body:    []byte("body"),
+		},
+		Stats: &stats.Stats{FetchedSeriesCount: 10},
+	}
+
+	leaderStats, leaderCtx := stats.ContextWithEmptyStats(context.Background())
+	leader := f.queryResult(leaderCtx, resp, true)
+	waiterStats, waiterCtx := stats.ContextWithEmptyStats(context.Background())
+	waiter := f.queryResult(waiterCtx, resp, false)
+
+	// Only the request which executed the query accounts its statistics.
+	require.Equal(t, uint64(10), leaderStats.LoadFetchedSeries())
+	require.Equal(t, uint64(0), waiterStats.LoadFetchedSeries())
+
+	// The responses are not shared.
+	leader.Headers[0].Values[0] = "text/plain"
+	leader.Body[0] = 'B'
+	require.Equal(t, "application/proto", waiter.Headers[0].Values[0])
+	require.Equal(t, "body", string(waiter.Body))
+	require.Equal(t, "application/proto", resp.HttpResponse.Headers[0].Values[0])
+	require.Equal(t, "body", string(resp.HttpResponse.Body))
+}
+
 func TestFrontendRetryEnqueue(t *testing.T) {
 	// Frontend uses worker concurrency to compute number of retries. We use one less failure.
 	failures := atomic.NewInt64(testFrontendWorkerConcurrency - 1)
@@ -0,0 +1,206 @@
+package frontend
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/weaveworks/common/user"
+
+	querierv1 "github.com/grafana/phlare/api/gen/proto/go/querier/v1"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/scheduler/queue"
+	"github.com/grafana/phlare/pkg/util/connectgrpc"
+	"github.com/grafana/phlare/pkg/util/httpgrpc"
+)
+
+const seriesProcedure = "/querier.v1.QuerierService/Series"
+
+type queryCostKey struct{}
+
+// withQueryCost returns a context carrying the cost estimate of the query
+// round tripped with it.
+func withQueryCost(ctx context.Context, cost float64) context.Context {
+	return context.WithValue(ctx, queryCostKey{}, cost)
+}
+
+// setQueryCostHeader sets the cost header of the request from the context.
+// Any cost header set by the client is removed: the cost is only trusted if
+// estimated by the frontend.
+func setQueryCostHeader(ctx context.Context, req *httpgrpc.HTTPRequest) {
+	headers := req.Headers[:0]
+	for _, h := range req.Headers {
+		if !strings.EqualFold(h.Key, queue.CostHeader) {
+			headers = append(headers, h)
+		}
+	}
+	req.Headers = headers
+	if cost, ok := ctx.Value(queryCostKey{}).(float64); ok {
+		req.Headers = append(req.Headers, &httpgrpc.Header{
+			Key:    queue.CostHeader,
+			Values: []string{strconv.FormatFloat(cost, 'f', -1, 64)},
+		})
+	}
+}
+
+// splitCost returns the cost estimate of a query split: the number of series
+// selected by the query times the split duration in hours.
+func splitCost(series int, r TimeInterval) float64 {
+	return float64(series) * r.End.Sub(r.Start).Hours()
+}
+
+const (
+	// seriesEstimateTTL is how long the number of series of a selector is
+	// used to estimate the cost of the queries before being refreshed.
+	seriesEstimateTTL = 5 * time.Minute
+	// seriesEstimateTimeout bounds the Series requests of the refreshes.
+	seriesEstimateTimeout = time.Minute
+	// maxSeriesEstimates bounds the number of selectors cached.
+	maxSeriesEstimates = 4096
+)
+
+// seriesEstimates caches the number of series per tenant and selector, so
+// that the cost of a query is estimated without querying the series of
+// each query.
+type seriesEstimates struct {
+	mu      sync.Mutex
+	entries map[string]*seriesEstimate
+}
+
+type seriesEstimate struct {
+	series     int
+	updated    time.Time
+	refreshing bool
+}
+
+func newSeriesEstimates() *seriesEstimates {
+	return &seriesEstimates{entries: make(map[string]*seriesEstimate)}
+}
+
+// get returns the number of series cached for the key, or 1 if unknown. It
+// reports whether the estimate must be refreshed by the caller, in which
+// case the caller must call set once done.
+func (e *seriesEstimates) get(key string, now time.Time) (series int, refresh bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	entry, ok := e.entries[key]
+	if !ok {
+		if len(e.entries) >= maxSeriesEstimates {
+			e.evict(now)
+			if len(e.entries) >= maxSeriesEstimates {
+				return 1, false
+			}
+		}
+		entry = &seriesEstimate{series: 1}
+		e.entries[key] = entry
+	}
+	if entry.refreshing || (!entry.updated.IsZero() && now.Sub(entry.updated) < seriesEstimateTTL) {
+		return entry.series, false
+	}
+	entry.refreshing = true
+	return entry.series, true
+}
+
+// set records the number of series of the key. The previous estimate is
+// kept if the refresh failed, signaled by a non-positive number of series.
+func (e *seriesEstimates) set(key string, series int, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	entry, ok := e.entries[key]
+	if !ok {
+		return
+	}
+	entry.refreshing = false
+	entry.updated = now
+	if series > 0 {
+		entry.series = series
+	}
+}
+
+// evict removes the expired estimates. e.mu must be held.
+func (e *seriesEstimates) evict(now time.Time) {
+	for k, entry := range e.entries {
+		if !entry.refreshing && now.Sub(entry.updated) >= seriesEstimateTTL {
+			delete(e.entries, k)
+		}
+	}
+}
+
+// estimateSeries returns the number of series of the profile type matching
+// the label selector, according to the ingesters index. The estimates are
+// cached and refreshed in the background, so the query is not delayed and
+// at most one Series request per tenant and selector is issued per TTL. It
+// returns 1 if the number of series is not known yet, so that the cost of
+// the query is proportional to its time range.
+func (f *Frontend) estimateSeries(ctx context.Context, header http.Header, profileTypeID, labelSelector string) int {
+	selector, err := seriesSelector(profileTypeID, labelSelector)
+	if err != nil {
+		return 1
+	}
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return 1
+	}
+	tenantID := tenant.JoinTenantIDs(tenantIDs)
+	key := tenantID + "\x00" + selector
+	series, refresh := f.seriesEstimates.get(key, time.Now())
+	if refresh {
+		// The refresh must not be canceled with the query.
+		ctx := user.InjectOrgID(context.Background(), tenantID)
+		header := header.Clone()
+		go func() {
+			ctx, cancel := context.WithTimeout(ctx, seriesEstimateTimeout)
+			defer cancel()
+			f.seriesEstimates.set(key, f.countSeries(ctx, header, selector), time.Now())
+		}()
+	}
+	return series
+}
+
+// countSeries returns the number of series matching the selector, or 0 if
+// it can't be counted.
+func (f *Frontend) countSeries(ctx context.Context, header http.Header, selector string) int {
+	req := connect.NewRequest(&querierv1.SeriesRequest{Matchers: []string{selector}})
+	for k, v := range header {
+		req.Header()[k] = v
+	}
+	resp, err := connectgrpc.RoundTripUnaryProcedure[
+		querierv1.SeriesRequest,
+		querierv1.SeriesResponse](ctx, f, seriesProcedure, req)
+	if err != nil {
+		level.Warn(f.log).Log("msg", "failed to estimate the number of series of the query", "err", err)
+		return 0
+	}
+	return len(resp.Msg.LabelsSet)
+}
+
+func seriesSelector(profileTypeID, labelSelector string) (string, error) {
+	profileType, err := phlaremodel.ParseProfileTypeSelector(profileTypeID)
+	if err != nil {
+		return "", err
+	}
+	matchers := []*labels.Matcher{phlaremodel.SelectorFromProfileType(profileType)}
+	// The query fails if the selector is invalid: it is only
+	// estimated by profile type in this case.
+	if m, err := parser.ParseMetricSelector(labelSelector); err == nil {
+		matchers = append(matchers, m...)
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, m := range matchers {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(m.String())
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
@@ -0,0 +1,77 @@
+package frontend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/phlare/pkg/scheduler/queue"
+	"github.com/grafana/phlare/pkg/util/httpgrpc"
+)
+
+func Test_seriesSelector(t *testing.T) {
+	const profileType = "process_cpu:cpu:nanoseconds:cpu:nanoseconds"
+
+	s, err := seriesSelector(profileType, `{service_name="foo", env=~"prod|dev"}`)
+	require.NoError(t, err)
+	require.Equal(t, `{__profile_type__="process_cpu:cpu:nanoseconds:cpu:nanoseconds",service_name="foo",env=~"prod|dev"}`, s)
+
+	s, err = seriesSelector(profileType, `{}`)
+	require.NoError(t, err)
+	require.Equal(t, `{__profile_type__="process_cpu:cpu:nanoseconds:cpu:nanoseconds"}`, s)
+
+	_, err = seriesSelector("cpu", `{}`)
+	require.Error(t, err)
+}
+
+func Test_splitCost(t *testing.T) {
+	start := time.Unix(0, 0)
+	require.Equal(t, 20.0, splitCost(10, TimeInterval{Start: start, End: start.Add(2 * time.Hour)}))
+}
+
+func Test_setQueryCostHeader(t *testing.T) {
+	req := &httpgrpc.HTTPRequest{Headers: []*httpgrpc.Header{
+		{Key: "X-Scope-OrgID", Values: []string{"tenant"}},
+		{Key: "x-query-cost", Values: []string{"0.001"}},
+	}}
+	setQueryCostHeader(context.Background(), req)
+	require.Equal(t, []*httpgrpc.Header{
+		{Key: "X-Scope-OrgID", Values: []string{"tenant"}},
+	}, req.Headers)
+
+	setQueryCostHeader(withQueryCost(context.Background(), 42.5), req)
+	require.Equal(t, []*httpgrpc.Header{
+		{Key: "X-Scope-OrgID", Values: []string{"tenant"}},
+		{Key: queue.CostHeader, Values: []string{"42.5"}},
+	}, req.Headers)
+}
+
+func Test_seriesEstimates(t *testing.T) {
+	e := newSeriesEstimates()
+	now := time.Unix(0, 0)
+
+	// Unknown selectors are refreshed once, their cost is estimated by
+	// their time range meanwhile.
+	series, refresh := e.get("a", now)
+	require.Equal(t, 1, series)
+	require.True(t, refresh)
+	series, refresh = e.get("a", now)
+	require.Equal(t, 1, series)
+	require.False(t, refresh)
+
+	e.set("a", 10, now)
+	series, refresh = e.get("a", now.Add(time.Minute))
+	require.Equal(t, 10, series)
+	require.False(t, refresh)
+
+	// Expired estimates are used until refreshed, failed refreshes keep them.
+	series, refresh = e.get("a", now.Add(seriesEstimateTTL))
+	require.Equal(t, 10, series)
+	require.True(t, refresh)
+	e.set("a", 0, now.Add(seriesEstimateTTL))
+	series, refresh = e.get("a", now.Add(seriesEstimateTTL))
+	require.Equal(t, 10, series)
+	require.False(t, refresh)
+}
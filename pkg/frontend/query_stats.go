@@ -0,0 +1,30 @@
+package frontend
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+
+	"github.com/grafana/phlare/pkg/querier/stats"
+)
+
+// logQueryStats logs the statistics collected by the queriers for a query,
+// aggregated across all its sub-queries, in a structured "query stats" line.
+func (f *Frontend) logQueryStats(ctx context.Context, method string, start time.Time, subQueries int, qs *stats.Stats, err error) {
+	tenantIDs, _ := tenant.TenantIDs(ctx)
+	status := "success"
+	if err != nil {
+		status = "failed"
+	}
+	kvs := []interface{}{
+		"msg", "query stats",
+		"method", method,
+		"tenant", tenant.JoinTenantIDs(tenantIDs),
+		"status", status,
+		"duration", time.Since(start),
+		"sub_queries", subQueries,
+	}
+	level.Info(f.log).Log(append(kvs, qs.LogFields()...)...)
+}
@@ -0,0 +1,42 @@
+package frontend
+
+import (
+	"strings"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/phlare/pkg/phlaredb/tsdb/shard"
+)
+
+// shardSelectors returns the label selectors of the shards of a query: each
+// shard selects the series which fingerprint modulo the number of shards
+// equals the shard index. The shards are disjoint and cover all the series
+// selected by the query. The selector is returned as is if there are less
+// than two shards.
+func shardSelectors(selector string, shards int) ([]string, error) {
+	if shards < 2 {
+		return []string{selector}, nil
+	}
+	var matchers []*labels.Matcher
+	if s := strings.TrimSpace(selector); s != "" && s != "{}" {
+		var err error
+		if matchers, err = parser.ParseMetricSelector(selector); err != nil {
+			return nil, err
+		}
+	}
+	selectors := make([]string, shards)
+	for i := range selectors {
+		var b strings.Builder
+		b.WriteByte('{')
+		for _, m := range matchers {
+			b.WriteString(m.String())
+			b.WriteByte(',')
+		}
+		l := shard.Annotation{Shard: i, Of: shards}.Label()
+		b.WriteString(labels.MustNewMatcher(labels.MatchEqual, l.Name, l.Value).String())
+		b.WriteByte('}')
+		selectors[i] = b.String()
+	}
+	return selectors, nil
+}
@@ -0,0 +1,58 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/phlare/pkg/phlaredb/tsdb/shard"
+)
+
+func Test_shardSelectors(t *testing.T) {
+	for _, tc := range []struct {
+		selector string
+		shards   int
+		expected []string
+	}{
+		{selector: `{foo="bar"}`, shards: 0, expected: []string{`{foo="bar"}`}},
+		{selector: `{foo="bar"}`, shards: 1, expected: []string{`{foo="bar"}`}},
+		{selector: `{}`, shards: 2, expected: []string{`{__cortex_shard__="0_of_2"}`, `{__cortex_shard__="1_of_2"}`}},
+		{selector: ``, shards: 2, expected: []string{`{__cortex_shard__="0_of_2"}`, `{__cortex_shard__="1_of_2"}`}},
+		{
+			selector: `{foo="bar", baz=~"qu.*"}`,
+			shards:   3,
+			expected: []string{
+				`{foo="bar",baz=~"qu.*",__cortex_shard__="0_of_3"}`,
+				`{foo="bar",baz=~"qu.*",__cortex_shard__="1_of_3"}`,
+				`{foo="bar",baz=~"qu.*",__cortex_shard__="2_of_3"}`,
+			},
+		},
+	} {
+		actual, err := shardSelectors(tc.selector, tc.shards)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, actual)
+	}
+
+	_, err := shardSelectors(`{foo=`, 2)
+	require.Error(t, err)
+}
+
+func Test_shardSelectors_Disjoint(t *testing.T) {
+	selectors, err := shardSelectors(`{foo="bar"}`, 4)
+	require.NoError(t, err)
+	for fp := model.Fingerprint(0); fp < 100; fp++ {
+		var matched int
+		for _, selector := range selectors {
+			matchers, err := parser.ParseMetricSelector(selector)
+			require.NoError(t, err)
+			s, _, err := shard.FromMatchers(matchers)
+			require.NoError(t, err)
+			if s.Match(fp) {
+				matched++
+			}
+		}
+		require.Equal(t, 1, matched, "fingerprint %d", fp)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/bufbuild/connect-go"
@@ -23,8 +25,10 @@ import (
 	phlareobj "github.com/grafana/phlare/pkg/objstore"
 	phlarecontext "github.com/grafana/phlare/pkg/phlare/context"
 	"github.com/grafana/phlare/pkg/phlaredb"
+	"github.com/grafana/phlare/pkg/phlaredb/tombstones"
 	"github.com/grafana/phlare/pkg/pprof"
 	"github.com/grafana/phlare/pkg/tenant"
+	"github.com/grafana/phlare/pkg/tenantdeletion"
 	"github.com/grafana/phlare/pkg/usagestats"
 	"github.com/grafana/phlare/pkg/util"
 	"github.com/grafana/phlare/pkg/validation"
@@ -62,8 +66,10 @@ type Ingester struct {
 	instances    map[string]*instance
 	instancesMtx sync.RWMutex
 
-	limits Limits
-	reg    prometheus.Registerer
+	limits        Limits
+	deletionMarks *tenantdeletion.Marks
+	tombstones    *tombstones.Cache
+	reg           prometheus.Registerer
 }
 
 type ingesterFlusherCompat struct {
@@ -77,7 +83,7 @@ func (i *ingesterFlusherCompat) Flush() {
 	}
 }
 
-func New(phlarectx context.Context, cfg Config, dbConfig phlaredb.Config, storageBucket phlareobj.Bucket, limits Limits) (*Ingester, error) {
+func New(phlarectx context.Context, cfg Config, dbConfig phlaredb.Config, storageBucket phlareobj.Bucket, limits Limits, deletionMarks *tenantdeletion.Marks, tombstones *tombstones.Cache) (*Ingester, error) {
 	i := &Ingester{
 		cfg:           cfg,
 		phlarectx:     phlarectx,
@@ -87,6 +93,11 @@ func New(phlarectx context.Context, cfg Config, dbConfig phlaredb.Config, storag
 		dbConfig:      dbConfig,
 		storageBucket: storageBucket,
 		limits:        limits,
+		deletionMarks: deletionMarks,
+		tombstones:    tombstones,
+	}
+	if deletionMarks != nil {
+		deletionMarks.OnMarked(i.deleteTenant)
 	}
 
 	var err error
@@ -147,6 +158,9 @@ func (i *Ingester) GetOrCreateInstance(tenantID string) (*instance, error) { //n
 	defer i.instancesMtx.Unlock()
 	inst, ok = i.instances[tenantID]
 	if !ok {
+		if i.deletionMarks.IsMarked(tenantID) {
+			return nil, tenantdeletion.ErrTenantMarked
+		}
 		var err error
 
 		inst, err = newInstance(i.phlarectx, i.dbConfig, tenantID, i.storageBucket, NewLimiter(tenantID, i.limits, i.lifecycler, i.cfg.LifecyclerConfig.RingConfig.ReplicationFactor))
@@ -187,12 +201,43 @@ func (i *Ingester) forInstance(ctx context.Context, f func(*instance) error) err
 		return connect.NewError(connect.CodeInvalidArgument, err)
 	}
 	instance, err := i.GetOrCreateInstance(tenantID)
+	if errors.Is(err, tenantdeletion.ErrTenantMarked) {
+		return connect.NewError(connect.CodeFailedPrecondition, err)
+	}
 	if err != nil {
 		return connect.NewError(connect.CodeInternal, err)
 	}
+	// The instance may have been deleted since it was looked up.
+	if !instance.acquire() {
+		return connect.NewError(connect.CodeFailedPrecondition, tenantdeletion.ErrTenantMarked)
+	}
+	defer instance.release()
 	return f(instance)
 }
 
+// deleteTenant drops the instance of a tenant marked for deletion, along with
+// all its local data, once its in-flight requests are done. The blocks that
+// have not been shipped are discarded.
+func (i *Ingester) deleteTenant(_ context.Context, tenantID string) error {
+	// The instance can't be re-created once the tenant is marked.
+	i.instancesMtx.Lock()
+	inst, ok := i.instances[tenantID]
+	delete(i.instances, tenantID)
+	activeTenantsStats.Set(int64(len(i.instances)))
+	i.instancesMtx.Unlock()
+	if ok {
+		if err := inst.Delete(); err != nil {
+			level.Warn(i.logger).Log("msg", "failed to stop deleted tenant instance", "tenant", tenantID, "err", err)
+		}
+	}
+	dir := filepath.Join(i.dbConfig.DataPath, tenantID)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove tenant data %s: %w", dir, err)
+	}
+	level.Info(i.logger).Log("msg", "tenant data deleted", "tenant", tenantID)
+	return nil
+}
+
 func (i *Ingester) evictBlock(tenantID string, b ulid.ULID, fn func() error) (err error) {
 	// We lock instances map for writes to ensure that no new instances are
 	// created during the procedure. Otherwise, during initialization, the
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"os"
+	"path/filepath"
 	"runtime/pprof"
 	"testing"
 	"time"
@@ -25,7 +26,9 @@ import (
 	"github.com/grafana/phlare/pkg/objstore/providers/filesystem"
 	phlarecontext "github.com/grafana/phlare/pkg/phlare/context"
 	"github.com/grafana/phlare/pkg/phlaredb"
+	"github.com/grafana/phlare/pkg/phlaredb/bucket"
 	"github.com/grafana/phlare/pkg/tenant"
+	"github.com/grafana/phlare/pkg/tenantdeletion"
 )
 
 func defaultIngesterTestConfig(t testing.TB) Config {
@@ -72,7 +75,7 @@ func Test_MultitenantReadWrite(t *testing.T) {
 	ing, err := New(ctx, defaultIngesterTestConfig(t), phlaredb.Config{
 		DataPath:         dbPath,
 		MaxBlockDuration: 30 * time.Hour,
-	}, fs, &fakeLimits{})
+	}, fs, &fakeLimits{}, nil, nil)
 	require.NoError(t, err)
 	require.NoError(t, services.StartAndAwaitRunning(context.Background(), ing))
 
@@ -116,3 +119,62 @@ func Test_MultitenantReadWrite(t *testing.T) {
 
 	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), ing))
 }
+
+func Test_DeleteTenant(t *testing.T) {
+	dataPath := t.TempDir()
+	ctx := phlarecontext.WithLogger(context.Background(), log.NewNopLogger())
+	ctx = phlarecontext.WithRegistry(ctx, prometheus.NewRegistry())
+	bkt, err := filesystem.NewBucket(t.TempDir())
+	require.NoError(t, err)
+
+	marks := tenantdeletion.NewMarks(bkt, time.Hour, log.NewNopLogger())
+	ing, err := New(ctx, defaultIngesterTestConfig(t), phlaredb.Config{
+		DataPath:         dataPath,
+		MaxBlockDuration: 30 * time.Hour,
+	}, nil, &fakeLimits{}, marks, nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), ing))
+
+	push := func(tenantID string) error {
+		_, err := ing.Push(tenant.InjectTenantID(context.Background(), tenantID), connect.NewRequest(&pushv1.PushRequest{
+			Series: []*pushv1.RawProfileSeries{{
+				Labels:  phlaremodel.LabelsFromStrings("foo", "bar"),
+				Samples: []*pushv1.RawSample{{ID: uuid.NewString(), RawProfile: testProfile(t)}},
+			}},
+		}))
+		return err
+	}
+	require.NoError(t, push("foo"))
+	require.NoError(t, push("buzz"))
+	require.DirExists(t, filepath.Join(dataPath, "foo"))
+
+	require.NoError(t, bucket.WriteTenantDeletionMark(context.Background(), bkt, "foo", bucket.NewTenantDeletionMark(time.Now())))
+	// The marks are synced once the service starts.
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), marks))
+	require.NoDirExists(t, filepath.Join(dataPath, "foo"))
+	require.DirExists(t, filepath.Join(dataPath, "buzz"))
+
+	require.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(push("foo")))
+	require.NoError(t, push("buzz"))
+
+	// The in-flight requests are drained before the data is deleted, and the
+	// requests to the deleted instance are refused.
+	inst, ok := ing.getInstanceByID("buzz")
+	require.True(t, ok)
+	require.True(t, inst.acquire())
+	deleted := make(chan error)
+	go func() { deleted <- ing.deleteTenant(context.Background(), "buzz") }()
+	select {
+	case <-deleted:
+		t.Fatal("the tenant was deleted with a request in-flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	require.DirExists(t, filepath.Join(dataPath, "buzz"))
+	inst.release()
+	require.NoError(t, <-deleted)
+	require.NoDirExists(t, filepath.Join(dataPath, "buzz"))
+	require.False(t, inst.acquire())
+
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), marks))
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), ing))
+}
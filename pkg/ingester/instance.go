@@ -27,6 +27,11 @@ type instance struct {
 	cancel   context.CancelFunc
 	wg       sync.WaitGroup
 	tenantID string
+
+	// In-flight requests, drained before the instance is deleted.
+	requestsMtx sync.Mutex
+	requests    sync.WaitGroup
+	deleted     bool
 }
 
 func newInstance(phlarectx context.Context, cfg phlaredb.Config, tenantID string, storageBucket phlareobj.Bucket, limiter Limiter) (*instance, error) {
@@ -104,3 +109,33 @@ func (i *instance) Stop() error {
 	i.wg.Wait()
 	return err
 }
+
+// acquire registers an in-flight request to the instance. It returns false
+// if the instance is deleted, release must be called otherwise.
+func (i *instance) acquire() bool {
+	i.requestsMtx.Lock()
+	defer i.requestsMtx.Unlock()
+	if i.deleted {
+		return false
+	}
+	i.requests.Add(1)
+	return true
+}
+
+// release unregisters an in-flight request.
+func (i *instance) release() { i.requests.Done() }
+
+// Delete stops the instance without shipping the blocks that have not been
+// uploaded yet. The new requests are refused, and the in-flight requests are
+// waited for before the instance is stopped.
+func (i *instance) Delete() error {
+	i.requestsMtx.Lock()
+	i.deleted = true
+	i.requestsMtx.Unlock()
+	i.requests.Wait()
+
+	i.shipperLock.Lock()
+	i.shipper = nil
+	i.shipperLock.Unlock()
+	return i.Stop()
+}
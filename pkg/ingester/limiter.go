@@ -9,6 +9,7 @@ import (
 	"github.com/samber/lo"
 
 	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/phlaredb"
 	"github.com/grafana/phlare/pkg/util"
 	"github.com/grafana/phlare/pkg/validation"
 )
@@ -23,6 +24,7 @@ type RingCount interface {
 }
 
 type Limits interface {
+	phlaredb.QueryLimits
 	MaxLocalSeriesPerTenant(tenantID string) int
 	MaxGlobalSeriesPerTenant(tenantID string) int
 	IngestionTenantShardSize(tenantID string) int
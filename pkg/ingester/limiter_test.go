@@ -14,9 +14,12 @@ import (
 )
 
 type fakeLimits struct {
-	maxLocalSeriesPerTenant  int
-	maxGlobalSeriesPerTenant int
-	ingestionTenantShardSize int
+	maxLocalSeriesPerTenant     int
+	maxGlobalSeriesPerTenant    int
+	ingestionTenantShardSize    int
+	maxQuerySeries              int
+	maxQueryProfiles            int
+	maxQueryStacktracesPerBlock int
 }
 
 func (f *fakeLimits) MaxLocalSeriesPerTenant(userID string) int {
@@ -31,6 +34,18 @@ func (f *fakeLimits) IngestionTenantShardSize(userID string) int {
 	return f.ingestionTenantShardSize
 }
 
+func (f *fakeLimits) MaxQuerySeries(userID string) int {
+	return f.maxQuerySeries
+}
+
+func (f *fakeLimits) MaxQueryProfiles(userID string) int {
+	return f.maxQueryProfiles
+}
+
+func (f *fakeLimits) MaxQueryStacktracesPerBlock(userID string) int {
+	return f.maxQueryStacktracesPerBlock
+}
+
 type fakeRingCount struct {
 	healthyInstancesCount int
 }
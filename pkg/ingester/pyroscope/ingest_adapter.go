@@ -1,7 +1,9 @@
 package pyroscope
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -13,12 +15,15 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/pyroscope-io/pyroscope/pkg/ingestion"
 	"github.com/pyroscope-io/pyroscope/pkg/storage"
+	"github.com/pyroscope-io/pyroscope/pkg/storage/segment"
 	"github.com/pyroscope-io/pyroscope/pkg/storage/tree"
 	"google.golang.org/protobuf/proto"
 
+	profilev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
 	pushv1 "github.com/grafana/phlare/api/gen/proto/go/push/v1"
 	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
 	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/pprof"
 )
 
 type PushService interface {
@@ -37,6 +42,9 @@ type pyroscopeIngesterAdapter struct {
 }
 
 func (p *pyroscopeIngesterAdapter) Ingest(ctx context.Context, in *ingestion.IngestInput) error {
+	if raw, ok := in.Profile.(*nativeRawProfile); ok {
+		return p.pushNative(ctx, raw, in.Metadata)
+	}
 	return in.Profile.Parse(ctx, p, p, in.Metadata)
 }
 
@@ -93,9 +101,14 @@ func (p *pyroscopeIngesterAdapter) Put(ctx context.Context, pi *storage.PutInput
 			fmt.Errorf("pyroscopeIngesterAdapter failed to marshal pprof: %w", err),
 		)
 	}
+	return p.push(ctx, pi.Key, metric, app, pi.SpyName, b)
+}
+
+// push pushes the pprof profile with the labels of the key.
+func (p *pyroscopeIngesterAdapter) push(ctx context.Context, key *segment.Key, metric, app, spyName string, b []byte) error {
 	req := &pushv1.PushRequest{}
 	series := &pushv1.RawProfileSeries{
-		Labels: make([]*typesv1.LabelPair, 0, 3+len(pi.Key.Labels())),
+		Labels: make([]*typesv1.LabelPair, 0, 3+len(key.Labels())),
 	}
 	series.Labels = append(series.Labels, &typesv1.LabelPair{
 		Name:  labels.MetricName,
@@ -104,14 +117,14 @@ func (p *pyroscopeIngesterAdapter) Put(ctx context.Context, pi *storage.PutInput
 		Name:  phlaremodel.LabelNameDelta,
 		Value: "false",
 	})
-	if pi.SpyName != "" {
+	if spyName != "" {
 		series.Labels = append(series.Labels, &typesv1.LabelPair{
 			Name:  "pyroscope_spy",
-			Value: pi.SpyName,
+			Value: spyName,
 		})
 	}
 	hasServiceName := false
-	for k, v := range pi.Key.Labels() {
+	for k, v := range key.Labels() {
 		if strings.HasPrefix(k, "__") {
 			continue
 		}
@@ -140,13 +153,94 @@ func (p *pyroscopeIngesterAdapter) Put(ctx context.Context, pi *storage.PutInput
 		ID:         uuid.New().String(),
 	}}
 	req.Series = append(req.Series, series)
-	_, err = p.svc.Push(ctx, connect.NewRequest(req))
+	_, err := p.svc.Push(ctx, connect.NewRequest(req))
 	if err != nil {
 		return fmt.Errorf("pyroscopeIngesterAdapter failed to push: %w", err)
 	}
 	return nil
 }
 
+// pushNative converts the profile into pprof natively, rather than through
+// the tree of Pyroscope, to keep the lines and the sample types.
+func (p *pyroscopeIngesterAdapter) pushNative(ctx context.Context, raw *nativeRawProfile, md ingestion.Metadata) error {
+	var (
+		prof   *profilev1.Profile
+		metric string
+		err    error
+	)
+	switch raw.Format {
+	case formatFolded:
+		prof, metric, err = foldedProfile(raw.RawData, md)
+	case formatPerfScript:
+		prof, err = pprof.ParsePerfScript(bytes.NewReader(raw.RawData))
+		metric = pprof.PerfScriptProfileName
+	default:
+		prof, err = pprof.FromJSON(raw.RawData)
+		metric = metricProcessCPU
+		if err == nil {
+			switch prof.StringTable[prof.SampleType[0].Type] {
+			case stTypeWall:
+				metric = metricWall
+			case "alloc_space":
+				metric = metricMemory
+			}
+		}
+	}
+	if err != nil {
+		return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("pyroscopeIngesterAdapter failed to convert profile: %w", err))
+	}
+	prof.TimeNanos = md.StartTime.UnixNano()
+	app := md.Key.AppName()
+	if _, _, _, a, err := convertMetadata(&storage.PutInput{Key: md.Key}); err == nil {
+		app = a
+	}
+	b, err := prof.MarshalVT()
+	if err != nil {
+		return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("pyroscopeIngesterAdapter failed to marshal pprof: %w", err))
+	}
+	return p.push(ctx, md.Key, metric, app, md.SpyName, b)
+}
+
+// foldedProfile converts the folded stacks into pprof, with the profile
+// type given by the application name, like Put does for the trees.
+func foldedProfile(data []byte, md ingestion.Metadata) (*profilev1.Profile, string, error) {
+	metric, stType, stUnit, _, err := convertMetadata(&storage.PutInput{Key: md.Key})
+	if err != nil {
+		return nil, "", err
+	}
+	t, err := phlaremodel.ParseFolded(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if t.Total() == 0 {
+		return nil, "", errors.New("the profile has no samples")
+	}
+	ty := &typesv1.ProfileType{
+		Name:       metric,
+		SampleType: stType,
+		SampleUnit: stUnit,
+		PeriodType: stType,
+		PeriodUnit: stUnit,
+	}
+	var period int64
+	if md.SampleRate != 0 && (metric == metricWall || metric == metricProcessCPU) {
+		period = time.Second.Nanoseconds() / int64(md.SampleRate)
+		ty.SampleType, ty.SampleUnit = stTypeCPU, stUnitNanos
+		if metric == metricWall {
+			ty.SampleType = stTypeWall
+		}
+		ty.PeriodType, ty.PeriodUnit = stTypeCPU, stUnitNanos
+	}
+	prof := t.Pprof(ty, md.StartTime.UnixNano())
+	if period != 0 {
+		prof.Period = period
+		for _, s := range prof.Sample {
+			s.Value[0] *= period
+		}
+	}
+	return prof, metric, nil
+}
+
 func (p *pyroscopeIngesterAdapter) Evaluate(input *storage.PutInput) (storage.SampleObserver, bool) {
 	return nil, false // noop
 }
@@ -0,0 +1,84 @@
+package pyroscope
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+
+	pushv1 "github.com/grafana/phlare/api/gen/proto/go/push/v1"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/pprof"
+)
+
+type fakePushService struct {
+	requests []*pushv1.PushRequest
+}
+
+func (f *fakePushService) Push(_ context.Context, req *connect.Request[pushv1.PushRequest]) (*connect.Response[pushv1.PushResponse], error) {
+	f.requests = append(f.requests, req.Msg)
+	return connect.NewResponse(&pushv1.PushResponse{}), nil
+}
+
+func Test_IngestNative(t *testing.T) {
+	svc := new(fakePushService)
+	h := NewPyroscopeIngestHandler(svc, log.NewNopLogger())
+	do := func(params url.Values, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/ingest?"+params.Encode(), strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusBadRequest, do(url.Values{"name": {"svc.foo"}, "format": {"folded"}}, "main 1").Code)
+	require.Equal(t, http.StatusBadRequest, do(url.Values{"name": {"svc"}, "format": {"folded"}}, "main").Code)
+	require.Equal(t, http.StatusBadRequest, do(url.Values{"name": {"svc"}, "format": {"folded"}}, "").Code)
+	require.Equal(t, http.StatusRequestEntityTooLarge, do(url.Values{"name": {"svc"}, "format": {"folded"}}, strings.Repeat("main 1\n", maxNativeProfileSize/7+1)).Code)
+	require.Empty(t, svc.requests)
+
+	const folded = "main;foo 2\nmain;foo;bar 4\n"
+	rec := do(url.Values{
+		"name":       {"svc.cpu{cluster=us-central1}"},
+		"format":     {"folded"},
+		"sampleRate": {"100"},
+		"from":       {"1687869700"},
+	}, folded)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, svc.requests, 1)
+
+	series := svc.requests[0].Series[0]
+	require.Equal(t, `{__name__="process_cpu", __delta__="false", pyroscope_spy="unknown", cluster="us-central1", service_name="svc"}`, phlaremodel.LabelPairsString(series.Labels))
+	p, err := pprof.RawFromBytes(series.Samples[0].RawProfile)
+	require.NoError(t, err)
+	defer p.Close()
+	require.Equal(t, int64(1687869700000000000), p.TimeNanos)
+	require.Equal(t, "cpu", p.StringTable[p.SampleType[0].Type])
+	require.Equal(t, "nanoseconds", p.StringTable[p.SampleType[0].Unit])
+	require.Equal(t, int64(10000000), p.Period)
+	var buf bytes.Buffer
+	require.NoError(t, phlaremodel.TreeFromProfile(p.Profile, 0).WriteFolded(&buf))
+	require.Equal(t, "main;foo 20000000\nmain;foo;bar 40000000\n", buf.String())
+
+	perfScript, err := os.ReadFile("../../pprof/testdata/perf-script/cpu-clock.txt")
+	require.NoError(t, err)
+	rec = do(url.Values{
+		"name":   {"svc"},
+		"format": {"perf-script"},
+	}, string(perfScript))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, svc.requests, 2)
+	series = svc.requests[1].Series[0]
+	require.Equal(t, `{__name__="perf", __delta__="false", pyroscope_spy="unknown", service_name="svc"}`, phlaremodel.LabelPairsString(series.Labels))
+	p, err = pprof.RawFromBytes(series.Samples[0].RawProfile)
+	require.NoError(t, err)
+	defer p.Close()
+	require.NotEmpty(t, p.Sample)
+}
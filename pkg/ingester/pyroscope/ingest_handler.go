@@ -2,6 +2,7 @@ package pyroscope
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,8 +15,6 @@ import (
 
 	"github.com/grafana/phlare/pkg/util/connectgrpc"
 
-	"github.com/pyroscope-io/pyroscope/pkg/convert/speedscope"
-
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 
@@ -24,6 +23,7 @@ import (
 	"github.com/pyroscope-io/pyroscope/pkg/convert/pprof"
 	"github.com/pyroscope-io/pyroscope/pkg/convert/profile"
 	"github.com/pyroscope-io/pyroscope/pkg/ingestion"
+	"github.com/pyroscope-io/pyroscope/pkg/storage"
 	"github.com/pyroscope-io/pyroscope/pkg/storage/metadata"
 	"github.com/pyroscope-io/pyroscope/pkg/storage/segment"
 	"github.com/pyroscope-io/pyroscope/pkg/util/attime"
@@ -45,9 +45,17 @@ func NewIngestHandler(l log.Logger, p ingestion.Ingester) http.Handler {
 }
 
 func (h ingestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if nativeFormat(r.URL.Query().Get("format")) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxNativeProfileSize)
+	}
 	input, err := h.ingestInputFromRequest(r)
 	if err != nil {
 		_ = h.log.Log("msg", "bad request", "err", err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -161,9 +169,19 @@ func (h ingestHandler) ingestInputFromRequest(r *http.Request) (*ingestion.Inges
 
 	case format == "speedscope":
 		input.Format = ingestion.FormatSpeedscope
-		input.Profile = &speedscope.RawProfile{
-			RawData: b,
-		}
+		input.Profile = &nativeRawProfile{Format: input.Format, RawData: b}
+
+	case format == string(formatCPUProfile):
+		input.Format = formatCPUProfile
+		input.Profile = &nativeRawProfile{Format: input.Format, RawData: b}
+
+	case format == string(formatFolded):
+		input.Format = formatFolded
+		input.Profile = &nativeRawProfile{Format: input.Format, RawData: b}
+
+	case format == string(formatPerfScript):
+		input.Format = formatPerfScript
+		input.Profile = &nativeRawProfile{Format: input.Format, RawData: b}
 
 	case strings.Contains(contentType, "multipart/form-data"):
 		input.Profile = &pprof.RawProfile{
@@ -191,3 +209,46 @@ func copyBody(r *http.Request) ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+const (
+	// formatCPUProfile is the .cpuprofile format of the Chrome DevTools.
+	formatCPUProfile ingestion.Format = "cpuprofile"
+	// formatFolded is the folded stacks format, e.g. "main;foo;bar 42".
+	formatFolded ingestion.Format = "folded"
+	// formatPerfScript is the text output of perf script.
+	formatPerfScript ingestion.Format = "perf-script"
+)
+
+// maxNativeProfileSize is the maximum size of the body of the requests of
+// the profiles converted natively, which are parsed in memory at once.
+const maxNativeProfileSize = 64 << 20
+
+func nativeFormat(format string) bool {
+	switch ingestion.Format(format) {
+	case ingestion.FormatSpeedscope, formatCPUProfile, formatFolded, formatPerfScript:
+		return true
+	}
+	return false
+}
+
+// nativeRawProfile is a profile converted natively into pprof by the
+// adapter, rather than through the tree of Pyroscope: Speedscope, Chrome
+// DevTools .cpuprofile, folded stacks and perf script.
+type nativeRawProfile struct {
+	Format  ingestion.Format
+	RawData []byte
+}
+
+func (p *nativeRawProfile) Parse(context.Context, storage.Putter, storage.MetricsExporter, ingestion.Metadata) error {
+	return errors.New("native profiles are pushed as pprof")
+}
+
+func (p *nativeRawProfile) Bytes() ([]byte, error) { return p.RawData, nil }
+
+func (p *nativeRawProfile) ContentType() string {
+	switch p.Format {
+	case formatFolded, formatPerfScript:
+		return "text/plain"
+	}
+	return "application/json"
+}
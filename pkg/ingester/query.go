@@ -7,11 +7,17 @@ import (
 
 	ingestv1 "github.com/grafana/phlare/api/gen/proto/go/ingester/v1"
 	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/phlaredb"
 )
 
 // LabelValues returns the possible label values for a given label name.
 func (i *Ingester) LabelValues(ctx context.Context, req *connect.Request[typesv1.LabelValuesRequest]) (*connect.Response[typesv1.LabelValuesResponse], error) {
 	return forInstanceUnary(ctx, i, func(instance *instance) (*connect.Response[typesv1.LabelValuesResponse], error) {
+		ctx, err := i.queryContext(ctx, instance)
+		if err != nil {
+			return nil, err
+		}
 		return instance.LabelValues(ctx, req)
 	})
 }
@@ -19,6 +25,10 @@ func (i *Ingester) LabelValues(ctx context.Context, req *connect.Request[typesv1
 // LabelNames returns the possible label names.
 func (i *Ingester) LabelNames(ctx context.Context, req *connect.Request[typesv1.LabelNamesRequest]) (*connect.Response[typesv1.LabelNamesResponse], error) {
 	return forInstanceUnary(ctx, i, func(instance *instance) (*connect.Response[typesv1.LabelNamesResponse], error) {
+		ctx, err := i.queryContext(ctx, instance)
+		if err != nil {
+			return nil, err
+		}
 		return instance.LabelNames(ctx, req)
 	})
 }
@@ -26,6 +36,10 @@ func (i *Ingester) LabelNames(ctx context.Context, req *connect.Request[typesv1.
 // ProfileTypes returns the possible profile types.
 func (i *Ingester) ProfileTypes(ctx context.Context, req *connect.Request[ingestv1.ProfileTypesRequest]) (*connect.Response[ingestv1.ProfileTypesResponse], error) {
 	return forInstanceUnary(ctx, i, func(instance *instance) (*connect.Response[ingestv1.ProfileTypesResponse], error) {
+		ctx, err := i.queryContext(ctx, instance)
+		if err != nil {
+			return nil, err
+		}
 		return instance.ProfileTypes(ctx, req)
 	})
 }
@@ -33,24 +47,69 @@ func (i *Ingester) ProfileTypes(ctx context.Context, req *connect.Request[ingest
 // Series returns labels series for the given set of matchers.
 func (i *Ingester) Series(ctx context.Context, req *connect.Request[ingestv1.SeriesRequest]) (*connect.Response[ingestv1.SeriesResponse], error) {
 	return forInstanceUnary(ctx, i, func(instance *instance) (*connect.Response[ingestv1.SeriesResponse], error) {
+		ctx, err := i.queryContext(ctx, instance)
+		if err != nil {
+			return nil, err
+		}
 		return instance.Series(ctx, req)
 	})
 }
 
 func (i *Ingester) MergeProfilesStacktraces(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesStacktracesRequest, ingestv1.MergeProfilesStacktracesResponse]) error {
 	return i.forInstance(ctx, func(instance *instance) error {
+		ctx, err := i.queryContext(ctx, instance)
+		if err != nil {
+			return err
+		}
 		return instance.MergeProfilesStacktraces(ctx, stream)
 	})
 }
 
 func (i *Ingester) MergeProfilesLabels(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesLabelsRequest, ingestv1.MergeProfilesLabelsResponse]) error {
 	return i.forInstance(ctx, func(instance *instance) error {
+		ctx, err := i.queryContext(ctx, instance)
+		if err != nil {
+			return err
+		}
 		return instance.MergeProfilesLabels(ctx, stream)
 	})
 }
 
 func (i *Ingester) MergeProfilesPprof(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesPprofRequest, ingestv1.MergeProfilesPprofResponse]) error {
 	return i.forInstance(ctx, func(instance *instance) error {
+		ctx, err := i.queryContext(ctx, instance)
+		if err != nil {
+			return err
+		}
 		return instance.MergeProfilesPprof(ctx, stream)
 	})
 }
+
+// queryContext returns the context of the queries of the instance, enforcing
+// the query limits and the tombstones of the tenant.
+func (i *Ingester) queryContext(ctx context.Context, instance *instance) (context.Context, error) {
+	ts, err := i.tombstones.Get(ctx, instance.tenantID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return phlaredb.ContextWithTombstones(phlaredb.ContextWithQueryLimiter(ctx, i.limits, instance.tenantID), ts), nil
+}
+
+// Cardinality returns the cardinality of the series of the instance of the
+// tenant, the querier merges it with the ones of the other ingesters.
+func (i *Ingester) Cardinality(ctx context.Context, req *connect.Request[typesv1.CardinalityRequest]) (*connect.Response[typesv1.CardinalityResponse], error) {
+	if err := phlaremodel.ValidateCardinalityRequest(req.Msg); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	return forInstanceUnary(ctx, i, func(instance *instance) (*connect.Response[typesv1.CardinalityResponse], error) {
+		ctx, err := i.queryContext(ctx, instance)
+		if err != nil {
+			return nil, err
+		}
+		series, err := instance.SeriesTimeRanges(ctx, req.Msg)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+		return connect.NewResponse(phlaremodel.NewCardinalityResponse(req.Msg, series)), nil
+	})
+}
@@ -0,0 +1,290 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	pmodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+)
+
+const (
+	defaultCardinalityLimit = 10
+	defaultCardinalityRange = 24 * time.Hour
+	// defaultCardinalityPoints is the number of intervals of the series churn
+	// when no step is given.
+	defaultCardinalityPoints = 24
+)
+
+// ValidateCardinalityRequest validates the request and sets its defaults: the
+// last 24 hours divided in 24 intervals, and the top 10 values per label.
+// The sources of the series must be given the validated request, so that
+// their series churns share the same intervals.
+func ValidateCardinalityRequest(req *typesv1.CardinalityRequest) error {
+	if req.ProfileTypeID != "" {
+		if _, err := ParseProfileTypeSelector(req.ProfileTypeID); err != nil {
+			return err
+		}
+	}
+	if req.End == 0 {
+		req.End = int64(pmodel.Now())
+	}
+	if req.Start == 0 {
+		req.Start = req.End - defaultCardinalityRange.Milliseconds()
+	}
+	if req.End < req.Start {
+		return fmt.Errorf("the end %d is before the start %d", req.End, req.Start)
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultCardinalityLimit
+	}
+	if req.Step <= 0 {
+		req.Step = (req.End - req.Start) / defaultCardinalityPoints
+	}
+	if req.Step <= 0 {
+		req.Step = 1
+	}
+	return nil
+}
+
+// CardinalityMatchers returns the label matchers of the series of the request.
+func CardinalityMatchers(req *typesv1.CardinalityRequest) ([]*labels.Matcher, error) {
+	if req.ProfileTypeID == "" {
+		return []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, LabelNameProfileType, ".+")}, nil
+	}
+	profileType, err := ParseProfileTypeSelector(req.ProfileTypeID)
+	if err != nil {
+		return nil, err
+	}
+	return []*labels.Matcher{SelectorFromProfileType(profileType)}, nil
+}
+
+// SeriesTimeRange is a series and the time range of its profiles, in
+// milliseconds.
+type SeriesTimeRange struct {
+	Labels  Labels
+	MinTime int64
+	MaxTime int64
+}
+
+// SeriesTimeRanges deduplicates the series by labels, the time range of a
+// series being the union of its time ranges.
+type SeriesTimeRanges struct {
+	series map[uint64]*SeriesTimeRange
+}
+
+func NewSeriesTimeRanges() *SeriesTimeRanges {
+	return &SeriesTimeRanges{series: make(map[uint64]*SeriesTimeRange)}
+}
+
+func (s *SeriesTimeRanges) Add(series ...*SeriesTimeRange) {
+	for _, r := range series {
+		h := r.Labels.Hash()
+		existing, ok := s.series[h]
+		if !ok {
+			s.series[h] = &SeriesTimeRange{Labels: r.Labels, MinTime: r.MinTime, MaxTime: r.MaxTime}
+			continue
+		}
+		if r.MinTime < existing.MinTime {
+			existing.MinTime = r.MinTime
+		}
+		if r.MaxTime > existing.MaxTime {
+			existing.MaxTime = r.MaxTime
+		}
+	}
+}
+
+// Series returns the series sorted by labels.
+func (s *SeriesTimeRanges) Series() []*SeriesTimeRange {
+	res := make([]*SeriesTimeRange, 0, len(s.series))
+	for _, r := range s.series {
+		res = append(res, r)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return CompareLabelPairs(res[i].Labels, res[j].Labels) < 0
+	})
+	return res
+}
+
+// NewCardinalityResponse computes the cardinality of the deduplicated series
+// of a validated request.
+func NewCardinalityResponse(req *typesv1.CardinalityRequest, series []*SeriesTimeRange) *typesv1.CardinalityResponse {
+	res := &typesv1.CardinalityResponse{
+		Series: int64(len(series)),
+		Labels: []*typesv1.LabelCardinality{},
+		Churn:  []*typesv1.SeriesChurn{},
+	}
+	values := make(map[string]map[string]int64)
+	for _, s := range series {
+		for _, l := range s.Labels {
+			v, ok := values[l.Name]
+			if !ok {
+				v = make(map[string]int64)
+				values[l.Name] = v
+			}
+			v[l.Value]++
+		}
+	}
+	for name, v := range values {
+		lc := &typesv1.LabelCardinality{Name: name, Values: int64(len(v))}
+		for value, n := range v {
+			lc.Series += n
+			lc.TopValues = append(lc.TopValues, &typesv1.LabelValueCardinality{Value: value, Series: n})
+		}
+		res.Labels = append(res.Labels, lc)
+	}
+	sortLabelCardinalities(res.Labels, req.Limit)
+
+	if req.Step <= 0 || req.End < req.Start {
+		return res
+	}
+	for t := req.Start; t <= req.End; t += req.Step {
+		res.Churn = append(res.Churn, &typesv1.SeriesChurn{Timestamp: t})
+	}
+	interval := func(t int64) int {
+		return int((t - req.Start) / req.Step)
+	}
+	for _, s := range series {
+		if s.MaxTime < req.Start || s.MinTime > req.End {
+			continue
+		}
+		first, last := 0, len(res.Churn)-1
+		if s.MinTime >= req.Start {
+			first = interval(s.MinTime)
+			res.Churn[first].Created++
+		}
+		if s.MaxTime <= req.End {
+			last = interval(s.MaxTime)
+			res.Churn[last].Ended++
+		}
+		for i := first; i <= last; i++ {
+			res.Churn[i].Active++
+		}
+	}
+	return res
+}
+
+// sortLabelCardinalities sorts the labels by decreasing number of series then
+// by name, and keeps the top values of each label up to the limit.
+func sortLabelCardinalities(lcs []*typesv1.LabelCardinality, limit int64) {
+	for _, lc := range lcs {
+		sort.Slice(lc.TopValues, func(i, j int) bool {
+			if lc.TopValues[i].Series != lc.TopValues[j].Series {
+				return lc.TopValues[i].Series > lc.TopValues[j].Series
+			}
+			return lc.TopValues[i].Value < lc.TopValues[j].Value
+		})
+		if limit >= 0 && int64(len(lc.TopValues)) > limit {
+			lc.TopValues = lc.TopValues[:limit]
+		}
+	}
+	sort.Slice(lcs, func(i, j int) bool {
+		if lcs[i].Series != lcs[j].Series {
+			return lcs[i].Series > lcs[j].Series
+		}
+		return lcs[i].Name < lcs[j].Name
+	})
+}
+
+// SumCardinalityResponses estimates the cardinality of the series replicated
+// across the sources of the responses: the numbers of series are summed and
+// divided by replicas, the average number of responses reporting a series.
+// The sources only report their top values, and the number of distinct values
+// of a label is the highest reported, so both are lower bounds.
+func SumCardinalityResponses(limit int64, replicas float64, responses ...*typesv1.CardinalityResponse) *typesv1.CardinalityResponse {
+	if replicas <= 0 {
+		replicas = 1
+	}
+	res := mergeCardinalityResponses(responses, func(a, b int64) int64 { return a + b })
+	scale := func(n *int64) { *n = int64(math.Round(float64(*n) / replicas)) }
+	scale(&res.Series)
+	for _, lc := range res.Labels {
+		scale(&lc.Series)
+		for _, v := range lc.TopValues {
+			scale(&v.Series)
+		}
+	}
+	for _, c := range res.Churn {
+		scale(&c.Active)
+		scale(&c.Created)
+		scale(&c.Ended)
+	}
+	sortLabelCardinalities(res.Labels, limit)
+	return res
+}
+
+// MaxCardinalityResponses combines the estimates of sources sharing series,
+// such as the ingesters and the store-gateways, by keeping the highest
+// number of series reported.
+func MaxCardinalityResponses(limit int64, responses ...*typesv1.CardinalityResponse) *typesv1.CardinalityResponse {
+	res := mergeCardinalityResponses(responses, func(a, b int64) int64 {
+		if a > b {
+			return a
+		}
+		return b
+	})
+	sortLabelCardinalities(res.Labels, limit)
+	return res
+}
+
+// mergeCardinalityResponses merges the numbers of series of the responses
+// with the merge function, the number of distinct values of a label being the
+// highest reported.
+func mergeCardinalityResponses(responses []*typesv1.CardinalityResponse, merge func(a, b int64) int64) *typesv1.CardinalityResponse {
+	var (
+		res = &typesv1.CardinalityResponse{
+			Labels: []*typesv1.LabelCardinality{},
+			Churn:  []*typesv1.SeriesChurn{},
+		}
+		names  = make(map[string]*typesv1.LabelCardinality)
+		values = make(map[string]map[string]*typesv1.LabelValueCardinality)
+		churn  = make(map[int64]*typesv1.SeriesChurn)
+	)
+	for _, r := range responses {
+		if r == nil {
+			continue
+		}
+		res.Series = merge(res.Series, r.Series)
+		for _, l := range r.Labels {
+			lc, ok := names[l.Name]
+			if !ok {
+				lc = &typesv1.LabelCardinality{Name: l.Name}
+				names[l.Name] = lc
+				values[l.Name] = make(map[string]*typesv1.LabelValueCardinality)
+				res.Labels = append(res.Labels, lc)
+			}
+			lc.Series = merge(lc.Series, l.Series)
+			if l.Values > lc.Values {
+				lc.Values = l.Values
+			}
+			for _, v := range l.TopValues {
+				vc, ok := values[l.Name][v.Value]
+				if !ok {
+					vc = &typesv1.LabelValueCardinality{Value: v.Value}
+					values[l.Name][v.Value] = vc
+					lc.TopValues = append(lc.TopValues, vc)
+				}
+				vc.Series = merge(vc.Series, v.Series)
+			}
+		}
+		for _, c := range r.Churn {
+			sc, ok := churn[c.Timestamp]
+			if !ok {
+				sc = &typesv1.SeriesChurn{Timestamp: c.Timestamp}
+				churn[c.Timestamp] = sc
+				res.Churn = append(res.Churn, sc)
+			}
+			sc.Active = merge(sc.Active, c.Active)
+			sc.Created = merge(sc.Created, c.Created)
+			sc.Ended = merge(sc.Ended, c.Ended)
+		}
+	}
+	sort.Slice(res.Churn, func(i, j int) bool {
+		return res.Churn[i].Timestamp < res.Churn[j].Timestamp
+	})
+	return res
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+)
+
+func Test_ValidateCardinalityRequest(t *testing.T) {
+	req := &typesv1.CardinalityRequest{Start: 1000, End: 25000, Limit: 3}
+	require.NoError(t, ValidateCardinalityRequest(req))
+	require.Equal(t, &typesv1.CardinalityRequest{Start: 1000, End: 25000, Limit: 3, Step: 1000}, req)
+
+	req = &typesv1.CardinalityRequest{ProfileTypeID: "memory:alloc_space:bytes:space:bytes", End: 60000, Step: 30000}
+	require.NoError(t, ValidateCardinalityRequest(req))
+	require.Equal(t, int64(30000), req.Step)
+	require.Equal(t, int64(60000)-defaultCardinalityRange.Milliseconds(), req.Start)
+	require.Equal(t, int64(defaultCardinalityLimit), req.Limit)
+	matchers, err := CardinalityMatchers(req)
+	require.NoError(t, err)
+	require.Len(t, matchers, 1)
+	require.Equal(t, `__profile_type__="memory:alloc_space:bytes:space:bytes"`, matchers[0].String())
+
+	for _, req := range []*typesv1.CardinalityRequest{
+		{Start: 2, End: 1},
+		{ProfileTypeID: "foo"},
+	} {
+		require.Error(t, ValidateCardinalityRequest(req), req)
+	}
+}
+
+func Test_SeriesTimeRanges(t *testing.T) {
+	ranges := NewSeriesTimeRanges()
+	ranges.Add(
+		&SeriesTimeRange{Labels: LabelsFromStrings("job", "b"), MinTime: 10, MaxTime: 20},
+		&SeriesTimeRange{Labels: LabelsFromStrings("job", "a"), MinTime: 10, MaxTime: 20},
+	)
+	ranges.Add(&SeriesTimeRange{Labels: LabelsFromStrings("job", "a"), MinTime: 5, MaxTime: 15})
+	require.Equal(t, []*SeriesTimeRange{
+		{Labels: LabelsFromStrings("job", "a"), MinTime: 5, MaxTime: 20},
+		{Labels: LabelsFromStrings("job", "b"), MinTime: 10, MaxTime: 20},
+	}, ranges.Series())
+}
+
+func Test_NewCardinalityResponse(t *testing.T) {
+	req := &typesv1.CardinalityRequest{Start: 0, End: 299, Limit: 2, Step: 100}
+	res := NewCardinalityResponse(req, []*SeriesTimeRange{
+		{Labels: LabelsFromStrings("job", "a", "pod", "1"), MinTime: 0, MaxTime: 50},
+		{Labels: LabelsFromStrings("job", "a", "pod", "2"), MinTime: 50, MaxTime: 150},
+		{Labels: LabelsFromStrings("job", "a", "pod", "3"), MinTime: 150, MaxTime: 250},
+		{Labels: LabelsFromStrings("job", "b"), MinTime: -100, MaxTime: 400},
+	})
+	require.Equal(t, &typesv1.CardinalityResponse{
+		Series: 4,
+		Labels: []*typesv1.LabelCardinality{
+			{Name: "job", Series: 4, Values: 2, TopValues: []*typesv1.LabelValueCardinality{{Value: "a", Series: 3}, {Value: "b", Series: 1}}},
+			{Name: "pod", Series: 3, Values: 3, TopValues: []*typesv1.LabelValueCardinality{{Value: "1", Series: 1}, {Value: "2", Series: 1}}},
+		},
+		Churn: []*typesv1.SeriesChurn{
+			{Timestamp: 0, Active: 3, Created: 2, Ended: 1},
+			{Timestamp: 100, Active: 3, Created: 1, Ended: 1},
+			{Timestamp: 200, Active: 2, Created: 0, Ended: 1},
+		},
+	}, res)
+}
+
+func Test_MergeCardinalityResponses(t *testing.T) {
+	response := func(series int64, pods ...string) *typesv1.CardinalityResponse {
+		res := &typesv1.CardinalityResponse{
+			Series: series,
+			Labels: []*typesv1.LabelCardinality{{Name: "pod", Series: series, Values: int64(len(pods))}},
+			Churn:  []*typesv1.SeriesChurn{{Timestamp: 0, Active: series}},
+		}
+		for _, pod := range pods {
+			res.Labels[0].TopValues = append(res.Labels[0].TopValues, &typesv1.LabelValueCardinality{Value: pod, Series: series / int64(len(pods))})
+		}
+		return res
+	}
+	// Two replicas of the series of the pods 1 and 2, one of the pod 3.
+	sum := SumCardinalityResponses(2, 2, response(2, "1", "2"), response(2, "1", "2"), response(1, "3"))
+	require.Equal(t, &typesv1.CardinalityResponse{
+		Series: 3,
+		Labels: []*typesv1.LabelCardinality{
+			{Name: "pod", Series: 3, Values: 2, TopValues: []*typesv1.LabelValueCardinality{{Value: "1", Series: 1}, {Value: "2", Series: 1}}},
+		},
+		Churn: []*typesv1.SeriesChurn{{Timestamp: 0, Active: 3}},
+	}, sum)
+
+	max := MaxCardinalityResponses(-1, sum, response(4, "1", "4"), nil)
+	require.Equal(t, &typesv1.CardinalityResponse{
+		Series: 4,
+		Labels: []*typesv1.LabelCardinality{
+			{Name: "pod", Series: 4, Values: 2, TopValues: []*typesv1.LabelValueCardinality{
+				{Value: "1", Series: 2}, {Value: "4", Series: 2}, {Value: "2", Series: 1},
+			}},
+		},
+		Churn: []*typesv1.SeriesChurn{{Timestamp: 0, Active: 4}},
+	}, max)
+}
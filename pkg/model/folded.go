@@ -0,0 +1,73 @@
+package model
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxFoldedLineSize is the maximum size of a line of the folded format.
+const maxFoldedLineSize = 16 << 20
+
+// ParseFolded parses stacks in the folded format, also known as collapsed
+// stacks: one stack per line, with the frames ordered from the root and
+// separated by semicolons, followed by a space and the value of the stack,
+// e.g. "main;foo;bar 42". The values of duplicate stacks are summed up.
+func ParseFolded(r io.Reader) (*Tree, error) {
+	t := new(Tree)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxFoldedLineSize)
+	var n int
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: missing the value of the stack", n)
+		}
+		v, err := strconv.ParseInt(line[i+1:], 10, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("line %d: invalid value %q", n, line[i+1:])
+		}
+		stack := strings.TrimRight(line[:i], " ")
+		if stack == "" {
+			return nil, fmt.Errorf("line %d: empty stack", n)
+		}
+		if v == 0 {
+			continue
+		}
+		t.InsertStack(v, strings.Split(stack, ";")...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// WriteFolded writes the stacks of the tree in the folded format, see
+// ParseFolded.
+func (t *Tree) WriteFolded(w io.Writer) (err error) {
+	bw := bufio.NewWriter(w)
+	t.IterateStacks(func(_ string, self int64, stack []string) {
+		if err != nil {
+			return
+		}
+		for i, name := range stack {
+			if i > 0 {
+				_ = bw.WriteByte(';')
+			}
+			_, _ = bw.WriteString(name)
+		}
+		_ = bw.WriteByte(' ')
+		_, err = bw.WriteString(strconv.FormatInt(self, 10) + "\n")
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
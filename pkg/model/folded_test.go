@@ -0,0 +1,87 @@
+package model
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	profilev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
+)
+
+func Test_ParseFolded(t *testing.T) {
+	tr, err := ParseFolded(strings.NewReader(`
+main;foo;bar 1
+main;foo 2
+main;foo;bar 3
+main;func with spaces 4
+main;baz 0
+other 5
+`))
+	require.NoError(t, err)
+
+	expected := new(Tree)
+	expected.InsertStack(4, "main", "foo", "bar")
+	expected.InsertStack(2, "main", "foo")
+	expected.InsertStack(4, "main", "func with spaces")
+	expected.InsertStack(5, "other")
+	require.Equal(t, expected.String(), tr.String())
+
+	var buf bytes.Buffer
+	require.NoError(t, tr.WriteFolded(&buf))
+	require.Equal(t, `main;foo 2
+main;foo;bar 4
+main;func with spaces 4
+other 5
+`, buf.String())
+
+	for _, tc := range []struct {
+		input string
+		err   string
+	}{
+		{"main;foo", "line 1: missing the value of the stack"},
+		{"main;foo 1\nmain;foo bar", `line 2: invalid value "bar"`},
+		{"main;foo -1", `line 1: invalid value "-1"`},
+		{" 1", "line 1: missing the value of the stack"},
+	} {
+		_, err := ParseFolded(strings.NewReader(tc.input))
+		require.EqualError(t, err, tc.err)
+	}
+}
+
+func Test_Tree_Pprof(t *testing.T) {
+	expected := randomTree(0, 1000)
+	ty, err := ParseProfileTypeSelector("process_cpu:samples:count:cpu:nanoseconds")
+	require.NoError(t, err)
+	p := expected.Pprof(ty, 42)
+	require.Equal(t, int64(42), p.TimeNanos)
+	require.Equal(t, "samples", p.StringTable[p.SampleType[0].Type])
+	require.Equal(t, "nanoseconds", p.StringTable[p.PeriodType.Unit])
+	require.Equal(t, expected.String(), TreeFromProfile(p, 0).String())
+
+	// Inlined functions and locations without lines.
+	p = &profilev1.Profile{
+		StringTable: []string{"", "main", "foo", "bar"},
+		Function: []*profilev1.Function{
+			{Id: 1, Name: 1},
+			{Id: 2, Name: 2},
+			{Id: 3, Name: 3},
+		},
+		Location: []*profilev1.Location{
+			{Id: 1, Line: []*profilev1.Line{{FunctionId: 1}}},
+			{Id: 2, Line: []*profilev1.Line{{FunctionId: 3}, {FunctionId: 2}}},
+			{Id: 3, Address: 0xff},
+		},
+		Sample: []*profilev1.Sample{
+			{LocationId: []uint64{2, 1}, Value: []int64{1, 10}},
+			{LocationId: []uint64{3, 1}, Value: []int64{2, 0}},
+		},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, TreeFromProfile(p, 1).WriteFolded(&buf))
+	require.Equal(t, "main;foo;bar 10\n", buf.String())
+	buf.Reset()
+	require.NoError(t, TreeFromProfile(p, 0).WriteFolded(&buf))
+	require.Equal(t, "main;0xff 2\nmain;foo;bar 1\n", buf.String())
+}
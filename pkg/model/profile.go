@@ -55,13 +55,18 @@ func SetProfileMetadata(p *profile.Profile, ty *typesv1.ProfileType) {
 	p.SampleType = []*profile.ValueType{{Type: ty.SampleType, Unit: ty.SampleUnit}}
 	p.DefaultSampleType = ty.SampleType
 	p.PeriodType = &profile.ValueType{Type: ty.PeriodType, Unit: ty.PeriodUnit}
-	switch ty.Name {
+	p.Period = defaultPeriod(ty.Name)
+}
+
+// defaultPeriod returns the period of the profiles of the given name.
+func defaultPeriod(name string) int64 {
+	switch name {
 	case "process_cpu": // todo: this should support other types of cpu profiles
-		p.Period = 1000000000
+		return 1000000000
 	case "memory":
-		p.Period = 512 * 1024
+		return 512 * 1024
 	default:
-		p.Period = 1
+		return 1
 	}
 }
 
@@ -221,6 +221,17 @@ func (m *StacktraceMerger) TreeBytes(maxNodes int64) []byte {
 	return b.Bytes()
 }
 
+// IterateTreeChunks calls fn for each chunk of at most chunkSize nodes of
+// the tree truncated to maxNodes, as returned by Tree.Chunks for the tree
+// of TreeBytes. The chunks are built one at a time from the merged stack
+// traces, as fn is called.
+func (m *StacktraceMerger) IterateTreeChunks(maxNodes int64, chunkSize int, fn func(*Tree) error) error {
+	if m.s == nil || len(m.s.nodes) == 0 {
+		return nil
+	}
+	return m.s.iterateChunks(maxNodes, chunkSize, m.r.names, fn)
+}
+
 func (m *StacktraceMerger) Size() int {
 	if m.s != nil {
 		return len(m.s.nodes)
@@ -433,6 +444,59 @@ func (t *stacktraceTree) bytes(dst io.Writer, maxNodes int64, funcs []string) {
 	}
 }
 
+// iterateChunks visits the tree truncated as in bytes, in depth-first order
+// with the siblings ordered by name, and calls fn for each chunk of at most
+// chunkSize nodes.
+func (t *stacktraceTree) iterateChunks(maxNodes int64, chunkSize int, funcs []string, fn func(*Tree) error) error {
+	if len(t.nodes) == 0 || len(funcs) == 0 {
+		return nil
+	}
+	min := t.minValue(maxNodes)
+	type entry struct {
+		name  string
+		self  int64
+		i     int32 // Negative for the stub of the truncated nodes.
+		depth int
+	}
+	var (
+		nodes    = make([]entry, 0, defaultDFSSize)
+		children []entry
+		c        = treeChunker{maxNodes: chunkSize, fn: fn}
+		e        = entry{i: 0, depth: -1} // The root is not part of the tree.
+	)
+	for {
+		if e.depth >= 0 {
+			if err := c.add(e.name, e.self, e.depth); err != nil {
+				return err
+			}
+		}
+		if e.i >= 0 {
+			children = children[:0]
+			var truncated int64
+			for x := t.nodes[e.i].fc; x > 0; {
+				child := &t.nodes[x]
+				if child.total >= min && child.fid != lostDuringSerializationNameReference {
+					children = append(children, entry{name: funcs[child.fid], self: child.val, i: x, depth: e.depth + 1})
+				} else {
+					truncated += child.total
+				}
+				x = child.ns
+			}
+			if truncated > 0 {
+				// Create a stub for removed nodes.
+				children = append(children, entry{name: lostDuringSerializationName, self: truncated, i: -1, depth: e.depth + 1})
+			}
+			sort.Slice(children, func(i, j int) bool { return children[i].name > children[j].name })
+			nodes = append(nodes, children...)
+		}
+		if len(nodes) == 0 {
+			break
+		}
+		e, nodes = nodes[len(nodes)-1], nodes[:len(nodes)-1]
+	}
+	return c.flush()
+}
+
 func unsafeStringBytes(s string) []byte {
 	p := unsafe.Pointer((*reflect.StringHeader)(unsafe.Pointer(&s)).Data)
 	var b []byte
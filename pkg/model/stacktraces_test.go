@@ -258,6 +258,20 @@ func TestStackTraceMerger(t *testing.T) {
 			actual, err := UnmarshalTree(yn)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected.String(), actual.String())
+
+			// The chunks are ordered by stack prefix and merged into the same tree.
+			for _, chunkSize := range []int{1, 2, 1 << 10} {
+				merged := new(Tree)
+				var last []string
+				require.NoError(t, m.IterateTreeChunks(tc.maxNodes, chunkSize, func(c *Tree) error {
+					stack := c.lastStack(nil)
+					require.Less(t, compareStacks(last, stack), 0)
+					last = stack
+					merged.Merge(c)
+					return nil
+				}))
+				require.Equal(t, tc.expected.String(), merged.String(), "chunk size: %d", chunkSize)
+			}
 		})
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 	"sync"
 
 	dvarint "github.com/dennwc/varint"
@@ -75,6 +76,11 @@ func (t *Tree) InsertStack(v int64, stack ...string) {
 const defaultDFSSize = 128
 
 func (t *Tree) Merge(src *Tree) {
+	t.merge(src)
+}
+
+// merge merges src into t and returns the number of nodes added to t.
+func (t *Tree) merge(src *Tree) (added int) {
 	srcNodes := make([]*node, 0, defaultDFSSize)
 	srcRoot := &node{children: src.root}
 	srcNodes = append(srcNodes, srcRoot)
@@ -93,13 +99,45 @@ func (t *Tree) Merge(src *Tree) {
 
 		for _, srcChildNode := range st.children {
 			// Note that we don't copy the name, but reference it.
+			n := len(dt.children)
 			dstChildNode := dt.insert(srcChildNode.name)
+			if len(dt.children) > n {
+				added++
+			}
 			srcNodes = append(srcNodes, srcChildNode)
 			dstNodes = append(dstNodes, dstChildNode)
 		}
 	}
 
 	t.root = dstRoot.children
+	return added
+}
+
+// IterateStacks calls fn for every node of the tree with a self value, in
+// depth-first order. The stack is ordered from the root to the node itself,
+// and is only valid until fn returns.
+func (t *Tree) IterateStacks(fn func(name string, self int64, stack []string)) {
+	type entry struct {
+		n     *node
+		depth int
+	}
+	nodes := make([]entry, 0, defaultDFSSize)
+	for i := len(t.root) - 1; i >= 0; i-- {
+		nodes = append(nodes, entry{n: t.root[i]})
+	}
+	stack := make([]string, 0, 64)
+	var e entry
+	for len(nodes) > 0 {
+		last := len(nodes) - 1
+		e, nodes = nodes[last], nodes[:last]
+		stack = append(stack[:e.depth], e.n.name)
+		if e.n.self > 0 {
+			fn(e.n.name, e.n.self, stack)
+		}
+		for i := len(e.n.children) - 1; i >= 0; i-- {
+			nodes = append(nodes, entry{n: e.n.children[i], depth: e.depth + 1})
+		}
+	}
 }
 
 func (n *node) String() string {
@@ -139,6 +177,12 @@ func (t *Tree) minValue(maxNodes int64) int64 {
 	for len(nodes) > 0 {
 		last := len(nodes) - 1
 		n, nodes = nodes[last], nodes[:last]
+		if n.name == lostDuringSerializationName {
+			// The "other" nodes are always kept, they don't count
+			// toward the nodes of the flamegraph.
+			nodes = append(nodes, n.children...)
+			continue
+		}
 		if h.Len() >= int(maxNodes) {
 			if n.total > (*h)[0] {
 				heap.Pop(h)
@@ -216,35 +260,221 @@ func (t *Tree) MarshalTruncate(w io.Writer, maxNodes int64) (err error) {
 			return err
 		}
 
+		n.truncateChildren(minVal)
+		if len(n.children) > 0 {
+			nodes = append(nodes, n.children...)
+		}
+		if _, err = vw.Write(w, uint64(len(n.children))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// truncateChildren removes the children of the node which total is below
+// minVal: their values are attributed to the "other" child of the node.
+func (n *node) truncateChildren(minVal int64) {
+	var other int64
+	var j int
+	for _, cn := range n.children {
+		if cn.total >= minVal || cn.name == lostDuringSerializationName {
+			n.children[j] = cn
+			j++
+		} else {
+			other += cn.total
+		}
+	}
+
+	n.children = n.children[:j]
+	if other > 0 {
+		o := n.insert(lostDuringSerializationName)
+		o.total += other
+		o.self += other
+	}
+}
+
+// truncateBefore removes the nodes that would not show up in a flamegraph of
+// maxNodes nodes, the same way MarshalTruncate does, and returns the number
+// of nodes left in the tree. Only the nodes ordered before the given stack
+// in depth-first order, and which are not its ancestors, are truncated:
+// their values are final. All the nodes are truncated if stack is nil.
+func (t *Tree) truncateBefore(maxNodes int64, stack []string) (size int, truncated bool) {
+	minVal := t.minValue(maxNodes)
+	type entry struct {
+		n     *node
+		depth int
+		// The node is an ancestor of the stack, or the node itself.
+		onPath bool
+		// The subtree of the node is ordered before the stack.
+		final bool
+	}
+	root := &node{children: t.root}
+	nodes := make([]entry, 1, defaultDFSSize)
+	nodes[0] = entry{n: root, onPath: stack != nil, final: stack == nil}
+	var e entry
+	for len(nodes) > 0 {
+		last := len(nodes) - 1
+		e, nodes = nodes[last], nodes[:last]
 		var other int64
 		var j int
-		for _, cn := range n.children {
-			if cn.total >= minVal || cn.name == lostDuringSerializationName {
-				n.children[j] = cn
-				j++
-			} else {
+		for _, cn := range e.n.children {
+			c := entry{n: cn, depth: e.depth + 1, final: e.final}
+			if e.onPath && e.depth < len(stack) {
+				switch strings.Compare(cn.name, stack[e.depth]) {
+				case -1:
+					c.final = true
+				case 0:
+					c.onPath = true
+				}
+			}
+			if c.final && cn.total < minVal && cn.name != lostDuringSerializationName {
 				other += cn.total
+				continue
 			}
+			e.n.children[j] = cn
+			j++
+			nodes = append(nodes, c)
 		}
-
-		n.children = n.children[:j]
+		e.n.children = e.n.children[:j]
+		size += j
 		if other > 0 {
-			o := n.insert(lostDuringSerializationName)
+			truncated = true
+			n := len(e.n.children)
+			o := e.n.insert(lostDuringSerializationName)
 			o.total += other
 			o.self += other
+			if len(e.n.children) > n {
+				size++
+			}
 		}
+	}
+	t.root = root.children
+	return size, truncated
+}
 
-		if len(n.children) > 0 {
-			nodes = append(nodes, n.children...)
-		}
-		if _, err = vw.Write(w, uint64(len(n.children))); err != nil {
+// TreeChunkSizeHeader is the header of the MergeProfilesStacktraces streams
+// with which a querier asks for the merged tree to be sent in chunks of at
+// most the given number of nodes. The server acknowledges it by setting the
+// same header in the response.
+const TreeChunkSizeHeader = "X-Tree-Chunk-Size"
+
+// Chunks splits the tree into trees of at most maxNodes nodes each, in the
+// depth-first order of the tree: the chunks are ordered by stack prefix.
+// Besides its own nodes, a chunk includes the ancestors of its first node,
+// which self values are accounted in the chunks they belong to. Merging
+// all the chunks results in the original tree.
+func (t *Tree) Chunks(maxNodes int) []*Tree {
+	var trees []*Tree
+	_ = t.IterateChunks(maxNodes, func(c *Tree) error {
+		trees = append(trees, c)
+		return nil
+	})
+	return trees
+}
+
+// IterateChunks calls fn for each chunk of the tree, as returned by Chunks.
+// The chunks are built one at a time, as fn is called.
+func (t *Tree) IterateChunks(maxNodes int, fn func(*Tree) error) error {
+	if maxNodes < 1 {
+		return fn(t)
+	}
+	type entry struct {
+		n     *node
+		depth int
+	}
+	nodes := make([]entry, 0, defaultDFSSize)
+	for i := len(t.root) - 1; i >= 0; i-- {
+		nodes = append(nodes, entry{n: t.root[i]})
+	}
+	c := treeChunker{maxNodes: maxNodes, fn: fn}
+	var e entry
+	for len(nodes) > 0 {
+		last := len(nodes) - 1
+		e, nodes = nodes[last], nodes[:last]
+		if err := c.add(e.n.name, e.n.self, e.depth); err != nil {
 			return err
 		}
+		for i := len(e.n.children) - 1; i >= 0; i-- {
+			nodes = append(nodes, entry{n: e.n.children[i], depth: e.depth + 1})
+		}
 	}
+	return c.flush()
+}
 
+// treeChunker builds the chunks of a tree which nodes are added in
+// depth-first order, with the siblings ordered by name. The chunks have at
+// most maxNodes nodes, if positive.
+type treeChunker struct {
+	maxNodes int
+	fn       func(*Tree) error
+
+	chunk   *node    // Virtual root of the current chunk.
+	srcPath []string // Ancestors of the current node.
+	dstPath []*node  // Ancestors of the current node in the current chunk.
+	size    int
+}
+
+// add adds the node at the given depth, which ancestors are the last nodes
+// added at the lower depths.
+func (c *treeChunker) add(name string, self int64, depth int) error {
+	c.srcPath = append(c.srcPath[:depth], name)
+	if c.chunk == nil || (c.maxNodes > 0 && c.size >= c.maxNodes) {
+		if err := c.flush(); err != nil {
+			return err
+		}
+		// Start a new chunk with the ancestors of the node.
+		c.size = 0
+		c.chunk = new(node)
+		c.dstPath = append(c.dstPath[:0], c.chunk)
+		for _, a := range c.srcPath[:depth] {
+			c.dstPath = append(c.dstPath, c.dstPath[len(c.dstPath)-1].insert(a))
+		}
+	}
+	// The nodes are visited in order: insert appends them.
+	n := c.dstPath[depth].insert(name)
+	n.self = self
+	for _, a := range c.dstPath[:depth+1] {
+		a.total += self
+	}
+	n.total += self
+	c.dstPath = append(c.dstPath[:depth+1], n)
+	c.size++
 	return nil
 }
 
+// flush calls fn with the current chunk, if any.
+func (c *treeChunker) flush() error {
+	if c.chunk == nil {
+		return nil
+	}
+	chunk := c.chunk
+	c.chunk = nil
+	return c.fn(&Tree{root: chunk.children})
+}
+
+// lastStack returns the stack of the last node of the tree in depth-first
+// order, appended to dst.
+func (t *Tree) lastStack(dst []string) []string {
+	for children := t.root; len(children) > 0; {
+		n := children[len(children)-1]
+		dst = append(dst, n.name)
+		children = n.children
+	}
+	return dst
+}
+
+// compareStacks compares the stacks in depth-first order: a stack is ordered
+// after its ancestors.
+func compareStacks(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
 var errMalformedTreeBytes = fmt.Errorf("malformed tree bytes")
 
 const estimateBytesPerNode = 16 // Chosen empirically.
@@ -310,12 +540,45 @@ func UnmarshalTree(b []byte) (*Tree, error) {
 type TreeMerger struct {
 	mu sync.Mutex
 	t  *Tree
+
+	// Memory budget, in nodes, of the merged tree.
+	size      int
+	maxSize   int
+	maxNodes  int64
+	truncated bool
+	// Size of the tree above which it is truncated next.
+	nextTruncate int
+	streams      []*TreeStream
 }
 
 func NewTreeMerger() *TreeMerger {
 	return new(TreeMerger)
 }
 
+// NewTreeMergerWithBudget returns a TreeMerger which merged tree is limited
+// to maxSize nodes: once exceeded, the tree is truncated to the nodes that
+// would show up in a flamegraph of maxNodes nodes, and the values of the
+// other nodes are attributed to "other" nodes. The budget is never lower
+// than twice maxNodes, as a truncated tree may have as many "other" nodes.
+// The budget is disabled if maxNodes is not positive.
+//
+// Only the trees merged with streams are truncated, see TreeStream.
+func NewTreeMergerWithBudget(maxSize int, maxNodes int64) *TreeMerger {
+	if maxNodes < 1 {
+		return NewTreeMerger()
+	}
+	if maxSize > 0 && int64(maxSize) < 2*maxNodes {
+		maxSize = int(2 * maxNodes)
+	}
+	return &TreeMerger{
+		maxSize:      maxSize,
+		maxNodes:     maxNodes,
+		nextTruncate: maxSize,
+	}
+}
+
+// MergeTreeBytes merges the tree into the merged tree. The tree is merged
+// as a whole and is never truncated.
 func (m *TreeMerger) MergeTreeBytes(b []byte) error {
 	// TODO(kolesnikovae): Ideally, we should not have
 	// the intermediate tree t but update m.t reading
@@ -325,18 +588,130 @@ func (m *TreeMerger) MergeTreeBytes(b []byte) error {
 		return err
 	}
 	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.maxSize > 0 {
+		if m.t == nil {
+			m.t = new(Tree)
+		}
+		m.size += m.t.merge(t)
+		return nil
+	}
 	if m.t != nil {
 		m.t.Merge(t)
 	} else {
 		m.t = t
 	}
-	m.mu.Unlock()
 	return nil
 }
 
+// TreeStream merges into a TreeMerger the chunks of a tree sent in stack
+// prefix order, as returned by Tree.Chunks.
+//
+// A node of the merged tree is final once all the streams sent the nodes
+// ordered after it: only the final nodes are truncated when the memory
+// budget is exceeded, so that the truncated nodes are not merged again and
+// the result does not depend on the order the chunks are received in. The
+// budget is exceeded if the streams are too far apart.
+type TreeStream struct {
+	m *TreeMerger
+	// Stack of the last node merged, nil until the first chunk is merged.
+	last   []string
+	closed bool
+}
+
+// Stream returns a new stream of the merger. All the streams must be
+// created before the first chunk is merged.
+func (m *TreeMerger) Stream() *TreeStream {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := &TreeStream{m: m}
+	m.streams = append(m.streams, s)
+	return s
+}
+
+// MergeTreeBytes merges the next chunk of the stream.
+func (s *TreeStream) MergeTreeBytes(b []byte) error {
+	t, err := UnmarshalTree(b)
+	if err != nil {
+		return err
+	}
+	m := s.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(t.root) > 0 {
+		s.last = t.lastStack(s.last[:0])
+	}
+	if m.maxSize <= 0 {
+		if m.t != nil {
+			m.t.Merge(t)
+		} else {
+			m.t = t
+		}
+		return nil
+	}
+	if m.t == nil {
+		m.t = new(Tree)
+	}
+	m.size += m.t.merge(t)
+	m.truncate()
+	return nil
+}
+
+// Close marks the stream as complete: all its chunks have been merged.
+func (s *TreeStream) Close() {
+	m := s.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.closed = true
+	if m.maxSize > 0 && m.t != nil {
+		// The nodes the stream was holding back may be final now.
+		m.nextTruncate = m.maxSize
+		m.truncate()
+	}
+}
+
+// truncate truncates the final nodes of the merged tree if it exceeds the
+// budget. m.mu must be held.
+func (m *TreeMerger) truncate() {
+	if m.size <= m.nextTruncate {
+		return
+	}
+	var stack []string
+	for _, s := range m.streams {
+		if s.closed {
+			continue
+		}
+		if s.last == nil {
+			// None of the nodes are final yet.
+			return
+		}
+		if stack == nil || compareStacks(s.last, stack) < 0 {
+			stack = s.last
+		}
+	}
+	var truncated bool
+	m.size, truncated = m.t.truncateBefore(m.maxNodes, stack)
+	m.truncated = m.truncated || truncated
+	// The nodes which are not final yet may keep the tree above the
+	// budget: the tree is truncated again once its size has doubled,
+	// rather than for every chunk.
+	m.nextTruncate = m.maxSize
+	if m.size > m.maxSize {
+		m.nextTruncate = 2 * m.size
+	}
+}
+
 func (m *TreeMerger) Tree() *Tree {
 	if m.t == nil {
 		return new(Tree)
 	}
 	return m.t
 }
+
+// Truncated reports whether the merged tree exceeded the memory budget and
+// was truncated.
+func (m *TreeMerger) Truncated() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.truncated
+}
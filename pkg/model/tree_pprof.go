@@ -0,0 +1,90 @@
+package model
+
+import (
+	"fmt"
+
+	profilev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+)
+
+// Pprof converts the tree into a pprof profile of the given type: every
+// stack of the tree with a self value becomes a sample, and every distinct
+// frame name becomes a function with a single location.
+func (t *Tree) Pprof(ty *typesv1.ProfileType, timeNanos int64) *profilev1.Profile {
+	p := &profilev1.Profile{
+		StringTable: []string{""},
+		TimeNanos:   timeNanos,
+		Period:      defaultPeriod(ty.Name),
+	}
+	strings := make(map[string]int64)
+	addString := func(s string) int64 {
+		if i, ok := strings[s]; ok {
+			return i
+		}
+		i := int64(len(p.StringTable))
+		p.StringTable = append(p.StringTable, s)
+		strings[s] = i
+		return i
+	}
+	p.SampleType = []*profilev1.ValueType{{Type: addString(ty.SampleType), Unit: addString(ty.SampleUnit)}}
+	p.PeriodType = &profilev1.ValueType{Type: addString(ty.PeriodType), Unit: addString(ty.PeriodUnit)}
+
+	locations := make(map[string]uint64)
+	t.IterateStacks(func(_ string, self int64, stack []string) {
+		s := &profilev1.Sample{
+			LocationId: make([]uint64, len(stack)),
+			Value:      []int64{self},
+		}
+		for i, name := range stack {
+			id, ok := locations[name]
+			if !ok {
+				id = uint64(len(p.Location) + 1)
+				locations[name] = id
+				p.Function = append(p.Function, &profilev1.Function{Id: id, Name: addString(name)})
+				p.Location = append(p.Location, &profilev1.Location{Id: id, Line: []*profilev1.Line{{FunctionId: id}}})
+			}
+			// The locations of a sample are ordered from the leaf.
+			s.LocationId[len(stack)-1-i] = id
+		}
+		p.Sample = append(p.Sample, s)
+	})
+	return p
+}
+
+// TreeFromProfile builds a tree from the values of the sample type at the
+// given index of the pprof profile. Inlined functions are separate frames
+// of the stacks, and locations without any line are named after their
+// address.
+func TreeFromProfile(p *profilev1.Profile, sampleType int) *Tree {
+	functions := make(map[uint64]string, len(p.Function))
+	for _, fn := range p.Function {
+		functions[fn.Id] = p.StringTable[fn.Name]
+	}
+	locations := make(map[uint64][]string, len(p.Location))
+	for _, loc := range p.Location {
+		if len(loc.Line) == 0 {
+			locations[loc.Id] = []string{fmt.Sprintf("0x%x", loc.Address)}
+			continue
+		}
+		// The last line is the caller the previous ones are inlined into.
+		names := make([]string, len(loc.Line))
+		for i, line := range loc.Line {
+			names[len(loc.Line)-1-i] = functions[line.FunctionId]
+		}
+		locations[loc.Id] = names
+	}
+
+	t := new(Tree)
+	stack := make([]string, 0, 64)
+	for _, s := range p.Sample {
+		if sampleType >= len(s.Value) || s.Value[sampleType] == 0 {
+			continue
+		}
+		stack = stack[:0]
+		for i := len(s.LocationId) - 1; i >= 0; i-- {
+			stack = append(stack, locations[s.LocationId[i]]...)
+		}
+		t.InsertStack(s.Value[sampleType], stack...)
+	}
+	return t
+}
@@ -2,6 +2,9 @@ package model
 
 import (
 	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -250,6 +253,149 @@ func Test_Tree_MarshalUnmarshal(t *testing.T) {
 	})
 }
 
+func Test_Tree_Chunks(t *testing.T) {
+	expected := randomTree(0, 1000)
+	for _, maxNodes := range []int{0, 1, 2, 7, 64, 1 << 20} {
+		chunks := expected.Chunks(maxNodes)
+		actual := new(Tree)
+		for _, c := range chunks {
+			// Chunks are sent serialized.
+			var buf bytes.Buffer
+			require.NoError(t, c.MarshalTruncate(&buf, -1))
+			u, err := UnmarshalTree(buf.Bytes())
+			require.NoError(t, err)
+			actual.Merge(u)
+		}
+		require.Equal(t, expected.String(), actual.String(), "max nodes: %d", maxNodes)
+		if maxNodes > 0 {
+			size := expected.size()
+			require.Len(t, chunks, (size+maxNodes-1)/maxNodes, "max nodes: %d", maxNodes)
+		}
+	}
+}
+
+func Test_Tree_IterateStacks(t *testing.T) {
+	tr := new(Tree)
+	tr.InsertStack(1, "a", "b", "c")
+	tr.InsertStack(2, "a", "b")
+	tr.InsertStack(3, "a", "d")
+	tr.InsertStack(4, "e")
+
+	var actual []string
+	tr.IterateStacks(func(name string, self int64, stack []string) {
+		require.Equal(t, stack[len(stack)-1], name)
+		actual = append(actual, fmt.Sprintf("%s %d", strings.Join(stack, ";"), self))
+	})
+	require.Equal(t, []string{"a;b 2", "a;b;c 1", "a;d 3", "e 4"}, actual)
+
+	expected := randomTree(0, 1000)
+	rebuilt := new(Tree)
+	expected.IterateStacks(func(_ string, self int64, stack []string) {
+		rebuilt.InsertStack(self, stack...)
+	})
+	require.Equal(t, expected.String(), rebuilt.String())
+}
+
+func Test_TreeMerger_Budget(t *testing.T) {
+	trees := make([]*Tree, 8)
+	for i := range trees {
+		trees[i] = randomTree(int64(i), 500)
+	}
+	// The chunks of the trees are merged as they are received from several
+	// replicas: interleaved, each stream in stack prefix order.
+	merge := func(m *TreeMerger) *Tree {
+		streams := make([]*TreeStream, len(trees))
+		chunks := make([][]*Tree, len(trees))
+		for i, tree := range trees {
+			streams[i] = m.Stream()
+			chunks[i] = tree.Chunks(16)
+		}
+		r := rand.New(rand.NewSource(42))
+		for open := len(trees); open > 0; {
+			i := r.Intn(len(trees))
+			if len(chunks[i]) == 0 {
+				continue
+			}
+			var buf bytes.Buffer
+			require.NoError(t, chunks[i][0].MarshalTruncate(&buf, -1))
+			require.NoError(t, streams[i].MergeTreeBytes(buf.Bytes()))
+			if chunks[i] = chunks[i][1:]; len(chunks[i]) == 0 {
+				streams[i].Close()
+				open--
+			}
+		}
+		return m.Tree()
+	}
+	expected := merge(NewTreeMerger())
+
+	t.Run("within budget", func(t *testing.T) {
+		m := NewTreeMergerWithBudget(1<<20, 64)
+		actual := merge(m)
+		require.False(t, m.Truncated())
+		require.Equal(t, expected.String(), actual.String())
+	})
+
+	t.Run("budget exceeded", func(t *testing.T) {
+		m := NewTreeMergerWithBudget(256, 64)
+		actual := merge(m)
+		require.True(t, m.Truncated())
+		require.Equal(t, expected.Total(), actual.Total())
+		require.Less(t, actual.size(), expected.size())
+		// The flamegraph is the same as the one of the tree merged
+		// without budget.
+		require.Equal(t, NewFlameGraph(expected, 64), NewFlameGraph(actual, 64))
+	})
+
+	t.Run("truncated once the streams are past the nodes", func(t *testing.T) {
+		m := NewTreeMergerWithBudget(256, 64)
+		a, b := m.Stream(), m.Stream()
+		for _, c := range trees[0].Chunks(16) {
+			var buf bytes.Buffer
+			require.NoError(t, c.MarshalTruncate(&buf, -1))
+			require.NoError(t, a.MergeTreeBytes(buf.Bytes()))
+		}
+		a.Close()
+		// The other stream has not sent anything: no node is final.
+		require.False(t, m.Truncated())
+		b.Close()
+		require.True(t, m.Truncated())
+		require.Equal(t, NewFlameGraph(trees[0], 64), NewFlameGraph(m.Tree(), 64))
+	})
+}
+
+func Test_compareStacks(t *testing.T) {
+	require.Equal(t, 0, compareStacks([]string{"a", "b"}, []string{"a", "b"}))
+	require.Less(t, compareStacks([]string{"a"}, []string{"a", "b"}), 0)
+	require.Less(t, compareStacks([]string{"a", "b"}, []string{"b"}), 0)
+	require.Greater(t, compareStacks([]string{"a", "c"}, []string{"a", "b", "c"}), 0)
+}
+
+// randomTree returns a tree of n stacks of random depth and frames.
+func randomTree(seed int64, n int) *Tree {
+	r := rand.New(rand.NewSource(seed))
+	t := new(Tree)
+	stack := make([]string, 0, 16)
+	for i := 0; i < n; i++ {
+		stack = stack[:0]
+		for d := r.Intn(cap(stack)) + 1; d > 0; d-- {
+			stack = append(stack, fmt.Sprintf("f%d", r.Intn(8)))
+		}
+		t.InsertStack(int64(r.Intn(100)+1), stack...)
+	}
+	return t
+}
+
+// size returns the number of nodes of the tree.
+func (t *Tree) size() (size int) {
+	nodes := append([]*node{}, t.root...)
+	for len(nodes) > 0 {
+		n := nodes[len(nodes)-1]
+		nodes = append(nodes[:len(nodes)-1], n.children...)
+		size++
+	}
+	return size
+}
+
 func emptyTree() *Tree {
 	return &Tree{}
 }
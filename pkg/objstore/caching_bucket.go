@@ -0,0 +1,239 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/cache"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	CacheBackendMemcached = cache.BackendMemcached
+
+	// subrangeTTL is the TTL of the cached subranges: blocks objects are
+	// immutable, the TTL only bounds the lifetime of unused items.
+	subrangeTTL = 24 * time.Hour
+)
+
+var errUnsupportedCacheBackend = errors.New("unsupported cache backend")
+
+// CacheConfig configures the cache of the objects subranges read from the
+// bucket, e.g. the parquet pages and row groups of the blocks.
+type CacheConfig struct {
+	Backend        string                      `yaml:"backend"`
+	Memcached      cache.MemcachedClientConfig `yaml:"memcached"`
+	MemoryMaxItems int                         `yaml:"memory_max_items" category:"advanced"`
+	SubrangeSize   int64                       `yaml:"subrange_size" category:"advanced"`
+}
+
+func (cfg *CacheConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Backend, prefix+"backend", "", fmt.Sprintf("Backend for the cache of the objects subranges. Supported values: %s. Empty to only use the in-memory cache, if enabled.", CacheBackendMemcached))
+	cfg.Memcached.RegisterFlagsWithPrefix(prefix+"memcached.", f)
+	f.IntVar(&cfg.MemoryMaxItems, prefix+"memory-max-items", 0, "Maximum number of subranges kept in the in-memory LRU cache, in front of the cache backend. 0 to disable the in-memory cache.")
+	f.Int64Var(&cfg.SubrangeSize, prefix+"subrange-size", 16*1024, "Size in bytes of the objects subranges cached.")
+}
+
+func (cfg *CacheConfig) Validate() error {
+	switch cfg.Backend {
+	case "":
+	case CacheBackendMemcached:
+		if err := cfg.Memcached.Validate(); err != nil {
+			return err
+		}
+	default:
+		return errors.Wrap(errUnsupportedCacheBackend, cfg.Backend)
+	}
+	if cfg.SubrangeSize <= 0 {
+		return errors.New("the cache subrange size must be positive")
+	}
+	return nil
+}
+
+func (cfg *CacheConfig) enabled() bool {
+	return cfg.Backend != "" || cfg.MemoryMaxItems > 0
+}
+
+// NewCachingBucket returns a bucket caching the ranges read from the objects
+// of the given bucket, or the bucket itself if the cache is disabled.
+func NewCachingBucket(bkt Bucket, cfg CacheConfig, name string, logger log.Logger, reg prometheus.Registerer) (Bucket, error) {
+	if !cfg.enabled() {
+		return bkt, nil
+	}
+	b := &CachingBucket{
+		Bucket:       bkt,
+		cache:        noopCache{},
+		subrangeSize: cfg.SubrangeSize,
+		stop:         func() {},
+		requests: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "pyroscope_bucket_cache_subrange_requests_total",
+			Help:        "Total number of objects subranges requested from the cache.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+		hits: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "pyroscope_bucket_cache_subrange_hits_total",
+			Help:        "Total number of objects subranges found in the cache.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+	}
+	if cfg.Backend == CacheBackendMemcached {
+		client, err := cache.NewMemcachedClientWithConfig(logger, name, cfg.Memcached, reg)
+		if err != nil {
+			return nil, errors.Wrap(err, "create memcached client")
+		}
+		b.cache = cache.NewMemcachedCache(name, logger, client, reg)
+		b.stop = client.Stop
+	}
+	if cfg.MemoryMaxItems > 0 {
+		lru, err := cache.WrapWithLRUCache(b.cache, name, reg, cfg.MemoryMaxItems, subrangeTTL)
+		if err != nil {
+			return nil, errors.Wrap(err, "create in-memory cache")
+		}
+		b.cache = lru
+	}
+	return b, nil
+}
+
+// CachingBucket caches the ranges read from the objects of the bucket, split
+// in aligned subranges of a fixed size. The objects are expected to be
+// immutable.
+type CachingBucket struct {
+	Bucket
+	cache        cache.Cache
+	subrangeSize int64
+	stop         func()
+
+	requests prometheus.Counter
+	hits     prometheus.Counter
+}
+
+func (b *CachingBucket) Close() error {
+	b.stop()
+	return b.Bucket.Close()
+}
+
+func (b *CachingBucket) ReaderAt(ctx context.Context, name string) (ReaderAtCloser, error) {
+	return &ReaderAt{
+		GetRangeReader: b,
+		name:           name,
+		ctx:            ctx,
+	}, nil
+}
+
+// GetRange returns the object range, reading the subranges missing from the
+// cache from the bucket.
+func (b *CachingBucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	if off < 0 || length <= 0 {
+		return b.Bucket.GetRange(ctx, name, off, length)
+	}
+	var (
+		first = off / b.subrangeSize * b.subrangeSize
+		last  = (off + length - 1) / b.subrangeSize * b.subrangeSize
+		keys  = make([]string, 0, (last-first)/b.subrangeSize+1)
+	)
+	for start := first; start <= last; start += b.subrangeSize {
+		keys = append(keys, subrangeKey(name, start, b.subrangeSize))
+	}
+	subranges := b.cache.Fetch(ctx, keys)
+	b.requests.Add(float64(len(keys)))
+	b.hits.Add(float64(len(subranges)))
+	if subranges == nil {
+		subranges = make(map[string][]byte, len(keys))
+	}
+
+	if len(subranges) < len(keys) {
+		if err := b.fetchMissing(ctx, name, first, keys, subranges); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := make([]byte, 0, length)
+	for i, key := range keys {
+		data := subranges[key]
+		start := first + int64(i)*b.subrangeSize
+		if lo := off - start; lo > 0 {
+			if lo >= int64(len(data)) {
+				break
+			}
+			data = data[lo:]
+		}
+		if n := int64(cap(buf) - len(buf)); int64(len(data)) > n {
+			data = data[:n]
+		}
+		buf = append(buf, data...)
+		if int64(len(subranges[key])) < b.subrangeSize {
+			// End of the object.
+			break
+		}
+	}
+	return io.NopCloser(bytes.NewReader(buf)), nil
+}
+
+// fetchMissing reads the subranges missing from the cache from the bucket,
+// merging consecutive subranges in a single read, and stores them.
+func (b *CachingBucket) fetchMissing(ctx context.Context, name string, first int64, keys []string, subranges map[string][]byte) error {
+	missing := make(map[string][]byte)
+	for i := 0; i < len(keys); {
+		if _, ok := subranges[keys[i]]; ok {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(keys) {
+			if _, ok := subranges[keys[j]]; ok {
+				break
+			}
+			j++
+		}
+		start := first + int64(i)*b.subrangeSize
+		data, err := b.readRange(ctx, name, start, int64(j-i)*b.subrangeSize)
+		if err != nil {
+			return err
+		}
+		for k := i; k < j && len(data) > 0; k++ {
+			n := b.subrangeSize
+			if int64(len(data)) < n {
+				n = int64(len(data))
+			}
+			subranges[keys[k]] = data[:n:n]
+			missing[keys[k]] = data[:n:n]
+			data = data[n:]
+		}
+		i = j
+	}
+	if len(missing) > 0 {
+		b.cache.StoreAsync(missing, subrangeTTL)
+	}
+	return nil
+}
+
+func (b *CachingBucket) readRange(ctx context.Context, name string, off, length int64) ([]byte, error) {
+	rc, err := b.Bucket.GetRange(ctx, name, off, length)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func subrangeKey(name string, start, size int64) string {
+	return "subrange:" + name + ":" + strconv.FormatInt(start, 10) + ":" + strconv.FormatInt(start+size, 10)
+}
+
+type noopCache struct{}
+
+func (noopCache) StoreAsync(map[string][]byte, time.Duration) {}
+
+func (noopCache) Fetch(context.Context, []string, ...cache.Option) map[string][]byte { return nil }
+
+func (noopCache) Delete(context.Context, string) error { return nil }
+
+func (noopCache) Name() string { return "noop" }
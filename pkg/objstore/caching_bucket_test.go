@@ -0,0 +1,238 @@
+package objstore_test
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"go.uber.org/atomic"
+
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
+)
+
+// countingBucket counts the ranged reads of the objects.
+type countingBucket struct {
+	phlareobj.Bucket
+	getRange atomic.Int64
+}
+
+func (b *countingBucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	b.getRange.Inc()
+	return b.Bucket.GetRange(ctx, name, off, length)
+}
+
+func newTestCacheConfig(t *testing.T) phlareobj.CacheConfig {
+	t.Helper()
+	var cfg phlareobj.CacheConfig
+	fs := flag.NewFlagSet("", flag.PanicOnError)
+	cfg.RegisterFlagsWithPrefix("", fs)
+	require.NoError(t, fs.Parse(nil))
+	cfg.SubrangeSize = 10
+	return cfg
+}
+
+func newTestObject(t *testing.T, size int) (*countingBucket, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	bkt := &countingBucket{Bucket: phlareobj.NewBucket(objstore.NewInMemBucket())}
+	require.NoError(t, bkt.Upload(context.Background(), "block/profiles.parquet", strings.NewReader(string(data))))
+	return bkt, data
+}
+
+func readAt(t *testing.T, b phlareobj.Bucket, off, length int64) []byte {
+	t.Helper()
+	r, err := b.ReaderAt(context.Background(), "block/profiles.parquet")
+	require.NoError(t, err)
+	p := make([]byte, length)
+	n, err := r.ReadAt(p, off)
+	require.NoError(t, err)
+	return p[:n]
+}
+
+func Test_CachingBucket_Disabled(t *testing.T) {
+	bkt, _ := newTestObject(t, 10)
+	cfg := newTestCacheConfig(t)
+	b, err := phlareobj.NewCachingBucket(bkt, cfg, "test", log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, err)
+	require.Same(t, bkt, b)
+}
+
+func Test_CachingBucket_InMemory(t *testing.T) {
+	bkt, data := newTestObject(t, 95)
+	cfg := newTestCacheConfig(t)
+	cfg.MemoryMaxItems = 100
+	reg := prometheus.NewRegistry()
+	b, err := phlareobj.NewCachingBucket(bkt, cfg, "test", log.NewNopLogger(), reg)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		off, length int64
+		reads       int64
+	}{
+		// Subranges [10, 20) and [20, 30) are read at once.
+		{off: 15, length: 10, reads: 1},
+		// Cached.
+		{off: 10, length: 20, reads: 1},
+		// Subranges [0, 10) and [30, 40) are missing.
+		{off: 5, length: 30, reads: 3},
+		// The last subrange is shorter than the others.
+		{off: 85, length: 10, reads: 4},
+		{off: 90, length: 5, reads: 4},
+		// Subranges [40, 80) are read at once.
+		{off: 0, length: 95, reads: 5},
+		{off: 0, length: 95, reads: 5},
+	} {
+		require.Equal(t, data[tc.off:tc.off+tc.length], readAt(t, b, tc.off, tc.length), "off: %d, length: %d", tc.off, tc.length)
+		require.Equal(t, tc.reads, bkt.getRange.Load(), "off: %d, length: %d", tc.off, tc.length)
+	}
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP pyroscope_bucket_cache_subrange_hits_total Total number of objects subranges found in the cache.
+# TYPE pyroscope_bucket_cache_subrange_hits_total counter
+pyroscope_bucket_cache_subrange_hits_total{name="test"} 21
+# HELP pyroscope_bucket_cache_subrange_requests_total Total number of objects subranges requested from the cache.
+# TYPE pyroscope_bucket_cache_subrange_requests_total counter
+pyroscope_bucket_cache_subrange_requests_total{name="test"} 31
+`), "pyroscope_bucket_cache_subrange_hits_total", "pyroscope_bucket_cache_subrange_requests_total"))
+}
+
+func Test_CachingBucket_Memcached(t *testing.T) {
+	server := newFakeMemcached(t)
+	bkt, data := newTestObject(t, 95)
+	cfg := newTestCacheConfig(t)
+	cfg.Backend = phlareobj.CacheBackendMemcached
+	cfg.Memcached.Addresses = []string{server.addr()}
+	require.NoError(t, cfg.Validate())
+
+	b, err := phlareobj.NewCachingBucket(bkt, cfg, "test", log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = b.Close() })
+
+	require.Equal(t, data[15:65], readAt(t, b, 15, 50))
+	require.Equal(t, int64(1), bkt.getRange.Load())
+	require.Eventually(t, func() bool { return server.len() == 6 }, 5*time.Second, 10*time.Millisecond)
+
+	// Subranges are read from memcached, including by another caching bucket.
+	b2, err := phlareobj.NewCachingBucket(bkt, cfg, "test", log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = b2.Close() })
+	require.Equal(t, data[10:70], readAt(t, b2, 10, 60))
+	require.Equal(t, int64(1), bkt.getRange.Load())
+}
+
+func Test_CacheConfig_Validate(t *testing.T) {
+	cfg := newTestCacheConfig(t)
+	require.NoError(t, cfg.Validate())
+	cfg.Backend = "redis"
+	require.Error(t, cfg.Validate())
+	cfg.Backend = phlareobj.CacheBackendMemcached
+	require.Error(t, cfg.Validate())
+	cfg.Memcached.Addresses = []string{"localhost:11211"}
+	require.NoError(t, cfg.Validate())
+	cfg.SubrangeSize = 0
+	require.Error(t, cfg.Validate())
+}
+
+// fakeMemcached is a memcached server supporting the subset of the text
+// protocol used by the cache client.
+type fakeMemcached struct {
+	l     net.Listener
+	mtx   sync.Mutex
+	items map[string][]byte
+}
+
+func newFakeMemcached(t *testing.T) *fakeMemcached {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeMemcached{l: l, items: make(map[string][]byte)}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeMemcached) addr() string { return s.l.Addr().String() }
+
+func (s *fakeMemcached) len() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return len(s.items)
+}
+
+func (s *fakeMemcached) serve(conn net.Conn) {
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return
+		}
+		switch fields[0] {
+		case "get", "gets":
+			s.mtx.Lock()
+			for _, key := range fields[1:] {
+				if v, ok := s.items[key]; ok {
+					fmt.Fprintf(rw, "VALUE %s 0 %d 1\r\n%s\r\n", key, len(v), v)
+				}
+			}
+			s.mtx.Unlock()
+			fmt.Fprint(rw, "END\r\n")
+		case "set":
+			if len(fields) < 5 {
+				return
+			}
+			n, err := strconv.Atoi(fields[4])
+			if err != nil {
+				return
+			}
+			v := make([]byte, n+2)
+			if _, err = io.ReadFull(rw, v); err != nil {
+				return
+			}
+			s.mtx.Lock()
+			s.items[fields[1]] = v[:n]
+			s.mtx.Unlock()
+			fmt.Fprint(rw, "STORED\r\n")
+		case "delete":
+			s.mtx.Lock()
+			delete(s.items, fields[1])
+			s.mtx.Unlock()
+			fmt.Fprint(rw, "DELETED\r\n")
+		case "version":
+			fmt.Fprint(rw, "VERSION 1.6.0\r\n")
+		default:
+			fmt.Fprint(rw, "ERROR\r\n")
+		}
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
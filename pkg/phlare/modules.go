@@ -21,6 +21,7 @@ import (
 	"github.com/thanos-io/thanos/pkg/discovery/dns"
 	"github.com/weaveworks/common/middleware"
 	"github.com/weaveworks/common/server"
+	"golang.org/x/exp/slices"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 	"google.golang.org/genproto/googleapis/api/httpbody"
@@ -30,16 +31,21 @@ import (
 	"github.com/grafana/phlare/api/gen/proto/go/push/v1/pushv1connect"
 	statusv1 "github.com/grafana/phlare/api/gen/proto/go/status/v1"
 	"github.com/grafana/phlare/pkg/agent"
+	"github.com/grafana/phlare/pkg/deleterequests"
 	"github.com/grafana/phlare/pkg/distributor"
 	"github.com/grafana/phlare/pkg/frontend"
 	"github.com/grafana/phlare/pkg/ingester"
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
 	objstoreclient "github.com/grafana/phlare/pkg/objstore/client"
 	"github.com/grafana/phlare/pkg/objstore/providers/filesystem"
 	phlarecontext "github.com/grafana/phlare/pkg/phlare/context"
+	"github.com/grafana/phlare/pkg/phlaredb/tombstones"
 	"github.com/grafana/phlare/pkg/querier"
 	"github.com/grafana/phlare/pkg/querier/worker"
 	"github.com/grafana/phlare/pkg/scheduler"
+	"github.com/grafana/phlare/pkg/selfprofiling"
 	"github.com/grafana/phlare/pkg/storegateway"
+	"github.com/grafana/phlare/pkg/tenantdeletion"
 	"github.com/grafana/phlare/pkg/usagestats"
 	"github.com/grafana/phlare/pkg/util"
 	"github.com/grafana/phlare/pkg/util/build"
@@ -67,6 +73,12 @@ const (
 	RuntimeConfig     string = "runtime-config"
 	Overrides         string = "overrides"
 	OverridesExporter string = "overrides-exporter"
+	SelfProfiling     string = "self-profiling"
+	TenantDeletion    string = "tenant-deletion"
+	DeleteRequests    string = "delete-requests"
+
+	TenantDeletionMarks string = "tenant-deletion-marks"
+	Tombstones          string = "tombstones"
 
 	// QueryFrontendTripperware string = "query-frontend-tripperware"
 	// Compactor                string = "compactor"
@@ -256,7 +268,7 @@ func (f *Phlare) initGRPCGateway() (services.Service, error) {
 
 func (f *Phlare) initDistributor() (services.Service, error) {
 	f.Cfg.Distributor.DistributorRing.ListenPort = f.Cfg.Server.HTTPListenPort
-	d, err := distributor.New(f.Cfg.Distributor, f.ring, nil, f.Overrides, f.reg, log.With(f.logger, "component", "distributor"), f.auth)
+	d, err := distributor.New(f.Cfg.Distributor, f.ring, nil, f.Overrides, f.tenantDeletionMarks, f.reg, log.With(f.logger, "component", "distributor"), f.auth)
 	if err != nil {
 		return nil, err
 	}
@@ -282,6 +294,21 @@ func (f *Phlare) initAgent() (services.Service, error) {
 	return a, nil
 }
 
+func (f *Phlare) initSelfProfiling() (services.Service, error) {
+	if !f.Cfg.SelfProfiling.Enabled {
+		return nil, nil
+	}
+	// The agent, if any, is initialized before the services are started.
+	cpuScraped := func() bool {
+		return f.agent != nil && f.agent.ScrapesLocalCPUProfile(f.Cfg.Server.HTTPListenPort)
+	}
+	p, err := selfprofiling.New(f.Cfg.SelfProfiling, f.Cfg.Target.String(), f.getPusherClient, cpuScraped, log.With(f.logger, "component", "self-profiling"))
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 func (f *Phlare) initMemberlistKV() (services.Service, error) {
 	f.Cfg.MemberlistKV.Codecs = []codec.Codec{
 		ring.GetCodec(),
@@ -354,7 +381,7 @@ func (f *Phlare) context() context.Context {
 func (f *Phlare) initIngester() (_ services.Service, err error) {
 	f.Cfg.Ingester.LifecyclerConfig.ListenPort = f.Cfg.Server.HTTPListenPort
 
-	svc, err := ingester.New(f.context(), f.Cfg.Ingester, f.Cfg.PhlareDB, f.storageBucket, f.Overrides)
+	svc, err := ingester.New(f.context(), f.Cfg.Ingester, f.Cfg.PhlareDB, f.storageBucket, f.Overrides, f.tenantDeletionMarks, f.tombstones)
 	if err != nil {
 		return nil, err
 	}
@@ -367,7 +394,7 @@ func (f *Phlare) initIngester() (_ services.Service, err error) {
 func (f *Phlare) initStoreGateway() (serv services.Service, err error) {
 	f.Cfg.StoreGateway.ShardingRing.ListenPort = f.Cfg.Server.HTTPListenPort
 
-	svc, err := storegateway.NewStoreGateway(f.Cfg.StoreGateway, f.storageBucket, f.Overrides, f.logger, f.reg)
+	svc, err := storegateway.NewStoreGateway(f.Cfg.StoreGateway, f.storageBucket, f.Overrides, f.tombstones, f.logger, f.reg)
 	if err != nil {
 		return nil, err
 	}
@@ -375,6 +402,62 @@ func (f *Phlare) initStoreGateway() (serv services.Service, err error) {
 	return svc, nil
 }
 
+func (f *Phlare) initTenantDeletionMarks() (services.Service, error) {
+	// The deletion marks are shared through the storage bucket: without
+	// it, no tenant can be marked for deletion.
+	if f.storageBucket == nil {
+		return nil, nil
+	}
+	f.tenantDeletionMarks = tenantdeletion.NewMarks(f.storageBucket, f.Cfg.TenantDeletion.MarksSyncInterval, log.With(f.logger, "component", "tenant-deletion-marks"))
+	return f.tenantDeletionMarks, nil
+}
+
+func (f *Phlare) initTenantDeletion() (services.Service, error) {
+	if f.storageBucket == nil {
+		// The deletion marks must be shared with the distributors and the
+		// ingesters, which is not possible with the local file system.
+		if !slices.Contains(f.Cfg.Target, TenantDeletion) {
+			level.Info(f.logger).Log("msg", "tenant deletion is disabled: no storage bucket configured")
+			return nil, nil
+		}
+		return nil, errors.New("tenant deletion requires a storage bucket configuration")
+	}
+	d := tenantdeletion.New(f.Cfg.TenantDeletion, f.storageBucket, log.With(f.logger, "component", "tenant-deletion"), f.reg)
+	f.API.RegisterTenantDeletion(d)
+	return d, nil
+}
+
+func (f *Phlare) initTombstones() (services.Service, error) {
+	b, err := f.storageOrLocalBucket()
+	if err != nil {
+		return nil, err
+	}
+	f.tombstones = tombstones.NewCache(b, f.Cfg.DeleteRequests.CacheTTL)
+	return nil, nil
+}
+
+func (f *Phlare) initDeleteRequests() (services.Service, error) {
+	b, err := f.storageOrLocalBucket()
+	if err != nil {
+		return nil, err
+	}
+	d := deleterequests.New(f.Cfg.DeleteRequests, b, f.tombstones, log.With(f.logger, "component", "delete-requests"), f.reg)
+	f.API.RegisterDeleteRequests(d)
+	return d, nil
+}
+
+// storageOrLocalBucket returns the storage bucket, if configured, or a bucket
+// on the local file system otherwise.
+func (f *Phlare) storageOrLocalBucket() (phlareobj.Bucket, error) {
+	if f.storageBucket != nil {
+		return f.storageBucket, nil
+	}
+	if err := os.MkdirAll(f.Cfg.PhlareDB.DataPath, 0o777); err != nil {
+		return nil, fmt.Errorf("mkdir %s: %w", f.Cfg.PhlareDB.DataPath, err)
+	}
+	return filesystem.NewBucket(f.Cfg.PhlareDB.DataPath)
+}
+
 func (f *Phlare) initServer() (services.Service, error) {
 	f.reg.MustRegister(version.NewCollector("pyroscope"))
 	f.reg.Unregister(collectors.NewGoCollector())
@@ -448,21 +531,9 @@ func (f *Phlare) initUsageReport() (services.Service, error) {
 
 	usagestats.Target(f.Cfg.Target.String())
 
-	b := f.storageBucket
-	if f.storageBucket == nil {
-		if err := os.MkdirAll(f.Cfg.PhlareDB.DataPath, 0o777); err != nil {
-			return nil, fmt.Errorf("mkdir %s: %w", f.Cfg.PhlareDB.DataPath, err)
-		}
-		fs, err := filesystem.NewBucket(f.Cfg.PhlareDB.DataPath)
-		if err != nil {
-			return nil, err
-		}
-		b = fs
-	}
-
-	if b == nil {
-		level.Warn(f.logger).Log("msg", "no storage bucket configured, usage report will not be sent")
-		return nil, nil
+	b, err := f.storageOrLocalBucket()
+	if err != nil {
+		return nil, err
 	}
 
 	ur, err := usagestats.NewReporter(f.Cfg.Analytics, f.Cfg.Ingester.LifecyclerConfig.RingConfig.KVStore, b, f.logger, f.reg)
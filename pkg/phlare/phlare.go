@@ -36,6 +36,7 @@ import (
 	"github.com/grafana/phlare/pkg/agent"
 	"github.com/grafana/phlare/pkg/api"
 	"github.com/grafana/phlare/pkg/cfg"
+	"github.com/grafana/phlare/pkg/deleterequests"
 	"github.com/grafana/phlare/pkg/distributor"
 	"github.com/grafana/phlare/pkg/frontend"
 	"github.com/grafana/phlare/pkg/ingester"
@@ -43,12 +44,15 @@ import (
 	objstoreclient "github.com/grafana/phlare/pkg/objstore/client"
 	phlarecontext "github.com/grafana/phlare/pkg/phlare/context"
 	"github.com/grafana/phlare/pkg/phlaredb"
+	"github.com/grafana/phlare/pkg/phlaredb/tombstones"
 	"github.com/grafana/phlare/pkg/querier"
 	"github.com/grafana/phlare/pkg/querier/worker"
 	"github.com/grafana/phlare/pkg/scheduler"
 	"github.com/grafana/phlare/pkg/scheduler/schedulerdiscovery"
+	"github.com/grafana/phlare/pkg/selfprofiling"
 	"github.com/grafana/phlare/pkg/storegateway"
 	"github.com/grafana/phlare/pkg/tenant"
+	"github.com/grafana/phlare/pkg/tenantdeletion"
 	"github.com/grafana/phlare/pkg/tracing"
 	"github.com/grafana/phlare/pkg/usagestats"
 	"github.com/grafana/phlare/pkg/util"
@@ -69,14 +73,16 @@ type Config struct {
 	QueryScheduler    scheduler.Config       `yaml:"query_scheduler"`
 	Ingester          ingester.Config        `yaml:"ingester,omitempty"`
 	StoreGateway      storegateway.Config    `yaml:"store_gateway,omitempty"`
+	TenantDeletion    tenantdeletion.Config  `yaml:"tenant_deletion,omitempty"`
+	DeleteRequests    deleterequests.Config  `yaml:"delete_requests,omitempty"`
 	MemberlistKV      memberlist.KVConfig    `yaml:"memberlist"`
 	PhlareDB          phlaredb.Config        `yaml:"phlaredb,omitempty"`
 	Tracing           tracing.Config         `yaml:"tracing"`
 	OverridesExporter exporter.Config        `yaml:"overrides_exporter" doc:"hidden"`
 	RuntimeConfig     runtimeconfig.Config   `yaml:"runtime_config"`
 
-	Storage       StorageConfig       `yaml:"storage"`
-	SelfProfiling SelfProfilingConfig `yaml:"self_profiling,omitempty"`
+	Storage       StorageConfig        `yaml:"storage"`
+	SelfProfiling selfprofiling.Config `yaml:"self_profiling,omitempty"`
 
 	MultitenancyEnabled bool              `yaml:"multitenancy_enabled,omitempty"`
 	Analytics           usagestats.Config `yaml:"analytics"`
@@ -100,17 +106,6 @@ func (c *StorageConfig) RegisterFlagsWithContext(ctx context.Context, f *flag.Fl
 	c.Bucket.RegisterFlagsWithPrefix("storage.", f, phlarecontext.Logger(ctx))
 }
 
-type SelfProfilingConfig struct {
-	MutexProfileFraction int `yaml:"mutex_profile_fraction,omitempty"`
-	BlockProfileRate     int `yaml:"block_profile_rate,omitempty"`
-}
-
-func (c *SelfProfilingConfig) RegisterFlags(f *flag.FlagSet) {
-	// these are values that worked well in OG Pyroscope Cloud without adding much overhead
-	f.IntVar(&c.MutexProfileFraction, "self-profiling.mutex-profile-fraction", 5, "")
-	f.IntVar(&c.BlockProfileRate, "self-profiling.block-profile-rate", 5, "")
-}
-
 func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	c.RegisterFlagsWithContext(context.Background(), f)
 }
@@ -130,6 +125,8 @@ func (c *Config) RegisterFlagsWithContext(ctx context.Context, f *flag.FlagSet)
 	c.MemberlistKV.RegisterFlags(f)
 	c.Querier.RegisterFlags(f)
 	c.StoreGateway.RegisterFlags(f, util.Logger)
+	c.TenantDeletion.RegisterFlags(f)
+	c.DeleteRequests.RegisterFlags(f)
 	c.PhlareDB.RegisterFlags(f)
 	c.Tracing.RegisterFlags(f)
 	c.Storage.RegisterFlagsWithContext(ctx, f)
@@ -181,6 +178,12 @@ func (c *Config) Validate() error {
 	if err := c.Ingester.Validate(); err != nil {
 		return err
 	}
+	if err := c.LimitsConfig.Validate(); err != nil {
+		return err
+	}
+	if err := c.StoreGateway.Validate(c.LimitsConfig); err != nil {
+		return err
+	}
 	return c.AgentConfig.Validate()
 }
 
@@ -246,7 +249,9 @@ type Phlare struct {
 
 	TenantLimits validation.TenantLimits
 
-	storageBucket phlareobj.Bucket
+	storageBucket       phlareobj.Bucket
+	tenantDeletionMarks *tenantdeletion.Marks
+	tombstones          *tombstones.Cache
 
 	grpcGatewayMux *grpcgw.ServeMux
 
@@ -316,32 +321,41 @@ func (f *Phlare) setupModuleManager() error {
 	mm.RegisterModule(Distributor, f.initDistributor)
 	mm.RegisterModule(Querier, f.initQuerier)
 	mm.RegisterModule(StoreGateway, f.initStoreGateway)
+	mm.RegisterModule(TenantDeletion, f.initTenantDeletion)
+	mm.RegisterModule(TenantDeletionMarks, f.initTenantDeletionMarks, modules.UserInvisibleModule)
+	mm.RegisterModule(DeleteRequests, f.initDeleteRequests)
+	mm.RegisterModule(Tombstones, f.initTombstones, modules.UserInvisibleModule)
 	mm.RegisterModule(Agent, f.initAgent)
 	mm.RegisterModule(UsageReport, f.initUsageReport)
+	mm.RegisterModule(SelfProfiling, f.initSelfProfiling)
 	mm.RegisterModule(QueryFrontend, f.initQueryFrontend)
 	mm.RegisterModule(QueryScheduler, f.initQueryScheduler)
 	mm.RegisterModule(All, nil)
 
 	// Add dependencies
 	deps := map[string][]string{
-		All: {Agent, Ingester, Distributor, QueryScheduler, QueryFrontend, Querier},
+		All: {Agent, Ingester, Distributor, QueryScheduler, QueryFrontend, Querier, SelfProfiling, TenantDeletion, DeleteRequests},
 
 		Server:         {GRPCGateway},
 		API:            {Server},
 		Agent:          {API},
-		Distributor:    {Overrides, Ring, API, UsageReport},
+		Distributor:    {Overrides, Ring, API, UsageReport, TenantDeletionMarks},
 		Querier:        {Overrides, API, MemberlistKV, Ring, UsageReport},
 		QueryFrontend:  {OverridesExporter, API, MemberlistKV, UsageReport},
 		QueryScheduler: {Overrides, API, MemberlistKV, UsageReport},
-		Ingester:       {Overrides, API, MemberlistKV, Storage, UsageReport},
-		StoreGateway:   {API, Storage, Overrides, MemberlistKV},
-
-		UsageReport:       {Storage, MemberlistKV},
-		Overrides:         {RuntimeConfig},
-		OverridesExporter: {Overrides, MemberlistKV},
-		RuntimeConfig:     {API},
-		Ring:              {API, MemberlistKV},
-		MemberlistKV:      {API},
+		Ingester:       {Overrides, API, MemberlistKV, Storage, UsageReport, TenantDeletionMarks, Tombstones},
+		StoreGateway:   {API, Storage, Overrides, MemberlistKV, Tombstones},
+		TenantDeletion: {API, Storage},
+		DeleteRequests: {API, Storage, Tombstones},
+
+		UsageReport:         {Storage, MemberlistKV},
+		TenantDeletionMarks: {Storage},
+		Tombstones:          {Storage},
+		Overrides:           {RuntimeConfig},
+		OverridesExporter:   {Overrides, MemberlistKV},
+		RuntimeConfig:       {API},
+		Ring:                {API, MemberlistKV},
+		MemberlistKV:        {API},
 	}
 
 	for mod, targets := range deps {
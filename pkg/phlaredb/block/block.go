@@ -1,6 +1,7 @@
 package block
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -48,6 +50,14 @@ func DownloadMeta(ctx context.Context, logger log.Logger, bkt objstore.Bucket, i
 	return m, nil
 }
 
+// Download downloads the block from the bucket into the directory dst.
+func Download(ctx context.Context, logger log.Logger, bkt objstore.Bucket, id ulid.ULID, dst string) error {
+	if err := objstore.DownloadDir(ctx, logger, bkt, id.String(), id.String(), dst); err != nil {
+		return errors.Wrapf(err, "download block %s", id)
+	}
+	return nil
+}
+
 func IsBlockDir(path string) (id ulid.ULID, ok bool) {
 	id, err := ulid.Parse(filepath.Base(path))
 	return id, err == nil
@@ -131,6 +141,64 @@ func cleanUp(logger log.Logger, bkt objstore.Bucket, id ulid.ULID, err error) er
 	return err
 }
 
+// DeletionMark marks a block for deletion: the block is no longer queried,
+// and it is removed from the bucket once the mark is old enough for all the
+// store-gateways to have unloaded it.
+type DeletionMark struct {
+	// ID of the block.
+	ID ulid.ULID `json:"id"`
+	// Details is a human readable reason of the deletion.
+	Details string `json:"details,omitempty"`
+	// DeletionTime is the unix timestamp (seconds) of when the block was
+	// marked for deletion.
+	DeletionTime int64 `json:"deletion_time"`
+}
+
+// MarkForDeletion uploads the deletion mark of the block, unless the block
+// is already marked.
+func MarkForDeletion(ctx context.Context, logger log.Logger, bkt objstore.Bucket, id ulid.ULID, details string) error {
+	markFile := path.Join(id.String(), DeletionMarkFilename)
+	ok, err := bkt.Exists(ctx, markFile)
+	if err != nil {
+		return errors.Wrapf(err, "stat %s", markFile)
+	}
+	if ok {
+		return nil
+	}
+	data, err := json.Marshal(DeletionMark{ID: id, Details: details, DeletionTime: time.Now().Unix()})
+	if err != nil {
+		return errors.Wrap(err, "serialize deletion mark")
+	}
+	if err = bkt.Upload(ctx, markFile, bytes.NewReader(data)); err != nil {
+		return errors.Wrapf(err, "upload %s", markFile)
+	}
+	level.Info(logger).Log("msg", "block marked for deletion", "block", id, "details", details)
+	return nil
+}
+
+// ReadDeletionMark returns the deletion mark of the block, or nil if the
+// block is not marked for deletion.
+func ReadDeletionMark(ctx context.Context, bkt objstore.BucketReader, id ulid.ULID) (*DeletionMark, error) {
+	markFile := path.Join(id.String(), DeletionMarkFilename)
+	r, err := bkt.Get(ctx, markFile)
+	if err != nil {
+		if bkt.IsObjNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "get %s", markFile)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s", markFile)
+	}
+	var mark DeletionMark
+	if err = json.Unmarshal(data, &mark); err != nil {
+		return nil, errors.Wrapf(err, "parse %s", markFile)
+	}
+	return &mark, nil
+}
+
 // Delete removes directory that is meant to be block directory.
 // NOTE: Always prefer this method for deleting blocks.
 //   - We have to delete block's files in the certain order (meta.json first and deletion-mark.json last)
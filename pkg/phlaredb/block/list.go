@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-kit/log/level"
@@ -45,6 +46,7 @@ func ListBlocks(path string, ulidMinTime time.Time) (map[ulid.ULID]*Meta, error)
 // It calls the given function for each block meta.
 // It returns the first error returned by the function.
 // It returns nil if all calls succeed.
+// The blocks without meta are skipped.
 // The function is called concurrently.
 // Currently doesn't work with filesystem bucket.
 func IterBlockMetas(ctx context.Context, bkt phlareobj.Bucket, from, to time.Time, fn func(*Meta)) error {
@@ -62,6 +64,10 @@ func IterBlockMetas(ctx context.Context, bkt phlareobj.Bucket, from, to time.Tim
 			g.Go(func() error {
 				r, err := bkt.Get(ctx, id+block.MetaFilename)
 				if err != nil {
+					if bkt.IsObjNotFoundErr(err) {
+						// A partial block, or a block being deleted.
+						return nil
+					}
 					return err
 				}
 
@@ -93,9 +99,17 @@ func listAllBlockByPrefixes(ctx context.Context, bkt phlareobj.Bucket, from, to
 		g.Go(func() error {
 			level.Debug(util.Logger).Log("msg", "listing blocks", "prefix", prefix, "i", i)
 			prefixIds := []string{}
+			seen := make(map[string]struct{})
 			err := bkt.Iter(ctx, prefix, func(name string) error {
-				if _, ok := block.IsBlockDir(name); ok {
-					prefixIds = append(prefixIds, name)
+				// Some buckets, such as the in-memory one, list the objects
+				// of the blocks rather than their directories.
+				dir, _, _ := strings.Cut(name, objstore.DirDelim)
+				if _, ok := seen[dir]; ok {
+					return nil
+				}
+				if _, ok := block.IsBlockDir(dir); ok {
+					seen[dir] = struct{}{}
+					prefixIds = append(prefixIds, dir+objstore.DirDelim)
 				}
 				return nil
 			}, objstore.WithoutApendingDirDelim)
@@ -3,11 +3,13 @@ package phlaredb
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -24,6 +26,7 @@ import (
 	otlog "github.com/opentracing/opentracing-go/log"
 	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/samber/lo"
 	"github.com/segmentio/parquet-go"
@@ -43,6 +46,7 @@ import (
 	schemav1 "github.com/grafana/phlare/pkg/phlaredb/schemas/v1"
 	"github.com/grafana/phlare/pkg/phlaredb/symdb"
 	"github.com/grafana/phlare/pkg/phlaredb/tsdb/index"
+	"github.com/grafana/phlare/pkg/querier/stats"
 	"github.com/grafana/phlare/pkg/util"
 )
 
@@ -478,6 +482,9 @@ type Querier interface {
 	MergeByStacktraces(ctx context.Context, rows iter.Iterator[Profile]) (*ingestv1.MergeProfilesStacktracesResult, error)
 	MergeByLabels(ctx context.Context, rows iter.Iterator[Profile], by ...string) ([]*typesv1.Series, error)
 	MergePprof(ctx context.Context, rows iter.Iterator[Profile]) (*profile.Profile, error)
+	// SeriesTimeRanges returns the series matching the matchers and the time
+	// range of their profiles.
+	SeriesTimeRanges(ctx context.Context, matchers []*labels.Matcher) ([]*phlaremodel.SeriesTimeRange, error)
 	Open(ctx context.Context) error
 	// Sorts profiles for retrieval.
 	Sort([]Profile) []Profile
@@ -535,6 +542,7 @@ type BlockGetter func(ctx context.Context, start, end model.Time) (Queriers, err
 func SelectMatchingProfiles(ctx context.Context, request *ingestv1.SelectProfilesRequest, queriers Queriers) ([]iter.Iterator[Profile], error) {
 	g, ctx := errgroup.WithContext(ctx)
 	iters := make([]iter.Iterator[Profile], len(queriers))
+	deleted := tombstonesFromContext(ctx).ForRange(model.Time(request.Start), model.Time(request.End))
 
 	for i, querier := range queriers {
 		i := i
@@ -544,7 +552,7 @@ func SelectMatchingProfiles(ctx context.Context, request *ingestv1.SelectProfile
 			if err != nil {
 				return err
 			}
-			iters[i] = iter.NewBufferedIterator(profiles, 1024)
+			iters[i] = iter.NewBufferedIterator(newTombstonesIterator(profiles, deleted), 1024)
 			return nil
 		}))
 	}
@@ -558,6 +566,7 @@ func SelectMatchingProfiles(ctx context.Context, request *ingestv1.SelectProfile
 func MergeProfilesStacktraces(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesStacktracesRequest, ingestv1.MergeProfilesStacktracesResponse], blockGetter BlockGetter) error {
 	sp, ctx := opentracing.StartSpanFromContext(ctx, "MergeProfilesStacktraces")
 	defer sp.Finish()
+	qs := stats.FromContext(ctx)
 
 	r, err := stream.Receive()
 	if err != nil {
@@ -577,11 +586,18 @@ func MergeProfilesStacktraces(ctx context.Context, stream *connect.BidiStream[in
 		otlog.String("selector", request.LabelSelector),
 		otlog.String("profile_id", request.Type.ID),
 	)
+	// The response headers are sent along with the first batch of profiles.
+	chunkSize, _ := strconv.Atoi(stream.RequestHeader().Get(phlaremodel.TreeChunkSizeHeader))
+	if chunkSize > 0 {
+		stream.ResponseHeader().Set(phlaremodel.TreeChunkSizeHeader, strconv.Itoa(chunkSize))
+	}
 
+	selectStart := time.Now()
 	queriers, err := blockGetter(ctx, model.Time(request.Start), model.Time(request.End))
 	if err != nil {
 		return err
 	}
+	qs.AddBlocksQueried(uint64(len(queriers)))
 
 	iters, err := SelectMatchingProfiles(ctx, request, queriers)
 	if err != nil {
@@ -596,7 +612,9 @@ func MergeProfilesStacktraces(ctx context.Context, stream *connect.BidiStream[in
 	if err != nil {
 		return err
 	}
+	qs.AddSelectTime(time.Since(selectStart))
 
+	mergeStart := time.Now()
 	m := phlaremodel.NewStackTraceMerger()
 	g, ctx := errgroup.WithContext(ctx)
 
@@ -628,15 +646,20 @@ func MergeProfilesStacktraces(ctx context.Context, stream *connect.BidiStream[in
 	if err = g.Wait(); err != nil {
 		return err
 	}
+	qs.AddMergeTime(time.Since(mergeStart))
 
 	// sends the final result to the client.
 	sp.LogFields(otlog.String("msg", "sending the final result to the client"))
-	err = stream.Send(&ingestv1.MergeProfilesStacktracesResponse{
-		Result: &ingestv1.MergeProfilesStacktracesResult{
-			Format:    ingestv1.StacktracesMergeFormat_MERGE_FORMAT_TREE,
-			TreeBytes: m.TreeBytes(r.GetMaxNodes()),
-		},
-	})
+	if chunkSize > 0 {
+		err = sendTreeChunks(stream, m, r.GetMaxNodes(), chunkSize)
+	} else {
+		err = stream.Send(&ingestv1.MergeProfilesStacktracesResponse{
+			Result: &ingestv1.MergeProfilesStacktracesResult{
+				Format:    ingestv1.StacktracesMergeFormat_MERGE_FORMAT_TREE,
+				TreeBytes: m.TreeBytes(r.GetMaxNodes()),
+			},
+		})
+	}
 	if err != nil {
 		if errors.Is(err, io.EOF) {
 			return connect.NewError(connect.CodeCanceled, errors.New("client closed stream"))
@@ -647,9 +670,29 @@ func MergeProfilesStacktraces(ctx context.Context, stream *connect.BidiStream[in
 	return nil
 }
 
+// sendTreeChunks sends the tree in chunks of at most chunkSize nodes, ordered
+// by stack prefix, so that the querier can merge them incrementally. The
+// chunks are built one at a time from the merged stack traces.
+func sendTreeChunks(stream *connect.BidiStream[ingestv1.MergeProfilesStacktracesRequest, ingestv1.MergeProfilesStacktracesResponse], m *phlaremodel.StacktraceMerger, maxNodes int64, chunkSize int) error {
+	var buf bytes.Buffer
+	return m.IterateTreeChunks(maxNodes, chunkSize, func(c *phlaremodel.Tree) error {
+		buf.Reset()
+		if err := c.MarshalTruncate(&buf, -1); err != nil {
+			return err
+		}
+		return stream.Send(&ingestv1.MergeProfilesStacktracesResponse{
+			Result: &ingestv1.MergeProfilesStacktracesResult{
+				Format:    ingestv1.StacktracesMergeFormat_MERGE_FORMAT_TREE,
+				TreeBytes: buf.Bytes(),
+			},
+		})
+	})
+}
+
 func MergeProfilesLabels(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesLabelsRequest, ingestv1.MergeProfilesLabelsResponse], blockGetter BlockGetter) error {
 	sp, ctx := opentracing.StartSpanFromContext(ctx, "MergeProfilesLabels")
 	defer sp.Finish()
+	qs := stats.FromContext(ctx)
 
 	r, err := stream.Receive()
 	if err != nil {
@@ -673,10 +716,12 @@ func MergeProfilesLabels(ctx context.Context, stream *connect.BidiStream[ingestv
 		otlog.String("by", strings.Join(by, ",")),
 	)
 
+	selectStart := time.Now()
 	queriers, err := blockGetter(ctx, model.Time(request.Start), model.Time(request.End))
 	if err != nil {
 		return err
 	}
+	qs.AddBlocksQueried(uint64(len(queriers)))
 
 	iters, err := SelectMatchingProfiles(ctx, request, queriers)
 	if err != nil {
@@ -690,6 +735,7 @@ func MergeProfilesLabels(ctx context.Context, stream *connect.BidiStream[ingestv
 	if err != nil {
 		return err
 	}
+	qs.AddSelectTime(time.Since(selectStart))
 
 	// Signals the end of the profile streaming by sending an empty request.
 	// This allows the client to not block other streaming ingesters.
@@ -697,6 +743,7 @@ func MergeProfilesLabels(ctx context.Context, stream *connect.BidiStream[ingestv
 		return err
 	}
 
+	mergeStart := time.Now()
 	result := make([][]*typesv1.Series, 0, len(queriers))
 	g, ctx := errgroup.WithContext(ctx)
 	sync := lo.Synchronize()
@@ -726,6 +773,7 @@ func MergeProfilesLabels(ctx context.Context, stream *connect.BidiStream[ingestv
 	if err := g.Wait(); err != nil {
 		return err
 	}
+	qs.AddMergeTime(time.Since(mergeStart))
 
 	// sends the final result to the client.
 	err = stream.Send(&ingestv1.MergeProfilesLabelsResponse{
@@ -744,6 +792,7 @@ func MergeProfilesLabels(ctx context.Context, stream *connect.BidiStream[ingestv
 func MergeProfilesPprof(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesPprofRequest, ingestv1.MergeProfilesPprofResponse], blockGetter BlockGetter) error {
 	sp, ctx := opentracing.StartSpanFromContext(ctx, "MergeProfilesPprof")
 	defer sp.Finish()
+	qs := stats.FromContext(ctx)
 
 	r, err := stream.Receive()
 	if err != nil {
@@ -764,10 +813,12 @@ func MergeProfilesPprof(ctx context.Context, stream *connect.BidiStream[ingestv1
 		otlog.String("profile_id", request.Type.ID),
 	)
 
+	selectStart := time.Now()
 	queriers, err := blockGetter(ctx, model.Time(request.Start), model.Time(request.End))
 	if err != nil {
 		return err
 	}
+	qs.AddBlocksQueried(uint64(len(queriers)))
 
 	iters, err := SelectMatchingProfiles(ctx, request, queriers)
 	if err != nil {
@@ -782,7 +833,9 @@ func MergeProfilesPprof(ctx context.Context, stream *connect.BidiStream[ingestv1
 	if err != nil {
 		return err
 	}
+	qs.AddSelectTime(time.Since(selectStart))
 
+	mergeStart := time.Now()
 	result := make([]*profile.Profile, 0, len(queriers))
 	var lock sync.Mutex
 	g, ctx := errgroup.WithContext(ctx)
@@ -826,6 +879,7 @@ func MergeProfilesPprof(ctx context.Context, stream *connect.BidiStream[ingestv1
 	if err != nil {
 		return err
 	}
+	qs.AddMergeTime(time.Since(mergeStart))
 
 	// connect go already handles compression.
 	var buf bytes.Buffer
@@ -900,6 +954,10 @@ func (b *singleBlockQuerier) SelectMatchingProfiles(ctx context.Context, params
 	if params.Type == nil {
 		return nil, errors.New("no profileType given")
 	}
+	matchers, shard, err := ExtractShard(matchers)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	matchers = append(matchers, phlaremodel.SelectorFromProfileType(params.Type))
 
 	postings, err := PostingsForMatchers(b.index, nil, matchers...)
@@ -914,11 +972,18 @@ func (b *singleBlockQuerier) SelectMatchingProfiles(ctx context.Context, params
 	)
 
 	// get all relevant labels/fingerprints
+	limiter := queryLimiterFromContext(ctx)
 	for postings.Next() {
 		fp, err := b.index.Series(postings.At(), &lbls, &chks)
 		if err != nil {
 			return nil, err
 		}
+		if shard != nil && !shard.Match(model.Fingerprint(fp)) {
+			continue
+		}
+		if err = limiter.AddSeries(model.Fingerprint(fp)); err != nil {
+			return nil, err
+		}
 		if lblsExisting, exists := lblsPerRef[int64(chks[0].SeriesIndex)]; exists {
 			// Compare to check if there is a clash
 			if phlaremodel.CompareLabelPairs(lbls, lblsExisting.lbs) != 0 {
@@ -932,6 +997,7 @@ func (b *singleBlockQuerier) SelectMatchingProfiles(ctx context.Context, params
 			lbls = make(phlaremodel.Labels, 0, 6)
 		}
 	}
+	stats.FromContext(ctx).AddFetchedSeries(uint64(len(lblsPerRef)))
 
 	var (
 		buf [][]parquet.Value
@@ -1090,6 +1156,10 @@ type parquetReader[M Models, P schemav1.PersisterName] struct {
 	reader    phlareobj.ReaderAtCloser
 	size      int64
 	metrics   *blocksMetrics
+
+	// rowGroupBounds are the bounds of the values of the columns per row
+	// group, if recorded in the file metadata.
+	rowGroupBounds map[string][]query.Int64Bounds
 }
 
 func (r *parquetReader[M, P]) open(ctx context.Context, bucketReader phlareobj.BucketReader) error {
@@ -1129,10 +1199,33 @@ func (r *parquetReader[M, P]) open(ctx context.Context, bucketReader phlareobj.B
 	if err != nil {
 		return errors.Wrapf(err, "opening parquet file '%s'", filePath)
 	}
+	if r.rowGroupBounds, err = readRowGroupBounds(r.file); err != nil {
+		return errors.Wrapf(err, "reading row group bounds of parquet file '%s'", filePath)
+	}
 
 	return nil
 }
 
+// readRowGroupBounds reads the bounds of the columns values per row group from
+// the file metadata, if any. Bounds not matching the row groups are ignored.
+func readRowGroupBounds(f *parquet.File) (map[string][]query.Int64Bounds, error) {
+	v, ok := f.Lookup(rowGroupBoundsKey)
+	if !ok || v == "" {
+		return nil, nil
+	}
+	var bounds map[string][]query.Int64Bounds
+	if err := json.Unmarshal([]byte(v), &bounds); err != nil {
+		return nil, err
+	}
+	numRowGroups := len(f.RowGroups())
+	for column, b := range bounds {
+		if len(b) != numRowGroups {
+			delete(bounds, column)
+		}
+	}
+	return bounds, nil
+}
+
 func (r *parquetReader[M, P]) Close() error {
 	if r.reader != nil {
 		return r.reader.Close()
@@ -1152,6 +1245,7 @@ func (r *parquetReader[M, P]) columnIter(ctx context.Context, columnName string,
 		return query.NewErrIterator(fmt.Errorf("column '%s' not found in parquet file '%s'", columnName, r.relPath()))
 	}
 	ctx = query.AddMetricsToContext(ctx, r.metrics.query)
+	predicate = query.NewRowGroupBoundsPredicate(predicate, r.rowGroupBounds[columnName])
 	return query.NewSyncIterator(ctx, r.file.RowGroups(), index, columnName, 1000, predicate, alias)
 }
 
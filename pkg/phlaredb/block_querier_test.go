@@ -189,3 +189,53 @@ func TestBlockCompatability(t *testing.T) {
 
 	}
 }
+
+func TestBlockQuerier_SelectMatchingProfiles_Shards(t *testing.T) {
+	bucket, err := filesystem.NewBucket("./block/testdata/")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	metas, err := NewBlockQuerier(ctx, bucket).BlockMetas(ctx)
+	require.NoError(t, err)
+
+	for _, meta := range metas {
+		meta := meta
+		t.Run(meta.ULID.String(), func(t *testing.T) {
+			q := NewSingleBlockQuerierFromMeta(ctx, bucket, meta)
+			require.NoError(t, q.Open(ctx))
+			defer q.Close()
+
+			profilesTypes, err := q.index.LabelValues("__profile_type__")
+			require.NoError(t, err)
+			require.NotEmpty(t, profilesTypes)
+			profileType := profilesTypes[0]
+			count := func(selector string, fn func(Profile)) int {
+				it, err := q.SelectMatchingProfiles(ctx, &ingestv1.SelectProfilesRequest{
+					LabelSelector: selector,
+					Start:         0,
+					End:           time.Now().UnixMilli(),
+					Type:          mustParseProfileSelector(t, profileType),
+				})
+				require.NoError(t, err)
+				var n int
+				for it.Next() {
+					fn(it.At())
+					n++
+				}
+				require.NoError(t, it.Err())
+				return n
+			}
+
+			const shards = 3
+			var total int
+			for i := 0; i < shards; i++ {
+				total += count(fmt.Sprintf(`{__cortex_shard__="%d_of_%d"}`, i, shards), func(p Profile) {
+					require.Equal(t, uint64(i), uint64(p.Fingerprint())%shards)
+				})
+			}
+			expected := count("{}", func(Profile) {})
+			require.NotZero(t, expected)
+			require.Equal(t, expected, total)
+		})
+	}
+}
@@ -0,0 +1,438 @@
+package phlaredb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/runutil"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/segmentio/parquet-go"
+
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	phlareparquet "github.com/grafana/phlare/pkg/parquet"
+	"github.com/grafana/phlare/pkg/phlaredb/block"
+	schemav1 "github.com/grafana/phlare/pkg/phlaredb/schemas/v1"
+	"github.com/grafana/phlare/pkg/phlaredb/tombstones"
+	"github.com/grafana/phlare/pkg/phlaredb/tsdb/index"
+	"github.com/grafana/phlare/pkg/util/build"
+)
+
+// RewriteBlock writes a copy of the block in srcDir, without the profiles
+// deleted by the tombstones, as a new block in dstDir. The series left without
+// profiles are removed from the index, the other files are copied as is.
+//
+// It returns the meta of the new block and the number of profiles deleted.
+// If no profile is deleted, no block is written. If all of them are, the
+// block is not written either and the meta returned is nil.
+func RewriteBlock(ctx context.Context, logger log.Logger, srcDir, dstDir string, ts tombstones.Tombstones) (_ *block.Meta, deleted uint64, err error) {
+	srcMeta, _, err := block.MetaFromDir(srcDir)
+	if err != nil {
+		return nil, 0, err
+	}
+	if ts = ts.ForRange(srcMeta.MinTime, srcMeta.MaxTime); len(ts) == 0 {
+		return nil, 0, nil
+	}
+	series, err := readRewriteSeries(filepath.Join(srcDir, block.IndexFilename), ts)
+	if err != nil {
+		return nil, 0, err
+	}
+	var affected bool
+	for _, s := range series {
+		if affected = s.deletable; affected {
+			break
+		}
+	}
+	if !affected {
+		return nil, 0, nil
+	}
+
+	meta := block.NewMeta()
+	meta.MinTime, meta.MaxTime = srcMeta.MinTime, srcMeta.MaxTime
+	meta.Version = srcMeta.Version
+	meta.Source = block.CompactorSource
+	for k, v := range srcMeta.Labels {
+		meta.Labels[k] = v
+	}
+	meta.Compaction = tsdb.BlockMetaCompaction{
+		Level:   srcMeta.Compaction.Level,
+		Sources: srcMeta.Compaction.Sources,
+		Parents: []tsdb.BlockDesc{{
+			ULID:    srcMeta.ULID,
+			MinTime: int64(srcMeta.MinTime),
+			MaxTime: int64(srcMeta.MaxTime),
+		}},
+	}
+	if len(meta.Compaction.Sources) == 0 {
+		meta.Compaction.Sources = []ulid.ULID{srcMeta.ULID}
+	}
+
+	dir := filepath.Join(dstDir, meta.ULID.String())
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err != nil || meta == nil {
+			_ = os.RemoveAll(dir)
+		}
+	}()
+
+	profilesFile := (&schemav1.ProfilePersister{}).Name() + block.ParquetSuffix
+	r, err := rewriteProfiles(filepath.Join(srcDir, profilesFile), filepath.Join(dir, profilesFile), series, ts)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "rewrite profiles")
+	}
+	if r.deleted == 0 {
+		meta = nil
+		return nil, 0, nil
+	}
+	if r.rows == 0 {
+		meta = nil
+		return nil, r.deleted, nil
+	}
+	numSeries, err := writeRewriteSeries(ctx, filepath.Join(dir, block.IndexFilename), series)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "write index")
+	}
+	if err = copyBlockFiles(srcDir, dir, block.MetaFilename, block.DeletionMarkFilename, block.IndexFilename, profilesFile); err != nil {
+		return nil, 0, err
+	}
+
+	meta.Stats = block.BlockStats{
+		NumSamples:  r.samples,
+		NumSeries:   numSeries,
+		NumProfiles: r.rows,
+	}
+	meta.Files = make([]block.File, 0, len(srcMeta.Files))
+	for _, f := range srcMeta.Files {
+		switch f.RelPath {
+		case block.IndexFilename:
+			f.TSDB = &block.TSDBFile{NumSeries: numSeries}
+		case profilesFile:
+			f.Parquet = &block.ParquetFile{NumRowGroups: r.rowGroups, NumRows: r.rows}
+		default:
+			meta.Files = append(meta.Files, f)
+			continue
+		}
+		stat, err := os.Stat(filepath.Join(dir, f.RelPath))
+		if err != nil {
+			return nil, 0, err
+		}
+		f.SizeBytes = uint64(stat.Size())
+		meta.Files = append(meta.Files, f)
+	}
+	if _, err = meta.WriteToFile(logger, dir); err != nil {
+		return nil, 0, err
+	}
+	return meta, r.deleted, nil
+}
+
+type rewriteSeries struct {
+	lbls phlaremodel.Labels
+	fp   model.Fingerprint
+	// deletable is true if the series may have profiles deleted.
+	deletable bool
+
+	// The profiles kept.
+	profiles         int
+	minTime, maxTime int64
+}
+
+// readRewriteSeries reads the series of the index, by series index.
+func readRewriteSeries(path string, ts tombstones.Tombstones) (map[uint32]*rewriteSeries, error) {
+	reader, err := index.NewFileReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	k, v := index.AllPostingsKey()
+	postings, err := reader.Postings(k, nil, v)
+	if err != nil {
+		return nil, err
+	}
+	series := make(map[uint32]*rewriteSeries)
+	chks := make([]index.ChunkMeta, 1)
+	for postings.Next() {
+		var lbls phlaremodel.Labels
+		fp, err := reader.Series(postings.At(), &lbls, &chks)
+		if err != nil {
+			return nil, err
+		}
+		s := &rewriteSeries{lbls: lbls, fp: model.Fingerprint(fp)}
+		for _, t := range ts {
+			if t.Matches(lbls) && t.Overlaps(model.TimeFromUnixNano(chks[0].MinTime), model.TimeFromUnixNano(chks[0].MaxTime)) {
+				s.deletable = true
+				break
+			}
+		}
+		series[chks[0].SeriesIndex] = s
+	}
+	return series, postings.Err()
+}
+
+// writeRewriteSeries writes the index of the series with profiles left. The
+// series keep their series index, so that the profiles need not be updated.
+func writeRewriteSeries(ctx context.Context, path string, series map[uint32]*rewriteSeries) (uint64, error) {
+	seriesIndexes := make([]uint32, 0, len(series))
+	symbolsMap := make(map[string]struct{})
+	for i, s := range series {
+		if s.profiles == 0 {
+			continue
+		}
+		seriesIndexes = append(seriesIndexes, i)
+		for _, l := range s.lbls {
+			symbolsMap[l.Name] = struct{}{}
+			symbolsMap[l.Value] = struct{}{}
+		}
+	}
+	sort.Slice(seriesIndexes, func(i, j int) bool {
+		return phlaremodel.CompareLabelPairs(series[seriesIndexes[i]].lbls, series[seriesIndexes[j]].lbls) < 0
+	})
+	symbols := make([]string, 0, len(symbolsMap))
+	for s := range symbolsMap {
+		symbols = append(symbols, s)
+	}
+	sort.Strings(symbols)
+
+	writer, err := index.NewWriter(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+	for _, symbol := range symbols {
+		if err = writer.AddSymbol(symbol); err != nil {
+			return 0, err
+		}
+	}
+	for i, seriesIndex := range seriesIndexes {
+		s := series[seriesIndex]
+		if err = writer.AddSeries(storage.SeriesRef(i), s.lbls, s.fp, index.ChunkMeta{
+			MinTime:     s.minTime,
+			MaxTime:     s.maxTime,
+			SeriesIndex: seriesIndex,
+		}); err != nil {
+			return 0, err
+		}
+	}
+	return uint64(len(seriesIndexes)), writer.Close()
+}
+
+type rewriteProfilesResult struct {
+	rows, rowGroups uint64
+	samples         uint64
+	deleted         uint64
+}
+
+func rewriteProfiles(srcPath, dstPath string, series map[uint32]*rewriteSeries, ts tombstones.Tombstones) (_ rewriteProfilesResult, err error) {
+	var r rewriteProfilesResult
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return r, err
+	}
+	defer runutil.CloseWithErrCapture(&err, src, "closing source parquet file")
+	stat, err := src.Stat()
+	if err != nil {
+		return r, err
+	}
+	file, err := parquet.OpenFile(src, stat.Size(), parquet.SkipBloomFilters(true))
+	if err != nil {
+		return r, err
+	}
+	reader, err := newTombstonesRowReader(file, series, ts)
+	if err != nil {
+		return r, err
+	}
+	r.rows, r.rowGroups, err = copyProfiles(file, file.RowGroups(), dstPath, func(rows parquet.RowReader) parquet.RowReader {
+		reader.RowReader = rows
+		return reader
+	})
+	if err != nil {
+		return r, err
+	}
+	r.samples, r.deleted = reader.samples, reader.deleted
+	return r, nil
+}
+
+// copyProfiles writes the rows of the row groups of the profiles file to a new
+// file, through the reader returned by wrap if any.
+func copyProfiles(file *parquet.File, rowGroups []parquet.RowGroup, dstPath string, wrap func(parquet.RowReader) parquet.RowReader) (numRows, numRowGroups uint64, err error) {
+	dst, err := os.OpenFile(dstPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer runutil.CloseWithErrCapture(&err, dst, "closing parquet file")
+	writer := parquet.NewWriter(dst, file.Schema(),
+		parquet.ColumnPageBuffers(parquet.NewFileBufferPool(os.TempDir(), "phlaredb-parquet-buffers*")),
+		parquet.CreatedBy("github.com/grafana/phlare/", build.Version, build.Revision),
+		parquet.PageBufferSize(3*1024*1024),
+	)
+	rowGroupSize := 0
+	for _, rg := range rowGroups {
+		if int(rg.NumRows()) > rowGroupSize {
+			rowGroupSize = int(rg.NumRows())
+		}
+	}
+	if rowGroupSize == 0 {
+		return 0, 0, writer.Close()
+	}
+
+	// The row group bounds are only recorded by the files with the current
+	// profiles schema.
+	_, withBounds := file.Lookup(rowGroupBoundsKey)
+	var w phlareparquet.RowWriterFlusher = writer
+	boundsWriter := newRowGroupBoundsWriter(writer)
+	if withBounds {
+		w = boundsWriter
+	}
+
+	rows := parquet.MultiRowGroup(rowGroups...).Rows()
+	defer runutil.CloseWithErrCapture(&err, rows, "closing rows")
+	var reader parquet.RowReader = rows
+	if wrap != nil {
+		reader = wrap(rows)
+	}
+	if numRows, numRowGroups, err = phlareparquet.CopyAsRowGroups(w, reader, rowGroupSize); err != nil {
+		return 0, 0, err
+	}
+	if withBounds {
+		bounds, err := boundsWriter.metadata()
+		if err != nil {
+			return 0, 0, err
+		}
+		writer.SetKeyValueMetadata(rowGroupBoundsKey, bounds)
+	}
+	return numRows, numRowGroups, writer.Close()
+}
+
+// tombstonesRowReader reads the profiles rows not deleted by the tombstones,
+// recording the profiles kept per series.
+type tombstonesRowReader struct {
+	parquet.RowReader
+
+	series     map[uint32]*rewriteSeries
+	tombstones tombstones.Tombstones
+
+	seriesIndexColumn  int
+	timeNanosColumn    int
+	stacktraceIDColumn int
+	stacktraceIDLevel  int
+
+	samples uint64
+	deleted uint64
+}
+
+func newTombstonesRowReader(file *parquet.File, series map[uint32]*rewriteSeries, ts tombstones.Tombstones) (*tombstonesRowReader, error) {
+	r := &tombstonesRowReader{series: series, tombstones: ts}
+	for _, c := range []struct {
+		path   []string
+		column *int
+	}{
+		{[]string{"SeriesIndex"}, &r.seriesIndexColumn},
+		{[]string{"TimeNanos"}, &r.timeNanosColumn},
+		{[]string{"Samples", "list", "element", "StacktraceID"}, &r.stacktraceIDColumn},
+	} {
+		leaf, ok := file.Schema().Lookup(c.path...)
+		if !ok {
+			return nil, fmt.Errorf("column %v not found", c.path)
+		}
+		*c.column = leaf.ColumnIndex
+		// Empty lists of samples are stored as a null value.
+		r.stacktraceIDLevel = leaf.MaxDefinitionLevel
+	}
+	return r, nil
+}
+
+func (r *tombstonesRowReader) ReadRows(rows []parquet.Row) (int, error) {
+	for {
+		n, err := r.RowReader.ReadRows(rows)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		kept := 0
+		for i := 0; i < n; i++ {
+			var (
+				seriesIndex uint32
+				timeNanos   int64
+				samples     uint64
+			)
+			for _, v := range rows[i] {
+				switch v.Column() {
+				case r.seriesIndexColumn:
+					seriesIndex = uint32(v.Int64())
+				case r.timeNanosColumn:
+					timeNanos = v.Int64()
+				case r.stacktraceIDColumn:
+					if v.DefinitionLevel() == r.stacktraceIDLevel {
+						samples++
+					}
+				}
+			}
+			s, ok := r.series[seriesIndex]
+			if !ok {
+				return 0, fmt.Errorf("series index %d not found in the block index", seriesIndex)
+			}
+			if s.deletable && r.tombstones.Deletes(s.lbls, model.TimeFromUnixNano(timeNanos)) {
+				r.deleted++
+				continue
+			}
+			if s.profiles == 0 || timeNanos < s.minTime {
+				s.minTime = timeNanos
+			}
+			if s.profiles == 0 || timeNanos > s.maxTime {
+				s.maxTime = timeNanos
+			}
+			s.profiles++
+			r.samples += samples
+			rows[kept], rows[i] = rows[i], rows[kept]
+			kept++
+		}
+		if kept > 0 || n == 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// copyBlockFiles copies the files of the block in srcDir to dstDir, except
+// the ones excluded.
+func copyBlockFiles(srcDir, dstDir string, exclude ...string) error {
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		for _, e := range exclude {
+			if rel == e {
+				return nil
+			}
+		}
+		return copyFile(path, filepath.Join(dstDir, rel))
+	})
+}
+
+func copyFile(src, dst string) (err error) {
+	if err = os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer runutil.CloseWithErrCapture(&err, out, "closing %s", dst)
+	_, err = io.Copy(out, in)
+	return err
+}
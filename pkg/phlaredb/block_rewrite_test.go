@@ -0,0 +1,169 @@
+package phlaredb
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	ingestv1 "github.com/grafana/phlare/api/gen/proto/go/ingester/v1"
+	"github.com/grafana/phlare/pkg/iter"
+	"github.com/grafana/phlare/pkg/objstore/providers/filesystem"
+	"github.com/grafana/phlare/pkg/phlaredb/block"
+	"github.com/grafana/phlare/pkg/phlaredb/tombstones"
+	"github.com/grafana/phlare/pkg/pprof/testhelper"
+)
+
+func newTestTombstone(t *testing.T, selector string, start, end model.Time) *tombstones.Tombstone {
+	ts, err := tombstones.New(selector, start, end, time.Now())
+	require.NoError(t, err)
+	return ts
+}
+
+func selectBlockProfiles(t *testing.T, ctx context.Context, dir string, meta *block.Meta, selector, profileType string) []Profile {
+	bkt, err := filesystem.NewBucket(dir)
+	require.NoError(t, err)
+	q := NewSingleBlockQuerierFromMeta(ctx, bkt, meta)
+	require.NoError(t, q.Open(ctx))
+	defer q.Close()
+	it, err := q.SelectMatchingProfiles(ctx, &ingestv1.SelectProfilesRequest{
+		LabelSelector: selector,
+		Start:         0,
+		End:           time.Now().UnixMilli(),
+		Type:          mustParseProfileSelector(t, profileType),
+	})
+	require.NoError(t, err)
+	profiles, err := iter.Slice(it)
+	require.NoError(t, err)
+	return profiles
+}
+
+func TestRewriteBlock(t *testing.T) {
+	ctx := testContext(t)
+	head := newTestHead(t)
+	for i := 0; i < 30; i++ {
+		p := testhelper.NewProfileBuilder(time.Second.Nanoseconds()*int64(i)).
+			CPUProfile().
+			WithLabels("stream", streams[i%3])
+		p.ForStacktraceString("func1", "func2").AddSamples(10)
+		p.ForStacktraceString("func1").AddSamples(20)
+		require.NoError(t, head.Ingest(ctx, p.Profile, p.UUID, p.Labels...))
+	}
+	require.NoError(t, head.Flush(ctx))
+	require.NoError(t, head.Move())
+	srcDir := head.localPath
+	const profileType = "process_cpu:cpu:nanoseconds:cpu:nanoseconds"
+
+	t.Run("nothing deleted", func(t *testing.T) {
+		dstDir := t.TempDir()
+		meta, deleted, err := RewriteBlock(ctx, log.NewNopLogger(), srcDir, dstDir, tombstones.Tombstones{
+			newTestTombstone(t, `{job="bar"}`, 0, model.Latest),
+			newTestTombstone(t, `{job="foo"}`, model.TimeFromUnix(100), model.Latest),
+		})
+		require.NoError(t, err)
+		require.Nil(t, meta)
+		require.Zero(t, deleted)
+		entries, err := filepath.Glob(filepath.Join(dstDir, "*"))
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+
+	t.Run("profiles deleted", func(t *testing.T) {
+		dstDir := t.TempDir()
+		meta, deleted, err := RewriteBlock(ctx, log.NewNopLogger(), srcDir, dstDir, tombstones.Tombstones{
+			// Profiles 0, 3, 6 and 9.
+			newTestTombstone(t, `{stream="stream-a"}`, 0, model.TimeFromUnix(9)),
+			// All the profiles of the series.
+			newTestTombstone(t, `{stream="stream-b"}`, 0, model.Latest),
+		})
+		require.NoError(t, err)
+		require.NotNil(t, meta)
+		require.Equal(t, uint64(14), deleted)
+		require.Equal(t, uint64(16), meta.Stats.NumProfiles)
+		require.Equal(t, uint64(2), meta.Stats.NumSeries)
+		require.Equal(t, uint64(16*2), meta.Stats.NumSamples)
+		require.Equal(t, block.CompactorSource, meta.Source)
+
+		fromDisk, err := block.ReadFromDir(filepath.Join(dstDir, meta.ULID.String()))
+		require.NoError(t, err)
+		require.Equal(t, meta.ULID, fromDisk.ULID)
+
+		profiles := selectBlockProfiles(t, ctx, dstDir, meta, `{job="foo"}`, profileType)
+		require.Len(t, profiles, 16)
+		for _, p := range profiles {
+			stream := p.Labels().Get("stream")
+			require.NotEqual(t, "stream-b", stream)
+			if stream == "stream-a" {
+				require.Greater(t, p.Timestamp(), model.TimeFromUnix(9))
+			}
+		}
+
+		bkt, err := filesystem.NewBucket(dstDir)
+		require.NoError(t, err)
+		q := NewSingleBlockQuerierFromMeta(ctx, bkt, meta)
+		require.NoError(t, q.Open(ctx))
+		defer q.Close()
+		values, err := q.index.LabelValues("stream")
+		require.NoError(t, err)
+		require.Equal(t, []string{"stream-a", "stream-c"}, values)
+	})
+
+	t.Run("all profiles deleted", func(t *testing.T) {
+		dstDir := t.TempDir()
+		meta, deleted, err := RewriteBlock(ctx, log.NewNopLogger(), srcDir, dstDir, tombstones.Tombstones{
+			newTestTombstone(t, `{job="foo"}`, 0, model.Latest),
+		})
+		require.NoError(t, err)
+		require.Nil(t, meta)
+		require.Equal(t, uint64(30), deleted)
+		entries, err := filepath.Glob(filepath.Join(dstDir, "*"))
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+}
+
+func TestRewriteBlock_Compatibility(t *testing.T) {
+	ctx := context.Background()
+	bkt, err := filesystem.NewBucket("./block/testdata/")
+	require.NoError(t, err)
+	metas, err := NewBlockQuerier(ctx, bkt).BlockMetas(ctx)
+	require.NoError(t, err)
+
+	for _, meta := range metas {
+		meta := meta
+		t.Run(fmt.Sprintf("block-v%d-%s", meta.Version, meta.ULID.String()), func(t *testing.T) {
+			q := NewSingleBlockQuerierFromMeta(ctx, bkt, meta)
+			require.NoError(t, q.Open(ctx))
+			profileTypes, err := q.index.LabelValues("__profile_type__")
+			require.NoError(t, err)
+			require.NoError(t, q.Close())
+			require.Greater(t, len(profileTypes), 1)
+
+			dstDir := t.TempDir()
+			selector := fmt.Sprintf(`{__profile_type__=%q}`, profileTypes[0])
+			newMeta, deleted, err := RewriteBlock(ctx, log.NewNopLogger(), filepath.Join("./block/testdata/", meta.ULID.String()), dstDir, tombstones.Tombstones{
+				newTestTombstone(t, selector, 0, model.Latest),
+			})
+			require.NoError(t, err)
+			require.NotNil(t, newMeta)
+			require.NotZero(t, deleted)
+			require.Equal(t, meta.Stats.NumProfiles-deleted, newMeta.Stats.NumProfiles)
+
+			var kept int
+			for _, profileType := range profileTypes {
+				n := len(selectBlockProfiles(t, ctx, dstDir, newMeta, "{}", profileType))
+				if profileType == profileTypes[0] {
+					require.Zero(t, n, strings.Join(profileTypes, ","))
+				}
+				kept += n
+			}
+			require.Equal(t, int(newMeta.Stats.NumProfiles), kept)
+		})
+	}
+}
@@ -0,0 +1,606 @@
+package phlaredb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/segmentio/parquet-go"
+
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/objstore/providers/filesystem"
+	"github.com/grafana/phlare/pkg/phlaredb/block"
+	schemav1 "github.com/grafana/phlare/pkg/phlaredb/schemas/v1"
+	"github.com/grafana/phlare/pkg/phlaredb/symdb"
+	"github.com/grafana/phlare/pkg/phlaredb/tsdb/index"
+)
+
+// BlockProblem is an inconsistency found in a block.
+type BlockProblem struct {
+	// File is the path of the file concerned, relative to the block
+	// directory.
+	File    string
+	Message string
+}
+
+func (p BlockProblem) String() string {
+	return p.File + ": " + p.Message
+}
+
+// VerifyBlock checks the integrity of the block in dir: the meta against the
+// files and their content, the TSDB index, the parquet files, the symbols,
+// and the references of the profiles to the series and the stack traces.
+//
+// The problems found are returned, the error is only returned if the block
+// could not be verified at all.
+func VerifyBlock(ctx context.Context, dir string) ([]BlockProblem, error) {
+	v, err := verifyBlock(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	return v.problems, nil
+}
+
+// RepairBlock writes a repaired copy of the block in srcDir, as a new block
+// in dstDir with the block as parent: the row groups of the profiles which
+// can't be read are dropped, and the meta is rebuilt from the files of the
+// block. The other files can't be repaired, as their rows are referenced by
+// their number, and are copied as is.
+//
+// It returns the meta of the new block and the problems left in it. If
+// VerifyBlock reports no problem, no block is written and the meta returned
+// is nil.
+func RepairBlock(ctx context.Context, logger log.Logger, srcDir, dstDir string) (_ *block.Meta, _ []BlockProblem, err error) {
+	src, err := verifyBlock(ctx, srcDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(src.problems) == 0 {
+		return nil, nil, nil
+	}
+
+	meta := block.NewMeta()
+	meta.Version = block.MetaVersion1
+	if _, ok := src.files[filepath.Join(symdb.DefaultDirName, symdb.IndexFileName)]; ok {
+		meta.Version = block.MetaVersion2
+	}
+	var parent ulid.ULID
+	if src.meta != nil {
+		parent = src.meta.ULID
+		meta.MinTime, meta.MaxTime = src.meta.MinTime, src.meta.MaxTime
+		meta.Version = src.meta.Version
+		meta.Source = src.meta.Source
+		for k, v := range src.meta.Labels {
+			meta.Labels[k] = v
+		}
+		meta.Compaction.Level = src.meta.Compaction.Level
+		meta.Compaction.Sources = src.meta.Compaction.Sources
+	} else if id, ok := block.IsBlockDir(srcDir); ok {
+		parent = id
+	}
+
+	dir := filepath.Join(dstDir, meta.ULID.String())
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(dir)
+		}
+	}()
+
+	// Only the files of the block are copied.
+	profilesFile := (&schemav1.ProfilePersister{}).Name() + block.ParquetSuffix
+	broken := src.brokenRowGroups[profilesFile]
+	for _, f := range src.sortedFiles() {
+		if f.RelPath == profilesFile && len(broken) > 0 {
+			err = dropRowGroups(filepath.Join(srcDir, profilesFile), filepath.Join(dir, profilesFile), broken)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "drop broken row groups")
+			}
+			continue
+		}
+		if err = copyFile(filepath.Join(srcDir, f.RelPath), filepath.Join(dir, f.RelPath)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	v, err := verifyBlock(ctx, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if v.profiles.rows > 0 && (src.meta == nil || src.profilesOutOfRange || meta.MinTime > meta.MaxTime) {
+		meta.MinTime = model.TimeFromUnixNano(v.profiles.minTime)
+		meta.MaxTime = model.TimeFromUnixNano(v.profiles.maxTime)
+	}
+	if parent != (ulid.ULID{}) {
+		meta.Compaction.Parents = []tsdb.BlockDesc{{
+			ULID:    parent,
+			MinTime: int64(meta.MinTime),
+			MaxTime: int64(meta.MaxTime),
+		}}
+		if len(meta.Compaction.Sources) == 0 {
+			meta.Compaction.Sources = []ulid.ULID{parent}
+		}
+	}
+	meta.Stats = block.BlockStats{
+		NumSamples:  v.profiles.samples,
+		NumSeries:   uint64(len(v.seriesIndexes)),
+		NumProfiles: v.profiles.rows,
+	}
+	meta.Files = v.sortedFiles()
+	if _, err = meta.WriteToFile(logger, dir); err != nil {
+		return nil, nil, err
+	}
+
+	if v, err = verifyBlock(ctx, dir); err != nil {
+		return nil, nil, err
+	}
+	return meta, v.problems, nil
+}
+
+// dropRowGroups copies the profiles file without the row groups given.
+func dropRowGroups(src, dst string, rowGroups []int) (err error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	file, err := parquet.OpenFile(f, stat.Size(), parquet.SkipBloomFilters(true))
+	if err != nil {
+		return err
+	}
+	drop := make(map[int]struct{}, len(rowGroups))
+	for _, i := range rowGroups {
+		drop[i] = struct{}{}
+	}
+	keep := make([]parquet.RowGroup, 0, len(file.RowGroups()))
+	for i, rg := range file.RowGroups() {
+		if _, ok := drop[i]; !ok {
+			keep = append(keep, rg)
+		}
+	}
+	_, _, err = copyProfiles(file, keep, dst, nil)
+	return err
+}
+
+type blockVerifier struct {
+	dir      string
+	meta     *block.Meta
+	problems []BlockProblem
+
+	// The files of the block, as they are recorded in the meta.
+	files           map[string]*block.File
+	brokenRowGroups map[string][]int
+
+	seriesIndexes map[uint32]struct{}
+	indexRead     bool
+	// The stack trace IDs are looked up in the symbols of v2 blocks, and
+	// are the row numbers of the stack traces of v1 blocks.
+	symbols     *symdb.Reader
+	stacktraces uint64
+
+	profiles           profilesStats
+	profilesOutOfRange bool
+}
+
+type profilesStats struct {
+	rows, samples    uint64
+	minTime, maxTime int64
+
+	unknownSeries      uint64
+	unknownStacktraces uint64
+}
+
+func (s *profilesStats) add(o profilesStats) {
+	if s.rows == 0 || (o.rows > 0 && o.minTime < s.minTime) {
+		s.minTime = o.minTime
+	}
+	if s.rows == 0 || (o.rows > 0 && o.maxTime > s.maxTime) {
+		s.maxTime = o.maxTime
+	}
+	s.rows += o.rows
+	s.samples += o.samples
+	s.unknownSeries += o.unknownSeries
+	s.unknownStacktraces += o.unknownStacktraces
+}
+
+func verifyBlock(ctx context.Context, dir string) (*blockVerifier, error) {
+	v := &blockVerifier{
+		dir:             dir,
+		files:           make(map[string]*block.File),
+		brokenRowGroups: make(map[string][]int),
+		seriesIndexes:   make(map[uint32]struct{}),
+	}
+	if err := v.listFiles(); err != nil {
+		return nil, err
+	}
+	v.verifyMeta()
+	v.verifyIndex()
+	v.verifySymbols(ctx)
+	v.verifyParquetFiles()
+	v.verifyFiles()
+	v.verifyStats()
+	return v, nil
+}
+
+func (v *blockVerifier) problem(file string, format string, args ...interface{}) {
+	v.problems = append(v.problems, BlockProblem{File: file, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *blockVerifier) listFiles() error {
+	return filepath.Walk(v.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(v.dir, path)
+		if err != nil {
+			return err
+		}
+		switch rel {
+		case block.MetaFilename, block.DeletionMarkFilename:
+			return nil
+		}
+		v.files[rel] = &block.File{RelPath: filepath.ToSlash(rel), SizeBytes: uint64(info.Size())}
+		return nil
+	})
+}
+
+func (v *blockVerifier) verifyMeta() {
+	meta, err := block.ReadFromDir(v.dir)
+	if err != nil {
+		v.problem(block.MetaFilename, "failed to read the meta: %v", err)
+		return
+	}
+	v.meta = meta
+	if id, ok := block.IsBlockDir(v.dir); ok && id != meta.ULID {
+		v.problem(block.MetaFilename, "the block ID %s does not match the directory name", meta.ULID)
+	}
+	if meta.MinTime > meta.MaxTime {
+		v.problem(block.MetaFilename, "the min time %d is after the max time %d", meta.MinTime, meta.MaxTime)
+	}
+}
+
+func (v *blockVerifier) verifyIndex() {
+	f, ok := v.files[block.IndexFilename]
+	if !ok {
+		v.problem(block.IndexFilename, "missing file")
+		return
+	}
+	r, err := index.NewFileReader(filepath.Join(v.dir, block.IndexFilename))
+	if err != nil {
+		v.problem(block.IndexFilename, "failed to open the index: %v", err)
+		return
+	}
+	defer r.Close()
+	k, val := index.AllPostingsKey()
+	postings, err := r.Postings(k, nil, val)
+	if err != nil {
+		v.problem(block.IndexFilename, "failed to read the postings: %v", err)
+		return
+	}
+	var (
+		lbls     phlaremodel.Labels
+		chks     = make([]index.ChunkMeta, 1)
+		previous phlaremodel.Labels
+	)
+	for postings.Next() {
+		if _, err = r.Series(postings.At(), &lbls, &chks); err != nil {
+			v.problem(block.IndexFilename, "failed to read the series %d: %v", postings.At(), err)
+			return
+		}
+		if len(chks) != 1 {
+			v.problem(block.IndexFilename, "the series %s has %d chunks, expected 1", lbls, len(chks))
+			return
+		}
+		if previous != nil && phlaremodel.CompareLabelPairs(previous, lbls) >= 0 {
+			v.problem(block.IndexFilename, "the series %s is not sorted", lbls)
+		}
+		previous = append(previous[:0], lbls...)
+		if _, ok := v.seriesIndexes[chks[0].SeriesIndex]; ok {
+			v.problem(block.IndexFilename, "the series index %d is used by several series", chks[0].SeriesIndex)
+		}
+		v.seriesIndexes[chks[0].SeriesIndex] = struct{}{}
+	}
+	if err = postings.Err(); err != nil {
+		v.problem(block.IndexFilename, "failed to read the postings: %v", err)
+		return
+	}
+	f.TSDB = &block.TSDBFile{NumSeries: uint64(len(v.seriesIndexes))}
+	v.indexRead = true
+}
+
+func (v *blockVerifier) verifySymbols(ctx context.Context) {
+	symbolsIndex := filepath.Join(symdb.DefaultDirName, symdb.IndexFileName)
+	if _, ok := v.files[symbolsIndex]; !ok {
+		if v.meta != nil && v.meta.Version >= block.MetaVersion2 {
+			v.problem(symbolsIndex, "missing file")
+		}
+		return
+	}
+	bkt, err := filesystem.NewBucket(filepath.Join(v.dir, symdb.DefaultDirName))
+	if err != nil {
+		v.problem(symbolsIndex, "failed to open the symbols: %v", err)
+		return
+	}
+	r, err := symdb.Open(ctx, bkt)
+	if err != nil {
+		v.problem(symbolsIndex, "failed to open the symbols: %v", err)
+		return
+	}
+	if err = r.VerifyChunks(ctx); err != nil {
+		v.problem(filepath.Join(symdb.DefaultDirName, symdb.StacktracesFileName), "invalid stack traces: %v", err)
+		return
+	}
+	v.symbols = r
+}
+
+func (v *blockVerifier) verifyParquetFiles() {
+	names := make([]string, 0, len(v.files))
+	for name := range v.files {
+		if filepath.Ext(name) == block.ParquetSuffix {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	profilesFile := (&schemav1.ProfilePersister{}).Name() + block.ParquetSuffix
+	stacktracesFile := (&schemav1.StacktracePersister{}).Name() + block.ParquetSuffix
+	// The stack traces are needed to verify the profiles.
+	sort.SliceStable(names, func(i, j int) bool {
+		return names[i] == stacktracesFile && names[j] != stacktracesFile
+	})
+	for _, name := range names {
+		var verifyRows func(*parquet.File, parquet.RowGroup) (profilesStats, error)
+		if name == profilesFile {
+			verifyRows = v.verifyProfiles
+		}
+		v.verifyParquetFile(name, verifyRows)
+		if name == stacktracesFile && v.files[name].Parquet != nil {
+			v.stacktraces = v.files[name].Parquet.NumRows
+		}
+	}
+}
+
+// verifyParquetFile reads all the rows of the file, recording the row groups
+// which can't be read.
+func (v *blockVerifier) verifyParquetFile(name string, verifyRows func(*parquet.File, parquet.RowGroup) (profilesStats, error)) {
+	f, err := os.Open(filepath.Join(v.dir, name))
+	if err != nil {
+		v.problem(name, "failed to open the file: %v", err)
+		return
+	}
+	defer f.Close()
+	file, err := parquet.OpenFile(f, int64(v.files[name].SizeBytes), parquet.SkipBloomFilters(true))
+	if err != nil {
+		v.problem(name, "failed to open the parquet file: %v", err)
+		return
+	}
+	for i, rg := range file.RowGroups() {
+		var err error
+		if verifyRows != nil {
+			var s profilesStats
+			if s, err = verifyRows(file, rg); err == nil {
+				v.profiles.add(s)
+			}
+		} else {
+			err = readRowGroup(rg, nil)
+		}
+		if err != nil {
+			v.problem(name, "failed to read the row group %d: %v", i, err)
+			v.brokenRowGroups[name] = append(v.brokenRowGroups[name], i)
+		}
+	}
+	v.files[name].Parquet = &block.ParquetFile{
+		NumRowGroups: uint64(len(file.RowGroups())),
+		NumRows:      uint64(file.NumRows()),
+	}
+}
+
+// readRowGroup reads all the rows of the row group, the function given is
+// called for each of them.
+func readRowGroup(rg parquet.RowGroup, fn func(parquet.Row)) (err error) {
+	// Corrupted pages may lead the decoders to panic.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	rows := rg.Rows()
+	defer func() {
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	buf := make([]parquet.Row, 64)
+	var n int64
+	for {
+		c, err := rows.ReadRows(buf)
+		if fn != nil {
+			for _, row := range buf[:c] {
+				fn(row)
+			}
+		}
+		n += int64(c)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if n != rg.NumRows() {
+		return fmt.Errorf("read %d rows, expected %d", n, rg.NumRows())
+	}
+	return nil
+}
+
+func (v *blockVerifier) verifyProfiles(file *parquet.File, rg parquet.RowGroup) (s profilesStats, err error) {
+	columns := make(map[string]int)
+	var stacktraceIDLevel int
+	for _, path := range [][]string{
+		{"SeriesIndex"},
+		{"TimeNanos"},
+		{"StacktracePartition"},
+		{"Samples", "list", "element", "StacktraceID"},
+	} {
+		leaf, ok := file.Schema().Lookup(path...)
+		if !ok {
+			// Profiles written before the stack trace partitions were
+			// introduced only have the partition 0.
+			if path[0] == "StacktracePartition" {
+				continue
+			}
+			return s, fmt.Errorf("column %v not found", path)
+		}
+		columns[path[len(path)-1]] = leaf.ColumnIndex
+		stacktraceIDLevel = leaf.MaxDefinitionLevel
+	}
+	partitionColumn, withPartitions := columns["StacktracePartition"]
+	stacktraceIDs := make([]uint32, 0, 64)
+	err = readRowGroup(rg, func(row parquet.Row) {
+		var (
+			seriesIndex uint32
+			timeNanos   int64
+			partition   uint64
+		)
+		stacktraceIDs = stacktraceIDs[:0]
+		for _, value := range row {
+			switch value.Column() {
+			case columns["SeriesIndex"]:
+				seriesIndex = uint32(value.Int64())
+			case columns["TimeNanos"]:
+				timeNanos = value.Int64()
+			case columns["StacktraceID"]:
+				if value.DefinitionLevel() == stacktraceIDLevel {
+					stacktraceIDs = append(stacktraceIDs, uint32(value.Uint64()))
+				}
+			default:
+				if withPartitions && value.Column() == partitionColumn {
+					partition = value.Uint64()
+				}
+			}
+		}
+		if s.rows == 0 || timeNanos < s.minTime {
+			s.minTime = timeNanos
+		}
+		if s.rows == 0 || timeNanos > s.maxTime {
+			s.maxTime = timeNanos
+		}
+		s.rows++
+		s.samples += uint64(len(stacktraceIDs))
+		// If the index could not be read, the problem is already reported.
+		if _, ok := v.seriesIndexes[seriesIndex]; !ok && v.indexRead {
+			s.unknownSeries++
+		}
+		for _, id := range stacktraceIDs {
+			if !v.hasStacktrace(partition, id) {
+				s.unknownStacktraces++
+				break
+			}
+		}
+	})
+	return s, err
+}
+
+func (v *blockVerifier) hasStacktrace(partition uint64, id uint32) bool {
+	if v.symbols != nil {
+		return v.symbols.HasStacktrace(partition, id)
+	}
+	if v.stacktraces > 0 {
+		return uint64(id) < v.stacktraces
+	}
+	// The stack traces could not be read: the problem is already reported.
+	return true
+}
+
+// verifyFiles compares the files of the block with the meta.
+func (v *blockVerifier) verifyFiles() {
+	if v.meta == nil {
+		return
+	}
+	recorded := make(map[string]struct{}, len(v.meta.Files))
+	for _, f := range v.meta.Files {
+		name := filepath.FromSlash(f.RelPath)
+		recorded[name] = struct{}{}
+		actual, ok := v.files[name]
+		if !ok {
+			v.problem(name, "missing file")
+			continue
+		}
+		if f.SizeBytes != actual.SizeBytes {
+			v.problem(name, "the size is %d bytes, the meta records %d bytes", actual.SizeBytes, f.SizeBytes)
+		}
+		if f.Parquet != nil && actual.Parquet != nil && *f.Parquet != *actual.Parquet {
+			v.problem(name, "the file has %d rows in %d row groups, the meta records %d rows in %d row groups",
+				actual.Parquet.NumRows, actual.Parquet.NumRowGroups, f.Parquet.NumRows, f.Parquet.NumRowGroups)
+		}
+		if f.TSDB != nil && actual.TSDB != nil && *f.TSDB != *actual.TSDB {
+			v.problem(name, "the index has %d series, the meta records %d series", actual.TSDB.NumSeries, f.TSDB.NumSeries)
+		}
+	}
+	for name := range v.files {
+		if _, ok := recorded[name]; !ok {
+			v.problem(name, "the file is not recorded in the meta")
+		}
+	}
+}
+
+func (v *blockVerifier) verifyStats() {
+	profilesFile := (&schemav1.ProfilePersister{}).Name() + block.ParquetSuffix
+	if n := v.profiles.unknownSeries; n > 0 {
+		v.problem(profilesFile, "%d profiles reference series missing from the index", n)
+	}
+	if n := v.profiles.unknownStacktraces; n > 0 {
+		v.problem(profilesFile, "%d profiles reference missing stack traces", n)
+	}
+	if v.meta == nil {
+		return
+	}
+	if v.profiles.rows > 0 {
+		minTime := model.TimeFromUnixNano(v.profiles.minTime)
+		maxTime := model.TimeFromUnixNano(v.profiles.maxTime)
+		if minTime < v.meta.MinTime || maxTime > v.meta.MaxTime {
+			v.profilesOutOfRange = true
+			v.problem(block.MetaFilename, "the profiles time range [%d, %d] is out of the block time range [%d, %d]",
+				minTime, maxTime, v.meta.MinTime, v.meta.MaxTime)
+		}
+	}
+	stats := v.meta.Stats
+	if stats.NumProfiles != v.profiles.rows {
+		v.problem(block.MetaFilename, "the block has %d profiles, the meta records %d", v.profiles.rows, stats.NumProfiles)
+	}
+	// The number of samples is not checked: some versions counted the
+	// samples ingested, rather than the samples written.
+	if n := uint64(len(v.seriesIndexes)); stats.NumSeries != n {
+		v.problem(block.MetaFilename, "the block has %d series, the meta records %d", n, stats.NumSeries)
+	}
+}
+
+// sortedFiles returns the files which belong to a block, the others are left
+// out of the meta.
+func (v *blockVerifier) sortedFiles() []block.File {
+	files := make([]block.File, 0, len(v.files))
+	for name, f := range v.files {
+		if name == block.IndexFilename ||
+			filepath.Ext(name) == block.ParquetSuffix ||
+			filepath.Dir(name) == symdb.DefaultDirName {
+			files = append(files, *f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].RelPath < files[j].RelPath
+	})
+	return files
+}
@@ -0,0 +1,165 @@
+package phlaredb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/segmentio/parquet-go"
+	"github.com/stretchr/testify/require"
+
+	phlareparquet "github.com/grafana/phlare/pkg/parquet"
+	"github.com/grafana/phlare/pkg/phlaredb/block"
+)
+
+func copyTestBlock(t *testing.T, id string) string {
+	dir := filepath.Join(t.TempDir(), id)
+	require.NoError(t, copyBlockFiles(filepath.Join("./block/testdata", id), dir))
+	return dir
+}
+
+func requireNoBlockProblems(t *testing.T, dir string) {
+	problems, err := VerifyBlock(context.Background(), dir)
+	require.NoError(t, err)
+	require.Empty(t, problems)
+}
+
+func requireBlockProblem(t *testing.T, dir string, expected BlockProblem) {
+	problems, err := VerifyBlock(context.Background(), dir)
+	require.NoError(t, err)
+	require.Contains(t, problems, expected)
+}
+
+func TestVerifyBlock_Compatibility(t *testing.T) {
+	for _, id := range []string{"01GR3QABQB6J30Q04K4E6MAKRE", "01H3YE0W63FNXM69N2WVTRBYYK"} {
+		t.Run(id, func(t *testing.T) {
+			requireNoBlockProblems(t, copyTestBlock(t, id))
+		})
+	}
+}
+
+// splitRowGroups rewrites the parquet file with row groups of n rows.
+func splitRowGroups(t *testing.T, path string, n int) {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	file, err := parquet.OpenFile(bytesReaderAt(data), int64(len(data)))
+	require.NoError(t, err)
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	w := parquet.NewWriter(f, file.Schema())
+	rows := parquet.MultiRowGroup(file.RowGroups()...).Rows()
+	_, _, err = phlareparquet.CopyAsRowGroups(w, rows, n)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	require.NoError(t, w.Close())
+}
+
+type bytesReaderAt []byte
+
+func (b bytesReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return copy(p, b[off:]), nil
+}
+
+func TestRepairBlock(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	dir := copyTestBlock(t, "01H3YE0W63FNXM69N2WVTRBYYK")
+	dstDir := filepath.Dir(dir)
+
+	// repair writes the repaired block, and checks that the block repaired
+	// is left as is and is the parent of the new one.
+	repair := func(dir string) (string, *block.Meta) {
+		srcMeta, _, _ := block.MetaFromDir(dir)
+		problems, err := VerifyBlock(ctx, dir)
+		require.NoError(t, err)
+		meta, left, err := RepairBlock(ctx, logger, dir, dstDir)
+		require.NoError(t, err)
+		require.Empty(t, left)
+		after, err := VerifyBlock(ctx, dir)
+		require.NoError(t, err)
+		require.Equal(t, problems, after)
+
+		require.NotEqual(t, filepath.Base(dir), meta.ULID.String())
+		require.Len(t, meta.Compaction.Parents, 1)
+		require.Equal(t, filepath.Base(dir), meta.Compaction.Parents[0].ULID.String())
+		if srcMeta != nil && len(srcMeta.Compaction.Sources) > 0 {
+			require.Equal(t, srcMeta.Compaction.Sources, meta.Compaction.Sources)
+		}
+		repaired := filepath.Join(dstDir, meta.ULID.String())
+		requireNoBlockProblems(t, repaired)
+		return repaired, meta
+	}
+
+	// The meta is outdated.
+	splitRowGroups(t, filepath.Join(dir, "profiles.parquet"), 4)
+	requireBlockProblem(t, dir, BlockProblem{
+		File:    "profiles.parquet",
+		Message: "the file has 12 rows in 3 row groups, the meta records 12 rows in 1 row groups",
+	})
+	dir, meta := repair(dir)
+	require.Equal(t, uint64(3), meta.FileByRelPath("profiles.parquet").Parquet.NumRowGroups)
+
+	// A row group is broken.
+	profilesPath := filepath.Join(dir, "profiles.parquet")
+	data, err := os.ReadFile(profilesPath)
+	require.NoError(t, err)
+	file, err := parquet.OpenFile(bytesReaderAt(data), int64(len(data)))
+	require.NoError(t, err)
+	offset := file.Metadata().RowGroups[1].Columns[0].MetaData.DataPageOffset
+	for i := offset; i < offset+16; i++ {
+		data[i] = 0xff
+	}
+	require.NoError(t, os.WriteFile(profilesPath, data, 0o644))
+	problems, err := VerifyBlock(ctx, dir)
+	require.NoError(t, err)
+	require.NotEmpty(t, problems)
+	require.Equal(t, "profiles.parquet", problems[0].File)
+	require.Contains(t, problems[0].Message, "failed to read the row group 1")
+
+	dir, meta = repair(dir)
+	require.Equal(t, uint64(8), meta.Stats.NumProfiles)
+	require.Equal(t, uint64(2), meta.FileByRelPath("profiles.parquet").Parquet.NumRowGroups)
+
+	// The meta is missing, the files which are not part of a block are
+	// left out.
+	expected := *meta
+	require.NoError(t, os.Remove(filepath.Join(dir, block.MetaFilename)))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo"), []byte("bar"), 0o644))
+	requireBlockProblem(t, dir, BlockProblem{
+		File:    block.MetaFilename,
+		Message: "failed to read the meta: open " + filepath.Join(dir, block.MetaFilename) + ": no such file or directory",
+	})
+	dir, meta = repair(dir)
+	require.Equal(t, expected.Version, meta.Version)
+	require.Equal(t, expected.Stats, meta.Stats)
+	require.Equal(t, expected.Files, meta.Files)
+	require.Equal(t, []ulid.ULID{expected.ULID}, meta.Compaction.Sources)
+
+	// No block is written for the blocks without problems.
+	meta, problems, err = RepairBlock(ctx, logger, dir, dstDir)
+	require.NoError(t, err)
+	require.Nil(t, meta)
+	require.Empty(t, problems)
+	entries, err := os.ReadDir(dstDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+}
+
+func TestVerifyBlock_Problems(t *testing.T) {
+	dir := copyTestBlock(t, "01H3YE0W63FNXM69N2WVTRBYYK")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo"), []byte("bar"), 0o644))
+	requireBlockProblem(t, dir, BlockProblem{File: "foo", Message: "the file is not recorded in the meta"})
+
+	require.NoError(t, os.Remove(filepath.Join(dir, block.IndexFilename)))
+	requireBlockProblem(t, dir, BlockProblem{File: block.IndexFilename, Message: "missing file"})
+
+	data, err := os.ReadFile(filepath.Join(dir, "symbols", "index.symdb"))
+	require.NoError(t, err)
+	data[len(data)-1]++
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "symbols", "index.symdb"), data, 0o644))
+	requireBlockProblem(t, dir, BlockProblem{File: "symbols/index.symdb", Message: "failed to open the symbols: invalid CRC"})
+}
@@ -0,0 +1,145 @@
+package bucket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
+	"github.com/grafana/phlare/pkg/phlaredb/block"
+)
+
+// TenantDeletionMarksPrefix is the bucket prefix under which the deletion
+// marks of the tenants are stored. The marks are kept outside the tenant
+// prefixes, so that they outlive the data they refer to.
+const TenantDeletionMarksPrefix = PyroscopeInternalsPrefix + "/tenant-deletion-marks"
+
+// TenantDeletionMark marks a tenant for deletion: the tenant is not allowed
+// to ingest data, and all its data is eventually removed.
+type TenantDeletionMark struct {
+	// DeletionTime is the unix timestamp (seconds) of when the tenant
+	// was marked for deletion.
+	DeletionTime int64 `json:"deletion_time"`
+	// FinishedTime is the unix timestamp (seconds) of when all the tenant
+	// blocks were removed from the bucket, if they were.
+	FinishedTime int64 `json:"finished_time,omitempty"`
+}
+
+func NewTenantDeletionMark(deletionTime time.Time) *TenantDeletionMark {
+	return &TenantDeletionMark{DeletionTime: deletionTime.Unix()}
+}
+
+// TenantDeletionMarkPath returns the path of the deletion mark of the tenant.
+func TenantDeletionMarkPath(tenantID string) string {
+	return path.Join(TenantDeletionMarksPrefix, tenantID+".json")
+}
+
+// WriteTenantDeletionMark uploads the deletion mark of the tenant,
+// overwriting the existing one, if any.
+func WriteTenantDeletionMark(ctx context.Context, bkt phlareobj.Bucket, tenantID string, mark *TenantDeletionMark) error {
+	data, err := json.Marshal(mark)
+	if err != nil {
+		return errors.Wrap(err, "serialize tenant deletion mark")
+	}
+	return errors.Wrap(bkt.Upload(ctx, TenantDeletionMarkPath(tenantID), bytes.NewReader(data)), "upload tenant deletion mark")
+}
+
+// ReadTenantDeletionMark returns the deletion mark of the tenant, or nil
+// if the tenant is not marked for deletion.
+func ReadTenantDeletionMark(ctx context.Context, bkt phlareobj.BucketReader, tenantID string) (*TenantDeletionMark, error) {
+	r, err := bkt.Get(ctx, TenantDeletionMarkPath(tenantID))
+	if err != nil {
+		if bkt.IsObjNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "read tenant deletion mark %s", tenantID)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read tenant deletion mark %s", tenantID)
+	}
+	var mark TenantDeletionMark
+	if err = json.Unmarshal(data, &mark); err != nil {
+		return nil, errors.Wrapf(err, "parse tenant deletion mark %s", tenantID)
+	}
+	return &mark, nil
+}
+
+// ListTenantDeletionMarks returns the deletion marks found in the bucket,
+// by tenant ID.
+func ListTenantDeletionMarks(ctx context.Context, bkt phlareobj.Bucket) (map[string]*TenantDeletionMark, error) {
+	var tenants []string
+	err := bkt.Iter(ctx, TenantDeletionMarksPrefix, func(name string) error {
+		tenantID := strings.TrimSuffix(path.Base(name), ".json")
+		if tenantID != path.Base(name) && validTenantID(tenantID) == nil {
+			tenants = append(tenants, tenantID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "list tenant deletion marks")
+	}
+	marks := make(map[string]*TenantDeletionMark, len(tenants))
+	for _, tenantID := range tenants {
+		mark, err := ReadTenantDeletionMark(ctx, bkt, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		// The mark may have been removed in the meantime.
+		if mark != nil {
+			marks[tenantID] = mark
+		}
+	}
+	return marks, nil
+}
+
+// DeleteTenant removes all the blocks of the tenant from the bucket, and then
+// any other object left under the tenant prefix. The tenant deletion mark is
+// kept. It returns the number of blocks deleted.
+func DeleteTenant(ctx context.Context, bkt phlareobj.Bucket, tenantID string, logger log.Logger) (int, error) {
+	if err := validTenantID(tenantID); err != nil {
+		return 0, err
+	}
+	blocksBucket := phlareobj.NewPrefixedBucket(bkt, tenantID+"/phlaredb")
+	var blocks []ulid.ULID
+	err := blocksBucket.Iter(ctx, "", func(name string) error {
+		if id, err := ulid.Parse(strings.TrimSuffix(name, "/")); err == nil {
+			blocks = append(blocks, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "list blocks")
+	}
+	for i, id := range blocks {
+		if err = block.Delete(ctx, logger, blocksBucket, id); err != nil {
+			return i, errors.Wrapf(err, "delete block %s", id)
+		}
+		level.Debug(logger).Log("msg", "deleted block", "tenant", tenantID, "block", id)
+	}
+	if _, err = phlareobj.DeletePrefix(ctx, bkt, tenantID+"/", logger); err != nil {
+		return len(blocks), errors.Wrap(err, "delete tenant objects")
+	}
+	return len(blocks), nil
+}
+
+// validTenantID checks that the tenant ID can be safely used as a path
+// element, both in the bucket and on the local disk.
+func validTenantID(tenantID string) error {
+	switch tenantID {
+	case "", ".", "..", PyroscopeInternalsPrefix:
+		return errors.Errorf("invalid tenant ID %q", tenantID)
+	}
+	return tenant.ValidTenantID(tenantID)
+}
@@ -0,0 +1,141 @@
+package phlaredb
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"golang.org/x/sync/errgroup"
+
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/phlaredb/tsdb/index"
+	"github.com/grafana/phlare/pkg/util"
+)
+
+// SeriesTimeRanges returns the series of the queriers matching the request
+// along with the time range of their profiles, for the analysis of their
+// cardinality.
+func SeriesTimeRanges(ctx context.Context, req *typesv1.CardinalityRequest, blockGetter BlockGetter) ([]*phlaremodel.SeriesTimeRange, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "SeriesTimeRanges")
+	defer sp.Finish()
+
+	matchers, err := phlaremodel.CardinalityMatchers(req)
+	if err != nil {
+		return nil, err
+	}
+	queriers, err := blockGetter(ctx, model.Time(req.Start), model.Time(req.End))
+	if err != nil {
+		return nil, err
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	results := make([][]*phlaremodel.SeriesTimeRange, len(queriers))
+	for i, querier := range queriers {
+		i := i
+		querier := querier
+		g.Go(util.RecoverPanic(func() error {
+			series, err := querier.SeriesTimeRanges(ctx, matchers)
+			if err != nil {
+				return err
+			}
+			results[i] = series
+			return nil
+		}))
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	ts := tombstonesFromContext(ctx)
+	ranges := phlaremodel.NewSeriesTimeRanges()
+	for _, series := range results {
+		for _, s := range series {
+			if s.MaxTime < req.Start || s.MinTime > req.End {
+				continue
+			}
+			// Skip the series whose profiles are all deleted.
+			if ts.DeletesSeries(s.Labels, model.Time(s.MinTime), model.Time(s.MaxTime)) {
+				continue
+			}
+			ranges.Add(s)
+		}
+	}
+	return ranges.Series(), nil
+}
+
+// SeriesTimeRanges returns the series of the head and of the blocks matching
+// the request.
+func (f *PhlareDB) SeriesTimeRanges(ctx context.Context, req *typesv1.CardinalityRequest) ([]*phlaremodel.SeriesTimeRange, error) {
+	f.headLock.RLock()
+	defer f.headLock.RUnlock()
+	return SeriesTimeRanges(ctx, req, f.queriers().ForTimeRange)
+}
+
+func (b *singleBlockQuerier) SeriesTimeRanges(ctx context.Context, matchers []*labels.Matcher) ([]*phlaremodel.SeriesTimeRange, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "SeriesTimeRanges - Block")
+	defer sp.Finish()
+	if err := b.Open(ctx); err != nil {
+		return nil, err
+	}
+	postings, err := PostingsForMatchers(b.index, nil, matchers...)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		result []*phlaremodel.SeriesTimeRange
+		lbls   = make(phlaremodel.Labels, 0, 6)
+		chks   = make([]index.ChunkMeta, 1)
+	)
+	for postings.Next() {
+		if _, err := b.index.Series(postings.At(), &lbls, &chks); err != nil {
+			return nil, err
+		}
+		if len(chks) == 0 {
+			continue
+		}
+		series := &phlaremodel.SeriesTimeRange{
+			Labels:  lbls,
+			MinTime: int64(model.TimeFromUnixNano(chks[0].MinTime)),
+			MaxTime: int64(model.TimeFromUnixNano(chks[0].MaxTime)),
+		}
+		for _, chk := range chks[1:] {
+			if t := int64(model.TimeFromUnixNano(chk.MinTime)); t < series.MinTime {
+				series.MinTime = t
+			}
+			if t := int64(model.TimeFromUnixNano(chk.MaxTime)); t > series.MaxTime {
+				series.MaxTime = t
+			}
+		}
+		result = append(result, series)
+		lbls = make(phlaremodel.Labels, 0, 6)
+	}
+	return result, postings.Err()
+}
+
+func (q *headInMemoryQuerier) SeriesTimeRanges(ctx context.Context, matchers []*labels.Matcher) ([]*phlaremodel.SeriesTimeRange, error) {
+	sp, _ := opentracing.StartSpanFromContext(ctx, "SeriesTimeRanges - HeadInMemory")
+	defer sp.Finish()
+
+	index := q.head.profiles.index
+	var result []*phlaremodel.SeriesTimeRange
+	err := index.forMatchingLabels(matchers, func(lbs phlaremodel.Labels, fp model.Fingerprint) error {
+		// forMatchingLabels holds the read lock of the index.
+		profiles := index.profilesPerFP[fp]
+		result = append(result, &phlaremodel.SeriesTimeRange{
+			Labels:  lbs,
+			MinTime: int64(model.TimeFromUnixNano(profiles.minTime)),
+			MaxTime: int64(model.TimeFromUnixNano(profiles.maxTime)),
+		})
+		return nil
+	})
+	return result, err
+}
+
+// SeriesTimeRanges returns no series: the index of the head, including the
+// series of the row groups on disk, is covered by the in-memory querier.
+func (q *headOnDiskQuerier) SeriesTimeRanges(context.Context, []*labels.Matcher) ([]*phlaremodel.SeriesTimeRange, error) {
+	return nil, nil
+}
@@ -0,0 +1,95 @@
+package phlaredb
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/objstore/providers/filesystem"
+	"github.com/grafana/phlare/pkg/phlaredb/block"
+	"github.com/grafana/phlare/pkg/pprof/testhelper"
+)
+
+func TestSeriesTimeRanges(t *testing.T) {
+	ctx := testContext(t)
+	head := newTestHead(t)
+	for i := 0; i < 30; i++ {
+		p := testhelper.NewProfileBuilder(time.Second.Nanoseconds()*int64(i)).
+			CPUProfile().
+			WithLabels("stream", streams[i%3])
+		p.ForStacktraceString("func1").AddSamples(10)
+		require.NoError(t, head.Ingest(ctx, p.Profile, p.UUID, p.Labels...))
+	}
+	const profileType = "process_cpu:cpu:nanoseconds:cpu:nanoseconds"
+	expected := func(stream string, minTime, maxTime int64) *phlaremodel.SeriesTimeRange {
+		return &phlaremodel.SeriesTimeRange{
+			Labels: phlaremodel.LabelsFromStrings(
+				phlaremodel.LabelNameProfileType, profileType,
+				"job", "foo",
+				"stream", stream,
+				phlaremodel.LabelNameProfileName, "process_cpu",
+				phlaremodel.LabelNameUnit, "nanoseconds",
+				phlaremodel.LabelNameType, "cpu",
+				phlaremodel.LabelNamePeriodType, "cpu",
+				phlaremodel.LabelNamePeriodUnit, "nanoseconds",
+			),
+			MinTime: minTime,
+			MaxTime: maxTime,
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		req      *typesv1.CardinalityRequest
+		expected []*phlaremodel.SeriesTimeRange
+	}{
+		{
+			name: "all the profile types",
+			req:  &typesv1.CardinalityRequest{Start: 0, End: 60000},
+			expected: []*phlaremodel.SeriesTimeRange{
+				expected("stream-a", 0, 27000),
+				expected("stream-b", 1000, 28000),
+				expected("stream-c", 2000, 29000),
+			},
+		},
+		{
+			name: "time range",
+			req:  &typesv1.CardinalityRequest{Start: 28500, End: 60000},
+			expected: []*phlaremodel.SeriesTimeRange{
+				expected("stream-c", 2000, 29000),
+			},
+		},
+		{
+			name:     "other profile type",
+			req:      &typesv1.CardinalityRequest{ProfileTypeID: "memory:alloc_space:bytes:space:bytes", Start: 0, End: 60000},
+			expected: []*phlaremodel.SeriesTimeRange{},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			series, err := SeriesTimeRanges(ctx, tc.req, head.Queriers().ForTimeRange)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, series)
+		})
+	}
+
+	require.NoError(t, head.Flush(ctx))
+	require.NoError(t, head.Move())
+	meta, err := block.ReadFromDir(head.localPath)
+	require.NoError(t, err)
+	bkt, err := filesystem.NewBucket(filepath.Dir(head.localPath))
+	require.NoError(t, err)
+	q := NewSingleBlockQuerierFromMeta(ctx, bkt, meta)
+	defer q.Close()
+	series, err := SeriesTimeRanges(ctx, &typesv1.CardinalityRequest{Start: 0, End: 60000}, Queriers{q}.ForTimeRange)
+	require.NoError(t, err)
+	require.Equal(t, []*phlaremodel.SeriesTimeRange{
+		expected("stream-a", 0, 27000),
+		expected("stream-b", 1000, 28000),
+		expected("stream-c", 2000, 29000),
+	}, series)
+}
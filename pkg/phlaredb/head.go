@@ -36,6 +36,7 @@ import (
 	"github.com/grafana/phlare/pkg/phlaredb/block"
 	schemav1 "github.com/grafana/phlare/pkg/phlaredb/schemas/v1"
 	"github.com/grafana/phlare/pkg/phlaredb/symdb"
+	"github.com/grafana/phlare/pkg/querier/stats"
 )
 
 func copySlice[T any](in []T) []T {
@@ -482,8 +483,8 @@ func (h *Head) LabelValues(ctx context.Context, req *connect.Request[typesv1.Lab
 		return nil, err
 	}
 
-	// shortcut to index when matcher match all
-	if selectors.matchesAll() {
+	// shortcut to index when matcher match all, unless series are deleted
+	if selectors.matchesAll() && len(tombstonesFromContext(ctx)) == 0 {
 		values, err := h.profiles.index.ix.LabelValues(req.Msg.Name, nil)
 		if err != nil {
 			return nil, err
@@ -496,7 +497,7 @@ func (h *Head) LabelValues(ctx context.Context, req *connect.Request[typesv1.Lab
 	// aggregate all label values from series matching, when matchers are given.
 
 	values := make(map[string]struct{})
-	if err := h.forMatchingSelectors(selectors, func(lbs phlaremodel.Labels, fp model.Fingerprint) error {
+	if err := h.forMatchingSelectors(ctx, selectors, func(lbs phlaremodel.Labels, fp model.Fingerprint) error {
 		if v := lbs.Get(req.Msg.Name); v != "" {
 			values[v] = struct{}{}
 		}
@@ -517,8 +518,8 @@ func (h *Head) LabelNames(ctx context.Context, req *connect.Request[typesv1.Labe
 		return nil, err
 	}
 
-	// shortcut to index when matcher match all
-	if selectors.matchesAll() {
+	// shortcut to index when matcher match all, unless series are deleted
+	if selectors.matchesAll() && len(tombstonesFromContext(ctx)) == 0 {
 		values, err := h.profiles.index.ix.LabelNames(nil)
 		if err != nil {
 			return nil, err
@@ -531,7 +532,7 @@ func (h *Head) LabelNames(ctx context.Context, req *connect.Request[typesv1.Labe
 
 	// aggregate all label values from series matching, when matchers are given.
 	values := make(map[string]struct{})
-	if err := h.forMatchingSelectors(selectors, func(lbs phlaremodel.Labels, fp model.Fingerprint) error {
+	if err := h.forMatchingSelectors(ctx, selectors, func(lbs phlaremodel.Labels, fp model.Fingerprint) error {
 		for _, lbl := range lbs {
 			values[lbl.Name] = struct{}{}
 		}
@@ -590,9 +591,14 @@ func (h *Head) Queriers() Queriers {
 }
 
 // add the location IDs to the stacktraces
-func (h *Head) resolveStacktraces(ctx context.Context, stacktracesByMapping stacktracesByMapping) *ingestv1.MergeProfilesStacktracesResult {
+func (h *Head) resolveStacktraces(ctx context.Context, stacktracesByMapping stacktracesByMapping) (*ingestv1.MergeProfilesStacktracesResult, error) {
 	sp, _ := opentracing.StartSpanFromContext(ctx, "resolveStacktraces - Head")
 	defer sp.Finish()
+	qs := stats.FromContext(ctx)
+	defer func(start time.Time) { qs.AddResolveTime(time.Since(start)) }(time.Now())
+	if err := queryLimiterFromContext(ctx).AddStacktraces(stacktracesByMapping.size()); err != nil {
+		return nil, err
+	}
 
 	names := []string{}
 	functions := map[uint32]int{}
@@ -617,6 +623,7 @@ func (h *Head) resolveStacktraces(ctx context.Context, stacktracesByMapping stac
 			defer resolver.Release()
 			// sort the stacktrace IDs as expected by the resolver
 			stacktraceIDs := stacktraceSamples.Ids()
+			qs.AddStacktracesResolved(uint64(len(stacktraceIDs)))
 			sort.Slice(stacktraceIDs, func(i, j int) bool {
 				return stacktraceIDs[i] < stacktraceIDs[j]
 			})
@@ -649,12 +656,17 @@ func (h *Head) resolveStacktraces(ctx context.Context, stacktracesByMapping stac
 	return &ingestv1.MergeProfilesStacktracesResult{
 		Stacktraces:   stacktracesByMapping.StacktraceSamples(),
 		FunctionNames: names,
-	}
+	}, nil
 }
 
-func (h *Head) resolvePprof(ctx context.Context, stacktracesByMapping profileSampleByMapping) *profile.Profile {
+func (h *Head) resolvePprof(ctx context.Context, stacktracesByMapping profileSampleByMapping) (*profile.Profile, error) {
 	sp, _ := opentracing.StartSpanFromContext(ctx, "resolvePprof - Head")
 	defer sp.Finish()
+	qs := stats.FromContext(ctx)
+	defer func(start time.Time) { qs.AddResolveTime(time.Since(start)) }(time.Now())
+	if err := queryLimiterFromContext(ctx).AddStacktraces(stacktracesByMapping.size()); err != nil {
+		return nil, err
+	}
 
 	locations := map[int32]*profile.Location{}
 	functions := map[uint32]*profile.Function{}
@@ -681,6 +693,7 @@ func (h *Head) resolvePprof(ctx context.Context, stacktracesByMapping profileSam
 
 			// sort the stacktrace IDs as expected by the resolver
 			stacktraceIDs := stacktraceSamples.Ids()
+			qs.AddStacktracesResolved(uint64(len(stacktraceIDs)))
 			sort.Slice(stacktraceIDs, func(i, j int) bool {
 				return stacktraceIDs[i] < stacktraceIDs[j]
 			})
@@ -756,7 +769,7 @@ func (h *Head) resolvePprof(ctx context.Context, stacktracesByMapping profileSam
 		Mapping:  lo.Values(mappings),
 	}
 	normalizeProfileIds(result)
-	return result
+	return result, nil
 }
 
 func normalizeProfileIds(p *profile.Profile) {
@@ -858,7 +871,21 @@ func (sels selectors) matchesAll() bool {
 	return false
 }
 
-func (h *Head) forMatchingSelectors(sels selectors, fn func(lbs phlaremodel.Labels, fp model.Fingerprint) error) error {
+// forMatchingSelectors iterates through the series matching the selectors,
+// skipping the series whose profiles are all deleted by the tombstones of the
+// context.
+func (h *Head) forMatchingSelectors(ctx context.Context, sels selectors, fn func(lbs phlaremodel.Labels, fp model.Fingerprint) error) error {
+	if ts := tombstonesFromContext(ctx); len(ts) > 0 {
+		next := fn
+		// The callback is called with the read lock of the index held.
+		fn = func(lbs phlaremodel.Labels, fp model.Fingerprint) error {
+			series := h.profiles.index.profilesPerFP[fp]
+			if ts.DeletesSeries(lbs, model.TimeFromUnixNano(series.minTime), model.TimeFromUnixNano(series.maxTime)) {
+				return nil
+			}
+			return next(lbs, fp)
+		}
+	}
 	if sels.matchesAll() {
 		return h.profiles.index.forMatchingLabels(nil, fn)
 	}
@@ -879,7 +906,7 @@ func (h *Head) Series(ctx context.Context, req *connect.Request[ingestv1.SeriesR
 	}
 	response := &ingestv1.SeriesResponse{}
 	uniqu := map[model.Fingerprint]struct{}{}
-	if err := h.forMatchingSelectors(selectors, func(lbs phlaremodel.Labels, fp model.Fingerprint) error {
+	if err := h.forMatchingSelectors(ctx, selectors, func(lbs phlaremodel.Labels, fp model.Fingerprint) error {
 		if _, ok := uniqu[fp]; ok {
 			return nil
 		}
@@ -16,6 +16,7 @@ import (
 	"github.com/grafana/phlare/pkg/iter"
 	"github.com/grafana/phlare/pkg/phlaredb/query"
 	schemav1 "github.com/grafana/phlare/pkg/phlaredb/schemas/v1"
+	"github.com/grafana/phlare/pkg/querier/stats"
 )
 
 type headOnDiskQuerier struct {
@@ -116,7 +117,7 @@ func (q *headOnDiskQuerier) MergeByStacktraces(ctx context.Context, rows iter.It
 	}
 
 	// TODO: Truncate insignificant stacks.
-	return q.head.resolveStacktraces(ctx, stacktraceSamples), nil
+	return q.head.resolveStacktraces(ctx, stacktraceSamples)
 }
 
 func (q *headOnDiskQuerier) MergePprof(ctx context.Context, rows iter.Iterator[Profile]) (*profile.Profile, error) {
@@ -129,7 +130,7 @@ func (q *headOnDiskQuerier) MergePprof(ctx context.Context, rows iter.Iterator[P
 		return nil, err
 	}
 
-	return q.head.resolvePprof(ctx, stacktraceSamples), nil
+	return q.head.resolvePprof(ctx, stacktraceSamples)
 }
 
 func (q *headOnDiskQuerier) MergeByLabels(ctx context.Context, rows iter.Iterator[Profile], by ...string) ([]*typesv1.Series, error) {
@@ -177,6 +178,13 @@ func (q *headInMemoryQuerier) SelectMatchingProfiles(ctx context.Context, params
 	if err != nil {
 		return nil, err
 	}
+	stats.FromContext(ctx).AddFetchedSeries(uint64(len(ids)))
+	limiter := queryLimiterFromContext(ctx)
+	for _, fp := range ids {
+		if err := limiter.AddSeries(fp); err != nil {
+			return nil, err
+		}
+	}
 
 	// get time nano information for profiles
 	var (
@@ -239,7 +247,7 @@ func (q *headInMemoryQuerier) MergeByStacktraces(ctx context.Context, rows iter.
 	}
 
 	// TODO: Truncate insignificant stacks.
-	return q.head.resolveStacktraces(ctx, stacktraceSamples), nil
+	return q.head.resolveStacktraces(ctx, stacktraceSamples)
 }
 
 func (q *headInMemoryQuerier) MergePprof(ctx context.Context, rows iter.Iterator[Profile]) (*profile.Profile, error) {
@@ -263,7 +271,7 @@ func (q *headInMemoryQuerier) MergePprof(ctx context.Context, rows iter.Iterator
 		}
 	}
 
-	return q.head.resolvePprof(ctx, stacktraceSamples), nil
+	return q.head.resolvePprof(ctx, stacktraceSamples)
 }
 
 func (q *headInMemoryQuerier) MergeByLabels(ctx context.Context, rows iter.Iterator[Profile], by ...string) ([]*typesv1.Series, error) {
@@ -20,6 +20,7 @@ import (
 	profilev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
 	ingestv1 "github.com/grafana/phlare/api/gen/proto/go/ingester/v1"
 	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+	"github.com/grafana/phlare/pkg/iter"
 	phlaremodel "github.com/grafana/phlare/pkg/model"
 	phlarecontext "github.com/grafana/phlare/pkg/phlare/context"
 	"github.com/grafana/phlare/pkg/pprof"
@@ -340,6 +341,44 @@ func mustParseProfileSelector(t testing.TB, selector string) *typesv1.ProfileTyp
 	return ps
 }
 
+func TestHead_SelectMatchingProfiles_Shards(t *testing.T) {
+	head := newTestHead(t)
+	ctx := context.Background()
+	for i := 0; i < 30; i++ {
+		require.NoError(t, ingestThreeProfileStreams(ctx, i, head.Ingest))
+	}
+
+	selectProfiles := func(selector string) []Profile {
+		it, err := head.Queriers().SelectMatchingProfiles(ctx, &ingestv1.SelectProfilesRequest{
+			Start:         0,
+			End:           1000000000000,
+			LabelSelector: selector,
+			Type:          mustParseProfileSelector(t, "process_cpu:cpu:nanoseconds:cpu:nanoseconds"),
+		})
+		require.NoError(t, err)
+		profiles, err := iter.Slice(it)
+		require.NoError(t, err)
+		return profiles
+	}
+
+	const shards = 2
+	var total int
+	for i := 0; i < shards; i++ {
+		for _, p := range selectProfiles(fmt.Sprintf(`{job="foo",__cortex_shard__="%d_of_%d"}`, i, shards)) {
+			require.Equal(t, uint64(i), uint64(p.Fingerprint())%shards)
+			total++
+		}
+	}
+	require.Equal(t, len(selectProfiles(`{job="foo"}`)), total)
+	require.Equal(t, 30, total)
+
+	_, err := head.Queriers().SelectMatchingProfiles(ctx, &ingestv1.SelectProfilesRequest{
+		LabelSelector: `{__cortex_shard__="2_of_2"}`,
+		Type:          mustParseProfileSelector(t, "process_cpu:cpu:nanoseconds:cpu:nanoseconds"),
+	})
+	require.Error(t, err)
+}
+
 func TestHeadIngestRealProfiles(t *testing.T) {
 	profilePaths := []string{
 		"testdata/heap",
@@ -31,6 +31,7 @@ import (
 	"github.com/grafana/phlare/pkg/objstore/providers/filesystem"
 	phlarecontext "github.com/grafana/phlare/pkg/phlare/context"
 	"github.com/grafana/phlare/pkg/phlaredb/block"
+	"github.com/grafana/phlare/pkg/querier/stats"
 )
 
 type Config struct {
@@ -315,18 +316,24 @@ func (f *PhlareDB) Series(ctx context.Context, req *connect.Request[ingestv1.Ser
 func (f *PhlareDB) MergeProfilesStacktraces(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesStacktracesRequest, ingestv1.MergeProfilesStacktracesResponse]) error {
 	f.headLock.RLock()
 	defer f.headLock.RUnlock()
+	qs, ctx := stats.ContextWithEmptyStats(ctx)
+	defer qs.SetHeader(stream.ResponseTrailer())
 	return MergeProfilesStacktraces(ctx, stream, f.queriers().ForTimeRange)
 }
 
 func (f *PhlareDB) MergeProfilesLabels(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesLabelsRequest, ingestv1.MergeProfilesLabelsResponse]) error {
 	f.headLock.RLock()
 	defer f.headLock.RUnlock()
+	qs, ctx := stats.ContextWithEmptyStats(ctx)
+	defer qs.SetHeader(stream.ResponseTrailer())
 	return MergeProfilesLabels(ctx, stream, f.queriers().ForTimeRange)
 }
 
 func (f *PhlareDB) MergeProfilesPprof(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesPprofRequest, ingestv1.MergeProfilesPprofResponse]) error {
 	f.headLock.RLock()
 	defer f.headLock.RUnlock()
+	qs, ctx := stats.ContextWithEmptyStats(ctx)
+	defer qs.SetHeader(stream.ResponseTrailer())
 	return MergeProfilesPprof(ctx, stream, f.queriers().ForTimeRange)
 }
 
@@ -386,6 +393,10 @@ func filterProfiles[B BidiServerMerge[Res, Req],
 			otlog.Int("batch_requested_size", batchProfileSize),
 		)
 		defer sp.Finish()
+		stats.FromContext(ctx).AddProfilesScanned(uint64(len(batch)))
+		if err := queryLimiterFromContext(ctx).AddProfiles(len(batch)); err != nil {
+			return err
+		}
 
 		seriesByFP := map[model.Fingerprint]labelWithIndex{}
 		selectProfileResult.LabelsSets = selectProfileResult.LabelsSets[:0]
@@ -3,6 +3,7 @@ package phlaredb
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"testing"
@@ -26,6 +27,7 @@ import (
 	"github.com/grafana/phlare/pkg/iter"
 	phlaremodel "github.com/grafana/phlare/pkg/model"
 	schemav1 "github.com/grafana/phlare/pkg/phlaredb/schemas/v1"
+	"github.com/grafana/phlare/pkg/querier/stats"
 	"github.com/grafana/phlare/pkg/testhelper"
 )
 
@@ -100,14 +102,20 @@ type ingesterHandlerPhlareDB struct {
 }
 
 func (i *ingesterHandlerPhlareDB) MergeProfilesStacktraces(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesStacktracesRequest, ingestv1.MergeProfilesStacktracesResponse]) error {
+	qs, ctx := stats.ContextWithEmptyStats(ctx)
+	defer qs.SetHeader(stream.ResponseTrailer())
 	return MergeProfilesStacktraces(ctx, stream, i.ForTimeRange)
 }
 
 func (i *ingesterHandlerPhlareDB) MergeProfilesLabels(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesLabelsRequest, ingestv1.MergeProfilesLabelsResponse]) error {
+	qs, ctx := stats.ContextWithEmptyStats(ctx)
+	defer qs.SetHeader(stream.ResponseTrailer())
 	return MergeProfilesLabels(ctx, stream, i.ForTimeRange)
 }
 
 func (i *ingesterHandlerPhlareDB) MergeProfilesPprof(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesPprofRequest, ingestv1.MergeProfilesPprofResponse]) error {
+	qs, ctx := stats.ContextWithEmptyStats(ctx)
+	defer qs.SetHeader(stream.ResponseTrailer())
 	return MergeProfilesPprof(ctx, stream, i.ForTimeRange)
 }
 
@@ -135,6 +143,10 @@ func (i *ingesterHandlerPhlareDB) Flush(context.Context, *connect.Request[ingest
 	return nil, errors.New("not implemented")
 }
 
+func (i *ingesterHandlerPhlareDB) Cardinality(context.Context, *connect.Request[typesv1.CardinalityRequest]) (*connect.Response[typesv1.CardinalityResponse], error) {
+	return nil, errors.New("not implemented")
+}
+
 func TestMergeProfilesStacktraces(t *testing.T) {
 	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
 
@@ -201,6 +213,59 @@ func TestMergeProfilesStacktraces(t *testing.T) {
 		at, err := phlaremodel.UnmarshalTree(resp.Result.TreeBytes)
 		require.NoError(t, err)
 		require.Equal(t, int64(500000000), at.Total())
+
+		// the query stats are returned in the trailers.
+		_, err = bidi.Receive()
+		require.ErrorIs(t, err, io.EOF)
+		qs := &stats.Stats{}
+		require.NoError(t, qs.MergeHeader(bidi.ResponseTrailer()))
+		require.NotZero(t, qs.LoadBlocksQueried())
+		require.Equal(t, uint64(1), qs.LoadFetchedSeries())
+		require.Equal(t, uint64(5), qs.LoadProfilesScanned())
+		require.NotZero(t, qs.LoadStacktracesResolved())
+	})
+
+	t.Run("request the tree in chunks", func(t *testing.T) {
+		bidi := client.MergeProfilesStacktraces(ctx)
+		bidi.RequestHeader().Set(phlaremodel.TreeChunkSizeHeader, "2")
+
+		require.NoError(t, bidi.Send(&ingestv1.MergeProfilesStacktracesRequest{
+			Request: &ingestv1.SelectProfilesRequest{
+				LabelSelector: `{pod="my-pod"}`,
+				Type:          mustParseProfileSelector(t, "process_cpu:cpu:nanoseconds:cpu:nanoseconds"),
+				Start:         start.UnixMilli(),
+				End:           end.UnixMilli(),
+			},
+		}))
+
+		resp, err := bidi.Receive()
+		require.NoError(t, err)
+		require.Len(t, resp.SelectedProfiles.Profiles, 5)
+		require.Equal(t, "2", bidi.ResponseHeader().Get(phlaremodel.TreeChunkSizeHeader))
+
+		require.NoError(t, bidi.Send(&ingestv1.MergeProfilesStacktracesRequest{
+			Profiles: []bool{true},
+		}))
+		resp, err = bidi.Receive()
+		require.NoError(t, err)
+		require.Nil(t, resp.Result)
+
+		// the chunks are sent until the end of the stream.
+		at := new(phlaremodel.Tree)
+		var chunks int
+		for {
+			resp, err = bidi.Receive()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			chunk, err := phlaremodel.UnmarshalTree(resp.Result.TreeBytes)
+			require.NoError(t, err)
+			at.Merge(chunk)
+			chunks++
+		}
+		require.Greater(t, chunks, 1)
+		require.Equal(t, int64(500000000), at.Total())
 	})
 
 	t.Run("request non existing series", func(t *testing.T) {
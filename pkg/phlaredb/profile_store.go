@@ -3,6 +3,7 @@ package phlaredb
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -201,6 +202,8 @@ func (s *profileStore) prepareFile(path string) (f *os.File, err error) {
 		return nil, err
 	}
 	s.writer.Reset(file)
+	// The writer retains the metadata across files.
+	s.writer.SetKeyValueMetadata(rowGroupBoundsKey, "")
 
 	return file, err
 }
@@ -368,7 +371,16 @@ func (s *profileStore) writeRowGroups(path string, rowGroups []parquet.RowGroup)
 	for i, rg := range rowGroups {
 		readers[i] = rg.Rows()
 	}
-	n, numRowGroups, err = phlareparquet.CopyAsRowGroups(s.writer, schemav1.NewMergeProfilesRowReader(readers), s.cfg.MaxBufferRowCount)
+	w := newRowGroupBoundsWriter(s.writer)
+	n, numRowGroups, err = phlareparquet.CopyAsRowGroups(w, schemav1.NewMergeProfilesRowReader(readers), s.cfg.MaxBufferRowCount)
+	if err != nil {
+		return 0, 0, err
+	}
+	bounds, err := w.metadata()
+	if err != nil {
+		return 0, 0, err
+	}
+	s.writer.SetKeyValueMetadata(rowGroupBoundsKey, bounds)
 
 	if err := s.writer.Close(); err != nil {
 		return 0, 0, err
@@ -524,6 +536,82 @@ var colIdxSeriesIndex = func() int {
 	return colIdx.ColumnIndex
 }()
 
+var colIdxTimeNanos = func() int {
+	p := &schemav1.ProfilePersister{}
+	colIdx, found := p.Schema().Lookup("TimeNanos")
+	if !found {
+		panic("column TimeNanos not found")
+	}
+	return colIdx.ColumnIndex
+}()
+
+// rowGroupBoundsKey is the key of the parquet file metadata holding the
+// bounds of the SeriesIndex and TimeNanos columns of each row group.
+const rowGroupBoundsKey = "phlare.row_group_bounds"
+
+// rowGroupBoundsWriter records the bounds of the SeriesIndex and TimeNanos
+// columns of the row groups written, letting the queries skip the row groups
+// which can't contain the selected series or time range.
+type rowGroupBoundsWriter struct {
+	phlareparquet.RowWriterFlusher
+
+	seriesIndex []query.Int64Bounds
+	timeNanos   []query.Int64Bounds
+	rows        int
+}
+
+func newRowGroupBoundsWriter(w phlareparquet.RowWriterFlusher) *rowGroupBoundsWriter {
+	return &rowGroupBoundsWriter{RowWriterFlusher: w}
+}
+
+func (w *rowGroupBoundsWriter) WriteRows(rows []parquet.Row) (int, error) {
+	n, err := w.RowWriterFlusher.WriteRows(rows)
+	for _, row := range rows[:n] {
+		seriesIndex := row[colIdxSeriesIndex].Int64()
+		var timeNanos int64
+		// TimeNanos follows the repeated samples: look it up from the end.
+		for i := len(row) - 1; i >= 0; i-- {
+			if row[i].Column() == colIdxTimeNanos {
+				timeNanos = row[i].Int64()
+				break
+			}
+		}
+		if w.rows == 0 {
+			w.seriesIndex = append(w.seriesIndex, query.Int64Bounds{Min: seriesIndex, Max: seriesIndex})
+			w.timeNanos = append(w.timeNanos, query.Int64Bounds{Min: timeNanos, Max: timeNanos})
+		} else {
+			expandBounds(&w.seriesIndex[len(w.seriesIndex)-1], seriesIndex)
+			expandBounds(&w.timeNanos[len(w.timeNanos)-1], timeNanos)
+		}
+		w.rows++
+	}
+	return n, err
+}
+
+func (w *rowGroupBoundsWriter) Flush() error {
+	w.rows = 0
+	return w.RowWriterFlusher.Flush()
+}
+
+// metadata returns the bounds of the row groups flushed, encoded for the
+// file metadata.
+func (w *rowGroupBoundsWriter) metadata() (string, error) {
+	b, err := json.Marshal(map[string][]query.Int64Bounds{
+		"SeriesIndex": w.seriesIndex,
+		"TimeNanos":   w.timeNanos,
+	})
+	return string(b), err
+}
+
+func expandBounds(b *query.Int64Bounds, v int64) {
+	if v < b.Min {
+		b.Min = v
+	}
+	if v > b.Max {
+		b.Max = v
+	}
+}
+
 func (r *seriesIDRowsRewriter) ReadRows(rows []parquet.Row) (int, error) {
 	n, err := r.Rows.ReadRows(rows)
 	if err != nil {
@@ -25,6 +25,7 @@ import (
 	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
 	phlaremodel "github.com/grafana/phlare/pkg/model"
 	phlarecontext "github.com/grafana/phlare/pkg/phlare/context"
+	"github.com/grafana/phlare/pkg/phlaredb/query"
 	schemav1 "github.com/grafana/phlare/pkg/phlaredb/schemas/v1"
 	"github.com/grafana/phlare/pkg/pprof/testhelper"
 )
@@ -228,6 +229,20 @@ func TestProfileStore_RowGroupSplitting(t *testing.T) {
 			require.Equal(t, int(tc.expectedNumRows), len(rows))
 			assert.Equal(t, tc.expectedNumRGs, numRGs)
 
+			// ensure the row group bounds are recorded
+			bounds := readParquetFileRowGroupBounds(t, path+"/profiles.parquet")
+			require.Len(t, bounds["SeriesIndex"], int(numRGs))
+			require.Len(t, bounds["TimeNanos"], int(numRGs))
+			for i, rg := 0, 0; i < len(rows); i++ {
+				if i > 0 && i%int(tc.expectedNumRows/numRGs) == 0 {
+					rg++
+				}
+				require.GreaterOrEqual(t, int64(rows[i].SeriesIndex), bounds["SeriesIndex"][rg].Min)
+				require.LessOrEqual(t, int64(rows[i].SeriesIndex), bounds["SeriesIndex"][rg].Max)
+				require.GreaterOrEqual(t, rows[i].TimeNanos, bounds["TimeNanos"][rg].Min)
+				require.LessOrEqual(t, rows[i].TimeNanos, bounds["TimeNanos"][rg].Max)
+			}
+
 			// ensure all profiles are there
 			idExisting := make(map[uuid.UUID]int, tc.expectedNumRows)
 			for i := range rows {
@@ -244,6 +259,22 @@ func TestProfileStore_RowGroupSplitting(t *testing.T) {
 	}
 }
 
+func readParquetFileRowGroupBounds(t *testing.T, path string) map[string][]query.Int64Bounds {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, f.Close())
+	}()
+	stat, err := f.Stat()
+	require.NoError(t, err)
+
+	pf, err := parquet.OpenFile(f, stat.Size())
+	require.NoError(t, err)
+	bounds, err := readRowGroupBounds(pf)
+	require.NoError(t, err)
+	return bounds
+}
+
 var streams = []string{"stream-a", "stream-b", "stream-c"}
 
 func threeProfileStreams(i int) *testProfile {
@@ -25,6 +25,7 @@ import (
 	schemav1 "github.com/grafana/phlare/pkg/phlaredb/schemas/v1"
 	"github.com/grafana/phlare/pkg/phlaredb/tsdb"
 	"github.com/grafana/phlare/pkg/phlaredb/tsdb/index"
+	"github.com/grafana/phlare/pkg/phlaredb/tsdb/shard"
 )
 
 // delta encoding for ranges
@@ -206,6 +207,11 @@ func (pi *profilesIndex) selectMatchingFPs(ctx context.Context, params *ingestv1
 	}
 	selectors = append(selectors, phlaremodel.SelectorFromProfileType(params.Type))
 
+	selectors, seriesShard, err := ExtractShard(selectors)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
 	filters, matchers := SplitFiltersAndMatchers(selectors)
 	ids, err := pi.ix.Lookup(matchers, nil)
 	if err != nil {
@@ -219,6 +225,9 @@ func (pi *profilesIndex) selectMatchingFPs(ctx context.Context, params *ingestv1
 	var idx int
 outer:
 	for _, fp := range ids {
+		if seriesShard != nil && !seriesShard.Match(fp) {
+			continue
+		}
 		profile, ok := pi.profilesPerFP[fp]
 		if !ok {
 			// If a profile labels is missing here, it has already been flushed
@@ -511,6 +520,17 @@ func SplitFiltersAndMatchers(allMatchers []*labels.Matcher) (filters, matchers [
 	return
 }
 
+// ExtractShard removes the shard matcher from the matchers, if any, and returns
+// the shard of the series selected: a series belongs to the shard if its
+// fingerprint modulo the number of shards equals the shard index.
+func ExtractShard(matchers []*labels.Matcher) ([]*labels.Matcher, *shard.Annotation, error) {
+	s, idx, err := shard.FromMatchers(matchers)
+	if err != nil || s == nil {
+		return matchers, nil, err
+	}
+	return append(matchers[:idx:idx], matchers[idx+1:]...), s, nil
+}
+
 // nolint unused
 const (
 	profileSize = uint64(unsafe.Sizeof(schemav1.InMemoryProfile{}))
@@ -824,6 +824,7 @@ type SyncIterator struct {
 	rgs        []parquet.RowGroup
 	rgsMin     []RowNumber
 	rgsMax     []RowNumber // Exclusive, row number of next one past the row group
+	rgsIndex   int         // Index of the next row group in the original list, for the row group predicates
 	readSize   int
 	selectAs   string
 	filter     *InstrumentedPredicate
@@ -990,10 +991,23 @@ func (c *SyncIterator) popRowGroup() (parquet.RowGroup, RowNumber, RowNumber) {
 	c.rgs = c.rgs[1:]
 	c.rgsMin = c.rgsMin[1:]
 	c.rgsMax = c.rgsMax[1:]
+	c.rgsIndex++
 
 	return rg, min, max
 }
 
+// keepRowGroup applies the predicate to the row group popped last, first
+// using the row group bounds if known, then using its column chunk.
+func (c *SyncIterator) keepRowGroup(rg parquet.RowGroup) bool {
+	if c.filter == nil {
+		return true
+	}
+	if !c.filter.KeepRowGroup(c.rgsIndex - 1) {
+		return false
+	}
+	return c.filter.KeepColumnChunk(rg.ColumnChunks()[c.column])
+}
+
 // seekRowGroup skips ahead to the row group that could contain the value at the
 // desired row number. Does nothing if the current row group is already the correct one.
 func (c *SyncIterator) seekRowGroup(seekTo RowNumber, definitionLevel int) (done bool) {
@@ -1013,8 +1027,7 @@ func (c *SyncIterator) seekRowGroup(seekTo RowNumber, definitionLevel int) (done
 			continue
 		}
 
-		cc := rg.ColumnChunks()[c.column]
-		if c.filter != nil && !c.filter.KeepColumnChunk(cc) {
+		if !c.keepRowGroup(rg) {
 			continue
 		}
 
@@ -1115,8 +1128,7 @@ func (c *SyncIterator) next() (RowNumber, *parquet.Value, error) {
 				return EmptyRowNumber(), nil, nil
 			}
 
-			cc := rg.ColumnChunks()[c.column]
-			if c.filter != nil && !c.filter.KeepColumnChunk(cc) {
+			if !c.keepRowGroup(rg) {
 				continue
 			}
 
@@ -52,6 +52,75 @@ func TestSubstringPredicate(t *testing.T) {
 	})
 }
 
+func TestMapPredicate_KeepBounds(t *testing.T) {
+	p := NewMapPredicate(map[uint32]struct{}{1: {}, 9: {}, 4: {}}).(BoundsPredicate)
+	for _, tc := range []struct {
+		min, max int64
+		keep     bool
+	}{
+		{min: 0, max: 0, keep: false},
+		{min: 0, max: 1, keep: true},
+		{min: 2, max: 3, keep: false},
+		{min: 2, max: 8, keep: true},
+		{min: 5, max: 8, keep: false},
+		{min: 9, max: 12, keep: true},
+		{min: 10, max: 20, keep: false},
+	} {
+		require.Equal(t, tc.keep, p.KeepBounds(tc.min, tc.max), "[%d, %d]", tc.min, tc.max)
+	}
+
+	require.False(t, NewMapPredicate(map[uint32]struct{}{}).(BoundsPredicate).KeepBounds(0, 10))
+}
+
+func TestRowGroupBoundsPredicate(t *testing.T) {
+	pf := createTestFile(t, 10_000)
+	idx, _ := GetColumnIndexByPath(pf, "A")
+
+	for _, tc := range []struct {
+		name          string
+		bounds        []Int64Bounds
+		keptChunks    int
+		expectedCount int
+	}{
+		{
+			name:          "no bounds",
+			keptChunks:    1,
+			expectedCount: 3,
+		},
+		{
+			name:          "bounds matching the row groups",
+			bounds:        []Int64Bounds{{Min: 0, Max: 4999}, {Min: 5000, Max: 9999}},
+			keptChunks:    1,
+			expectedCount: 3,
+		},
+		{
+			name:       "bounds excluding the row groups",
+			bounds:     []Int64Bounds{{Min: 0, Max: 4999}, {Min: 8000, Max: 9999}},
+			keptChunks: 0,
+		},
+		{
+			name:          "bounds not matching the number of row groups",
+			bounds:        []Int64Bounds{{Min: 8000, Max: 9999}},
+			keptChunks:    1,
+			expectedCount: 3,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := InstrumentedPredicate{pred: NewRowGroupBoundsPredicate(NewIntBetweenPredicate(7001, 7003), tc.bounds)}
+			it := NewSyncIterator(context.TODO(), pf.RowGroups(), idx, "A", 1000, &p, "A")
+			defer it.Close()
+			var count int
+			for it.Next() {
+				count++
+			}
+			require.NoError(t, it.Err())
+			require.Equal(t, tc.expectedCount, count)
+			require.Equal(t, 2, int(p.InspectedColumnChunks.Load()))
+			require.Equal(t, tc.keptChunks, int(p.KeptColumnChunks.Load()))
+		})
+	}
+}
+
 type predicateTestCase[P any] struct {
 	writeData  func(w *parquet.GenericWriter[P])
 	keptChunks int
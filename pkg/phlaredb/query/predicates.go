@@ -2,6 +2,7 @@ package query
 
 import (
 	"bytes"
+	"sort"
 	"strings"
 
 	pq "github.com/segmentio/parquet-go"
@@ -17,6 +18,52 @@ type Predicate interface {
 	KeepValue(pq.Value) bool
 }
 
+// RowGroupPredicate is implemented by the predicates able to skip a row
+// group, given its index in the file, before its column chunk is read.
+type RowGroupPredicate interface {
+	KeepRowGroup(rowGroup int) bool
+}
+
+// BoundsPredicate is implemented by the predicates on integer columns able
+// to tell whether values within the bounds [min,max] inclusive may match.
+type BoundsPredicate interface {
+	KeepBounds(min, max int64) bool
+}
+
+// Int64Bounds are the bounds [Min,Max] inclusive of the values of an
+// integer column in a row group.
+type Int64Bounds struct {
+	Min int64 `json:"min"`
+	Max int64 `json:"max"`
+}
+
+// rowGroupBoundsPredicate skips the row groups which bounds don't match the
+// predicate.
+type rowGroupBoundsPredicate struct {
+	Predicate
+	bounds []Int64Bounds
+	pred   BoundsPredicate
+}
+
+// NewRowGroupBoundsPredicate returns a predicate skipping the row groups
+// which column bounds, given per row group, can't match the predicate. The
+// predicate is returned as is if it doesn't implement BoundsPredicate or if
+// no bounds are given.
+func NewRowGroupBoundsPredicate(pred Predicate, bounds []Int64Bounds) Predicate {
+	bp, ok := pred.(BoundsPredicate)
+	if !ok || len(bounds) == 0 {
+		return pred
+	}
+	return &rowGroupBoundsPredicate{Predicate: pred, bounds: bounds, pred: bp}
+}
+
+func (p *rowGroupBoundsPredicate) KeepRowGroup(rowGroup int) bool {
+	if rowGroup < 0 || rowGroup >= len(p.bounds) {
+		return true
+	}
+	return p.pred.KeepBounds(p.bounds[rowGroup].Min, p.bounds[rowGroup].Max)
+}
+
 // StringInPredicate checks for any of the given strings.
 type StringInPredicate struct {
 	ss [][]byte
@@ -166,6 +213,10 @@ func (p *IntBetweenPredicate) KeepColumnChunk(c pq.ColumnChunk) bool {
 	return true
 }
 
+func (p *IntBetweenPredicate) KeepBounds(min, max int64) bool {
+	return p.max >= min && p.min <= max
+}
+
 func (p *IntBetweenPredicate) KeepValue(v pq.Value) bool {
 	vv := v.Int64()
 	return p.min <= vv && vv <= p.max
@@ -199,6 +250,10 @@ func (p EqualInt64Predicate) KeepColumnChunk(c pq.ColumnChunk) bool {
 	return true
 }
 
+func (p EqualInt64Predicate) KeepBounds(min, max int64) bool {
+	return int64(p) >= min && int64(p) <= max
+}
+
 func (p EqualInt64Predicate) KeepValue(v pq.Value) bool {
 	vv := v.Int64()
 	return int64(p) <= vv && vv <= int64(p)
@@ -234,6 +289,16 @@ func (p *InstrumentedPredicate) KeepColumnChunk(c pq.ColumnChunk) bool {
 	return false
 }
 
+// KeepRowGroup applies the predicate to the row group if supported. Skipped
+// row groups are accounted as inspected column chunks.
+func (p *InstrumentedPredicate) KeepRowGroup(rowGroup int) bool {
+	if rp, ok := p.pred.(RowGroupPredicate); ok && !rp.KeepRowGroup(rowGroup) {
+		p.InspectedColumnChunks.Inc()
+		return false
+	}
+	return true
+}
+
 func (p *InstrumentedPredicate) KeepPage(page pq.Page) bool {
 	p.InspectedPages.Inc()
 
@@ -257,37 +322,47 @@ func (p *InstrumentedPredicate) KeepValue(v pq.Value) bool {
 }
 
 type mapPredicate[K constraints.Integer, V any] struct {
-	inbetweenPred Predicate
-	m             map[K]V
+	keys []int64 // Sorted
+	m    map[K]V
 }
 
 func NewMapPredicate[K constraints.Integer, V any](m map[K]V) Predicate {
-
-	var min, max int64
-
-	first := true
+	keys := make([]int64, 0, len(m))
 	for k := range m {
-		if first || max < int64(k) {
-			max = int64(k)
-		}
-		if first || min > int64(k) {
-			min = int64(k)
-		}
-		first = false
+		keys = append(keys, int64(k))
 	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
 
 	return &mapPredicate[K, V]{
-		inbetweenPred: NewIntBetweenPredicate(min, max),
-		m:             m,
+		keys: keys,
+		m:    m,
 	}
 }
 
+// KeepBounds returns true if any of the keys is within the bounds.
+func (m *mapPredicate[K, V]) KeepBounds(min, max int64) bool {
+	i := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= min })
+	return i < len(m.keys) && m.keys[i] <= max
+}
+
 func (m *mapPredicate[K, V]) KeepColumnChunk(c pq.ColumnChunk) bool {
-	return m.inbetweenPred.KeepColumnChunk(c)
+	if ci := c.ColumnIndex(); ci != nil {
+		for i := 0; i < ci.NumPages(); i++ {
+			if m.KeepBounds(ci.MinValue(i).Int64(), ci.MaxValue(i).Int64()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
 }
 
 func (m *mapPredicate[K, V]) KeepPage(page pq.Page) bool {
-	return m.inbetweenPred.KeepPage(page)
+	if min, max, ok := page.Bounds(); ok {
+		return m.KeepBounds(min.Int64(), max.Int64())
+	}
+	return true
 }
 
 func (m *mapPredicate[K, V]) KeepValue(v pq.Value) bool {
@@ -0,0 +1,93 @@
+package phlaredb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/prometheus/common/model"
+	"go.uber.org/atomic"
+)
+
+// QueryLimits are the per-tenant limits of the resources a query may use in
+// an ingester or a store-gateway. A limit of 0 disables it.
+type QueryLimits interface {
+	MaxQuerySeries(tenantID string) int
+	MaxQueryProfiles(tenantID string) int
+	MaxQueryStacktracesPerBlock(tenantID string) int
+}
+
+type queryLimiterKey struct{}
+
+// ContextWithQueryLimiter returns a context enforcing the query limits of the
+// tenant on the queries executed with it.
+func ContextWithQueryLimiter(ctx context.Context, limits QueryLimits, tenantID string) context.Context {
+	return context.WithValue(ctx, queryLimiterKey{}, &queryLimiter{
+		maxSeries:      limits.MaxQuerySeries(tenantID),
+		maxProfiles:    limits.MaxQueryProfiles(tenantID),
+		maxStacktraces: limits.MaxQueryStacktracesPerBlock(tenantID),
+		series:         make(map[model.Fingerprint]struct{}),
+	})
+}
+
+func queryLimiterFromContext(ctx context.Context) *queryLimiter {
+	l, _ := ctx.Value(queryLimiterKey{}).(*queryLimiter)
+	return l
+}
+
+// queryLimiter tracks the resources used by a query, across all the blocks
+// it reads. A nil limiter does not enforce any limit.
+type queryLimiter struct {
+	maxSeries      int
+	maxProfiles    int
+	maxStacktraces int
+
+	seriesMtx sync.Mutex
+	series    map[model.Fingerprint]struct{}
+	profiles  atomic.Int64
+}
+
+// AddSeries records a series matched by the query. The same series matched
+// in several blocks is only counted once.
+func (l *queryLimiter) AddSeries(fp model.Fingerprint) error {
+	if l == nil || l.maxSeries <= 0 {
+		return nil
+	}
+	l.seriesMtx.Lock()
+	defer l.seriesMtx.Unlock()
+	l.series[fp] = struct{}{}
+	if len(l.series) > l.maxSeries {
+		return errQueryLimitExceeded("series", l.maxSeries)
+	}
+	return nil
+}
+
+// AddProfiles records profiles scanned by the query.
+func (l *queryLimiter) AddProfiles(n int) error {
+	if l == nil || l.maxProfiles <= 0 {
+		return nil
+	}
+	if l.profiles.Add(int64(n)) > int64(l.maxProfiles) {
+		return errQueryLimitExceeded("profiles", l.maxProfiles)
+	}
+	return nil
+}
+
+// AddStacktraces records the stacktraces resolved by the query in a block.
+// The stacktrace identifiers are local to each block, the limit therefore
+// applies to each block rather than to the whole query.
+func (l *queryLimiter) AddStacktraces(n int) error {
+	if l == nil || l.maxStacktraces <= 0 {
+		return nil
+	}
+	if n > l.maxStacktraces {
+		return errQueryLimitExceeded("stacktraces per block", l.maxStacktraces)
+	}
+	return nil
+}
+
+func errQueryLimitExceeded(what string, limit int) error {
+	return connect.NewError(connect.CodeResourceExhausted,
+		fmt.Errorf("the query exceeded the maximum number of %s (limit: %d), narrow down the label selector or the time range of the query", what, limit))
+}
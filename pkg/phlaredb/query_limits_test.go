@@ -0,0 +1,88 @@
+package phlaredb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/prometheus/common/model"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	ingestv1 "github.com/grafana/phlare/api/gen/proto/go/ingester/v1"
+	"github.com/grafana/phlare/pkg/iter"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	schemav1 "github.com/grafana/phlare/pkg/phlaredb/schemas/v1"
+)
+
+type fakeQueryLimits struct {
+	maxSeries, maxProfiles, maxStacktraces int
+}
+
+func (f fakeQueryLimits) MaxQuerySeries(string) int              { return f.maxSeries }
+func (f fakeQueryLimits) MaxQueryProfiles(string) int            { return f.maxProfiles }
+func (f fakeQueryLimits) MaxQueryStacktracesPerBlock(string) int { return f.maxStacktraces }
+
+func TestQueryLimiter(t *testing.T) {
+	t.Run("no limiter", func(t *testing.T) {
+		l := queryLimiterFromContext(context.Background())
+		require.NoError(t, l.AddSeries(1))
+		require.NoError(t, l.AddProfiles(1))
+		require.NoError(t, l.AddStacktraces(1))
+	})
+
+	t.Run("disabled limits", func(t *testing.T) {
+		l := queryLimiterFromContext(ContextWithQueryLimiter(context.Background(), fakeQueryLimits{}, "tenant"))
+		for i := 0; i < 10; i++ {
+			require.NoError(t, l.AddSeries(model.Fingerprint(i)))
+		}
+		require.NoError(t, l.AddProfiles(1000))
+		require.NoError(t, l.AddStacktraces(1000))
+	})
+
+	t.Run("series are counted once", func(t *testing.T) {
+		l := queryLimiterFromContext(ContextWithQueryLimiter(context.Background(), fakeQueryLimits{maxSeries: 2}, "tenant"))
+		require.NoError(t, l.AddSeries(1))
+		require.NoError(t, l.AddSeries(2))
+		require.NoError(t, l.AddSeries(1))
+		err := l.AddSeries(3)
+		require.Error(t, err)
+		require.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(err))
+	})
+
+	t.Run("profiles and stacktraces per block", func(t *testing.T) {
+		l := queryLimiterFromContext(ContextWithQueryLimiter(context.Background(), fakeQueryLimits{maxProfiles: 10, maxStacktraces: 5}, "tenant"))
+		require.NoError(t, l.AddProfiles(10))
+		require.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(l.AddProfiles(1)))
+		// The stacktraces are limited per block.
+		require.NoError(t, l.AddStacktraces(5))
+		require.NoError(t, l.AddStacktraces(5))
+		require.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(l.AddStacktraces(6)))
+	})
+}
+
+func TestFilterProfiles_MaxQueryProfiles(t *testing.T) {
+	ctx := ContextWithQueryLimiter(context.Background(), fakeQueryLimits{maxProfiles: 7}, "tenant")
+	profiles := lo.Times(11, func(i int) Profile {
+		lbs := phlaremodel.LabelsFromStrings("foo", "bar", "i", fmt.Sprintf("%d", i))
+		return ProfileWithLabels{
+			profile: &schemav1.InMemoryProfile{TimeNanos: int64(i * int(time.Minute))},
+			lbs:     lbs,
+			fp:      model.Fingerprint(lbs.Hash()),
+		}
+	})
+	bidi := &fakeBidiServerMergeProfilesStacktraces{
+		keep: [][]bool{{}, {true}, {true}},
+		t:    t,
+	}
+	_, err := filterProfiles[
+		BidiServerMerge[*ingestv1.MergeProfilesStacktracesResponse, *ingestv1.MergeProfilesStacktracesRequest],
+		*ingestv1.MergeProfilesStacktracesResponse,
+		*ingestv1.MergeProfilesStacktracesRequest](ctx, []iter.Iterator[Profile]{iter.NewSliceIterator(profiles)}, 5, bidi)
+	require.Error(t, err)
+	require.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(err))
+	// The first batch is sent, the second one exceeds the limit.
+	require.Len(t, bidi.profilesSent, 1)
+}
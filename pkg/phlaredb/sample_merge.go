@@ -3,6 +3,7 @@ package phlaredb
 import (
 	"context"
 	"sort"
+	"time"
 
 	"github.com/google/pprof/profile"
 	"github.com/opentracing/opentracing-go"
@@ -17,6 +18,7 @@ import (
 	"github.com/grafana/phlare/pkg/iter"
 	phlaremodel "github.com/grafana/phlare/pkg/model"
 	"github.com/grafana/phlare/pkg/phlaredb/query"
+	"github.com/grafana/phlare/pkg/querier/stats"
 )
 
 func (b *singleBlockQuerier) MergeByStacktraces(ctx context.Context, rows iter.Iterator[Profile]) (*ingestv1.MergeProfilesStacktracesResult, error) {
@@ -81,12 +83,18 @@ func (b *singleBlockQuerier) resolveLocations(ctx context.Context, mapping uint6
 	sort.Slice(stacktraceIDs, func(i, j int) bool {
 		return stacktraceIDs[i] < stacktraceIDs[j]
 	})
+	stats.FromContext(ctx).AddStacktracesResolved(uint64(len(stacktraceIDs)))
 	return b.stacktraces.Resolve(ctx, mapping, locs, stacktraceIDs)
 }
 
 func (b *singleBlockQuerier) resolvePprofSymbols(ctx context.Context, profileSampleByMapping profileSampleByMapping) (*profile.Profile, error) {
 	sp, ctx := opentracing.StartSpanFromContext(ctx, "ResolvePprofSymbols - Block")
 	defer sp.Finish()
+	qs := stats.FromContext(ctx)
+	defer func(start time.Time) { qs.AddResolveTime(time.Since(start)) }(time.Now())
+	if err := queryLimiterFromContext(ctx).AddStacktraces(profileSampleByMapping.size()); err != nil {
+		return nil, err
+	}
 
 	locationsIdsByStacktraceID := newLocationsIdsByStacktraceID(len(profileSampleByMapping) * 1024)
 
@@ -247,6 +255,11 @@ func (b *singleBlockQuerier) resolvePprofSymbols(ctx context.Context, profileSam
 func (b *singleBlockQuerier) resolveSymbols(ctx context.Context, stacktracesByMapping stacktracesByMapping) (*ingestv1.MergeProfilesStacktracesResult, error) {
 	sp, ctx := opentracing.StartSpanFromContext(ctx, "ResolveSymbols - Block")
 	defer sp.Finish()
+	qs := stats.FromContext(ctx)
+	defer func(start time.Time) { qs.AddResolveTime(time.Since(start)) }(time.Now())
+	if err := queryLimiterFromContext(ctx).AddStacktraces(stacktracesByMapping.size()); err != nil {
+		return nil, err
+	}
 	locationsIdsByStacktraceID := newLocationsIdsByStacktraceID(len(stacktracesByMapping) * 1024)
 
 	// gather stacktraces
@@ -389,6 +402,15 @@ func (m profileSampleByMapping) add(mapping uint64, key uint32, value int64) {
 	m[mapping].add(key, value)
 }
 
+// size returns the number of stacktraces, across all the mappings.
+func (m profileSampleByMapping) size() int {
+	var n int
+	for _, samples := range m {
+		n += len(samples)
+	}
+	return n
+}
+
 func (m profileSampleByMapping) ForEach(f func(mapping uint64, samples profileSampleMap) error) error {
 	for mapping, samples := range m {
 		if err := f(mapping, samples); err != nil {
@@ -431,6 +453,15 @@ func (m stacktracesByMapping) add(mapping uint64, key uint32, value int64) {
 	m[mapping].add(key, value)
 }
 
+// size returns the number of stacktraces, across all the mappings.
+func (m stacktracesByMapping) size() int {
+	var n int
+	for _, samples := range m {
+		n += len(samples)
+	}
+	return n
+}
+
 func (m stacktracesByMapping) ForEach(f func(mapping uint64, samples stacktraceSampleMap) error) error {
 	for mapping, samples := range m {
 		if err := f(mapping, samples); err != nil {
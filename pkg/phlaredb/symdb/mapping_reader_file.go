@@ -92,6 +92,32 @@ func (r *Reader) MappingReader(mappingName uint64) (MappingReader, bool) {
 	return m, ok
 }
 
+// VerifyChunks fetches all the stack trace chunks, verifying their checksums.
+func (r *Reader) VerifyChunks(ctx context.Context) error {
+	for _, h := range r.idx.StacktraceChunkHeaders.Entries {
+		c := &stacktraceChunkFileReader{reader: r, header: h}
+		if _, err := c.fetch(ctx); err != nil {
+			return fmt.Errorf("mapping %d, chunk %d: %w", h.MappingName, h.ChunkIndex, err)
+		}
+	}
+	return nil
+}
+
+// HasStacktrace reports whether the stack trace ID refers to a node of the
+// stack trace chunks of the mapping.
+func (r *Reader) HasStacktrace(mappingName uint64, stacktraceID uint32) bool {
+	m, ok := r.mappings[mappingName]
+	if !ok || len(m.stacktraceChunks) == 0 {
+		return false
+	}
+	var chunk uint32
+	if n := m.stacktraceChunks[0].header.StacktraceMaxNodes; n > 0 {
+		chunk, stacktraceID = stacktraceID/n, stacktraceID%n
+	}
+	c := m.stacktraceChunkReader(chunk)
+	return c != nil && stacktraceID < c.header.StacktraceNodes
+}
+
 type mappingFileReader struct {
 	reader           *Reader
 	stacktraceChunks []*stacktraceChunkFileReader
@@ -2,6 +2,8 @@ package symdb
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -52,3 +54,43 @@ func Test_Reader_Open(t *testing.T) {
 	require.NoError(t, err)
 	r.Release()
 }
+
+func Test_Reader_Verify(t *testing.T) {
+	cfg := &Config{
+		Dir: t.TempDir(),
+		Stacktraces: StacktracesConfig{
+			MaxNodesPerChunk: 7,
+		},
+	}
+
+	db := NewSymDB(cfg)
+	w := db.MappingWriter(1)
+	a := w.StacktraceAppender()
+	sids := make([]uint32, 3)
+	a.AppendStacktrace(sids, []*schemav1.Stacktrace{
+		{LocationIDs: []uint64{3, 2, 1}},
+		{LocationIDs: []uint64{4, 3, 2, 1}},
+		{LocationIDs: []uint64{5, 2, 1}},
+	})
+	a.Release()
+	require.NoError(t, db.Flush())
+
+	b, err := filesystem.NewBucket(cfg.Dir)
+	require.NoError(t, err)
+	x, err := Open(context.Background(), b)
+	require.NoError(t, err)
+	require.NoError(t, x.VerifyChunks(context.Background()))
+	for _, sid := range sids {
+		require.True(t, x.HasStacktrace(1, sid), sid)
+	}
+	require.False(t, x.HasStacktrace(1, 100))
+	require.False(t, x.HasStacktrace(2, sids[0]))
+
+	// Corrupt the stack traces.
+	path := filepath.Join(cfg.Dir, StacktracesFileName)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-1]++
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	require.ErrorIs(t, x.VerifyChunks(context.Background()), ErrInvalidCRC)
+}
@@ -0,0 +1,47 @@
+package phlaredb
+
+import (
+	"context"
+
+	"github.com/grafana/phlare/pkg/iter"
+	"github.com/grafana/phlare/pkg/phlaredb/tombstones"
+)
+
+type tombstonesKey struct{}
+
+// ContextWithTombstones returns a context filtering the profiles deleted by
+// the tombstones out of the queries executed with it.
+func ContextWithTombstones(ctx context.Context, ts tombstones.Tombstones) context.Context {
+	if len(ts) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, tombstonesKey{}, ts)
+}
+
+func tombstonesFromContext(ctx context.Context) tombstones.Tombstones {
+	ts, _ := ctx.Value(tombstonesKey{}).(tombstones.Tombstones)
+	return ts
+}
+
+// tombstonesIterator skips the profiles deleted by the tombstones.
+type tombstonesIterator struct {
+	iter.Iterator[Profile]
+	tombstones tombstones.Tombstones
+}
+
+func newTombstonesIterator(it iter.Iterator[Profile], ts tombstones.Tombstones) iter.Iterator[Profile] {
+	if len(ts) == 0 {
+		return it
+	}
+	return &tombstonesIterator{Iterator: it, tombstones: ts}
+}
+
+func (it *tombstonesIterator) Next() bool {
+	for it.Iterator.Next() {
+		p := it.Iterator.At()
+		if !it.tombstones.Deletes(p.Labels(), p.Timestamp()) {
+			return true
+		}
+	}
+	return false
+}
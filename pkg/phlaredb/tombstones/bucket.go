@@ -0,0 +1,90 @@
+package tombstones
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
+)
+
+// DeleteRequestsPrefix is the prefix, under the tenant prefix, of the
+// tombstones of the tenant. The tombstones are removed along with the
+// tenant data.
+const DeleteRequestsPrefix = "delete-requests"
+
+// Path returns the path of the tombstone in the bucket.
+func Path(tenantID, id string) string {
+	return path.Join(tenantID, DeleteRequestsPrefix, id+".json")
+}
+
+// Write uploads the tombstone of the tenant, overwriting the existing one,
+// if any.
+func Write(ctx context.Context, bkt phlareobj.Bucket, tenantID string, t *Tombstone) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return errors.Wrap(err, "serialize tombstone")
+	}
+	return errors.Wrap(bkt.Upload(ctx, Path(tenantID, t.ID), bytes.NewReader(data)), "upload tombstone")
+}
+
+// Read returns the tombstone of the tenant, or nil if it does not exist.
+func Read(ctx context.Context, bkt phlareobj.BucketReader, tenantID, id string) (*Tombstone, error) {
+	r, err := bkt.Get(ctx, Path(tenantID, id))
+	if err != nil {
+		if bkt.IsObjNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "read tombstone %s", id)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read tombstone %s", id)
+	}
+	var t Tombstone
+	if err = json.Unmarshal(data, &t); err != nil {
+		return nil, errors.Wrapf(err, "parse tombstone %s", id)
+	}
+	if err = t.init(); err != nil {
+		return nil, errors.Wrapf(err, "parse tombstone %s", id)
+	}
+	return &t, nil
+}
+
+// List returns the tombstones of the tenant.
+func List(ctx context.Context, bkt phlareobj.Bucket, tenantID string) (Tombstones, error) {
+	var ids []string
+	err := bkt.Iter(ctx, path.Join(tenantID, DeleteRequestsPrefix), func(name string) error {
+		if id := strings.TrimSuffix(path.Base(name), ".json"); id != path.Base(name) {
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "list tombstones")
+	}
+	ts := make(Tombstones, 0, len(ids))
+	for _, id := range ids {
+		t, err := Read(ctx, bkt, tenantID, id)
+		if err != nil {
+			return nil, err
+		}
+		// The tombstone may have been removed in the meantime.
+		if t != nil {
+			ts = append(ts, t)
+		}
+	}
+	ts.sort()
+	return ts, nil
+}
+
+// Delete removes the tombstone of the tenant.
+func Delete(ctx context.Context, bkt phlareobj.Bucket, tenantID, id string) error {
+	return errors.Wrap(bkt.Delete(ctx, Path(tenantID, id)), "delete tombstone")
+}
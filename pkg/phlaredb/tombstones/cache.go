@@ -0,0 +1,73 @@
+package tombstones
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
+)
+
+// Cache keeps the tombstones of the tenants read from the bucket for the
+// TTL, so that queries do not list the bucket.
+type Cache struct {
+	bucket phlareobj.Bucket
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	once       sync.Once
+	tombstones Tombstones
+	err        error
+	expiresAt  time.Time
+}
+
+func NewCache(bkt phlareobj.Bucket, ttl time.Duration) *Cache {
+	return &Cache{
+		bucket:  bkt,
+		ttl:     ttl,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+// Get returns the tombstones of the tenant. Concurrent calls for the same
+// tenant share the same bucket listing. The call is safe for a nil receiver,
+// which returns no tombstones.
+func (c *Cache) Get(ctx context.Context, tenantID string) (Tombstones, error) {
+	if c == nil {
+		return nil, nil
+	}
+	now := time.Now()
+	c.mu.Lock()
+	e, ok := c.entries[tenantID]
+	if !ok || now.After(e.expiresAt) {
+		e = &cacheEntry{expiresAt: now.Add(c.ttl)}
+		c.entries[tenantID] = e
+	}
+	c.mu.Unlock()
+	e.once.Do(func() {
+		e.tombstones, e.err = List(ctx, c.bucket, tenantID)
+	})
+	if e.err != nil {
+		// Do not keep the failures.
+		c.mu.Lock()
+		if c.entries[tenantID] == e {
+			delete(c.entries, tenantID)
+		}
+		c.mu.Unlock()
+	}
+	return e.tombstones, e.err
+}
+
+// Invalidate drops the tombstones of the tenant from the cache.
+func (c *Cache) Invalidate(tenantID string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	delete(c.entries, tenantID)
+	c.mu.Unlock()
+}
@@ -0,0 +1,163 @@
+package tombstones
+
+import (
+	"crypto/rand"
+	"sort"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+)
+
+// Tombstone records a request to delete the profiles of the series matching
+// the selector, within the time range. The profiles are filtered out of the
+// query results as soon as the tombstone is written, and physically removed
+// from the blocks later on.
+type Tombstone struct {
+	ID       string `json:"id"`
+	Selector string `json:"selector"`
+	// StartTime and EndTime are the inclusive bounds (milliseconds) of
+	// the time range of the profiles to delete.
+	StartTime model.Time `json:"start_time"`
+	EndTime   model.Time `json:"end_time"`
+	// CreatedAt is the unix timestamp (seconds) of when the tombstone
+	// was created.
+	CreatedAt int64 `json:"created_at"`
+	// ProcessedAt is the unix timestamp (seconds) of when the profiles
+	// were removed from the blocks in the object storage, if they were.
+	ProcessedAt int64 `json:"processed_at,omitempty"`
+
+	matchers []*labels.Matcher
+}
+
+// New creates a tombstone for the profiles of the series matching the
+// selector, within the time range.
+func New(selector string, start, end model.Time, now time.Time) (*Tombstone, error) {
+	t := &Tombstone{
+		ID:        ulid.MustNew(ulid.Timestamp(now), rand.Reader).String(),
+		Selector:  selector,
+		StartTime: start,
+		EndTime:   end,
+		CreatedAt: now.Unix(),
+	}
+	if err := t.init(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Tombstone) init() error {
+	if t.StartTime > t.EndTime {
+		return errors.New("the start time must not be after the end time")
+	}
+	matchers, err := parser.ParseMetricSelector(t.Selector)
+	if err != nil {
+		return errors.Wrapf(err, "invalid selector %q", t.Selector)
+	}
+	// Like the series deletion of Prometheus, refuse selectors matching
+	// every series.
+	for _, m := range matchers {
+		if !m.Matches("") {
+			t.matchers = matchers
+			return nil
+		}
+	}
+	return errors.Errorf("the selector %q must contain at least one matcher not matching the empty string", t.Selector)
+}
+
+// Overlaps reports whether the tombstone may delete profiles in the time
+// range.
+func (t *Tombstone) Overlaps(start, end model.Time) bool {
+	return t.StartTime <= end && start <= t.EndTime
+}
+
+// Deletes reports whether the profile of the series, with the timestamp, is
+// deleted by the tombstone.
+func (t *Tombstone) Deletes(lbls phlaremodel.Labels, ts model.Time) bool {
+	return ts >= t.StartTime && ts <= t.EndTime && t.Matches(lbls)
+}
+
+// Matches reports whether the series matches the selector of the tombstone.
+func (t *Tombstone) Matches(lbls phlaremodel.Labels) bool {
+	for _, m := range t.matchers {
+		if !m.Matches(lbls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Processed reports whether the profiles were removed from the blocks.
+func (t *Tombstone) Processed() bool {
+	return t.ProcessedAt > 0
+}
+
+// Tombstones is a set of tombstones, ordered by creation.
+type Tombstones []*Tombstone
+
+// ForRange returns the tombstones which may delete profiles in the time
+// range.
+func (ts Tombstones) ForRange(start, end model.Time) Tombstones {
+	var r Tombstones
+	for _, t := range ts {
+		if t.Overlaps(start, end) {
+			r = append(r, t)
+		}
+	}
+	return r
+}
+
+// Deletes reports whether the profile of the series, with the timestamp, is
+// deleted by any of the tombstones.
+func (ts Tombstones) Deletes(lbls phlaremodel.Labels, timestamp model.Time) bool {
+	for _, t := range ts {
+		if t.Deletes(lbls, timestamp) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeletesSeries reports whether all the profiles of the series, within the
+// time range, are deleted by the tombstones.
+func (ts Tombstones) DeletesSeries(lbls phlaremodel.Labels, start, end model.Time) bool {
+	var ranges []*Tombstone
+	for _, t := range ts {
+		if t.Overlaps(start, end) && t.Matches(lbls) {
+			ranges = append(ranges, t)
+		}
+	}
+	if len(ranges) == 0 {
+		return false
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].StartTime < ranges[j].StartTime
+	})
+	// The tombstones must cover the time range without any gap.
+	for _, t := range ranges {
+		if t.StartTime > start {
+			return false
+		}
+		if t.EndTime >= end {
+			return true
+		}
+		if t.EndTime >= start {
+			start = t.EndTime + 1
+		}
+	}
+	return false
+}
+
+func (ts Tombstones) sort() {
+	sort.Slice(ts, func(i, j int) bool {
+		if ts[i].CreatedAt != ts[j].CreatedAt {
+			return ts[i].CreatedAt < ts[j].CreatedAt
+		}
+		return ts[i].ID < ts[j].ID
+	})
+}
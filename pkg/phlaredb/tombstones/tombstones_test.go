@@ -0,0 +1,144 @@
+package tombstones
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/objstore/providers/filesystem"
+)
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		selector   string
+		start, end model.Time
+		err        bool
+	}{
+		{selector: `{service_name="billing", env="dev"}`, end: 10},
+		{selector: `{service_name=~"billing.*"}`, start: 10, end: 10},
+		{selector: `{service_name=~".*"}`, end: 10, err: true},
+		{selector: `{}`, end: 10, err: true},
+		{selector: `{service_name=}`, end: 10, err: true},
+		{selector: `{service_name="billing"}`, start: 10, end: 5, err: true},
+	} {
+		_, err := New(tc.selector, tc.start, tc.end, time.Now())
+		if tc.err {
+			require.Error(t, err, tc.selector)
+		} else {
+			require.NoError(t, err, tc.selector)
+		}
+	}
+}
+
+func TestTombstones_Deletes(t *testing.T) {
+	t1, err := New(`{service_name="billing", env="dev"}`, 10, 20, time.Now())
+	require.NoError(t, err)
+	t2, err := New(`{service_name="checkout", env!="prod"}`, 30, 40, time.Now())
+	require.NoError(t, err)
+	ts := Tombstones{t1, t2}
+
+	billingDev := phlaremodel.LabelsFromStrings("service_name", "billing", "env", "dev")
+	billingProd := phlaremodel.LabelsFromStrings("service_name", "billing", "env", "prod")
+	checkout := phlaremodel.LabelsFromStrings("service_name", "checkout")
+
+	require.True(t, ts.Deletes(billingDev, 10))
+	require.True(t, ts.Deletes(billingDev, 20))
+	require.False(t, ts.Deletes(billingDev, 21))
+	require.False(t, ts.Deletes(billingProd, 15))
+	require.True(t, ts.Deletes(checkout, 35))
+	require.False(t, ts.Deletes(checkout, 15))
+
+	require.Equal(t, Tombstones{t1}, ts.ForRange(0, 10))
+	require.Equal(t, Tombstones{t1, t2}, ts.ForRange(15, 30))
+	require.Empty(t, ts.ForRange(41, 50))
+}
+
+func TestTombstones_DeletesSeries(t *testing.T) {
+	t1, err := New(`{service_name="billing"}`, 10, 20, time.Now())
+	require.NoError(t, err)
+	t2, err := New(`{service_name="billing", env="dev"}`, 15, 30, time.Now())
+	require.NoError(t, err)
+	t3, err := New(`{service_name="billing"}`, 32, 40, time.Now())
+	require.NoError(t, err)
+	ts := Tombstones{t3, t2, t1}
+
+	billingDev := phlaremodel.LabelsFromStrings("service_name", "billing", "env", "dev")
+	billingProd := phlaremodel.LabelsFromStrings("service_name", "billing", "env", "prod")
+
+	require.True(t, ts.DeletesSeries(billingDev, 10, 30))
+	require.True(t, ts.DeletesSeries(billingDev, 12, 18))
+	require.False(t, ts.DeletesSeries(billingDev, 5, 30))
+	require.False(t, ts.DeletesSeries(billingDev, 10, 35))
+	require.True(t, ts.DeletesSeries(billingProd, 10, 20))
+	require.False(t, ts.DeletesSeries(billingProd, 10, 30))
+	require.False(t, ts.DeletesSeries(billingProd, 21, 31))
+}
+
+func TestBucket(t *testing.T) {
+	ctx := context.Background()
+	bkt, err := filesystem.NewBucket(t.TempDir())
+	require.NoError(t, err)
+
+	ts, err := List(ctx, bkt, "tenant-a")
+	require.NoError(t, err)
+	require.Empty(t, ts)
+
+	t1, err := New(`{service_name="billing"}`, 10, 20, time.Unix(100, 0))
+	require.NoError(t, err)
+	t2, err := New(`{service_name="checkout"}`, 10, 20, time.Unix(200, 0))
+	require.NoError(t, err)
+	require.NoError(t, Write(ctx, bkt, "tenant-a", t2))
+	require.NoError(t, Write(ctx, bkt, "tenant-a", t1))
+
+	ts, err = List(ctx, bkt, "tenant-a")
+	require.NoError(t, err)
+	require.Equal(t, Tombstones{t1, t2}, ts)
+	ts, err = List(ctx, bkt, "tenant-b")
+	require.NoError(t, err)
+	require.Empty(t, ts)
+
+	t1.ProcessedAt = 300
+	require.NoError(t, Write(ctx, bkt, "tenant-a", t1))
+	read, err := Read(ctx, bkt, "tenant-a", t1.ID)
+	require.NoError(t, err)
+	require.Equal(t, t1, read)
+	require.True(t, read.Processed())
+
+	require.NoError(t, Delete(ctx, bkt, "tenant-a", t2.ID))
+	read, err = Read(ctx, bkt, "tenant-a", t2.ID)
+	require.NoError(t, err)
+	require.Nil(t, read)
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	bkt, err := filesystem.NewBucket(t.TempDir())
+	require.NoError(t, err)
+	c := NewCache(bkt, time.Hour)
+
+	ts, err := c.Get(ctx, "tenant-a")
+	require.NoError(t, err)
+	require.Empty(t, ts)
+
+	t1, err := New(`{service_name="billing"}`, 10, 20, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, Write(ctx, bkt, "tenant-a", t1))
+
+	// Cached until invalidated.
+	ts, err = c.Get(ctx, "tenant-a")
+	require.NoError(t, err)
+	require.Empty(t, ts)
+	c.Invalidate("tenant-a")
+	ts, err = c.Get(ctx, "tenant-a")
+	require.NoError(t, err)
+	require.Equal(t, Tombstones{t1}, ts)
+
+	var nilCache *Cache
+	ts, err = nilCache.Get(ctx, "tenant-a")
+	require.NoError(t, err)
+	require.Empty(t, ts)
+}
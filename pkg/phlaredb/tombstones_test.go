@@ -0,0 +1,90 @@
+package phlaredb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	ingestv1 "github.com/grafana/phlare/api/gen/proto/go/ingester/v1"
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+	"github.com/grafana/phlare/pkg/iter"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/phlaredb/tombstones"
+)
+
+func TestSelectMatchingProfiles_Tombstones(t *testing.T) {
+	head := newTestHead(t)
+	ctx := context.Background()
+	for i := 0; i < 30; i++ {
+		require.NoError(t, ingestThreeProfileStreams(ctx, i, head.Ingest))
+	}
+
+	selectProfiles := func(ctx context.Context) []Profile {
+		iters, err := SelectMatchingProfiles(ctx, &ingestv1.SelectProfilesRequest{
+			Start:         0,
+			End:           1000000000000,
+			LabelSelector: `{job="foo"}`,
+			Type:          mustParseProfileSelector(t, "process_cpu:cpu:nanoseconds:cpu:nanoseconds"),
+		}, head.Queriers())
+		require.NoError(t, err)
+		var profiles []Profile
+		for _, it := range iters {
+			p, err := iter.Slice(it)
+			require.NoError(t, err)
+			profiles = append(profiles, p...)
+		}
+		return profiles
+	}
+	require.Len(t, selectProfiles(ctx), 30)
+
+	ctx = ContextWithTombstones(ctx, tombstones.Tombstones{
+		newTestTombstone(t, `{stream="stream-a"}`, 0, model.TimeFromUnix(9)),
+		newTestTombstone(t, `{stream="stream-b"}`, 0, model.Latest),
+		// Out of the time range of the query.
+		newTestTombstone(t, `{stream="stream-c"}`, model.Time(1000000000001), model.Latest),
+	})
+	profiles := selectProfiles(ctx)
+	require.Len(t, profiles, 16)
+	for _, p := range profiles {
+		stream := p.Labels().Get("stream")
+		require.NotEqual(t, "stream-b", stream)
+		if stream == "stream-a" {
+			require.Greater(t, p.Timestamp(), model.TimeFromUnix(9))
+		}
+	}
+}
+
+func TestHeadSeries_Tombstones(t *testing.T) {
+	head := newTestHead(t)
+	ctx := context.Background()
+	for i := 0; i < 30; i++ {
+		require.NoError(t, ingestThreeProfileStreams(ctx, i, head.Ingest))
+	}
+
+	ctx = ContextWithTombstones(ctx, tombstones.Tombstones{
+		newTestTombstone(t, `{stream="stream-a"}`, 0, model.TimeFromUnix(9)),
+		newTestTombstone(t, `{stream="stream-b"}`, 0, model.Latest),
+	})
+
+	series, err := head.Series(ctx, connect.NewRequest(&ingestv1.SeriesRequest{Matchers: []string{`{job="foo"}`}}))
+	require.NoError(t, err)
+	var streams []string
+	for _, s := range series.Msg.LabelsSet {
+		streams = append(streams, phlaremodel.Labels(s.Labels).Get("stream"))
+	}
+	require.Equal(t, []string{"stream-a", "stream-c"}, streams)
+
+	for _, matchers := range [][]string{nil, {`{job="foo"}`}} {
+		values, err := head.LabelValues(ctx, connect.NewRequest(&typesv1.LabelValuesRequest{Name: "stream", Matchers: matchers}))
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"stream-a", "stream-c"}, values.Msg.Names)
+	}
+
+	// Without the tombstones, the series are all returned.
+	values, err := head.LabelValues(context.Background(), connect.NewRequest(&typesv1.LabelValuesRequest{Name: "stream"}))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"stream-a", "stream-b", "stream-c"}, values.Msg.Names)
+}
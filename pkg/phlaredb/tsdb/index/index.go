@@ -876,6 +876,9 @@ func (w *Writer) writeFingerprintOffsetsTable() error {
 
 const indexTOCLen = 8*9 + crc32.Size
 
+// TOCLen is the length of the table of contents at the end of the index.
+const TOCLen = indexTOCLen
+
 func (w *Writer) writeTOC() error {
 	w.buf1.Reset()
 
@@ -0,0 +1,138 @@
+package pprof
+
+import (
+	"encoding/json"
+	"fmt"
+
+	profilev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
+)
+
+// chromeCPUProfile is the .cpuprofile format of the Chrome DevTools and V8,
+// see https://chromedevtools.github.io/devtools-protocol/tot/Profiler/#type-Profile.
+type chromeCPUProfile struct {
+	Nodes []struct {
+		ID        int64 `json:"id"`
+		CallFrame struct {
+			FunctionName string `json:"functionName"`
+			URL          string `json:"url"`
+			LineNumber   int64  `json:"lineNumber"`
+		} `json:"callFrame"`
+		HitCount int64   `json:"hitCount"`
+		Children []int64 `json:"children"`
+		Parent   int64   `json:"parent"`
+	} `json:"nodes"`
+	// The times are in microseconds.
+	StartTime  int64   `json:"startTime"`
+	EndTime    int64   `json:"endTime"`
+	Samples    []int64 `json:"samples"`
+	TimeDeltas []int64 `json:"timeDeltas"`
+}
+
+const chromeRootFunctionName = "(root)"
+
+// ParseChromeCPUProfile converts a Chrome DevTools .cpuprofile into a pprof
+// CPU profile with the samples/count and cpu/nanoseconds sample types. The
+// CPU time of a sample is the time until the next sample, or until the end
+// of the profile for the last one. Profiles without samples are converted
+// from the hit counts of their nodes, with no CPU time.
+func ParseChromeCPUProfile(data []byte) (*profilev1.Profile, error) {
+	var cp chromeCPUProfile
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	if len(cp.Samples) != len(cp.TimeDeltas) {
+		return nil, fmt.Errorf("the profile has %d samples and %d time deltas", len(cp.Samples), len(cp.TimeDeltas))
+	}
+
+	b := newJSONProfileBuilder()
+	b.sampleType("samples", "count")
+	cpu := b.sampleType("cpu", "nanoseconds")
+	p := b.profile
+	p.PeriodType = &profilev1.ValueType{Type: p.SampleType[cpu].Type, Unit: p.SampleType[cpu].Unit}
+	p.DurationNanos = (cp.EndTime - cp.StartTime) * 1000
+	if len(cp.Samples) > 0 {
+		p.Period = p.DurationNanos / int64(len(cp.Samples))
+	}
+
+	type node struct {
+		parent   int64
+		location uint64
+	}
+	nodes := make(map[int64]*node, len(cp.Nodes))
+	for _, n := range cp.Nodes {
+		nodes[n.ID] = &node{parent: n.Parent}
+	}
+	for _, n := range cp.Nodes {
+		for _, c := range n.Children {
+			if child, ok := nodes[c]; ok {
+				child.parent = n.ID
+			}
+		}
+		if n.CallFrame.FunctionName == chromeRootFunctionName {
+			continue
+		}
+		name := n.CallFrame.FunctionName
+		if name == "" {
+			name = "(anonymous)"
+		}
+		// The line numbers are zero-based.
+		var line int64
+		if n.CallFrame.LineNumber >= 0 {
+			line = n.CallFrame.LineNumber + 1
+		}
+		nodes[n.ID].location = b.location(name, n.CallFrame.URL, line)
+	}
+
+	stack := make([]uint64, 0, 64)
+	stackOf := func(id int64) ([]uint64, error) {
+		stack = stack[:0]
+		var depth int
+		for n, ok := nodes[id]; ok; n, ok = nodes[n.parent] {
+			if depth++; depth > len(nodes) {
+				return nil, fmt.Errorf("the node %d is part of a cycle", id)
+			}
+			if n.location != 0 {
+				stack = append(stack, n.location)
+			}
+		}
+		return stack, nil
+	}
+
+	if len(cp.Samples) == 0 {
+		for _, n := range cp.Nodes {
+			if n.HitCount == 0 {
+				continue
+			}
+			s, err := stackOf(n.ID)
+			if err != nil {
+				return nil, err
+			}
+			if len(s) > 0 {
+				b.add(s, nil, n.HitCount)
+			}
+		}
+		return b.build()
+	}
+
+	t := cp.StartTime
+	for i, id := range cp.Samples {
+		t += cp.TimeDeltas[i]
+		next := cp.EndTime
+		if i+1 < len(cp.Samples) {
+			next = t + cp.TimeDeltas[i+1]
+		}
+		s, err := stackOf(id)
+		if err != nil {
+			return nil, err
+		}
+		if len(s) == 0 {
+			continue
+		}
+		var d int64
+		if next > t {
+			d = (next - t) * 1000
+		}
+		b.add(s, nil, 1, d)
+	}
+	return b.build()
+}
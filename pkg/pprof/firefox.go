@@ -0,0 +1,331 @@
+package pprof
+
+import (
+	"encoding/json"
+
+	profilev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
+)
+
+// The versions of the processed profile format of the Firefox Profiler, see
+// https://github.com/firefox-devtools/profiler/blob/main/docs-developer/CHANGELOG-formats.md.
+// The profiler upgrades the older versions when loading a profile.
+const (
+	firefoxGeckoProfileVersion     = 27
+	firefoxProcessedProfileVersion = 47
+)
+
+// firefoxProfile is the processed profile format of the Firefox Profiler, see
+// https://github.com/firefox-devtools/profiler/blob/main/src/types/profile.js.
+// The tables are structures of arrays.
+type firefoxProfile struct {
+	Meta    firefoxMeta     `json:"meta"`
+	Libs    []struct{}      `json:"libs"`
+	Pages   []struct{}      `json:"pages"`
+	Threads []firefoxThread `json:"threads"`
+}
+
+type firefoxMeta struct {
+	Interval                   float64           `json:"interval"`
+	StartTime                  float64           `json:"startTime"`
+	ProcessType                int               `json:"processType"`
+	Product                    string            `json:"product"`
+	ImportedFrom               string            `json:"importedFrom"`
+	Stackwalk                  int               `json:"stackwalk"`
+	Debug                      bool              `json:"debug"`
+	Version                    int               `json:"version"`
+	PreprocessedProfileVersion int               `json:"preprocessedProfileVersion"`
+	Symbolicated               bool              `json:"symbolicated"`
+	Categories                 []firefoxCategory `json:"categories"`
+	MarkerSchema               []struct{}        `json:"markerSchema"`
+}
+
+type firefoxCategory struct {
+	Name          string   `json:"name"`
+	Color         string   `json:"color"`
+	Subcategories []string `json:"subcategories"`
+}
+
+type firefoxThread struct {
+	ProcessType         string              `json:"processType"`
+	ProcessStartupTime  float64             `json:"processStartupTime"`
+	ProcessShutdownTime *float64            `json:"processShutdownTime"`
+	RegisterTime        float64             `json:"registerTime"`
+	UnregisterTime      *float64            `json:"unregisterTime"`
+	PausedRanges        []struct{}          `json:"pausedRanges"`
+	Name                string              `json:"name"`
+	ProcessName         string              `json:"processName"`
+	IsMainThread        bool                `json:"isMainThread"`
+	PID                 string              `json:"pid"`
+	TID                 int                 `json:"tid"`
+	Samples             firefoxSamples      `json:"samples"`
+	Markers             firefoxMarkers      `json:"markers"`
+	StackTable          *firefoxStackTable  `json:"stackTable"`
+	FrameTable          *firefoxFrameTable  `json:"frameTable"`
+	FuncTable           *firefoxFuncTable   `json:"funcTable"`
+	ResourceTable       firefoxResources    `json:"resourceTable"`
+	NativeSymbols       firefoxNativeSymbol `json:"nativeSymbols"`
+	StringArray         []string            `json:"stringArray"`
+}
+
+type firefoxSamples struct {
+	Stack      []int     `json:"stack"`
+	Time       []float64 `json:"time"`
+	Weight     []float64 `json:"weight"`
+	WeightType string    `json:"weightType"`
+	Length     int       `json:"length"`
+}
+
+type firefoxMarkers struct {
+	Data      []struct{} `json:"data"`
+	Name      []int      `json:"name"`
+	StartTime []float64  `json:"startTime"`
+	EndTime   []float64  `json:"endTime"`
+	Phase     []int      `json:"phase"`
+	Category  []int      `json:"category"`
+	Length    int        `json:"length"`
+}
+
+type firefoxStackTable struct {
+	Frame       []int  `json:"frame"`
+	Prefix      []*int `json:"prefix"`
+	Category    []int  `json:"category"`
+	Subcategory []int  `json:"subcategory"`
+	Length      int    `json:"length"`
+}
+
+type firefoxFrameTable struct {
+	Address        []int    `json:"address"`
+	InlineDepth    []int    `json:"inlineDepth"`
+	Category       []int    `json:"category"`
+	Subcategory    []int    `json:"subcategory"`
+	Func           []int    `json:"func"`
+	NativeSymbol   []*int   `json:"nativeSymbol"`
+	InnerWindowID  []int    `json:"innerWindowID"`
+	Implementation []*int   `json:"implementation"`
+	Line           []*int64 `json:"line"`
+	Column         []*int64 `json:"column"`
+	Length         int      `json:"length"`
+}
+
+type firefoxFuncTable struct {
+	Name          []int    `json:"name"`
+	IsJS          []bool   `json:"isJS"`
+	RelevantForJS []bool   `json:"relevantForJS"`
+	Resource      []int    `json:"resource"`
+	FileName      []*int   `json:"fileName"`
+	LineNumber    []*int64 `json:"lineNumber"`
+	ColumnNumber  []*int64 `json:"columnNumber"`
+	Length        int      `json:"length"`
+}
+
+type firefoxResources struct {
+	Lib    []int `json:"lib"`
+	Name   []int `json:"name"`
+	Host   []int `json:"host"`
+	Type   []int `json:"type"`
+	Length int   `json:"length"`
+}
+
+type firefoxNativeSymbol struct {
+	LibIndex     []int `json:"libIndex"`
+	Address      []int `json:"address"`
+	Name         []int `json:"name"`
+	FunctionSize []int `json:"functionSize"`
+	Length       int   `json:"length"`
+}
+
+// MarshalFirefoxProfile converts the pprof profile into a processed profile
+// of the Firefox Profiler with a thread per sample type, named after it. The
+// samples are weighted by their values: in milliseconds for the time sample
+// types, in bytes for the memory ones and in counts otherwise. The samples
+// have no meaningful time, only the call tree views of the profiler apply.
+func MarshalFirefoxProfile(p *profilev1.Profile) ([]byte, error) {
+	b := newFirefoxBuilder()
+	stacks := newProfileStacks(p)
+	ids := make([]int, len(p.Sample))
+	for i, s := range p.Sample {
+		ids[i] = b.stack(stacks.stack(s))
+	}
+
+	startTime := float64(p.TimeNanos) / 1e6
+	f := firefoxProfile{
+		Meta: firefoxMeta{
+			Interval:                   1,
+			StartTime:                  startTime,
+			Product:                    "Phlare",
+			ImportedFrom:               "Phlare",
+			Version:                    firefoxGeckoProfileVersion,
+			PreprocessedProfileVersion: firefoxProcessedProfileVersion,
+			Symbolicated:               true,
+			Categories: []firefoxCategory{
+				{Name: "Other", Color: "grey", Subcategories: []string{"Other"}},
+			},
+			MarkerSchema: []struct{}{},
+		},
+		Libs:  []struct{}{},
+		Pages: []struct{}{},
+	}
+	for i, st := range p.SampleType {
+		typ, unit := p.StringTable[st.Type], p.StringTable[st.Unit]
+		samples := firefoxSamples{Stack: []int{}, Time: []float64{}, Weight: []float64{}, WeightType: "samples"}
+		// The weights are divided by the units per millisecond.
+		div := 1.0
+		switch unit {
+		case "nanoseconds":
+			samples.WeightType, div = "tracing-ms", 1e6
+		case "microseconds":
+			samples.WeightType, div = "tracing-ms", 1e3
+		case "milliseconds":
+			samples.WeightType = "tracing-ms"
+		case "seconds":
+			samples.WeightType, div = "tracing-ms", 1e-3
+		case "bytes":
+			samples.WeightType = "bytes"
+		}
+		for j, s := range p.Sample {
+			if i >= len(s.Value) || s.Value[i] == 0 || ids[j] < 0 {
+				continue
+			}
+			samples.Stack = append(samples.Stack, ids[j])
+			samples.Time = append(samples.Time, startTime+float64(samples.Length))
+			samples.Weight = append(samples.Weight, float64(s.Value[i])/div)
+			samples.Length++
+		}
+		f.Threads = append(f.Threads, firefoxThread{
+			ProcessType:   "default",
+			PausedRanges:  []struct{}{},
+			Name:          typ + " (" + unit + ")",
+			ProcessName:   "Phlare",
+			IsMainThread:  i == 0,
+			PID:           "0",
+			TID:           i,
+			Samples:       samples,
+			Markers:       firefoxMarkers{Data: []struct{}{}, Name: []int{}, StartTime: []float64{}, EndTime: []float64{}, Phase: []int{}, Category: []int{}},
+			StackTable:    &b.stacks,
+			FrameTable:    &b.frames,
+			FuncTable:     &b.funcs,
+			ResourceTable: firefoxResources{Lib: []int{}, Name: []int{}, Host: []int{}, Type: []int{}},
+			NativeSymbols: firefoxNativeSymbol{LibIndex: []int{}, Address: []int{}, Name: []int{}, FunctionSize: []int{}},
+			StringArray:   b.strings,
+		})
+	}
+	return json.Marshal(f)
+}
+
+// firefoxBuilder builds the tables shared by the threads of the profile.
+type firefoxBuilder struct {
+	strings   []string
+	stringIDs map[string]int
+	funcIDs   map[jsonFunctionKey]int
+	frameIDs  map[speedscopeFrame]int
+	stackIDs  map[[2]int]int
+	stacks    firefoxStackTable
+	frames    firefoxFrameTable
+	funcs     firefoxFuncTable
+}
+
+func newFirefoxBuilder() *firefoxBuilder {
+	return &firefoxBuilder{
+		strings:   []string{},
+		stringIDs: make(map[string]int),
+		funcIDs:   make(map[jsonFunctionKey]int),
+		frameIDs:  make(map[speedscopeFrame]int),
+		stackIDs:  make(map[[2]int]int),
+		stacks:    firefoxStackTable{Frame: []int{}, Prefix: []*int{}, Category: []int{}, Subcategory: []int{}},
+		frames: firefoxFrameTable{
+			Address: []int{}, InlineDepth: []int{}, Category: []int{}, Subcategory: []int{}, Func: []int{},
+			NativeSymbol: []*int{}, InnerWindowID: []int{}, Implementation: []*int{}, Line: []*int64{}, Column: []*int64{},
+		},
+		funcs: firefoxFuncTable{
+			Name: []int{}, IsJS: []bool{}, RelevantForJS: []bool{}, Resource: []int{},
+			FileName: []*int{}, LineNumber: []*int64{}, ColumnNumber: []*int64{},
+		},
+	}
+}
+
+func (b *firefoxBuilder) string(s string) int {
+	if id, ok := b.stringIDs[s]; ok {
+		return id
+	}
+	id := len(b.strings)
+	b.stringIDs[s] = id
+	b.strings = append(b.strings, s)
+	return id
+}
+
+func (b *firefoxBuilder) function(name, file string) int {
+	k := jsonFunctionKey{name: name, file: file}
+	if id, ok := b.funcIDs[k]; ok {
+		return id
+	}
+	t := &b.funcs
+	id := t.Length
+	b.funcIDs[k] = id
+	var fileName *int
+	if file != "" {
+		s := b.string(file)
+		fileName = &s
+	}
+	t.Name = append(t.Name, b.string(name))
+	t.IsJS = append(t.IsJS, false)
+	t.RelevantForJS = append(t.RelevantForJS, false)
+	t.Resource = append(t.Resource, -1)
+	t.FileName = append(t.FileName, fileName)
+	t.LineNumber = append(t.LineNumber, nil)
+	t.ColumnNumber = append(t.ColumnNumber, nil)
+	t.Length++
+	return id
+}
+
+func (b *firefoxBuilder) frame(fr speedscopeFrame) int {
+	if id, ok := b.frameIDs[fr]; ok {
+		return id
+	}
+	t := &b.frames
+	id := t.Length
+	b.frameIDs[fr] = id
+	var line *int64
+	if fr.Line != 0 {
+		l := fr.Line
+		line = &l
+	}
+	t.Address = append(t.Address, -1)
+	t.InlineDepth = append(t.InlineDepth, 0)
+	t.Category = append(t.Category, 0)
+	t.Subcategory = append(t.Subcategory, 0)
+	t.Func = append(t.Func, b.function(fr.Name, fr.File))
+	t.NativeSymbol = append(t.NativeSymbol, nil)
+	t.InnerWindowID = append(t.InnerWindowID, 0)
+	t.Implementation = append(t.Implementation, nil)
+	t.Line = append(t.Line, line)
+	t.Column = append(t.Column, nil)
+	t.Length++
+	return id
+}
+
+// stack returns the index of the stack of the frames ordered from the root,
+// or -1 for an empty stack.
+func (b *firefoxBuilder) stack(frames []speedscopeFrame) int {
+	prefix := -1
+	for _, fr := range frames {
+		k := [2]int{prefix, b.frame(fr)}
+		id, ok := b.stackIDs[k]
+		if !ok {
+			t := &b.stacks
+			id = t.Length
+			b.stackIDs[k] = id
+			var p *int
+			if prefix >= 0 {
+				p = new(int)
+				*p = prefix
+			}
+			t.Frame = append(t.Frame, k[1])
+			t.Prefix = append(t.Prefix, p)
+			t.Category = append(t.Category, 0)
+			t.Subcategory = append(t.Subcategory, 0)
+			t.Length++
+		}
+		prefix = id
+	}
+	return prefix
+}
@@ -0,0 +1,155 @@
+package pprof
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	profilev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
+)
+
+// IsJSON tells whether the profile is a JSON document rather than a pprof
+// protobuf: a profile message can't start with an opening brace, the
+// field 15 of the message being undefined.
+func IsJSON(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '{'
+}
+
+// FromJSON converts a profile in one of the supported JSON formats into
+// pprof: Chrome DevTools .cpuprofile and Speedscope.
+func FromJSON(data []byte) (*profilev1.Profile, error) {
+	var probe struct {
+		Schema string          `json:"$schema"`
+		Nodes  json.RawMessage `json:"nodes"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	switch {
+	case probe.Schema == speedscopeSchema:
+		return ParseSpeedscope(data)
+	case probe.Nodes != nil:
+		return ParseChromeCPUProfile(data)
+	}
+	return nil, fmt.Errorf("unknown JSON profile format")
+}
+
+// jsonProfileBuilder builds the profiles converted from the JSON formats.
+// The samples with the same stack and labels are merged.
+type jsonProfileBuilder struct {
+	profile     *profilev1.Profile
+	strings     map[string]int
+	sampleTypes map[string]int
+	functions   map[jsonFunctionKey]uint64
+	locations   map[jsonLocationKey]uint64
+	samples     map[string]*profilev1.Sample
+	key         strings.Builder
+}
+
+type jsonFunctionKey struct {
+	name, file string
+}
+
+type jsonLocationKey struct {
+	jsonFunctionKey
+	line int64
+}
+
+func newJSONProfileBuilder() *jsonProfileBuilder {
+	return &jsonProfileBuilder{
+		profile:     &profilev1.Profile{},
+		strings:     map[string]int{"": 0},
+		sampleTypes: make(map[string]int),
+		functions:   make(map[jsonFunctionKey]uint64),
+		locations:   make(map[jsonLocationKey]uint64),
+		samples:     make(map[string]*profilev1.Sample),
+	}
+}
+
+func (b *jsonProfileBuilder) string(s string) int64 {
+	return addString(b.strings, s)
+}
+
+// sampleType returns the index of the sample type, which is added if needed.
+func (b *jsonProfileBuilder) sampleType(typ, unit string) int {
+	if i, ok := b.sampleTypes[typ]; ok {
+		return i
+	}
+	p := b.profile
+	i := len(p.SampleType)
+	b.sampleTypes[typ] = i
+	p.SampleType = append(p.SampleType, &profilev1.ValueType{Type: b.string(typ), Unit: b.string(unit)})
+	for _, s := range p.Sample {
+		s.Value = append(s.Value, 0)
+	}
+	return i
+}
+
+// location returns the id of the location of the frame, the line being
+// one-based, or 0 if unknown.
+func (b *jsonProfileBuilder) location(name, file string, line int64) uint64 {
+	k := jsonLocationKey{jsonFunctionKey: jsonFunctionKey{name: name, file: file}, line: line}
+	if id, ok := b.locations[k]; ok {
+		return id
+	}
+	p := b.profile
+	fnID, ok := b.functions[k.jsonFunctionKey]
+	if !ok {
+		fnID = uint64(len(p.Function) + 1)
+		b.functions[k.jsonFunctionKey] = fnID
+		p.Function = append(p.Function, &profilev1.Function{
+			Id:         fnID,
+			Name:       b.string(name),
+			SystemName: b.string(name),
+			Filename:   b.string(file),
+		})
+	}
+	id := uint64(len(p.Location) + 1)
+	b.locations[k] = id
+	p.Location = append(p.Location, &profilev1.Location{
+		Id:   id,
+		Line: []*profilev1.Line{{FunctionId: fnID, Line: line}},
+	})
+	return id
+}
+
+// add adds the values to the sample of the stack, the locations being
+// ordered from the leaf, and of the labels.
+func (b *jsonProfileBuilder) add(locations []uint64, labels []*profilev1.Label, values ...int64) {
+	b.key.Reset()
+	for _, id := range locations {
+		b.key.WriteString(strconv.FormatUint(id, 16))
+		b.key.WriteByte(',')
+	}
+	for _, l := range labels {
+		b.key.WriteString(strconv.FormatInt(l.Key, 16) + "=" + strconv.FormatInt(l.Str, 16) + ",")
+	}
+	s, ok := b.samples[b.key.String()]
+	if !ok {
+		s = &profilev1.Sample{
+			LocationId: append([]uint64(nil), locations...),
+			Value:      make([]int64, len(b.profile.SampleType)),
+			Label:      labels,
+		}
+		b.samples[b.key.String()] = s
+		b.profile.Sample = append(b.profile.Sample, s)
+	}
+	for i, v := range values {
+		s.Value[i] += v
+	}
+}
+
+func (b *jsonProfileBuilder) build() (*profilev1.Profile, error) {
+	p := b.profile
+	if len(p.Sample) == 0 {
+		return nil, fmt.Errorf("no samples found")
+	}
+	p.StringTable = make([]string, len(b.strings))
+	for s, i := range b.strings {
+		p.StringTable[i] = s
+	}
+	return p, nil
+}
@@ -0,0 +1,195 @@
+package pprof
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	profilev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
+)
+
+func fromJSONFile(t *testing.T, path string) *profilev1.Profile {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.True(t, IsJSON(data))
+	p, err := FromJSON(data)
+	require.NoError(t, err)
+	return p
+}
+
+func sampleTypes(p *profilev1.Profile) []string {
+	types := make([]string, len(p.SampleType))
+	for i, st := range p.SampleType {
+		types[i] = p.StringTable[st.Type] + "/" + p.StringTable[st.Unit]
+	}
+	return types
+}
+
+// lines returns the file and the line of the functions.
+func lines(p *profilev1.Profile) map[string]string {
+	m := make(map[string]string)
+	for _, loc := range p.Location {
+		for _, l := range loc.Line {
+			fn := p.Function[l.FunctionId-1]
+			m[p.StringTable[fn.Name]] = p.StringTable[fn.Filename] + ":" + strconv.FormatInt(l.Line, 10)
+		}
+	}
+	return m
+}
+
+func TestParseChromeCPUProfile(t *testing.T) {
+	p := fromJSONFile(t, "testdata/chrome/node.cpuprofile")
+	require.Equal(t, []string{"samples/count", "cpu/nanoseconds"}, sampleTypes(p))
+	require.Equal(t, int64(7000000), p.DurationNanos)
+	require.Equal(t, []string{
+		"{} (anonymous);main [1 2000000]",
+		"{} (anonymous);main;fib [2 2500000]",
+		"{} (anonymous);main;readFileSync [1 400000]",
+		"{} (garbage collector) [1 1000000]",
+		"{} (program) [1 1000000]",
+	}, profileSamples(p))
+	require.Equal(t, map[string]string{
+		"(anonymous)":         "file:///app/index.js:1",
+		"main":                "file:///app/index.js:10",
+		"fib":                 "file:///app/index.js:2",
+		"readFileSync":        "node:fs:452",
+		"(program)":           ":0",
+		"(garbage collector)": ":0",
+	}, lines(p))
+}
+
+func TestParseSpeedscope(t *testing.T) {
+	p := fromJSONFile(t, "testdata/speedscope/sampled.speedscope.json")
+	require.Equal(t, []string{"cpu/nanoseconds"}, sampleTypes(p))
+	require.Equal(t, int64(40000000), p.DurationNanos)
+	require.Equal(t, []string{
+		"{thread=MainThread} <module>;main [5000000]",
+		"{thread=MainThread} <module>;main;sleep [15000000]",
+		"{thread=MainThread} <module>;main;work [20000000]",
+		"{thread=Thread-1} sleep [20000000]",
+	}, profileSamples(p))
+	require.Equal(t, "time.py:42", lines(p)["sleep"])
+
+	p = fromJSONFile(t, "testdata/speedscope/evented.speedscope.json")
+	require.Equal(t, []string{"wall/nanoseconds"}, sampleTypes(p))
+	require.Equal(t, int64(100000), p.DurationNanos)
+	require.Equal(t, []string{
+		"{} a [30000]",
+		"{} a;b [20000]",
+		"{} a;b;c [30000]",
+		"{} a;c [20000]",
+	}, profileSamples(p))
+}
+
+func TestFromJSON_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		err   string
+	}{
+		{`{}`, "unknown JSON profile format"},
+		{`{"nodes":[]}`, "no samples found"},
+		{`{"nodes":[{"id":1,"callFrame":{"functionName":"a"}}],"samples":[1],"timeDeltas":[]}`, "the profile has 1 samples and 0 time deltas"},
+		{`{"nodes":[{"id":1,"callFrame":{"functionName":"a"},"parent":2},{"id":2,"callFrame":{"functionName":"b"},"parent":1}],"samples":[1],"timeDeltas":[1]}`, "the node 1 is part of a cycle"},
+		{`{"$schema":"https://www.speedscope.app/file-format-schema.json","profiles":[{"type":"sampled","unit":"none","samples":[[0]]}]}`, "invalid frame 0"},
+		{`{"$schema":"https://www.speedscope.app/file-format-schema.json","profiles":[{"type":"foo","unit":"none"}]}`, `unknown profile type "foo"`},
+		{`{"$schema":"https://www.speedscope.app/file-format-schema.json","profiles":[{"type":"sampled","unit":"furlongs"}]}`, `unknown unit "furlongs"`},
+		{`{"$schema":"https://www.speedscope.app/file-format-schema.json","shared":{"frames":[{"name":"a"}]},"profiles":[{"type":"evented","name":"x","unit":"none","events":[{"type":"C","frame":0,"at":1}]}]}`, `the profile "x" closes the frame 0 which is not open`},
+	} {
+		_, err := FromJSON([]byte(tc.input))
+		require.EqualError(t, err, tc.err, tc.input)
+	}
+}
+
+func TestRawFromBytes_JSON(t *testing.T) {
+	data, err := os.ReadFile("testdata/chrome/node.cpuprofile")
+	require.NoError(t, err)
+	p, err := RawFromBytes(data)
+	require.NoError(t, err)
+	defer p.Close()
+	require.Len(t, p.Sample, 5)
+}
+
+func TestMarshalSpeedscope(t *testing.T) {
+	p := openPerfScript(t, "testdata/perf-script/no-callchain.txt")
+	data, err := MarshalSpeedscope(p, "perf")
+	require.NoError(t, err)
+
+	// The sample types are profiles of the file.
+	actual, err := FromJSON(data)
+	require.NoError(t, err)
+	require.Equal(t, []string{"samples/count"}, sampleTypes(actual))
+	require.Equal(t, []string{
+		"{thread=cycles} hogcpu [601125]",
+		"{thread=cycles} native_write_msr [16]",
+		"{thread=instructions} hogcpu [412300]",
+	}, profileSamples(actual))
+
+	p = fromJSONFile(t, "testdata/chrome/node.cpuprofile")
+	data, err = MarshalSpeedscope(p, "node")
+	require.NoError(t, err)
+	actual, err = FromJSON(data)
+	require.NoError(t, err)
+	require.Equal(t, []string{"samples/count", "cpu/nanoseconds"}, sampleTypes(actual))
+	require.Equal(t, []string{
+		"{thread=cpu} (anonymous);main [0 2000000]",
+		"{thread=cpu} (anonymous);main;fib [0 2500000]",
+		"{thread=cpu} (anonymous);main;readFileSync [0 400000]",
+		"{thread=cpu} (garbage collector) [0 1000000]",
+		"{thread=cpu} (program) [0 1000000]",
+		"{thread=samples} (anonymous);main [1 0]",
+		"{thread=samples} (anonymous);main;fib [2 0]",
+		"{thread=samples} (anonymous);main;readFileSync [1 0]",
+		"{thread=samples} (garbage collector) [1 0]",
+		"{thread=samples} (program) [1 0]",
+	}, profileSamples(actual))
+	require.Equal(t, lines(p), lines(actual))
+}
+
+func TestMarshalFirefoxProfile(t *testing.T) {
+	p := fromJSONFile(t, "testdata/chrome/node.cpuprofile")
+	data, err := MarshalFirefoxProfile(p)
+	require.NoError(t, err)
+
+	var f firefoxProfile
+	require.NoError(t, json.Unmarshal(data, &f))
+	require.Equal(t, firefoxProcessedProfileVersion, f.Meta.PreprocessedProfileVersion)
+	require.Len(t, f.Threads, 2)
+
+	samples := func(th firefoxThread) []string {
+		var res []string
+		for i, id := range th.Samples.Stack {
+			var frames []string
+			for s := &id; s != nil; s = th.StackTable.Prefix[*s] {
+				fn := th.FuncTable.Name[th.FrameTable.Func[th.StackTable.Frame[*s]]]
+				frames = append([]string{th.StringArray[fn]}, frames...)
+			}
+			res = append(res, fmt.Sprintf("%s %v", strings.Join(frames, ";"), th.Samples.Weight[i]))
+		}
+		sort.Strings(res)
+		return res
+	}
+	require.Equal(t, "samples (count)", f.Threads[0].Name)
+	require.Equal(t, "samples", f.Threads[0].Samples.WeightType)
+	require.Equal(t, []string{
+		"(anonymous);main 1",
+		"(anonymous);main;fib 2",
+		"(anonymous);main;readFileSync 1",
+		"(garbage collector) 1",
+		"(program) 1",
+	}, samples(f.Threads[0]))
+	require.Equal(t, "cpu (nanoseconds)", f.Threads[1].Name)
+	require.Equal(t, "tracing-ms", f.Threads[1].Samples.WeightType)
+	require.Equal(t, []string{
+		"(anonymous);main 2",
+		"(anonymous);main;fib 2.5",
+		"(anonymous);main;readFileSync 0.4",
+		"(garbage collector) 1",
+		"(program) 1",
+	}, samples(f.Threads[1]))
+}
@@ -0,0 +1,299 @@
+package pprof
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	profilev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
+)
+
+var (
+	// perfScriptHeaderRe matches the first line of a sample of the perf
+	// script output, e.g.
+	//
+	//	swapper     0 [000] 12345.678901:     250000 cpu-clock:pppH:
+	//	java 1234/1240 [003] 12345.678901:          1 cycles:u:  7f3a5c8 foo+0x1 (/tmp/bar)
+	//
+	// The pid, the cpu, the time and the period are optional.
+	perfScriptHeaderRe = regexp.MustCompile(`^\s*(\S.*?)\s+(\d+)(?:/(\d+))?\s+(?:\[\d+\]\s+)?(?:\d+\.\d+:\s+)?(?:(\d+)\s+)?(\S+):(?:\s+(.*))?$`)
+	// perfScriptFrameRe matches a frame of the call chain of a sample, e.g.
+	//
+	//	ffffffff81a5c5d1 native_safe_halt+0x11 ([kernel.kallsyms])
+	perfScriptFrameRe = regexp.MustCompile(`^([0-9a-f]+)\s+(.+?)(?:\s+\(([^()]*)\))?$`)
+	// perfScriptOffsetRe matches the offset of the address in the symbol.
+	perfScriptOffsetRe = regexp.MustCompile(`\+0x[0-9a-f]+$`)
+	// perfScriptModifiersRe matches the modifiers of an event, e.g. "pppH" in
+	// "cpu-clock:pppH".
+	perfScriptModifiersRe = regexp.MustCompile(`:[ukhpPGHISDW]+$`)
+)
+
+const (
+	// PerfScriptProfileName is the name of the profiles of perf script.
+	PerfScriptProfileName = "perf"
+
+	perfScriptUnknown = "[unknown]"
+)
+
+// ParsePerfScript parses the text output of perf script, including the call
+// chains of the samples recorded with perf record -g.
+//
+// Every event of the output becomes a sample type named after the event, the
+// value of a sample is its period, or 1 if the output has no period. The
+// command and the thread ids of a sample are its comm, pid and tid labels:
+// the default output of perf script only has the tid. The shared objects
+// are the mappings of the profile, and the frames without a symbol are
+// locations with an address only.
+func ParsePerfScript(r io.Reader) (*profilev1.Profile, error) {
+	b := newPerfScriptBuilder()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	var (
+		n      int
+		sample *perfScriptSample
+	)
+	for scanner.Scan() {
+		n++
+		line := scanner.Text()
+		switch trimmed := strings.TrimSpace(line); {
+		case trimmed == "":
+			b.add(sample)
+			sample = nil
+
+		case strings.HasPrefix(line, "#"):
+			// The header of perf script --header.
+
+		case line[0] == '\t':
+			// The sample lines are only indented with spaces, the commands
+			// being aligned to the right.
+			if sample == nil {
+				return nil, fmt.Errorf("line %d: frame outside of a sample", n)
+			}
+			f, err := parsePerfScriptFrame(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			sample.frames = append(sample.frames, f)
+
+		default:
+			b.add(sample)
+			var err error
+			if sample, err = parsePerfScriptHeader(line); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	b.add(sample)
+	if len(b.profile.Sample) == 0 {
+		return nil, fmt.Errorf("no samples found")
+	}
+	return b.build(), nil
+}
+
+type perfScriptSample struct {
+	comm     string
+	pid, tid int64
+	event    string
+	value    int64
+	frames   []perfScriptFrame
+}
+
+type perfScriptFrame struct {
+	address uint64
+	symbol  string
+	dso     string
+}
+
+func parsePerfScriptHeader(line string) (*perfScriptSample, error) {
+	m := perfScriptHeaderRe.FindStringSubmatch(line)
+	if m == nil {
+		return nil, fmt.Errorf("invalid sample %q", line)
+	}
+	s := &perfScriptSample{
+		comm:  m[1],
+		event: perfScriptModifiersRe.ReplaceAllString(m[5], ""),
+		pid:   -1,
+		value: 1,
+	}
+	id, _ := strconv.ParseInt(m[2], 10, 64)
+	if m[3] != "" {
+		s.pid = id
+		s.tid, _ = strconv.ParseInt(m[3], 10, 64)
+	} else {
+		s.tid = id
+	}
+	if m[4] != "" {
+		s.value, _ = strconv.ParseInt(m[4], 10, 64)
+	}
+	// Without call chains, the frame is part of the sample line. The fields
+	// of trace points are ignored.
+	if f, err := parsePerfScriptFrame(m[6]); err == nil && f.address != 0 {
+		s.frames = append(s.frames, f)
+	}
+	return s, nil
+}
+
+func parsePerfScriptFrame(s string) (perfScriptFrame, error) {
+	m := perfScriptFrameRe.FindStringSubmatch(s)
+	if m == nil {
+		return perfScriptFrame{}, fmt.Errorf("invalid frame %q", s)
+	}
+	address, err := strconv.ParseUint(m[1], 16, 64)
+	if err != nil {
+		return perfScriptFrame{}, fmt.Errorf("invalid frame address %q", m[1])
+	}
+	f := perfScriptFrame{
+		address: address,
+		symbol:  perfScriptOffsetRe.ReplaceAllString(m[2], ""),
+		dso:     m[3],
+	}
+	if f.symbol == perfScriptUnknown {
+		f.symbol = ""
+	}
+	if f.dso == perfScriptUnknown {
+		f.dso = ""
+	}
+	return f, nil
+}
+
+type perfScriptLocationKey struct {
+	dso     string
+	address uint64
+	symbol  string
+}
+
+type perfScriptBuilder struct {
+	profile    *profilev1.Profile
+	strings    map[string]int
+	events     map[string]int
+	mappings   map[string]*profilev1.Mapping
+	functions  map[string]uint64
+	locations  map[perfScriptLocationKey]uint64
+	firstValue int64
+}
+
+func newPerfScriptBuilder() *perfScriptBuilder {
+	return &perfScriptBuilder{
+		profile:   &profilev1.Profile{},
+		strings:   map[string]int{"": 0},
+		events:    make(map[string]int),
+		mappings:  make(map[string]*profilev1.Mapping),
+		functions: make(map[string]uint64),
+		locations: make(map[perfScriptLocationKey]uint64),
+	}
+}
+
+func (b *perfScriptBuilder) string(s string) int64 {
+	return addString(b.strings, s)
+}
+
+func (b *perfScriptBuilder) add(s *perfScriptSample) {
+	if s == nil || len(s.frames) == 0 || s.value == 0 {
+		return
+	}
+	p := b.profile
+	event, ok := b.events[s.event]
+	if !ok {
+		event = len(p.SampleType)
+		b.events[s.event] = event
+		p.SampleType = append(p.SampleType, &profilev1.ValueType{
+			Type: b.string(s.event),
+			Unit: b.string(perfScriptEventUnit(s.event)),
+		})
+		for _, sample := range p.Sample {
+			sample.Value = append(sample.Value, 0)
+		}
+	}
+	if len(p.Sample) == 0 {
+		b.firstValue = s.value
+	}
+	sample := &profilev1.Sample{
+		LocationId: make([]uint64, len(s.frames)),
+		Value:      make([]int64, len(p.SampleType)),
+		Label:      []*profilev1.Label{{Key: b.string("comm"), Str: b.string(s.comm)}},
+	}
+	sample.Value[event] = s.value
+	if s.pid >= 0 {
+		sample.Label = append(sample.Label, &profilev1.Label{Key: b.string("pid"), Num: s.pid})
+	}
+	sample.Label = append(sample.Label, &profilev1.Label{Key: b.string("tid"), Num: s.tid})
+	// The frames of perf script are ordered from the leaf, as the locations.
+	for i, f := range s.frames {
+		sample.LocationId[i] = b.location(f)
+	}
+	p.Sample = append(p.Sample, sample)
+}
+
+func (b *perfScriptBuilder) location(f perfScriptFrame) uint64 {
+	k := perfScriptLocationKey{dso: f.dso, address: f.address, symbol: f.symbol}
+	if id, ok := b.locations[k]; ok {
+		return id
+	}
+	p := b.profile
+	m, ok := b.mappings[f.dso]
+	if !ok {
+		m = &profilev1.Mapping{
+			Id:           uint64(len(p.Mapping) + 1),
+			Filename:     b.string(f.dso),
+			HasFunctions: true,
+		}
+		b.mappings[f.dso] = m
+		p.Mapping = append(p.Mapping, m)
+	}
+	loc := &profilev1.Location{
+		Id:        uint64(len(p.Location) + 1),
+		MappingId: m.Id,
+		Address:   f.address,
+	}
+	if f.symbol != "" {
+		loc.Line = []*profilev1.Line{{FunctionId: b.function(f.symbol)}}
+	} else {
+		// The addresses are kept for the mappings with unknown symbols.
+		m.HasFunctions = false
+	}
+	b.locations[k] = loc.Id
+	p.Location = append(p.Location, loc)
+	return loc.Id
+}
+
+func (b *perfScriptBuilder) function(name string) uint64 {
+	if id, ok := b.functions[name]; ok {
+		return id
+	}
+	p := b.profile
+	fn := &profilev1.Function{
+		Id:         uint64(len(p.Function) + 1),
+		Name:       b.string(name),
+		SystemName: b.string(name),
+	}
+	b.functions[name] = fn.Id
+	p.Function = append(p.Function, fn)
+	return fn.Id
+}
+
+func (b *perfScriptBuilder) build() *profilev1.Profile {
+	p := b.profile
+	p.StringTable = make([]string, len(b.strings))
+	for s, i := range b.strings {
+		p.StringTable[i] = s
+	}
+	p.PeriodType = &profilev1.ValueType{Type: p.SampleType[0].Type, Unit: p.SampleType[0].Unit}
+	p.Period = b.firstValue
+	return p
+}
+
+// perfScriptEventUnit returns the unit of the period of the event.
+func perfScriptEventUnit(event string) string {
+	switch event {
+	case "cpu-clock", "task-clock":
+		return "nanoseconds"
+	default:
+		return "count"
+	}
+}
@@ -0,0 +1,122 @@
+package pprof
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	profilev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
+)
+
+func openPerfScript(t *testing.T, path string) *profilev1.Profile {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	p, err := ParsePerfScript(f)
+	require.NoError(t, err)
+	return p
+}
+
+// profileSamples returns the labels, the stack and the values of the
+// samples of the profile, the frames being ordered from the root.
+func profileSamples(p *profilev1.Profile) []string {
+	samples := make([]string, 0, len(p.Sample))
+	for _, s := range p.Sample {
+		labels := make([]string, 0, len(s.Label))
+		for _, l := range s.Label {
+			if l.Str != 0 {
+				labels = append(labels, p.StringTable[l.Key]+"="+p.StringTable[l.Str])
+			} else {
+				labels = append(labels, fmt.Sprintf("%s=%d", p.StringTable[l.Key], l.Num))
+			}
+		}
+		frames := make([]string, 0, len(s.LocationId))
+		for i := len(s.LocationId) - 1; i >= 0; i-- {
+			loc := p.Location[s.LocationId[i]-1]
+			if len(loc.Line) > 0 {
+				frames = append(frames, p.StringTable[p.Function[loc.Line[0].FunctionId-1].Name])
+			} else {
+				frames = append(frames, fmt.Sprintf("0x%x", loc.Address))
+			}
+		}
+		samples = append(samples, fmt.Sprintf("{%s} %s %v", strings.Join(labels, ","), strings.Join(frames, ";"), s.Value))
+	}
+	sort.Strings(samples)
+	return samples
+}
+
+func TestParsePerfScript(t *testing.T) {
+	p := openPerfScript(t, "testdata/perf-script/cpu-clock.txt")
+	require.Len(t, p.SampleType, 1)
+	require.Equal(t, "cpu-clock", p.StringTable[p.SampleType[0].Type])
+	require.Equal(t, "nanoseconds", p.StringTable[p.SampleType[0].Unit])
+	require.Equal(t, "cpu-clock", p.StringTable[p.PeriodType.Type])
+	require.Equal(t, int64(10101010), p.Period)
+	require.Equal(t, []string{
+		"{comm=Web Content,pid=4211,tid=4211} __libc_start_call_main;js::RunScript;std::vector<int, std::allocator<int> >::push_back [10101010]",
+		"{comm=Web Content,pid=4211,tid=4215} __libc_start_call_main;js::RunScript [10101010]",
+		"{comm=java,pid=1234,tid=1240} 0x0;JavaMain;0x7f3a5c801234;0x7f3a5c801234 [10101010]",
+		"{comm=swapper,pid=0,tid=0} start_kernel;cpu_startup_entry;do_idle;default_idle;native_safe_halt [10101010]",
+		"{comm=swapper,pid=0,tid=0} start_kernel;cpu_startup_entry;do_idle;default_idle;native_safe_halt [10101010]",
+	}, profileSamples(p))
+
+	mappings := make(map[string]bool)
+	for _, m := range p.Mapping {
+		mappings[p.StringTable[m.Filename]] = m.HasFunctions
+	}
+	require.Equal(t, map[string]bool{
+		"[kernel.kallsyms]":                                true,
+		"/usr/lib/firefox/libxul.so":                       true,
+		"/usr/lib/x86_64-linux-gnu/libc.so.6":              true,
+		"/tmp/perf-1234.map":                               false,
+		"/usr/lib/jvm/java-17-openjdk-amd64/lib/libjli.so": true,
+		"": false,
+	}, mappings)
+
+	// The profile is valid once normalized.
+	profile := &Profile{Profile: p, hasher: StacktracesHasher{}}
+	profile.Normalize()
+	require.Len(t, profile.Sample, 4)
+	var total int64
+	for _, s := range profile.Sample {
+		total += s.Value[0]
+	}
+	require.Equal(t, int64(5*10101010), total)
+}
+
+func TestParsePerfScript_NoCallChain(t *testing.T) {
+	p := openPerfScript(t, "testdata/perf-script/no-callchain.txt")
+	require.Len(t, p.SampleType, 2)
+	require.Equal(t, "cycles", p.StringTable[p.SampleType[0].Type])
+	require.Equal(t, "count", p.StringTable[p.SampleType[0].Unit])
+	require.Equal(t, "instructions", p.StringTable[p.SampleType[1].Type])
+	require.Equal(t, []string{
+		"{comm=perf,tid=7375} native_write_msr [1 0]",
+		"{comm=perf,tid=7375} native_write_msr [15 0]",
+		"{comm=stress,tid=7380} hogcpu [0 412300]",
+		"{comm=stress,tid=7380} hogcpu [300125 0]",
+		"{comm=stress,tid=7380} hogcpu [301000 0]",
+	}, profileSamples(p))
+	// The samples at different addresses of the same function.
+	require.Len(t, p.Location, 3)
+	require.Len(t, p.Function, 2)
+}
+
+func TestParsePerfScript_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		err   string
+	}{
+		{"", "no samples found"},
+		{"\tffffffff81e4c8bb native_safe_halt+0xb ([kernel.kallsyms])", "line 1: frame outside of a sample"},
+		{"swapper 0 [000] 1.5: 1 cpu-clock:\n\tfoo", `line 2: invalid frame "foo"`},
+		{"swapper [000]", `line 1: invalid sample "swapper [000]"`},
+	} {
+		_, err := ParsePerfScript(strings.NewReader(tc.input))
+		require.EqualError(t, err, tc.err)
+	}
+}
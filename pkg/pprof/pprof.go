@@ -98,6 +98,16 @@ func RawFromBytes(input []byte) (_ *Profile, err error) {
 		return nil, errors.Wrap(err, "copy to buffer")
 	}
 
+	// The profiles in the JSON formats are converted.
+	if IsJSON(buf.Bytes()) {
+		p, err := FromJSON(buf.Bytes())
+		if err != nil {
+			return nil, errors.Wrap(err, "convert JSON profile")
+		}
+		pbp.ReturnToVTPool()
+		return &Profile{Profile: p, buf: buf}, nil
+	}
+
 	if err = pbp.UnmarshalVT(buf.Bytes()); err != nil {
 		return nil, err
 	}
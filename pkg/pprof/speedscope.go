@@ -0,0 +1,302 @@
+package pprof
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	profilev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
+)
+
+const speedscopeSchema = "https://www.speedscope.app/file-format-schema.json"
+
+// speedscopeFile is the Speedscope file format, see
+// https://github.com/jlfwong/speedscope/blob/main/src/lib/file-format-spec.ts.
+type speedscopeFile struct {
+	Schema string `json:"$schema"`
+	Shared struct {
+		Frames []speedscopeFrame `json:"frames"`
+	} `json:"shared"`
+	Profiles           []speedscopeProfile `json:"profiles"`
+	Name               string              `json:"name,omitempty"`
+	ActiveProfileIndex int                 `json:"activeProfileIndex"`
+	Exporter           string              `json:"exporter,omitempty"`
+}
+
+type speedscopeFrame struct {
+	Name string `json:"name"`
+	File string `json:"file,omitempty"`
+	Line int64  `json:"line,omitempty"`
+}
+
+type speedscopeProfile struct {
+	Type       string  `json:"type"`
+	Name       string  `json:"name"`
+	Unit       string  `json:"unit"`
+	StartValue float64 `json:"startValue"`
+	EndValue   float64 `json:"endValue"`
+	// The events of the evented profiles.
+	Events []struct {
+		Type  string  `json:"type"`
+		At    float64 `json:"at"`
+		Frame int     `json:"frame"`
+	} `json:"events,omitempty"`
+	// The samples of the sampled profiles, ordered from the root.
+	Samples [][]int   `json:"samples"`
+	Weights []float64 `json:"weights"`
+}
+
+const (
+	speedscopeEvented = "evented"
+	speedscopeSampled = "sampled"
+)
+
+// ParseSpeedscope converts a Speedscope file into a pprof profile.
+//
+// The sample type depends on the unit and on the type of the profiles: the
+// time of the sampled profiles is the cpu sample type and the time of the
+// evented profiles is the wall sample type, in nanoseconds. The other units
+// are the alloc_space (bytes) and samples (none) sample types. The time of
+// an evented profile is attributed to the stacks open between two events.
+// When the file has several profiles, the name of the profile of a sample
+// is its thread label.
+func ParseSpeedscope(data []byte) (*profilev1.Profile, error) {
+	var f speedscopeFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if f.Schema != speedscopeSchema {
+		return nil, fmt.Errorf("unknown speedscope schema %q", f.Schema)
+	}
+
+	b := newJSONProfileBuilder()
+	locations := make([]uint64, len(f.Shared.Frames))
+	for i, fr := range f.Shared.Frames {
+		locations[i] = b.location(fr.Name, fr.File, fr.Line)
+	}
+	frame := func(i int) (uint64, error) {
+		if i < 0 || i >= len(locations) {
+			return 0, fmt.Errorf("invalid frame %d", i)
+		}
+		return locations[i], nil
+	}
+
+	var duration float64
+	stack := make([]uint64, 0, 64)
+	for _, sp := range f.Profiles {
+		typ, unit, scale, err := speedscopeSampleType(sp)
+		if err != nil {
+			return nil, err
+		}
+		if unit == "nanoseconds" {
+			duration = math.Max(duration, (sp.EndValue-sp.StartValue)*scale)
+		}
+		var labels []*profilev1.Label
+		if len(f.Profiles) > 1 && sp.Name != "" {
+			labels = []*profilev1.Label{{Key: b.string("thread"), Str: b.string(sp.Name)}}
+		}
+		st := b.sampleType(typ, unit)
+		values := make([]int64, st+1)
+		add := func(v float64) {
+			if v = math.Round(v * scale); v > 0 {
+				values[st] = int64(v)
+				b.add(stack, labels, values...)
+			}
+		}
+
+		switch sp.Type {
+		case speedscopeSampled:
+			if sp.Weights != nil && len(sp.Weights) != len(sp.Samples) {
+				return nil, fmt.Errorf("the profile %q has %d samples and %d weights", sp.Name, len(sp.Samples), len(sp.Weights))
+			}
+			for i, s := range sp.Samples {
+				// The locations of a sample are ordered from the leaf.
+				stack = stack[:0]
+				for j := len(s) - 1; j >= 0; j-- {
+					id, err := frame(s[j])
+					if err != nil {
+						return nil, err
+					}
+					stack = append(stack, id)
+				}
+				if len(stack) == 0 {
+					continue
+				}
+				w := 1.0
+				if sp.Weights != nil {
+					w = sp.Weights[i]
+				}
+				add(w)
+			}
+
+		case speedscopeEvented:
+			// The open frames, from the root.
+			var open []uint64
+			last := sp.StartValue
+			for _, e := range sp.Events {
+				if len(open) > 0 && e.At > last {
+					stack = stack[:0]
+					for j := len(open) - 1; j >= 0; j-- {
+						stack = append(stack, open[j])
+					}
+					add(e.At - last)
+				}
+				last = e.At
+				id, err := frame(e.Frame)
+				if err != nil {
+					return nil, err
+				}
+				switch e.Type {
+				case "O":
+					open = append(open, id)
+				case "C":
+					if len(open) == 0 || open[len(open)-1] != id {
+						return nil, fmt.Errorf("the profile %q closes the frame %d which is not open", sp.Name, e.Frame)
+					}
+					open = open[:len(open)-1]
+				default:
+					return nil, fmt.Errorf("unknown event type %q", e.Type)
+				}
+			}
+
+		default:
+			return nil, fmt.Errorf("unknown profile type %q", sp.Type)
+		}
+	}
+
+	p, err := b.build()
+	if err != nil {
+		return nil, err
+	}
+	p.DurationNanos = int64(duration)
+	p.PeriodType = &profilev1.ValueType{Type: p.SampleType[0].Type, Unit: p.SampleType[0].Unit}
+	p.Period = 1
+	return p, nil
+}
+
+// speedscopeSampleType returns the sample type of the values of the profile
+// and the factor to convert them into the unit of the sample type.
+func speedscopeSampleType(p speedscopeProfile) (typ, unit string, scale float64, err error) {
+	typ, unit, scale = "cpu", "nanoseconds", 1
+	if p.Type == speedscopeEvented {
+		typ = "wall"
+	}
+	switch p.Unit {
+	case "nanoseconds":
+	case "microseconds":
+		scale = 1e3
+	case "milliseconds":
+		scale = 1e6
+	case "seconds":
+		scale = 1e9
+	case "bytes":
+		typ, unit = "alloc_space", "bytes"
+	case "none", "":
+		typ, unit = "samples", "count"
+	default:
+		return "", "", 0, fmt.Errorf("unknown unit %q", p.Unit)
+	}
+	return typ, unit, scale, nil
+}
+
+// MarshalSpeedscope converts the pprof profile into a Speedscope file with a
+// sampled profile per sample type. The inlined functions are separate frames.
+func MarshalSpeedscope(p *profilev1.Profile, name string) ([]byte, error) {
+	f := speedscopeFile{
+		Schema:   speedscopeSchema,
+		Name:     name,
+		Exporter: "phlare",
+	}
+	frames := make(map[speedscopeFrame]int)
+	stacks := newProfileStacks(p)
+	for i, st := range p.SampleType {
+		sp := speedscopeProfile{
+			Type:    speedscopeSampled,
+			Name:    p.StringTable[st.Type],
+			Unit:    speedscopeUnit(p.StringTable[st.Unit]),
+			Samples: [][]int{},
+			Weights: []float64{},
+		}
+		for _, s := range p.Sample {
+			if i >= len(s.Value) || s.Value[i] == 0 {
+				continue
+			}
+			stack := stacks.stack(s)
+			sample := make([]int, len(stack))
+			for j, fr := range stack {
+				id, ok := frames[fr]
+				if !ok {
+					id = len(f.Shared.Frames)
+					frames[fr] = id
+					f.Shared.Frames = append(f.Shared.Frames, fr)
+				}
+				sample[j] = id
+			}
+			sp.Samples = append(sp.Samples, sample)
+			sp.Weights = append(sp.Weights, float64(s.Value[i]))
+			sp.EndValue += float64(s.Value[i])
+		}
+		f.Profiles = append(f.Profiles, sp)
+	}
+	return json.Marshal(f)
+}
+
+// speedscopeUnit returns the Speedscope unit of the pprof unit.
+func speedscopeUnit(unit string) string {
+	switch unit {
+	case "nanoseconds", "microseconds", "milliseconds", "seconds", "bytes":
+		return unit
+	default:
+		return "none"
+	}
+}
+
+// profileStacks resolves the stacks of the samples of a pprof profile.
+type profileStacks struct {
+	p         *profilev1.Profile
+	functions map[uint64]*profilev1.Function
+	locations map[uint64]*profilev1.Location
+	frames    []speedscopeFrame
+}
+
+func newProfileStacks(p *profilev1.Profile) *profileStacks {
+	s := &profileStacks{
+		p:         p,
+		functions: make(map[uint64]*profilev1.Function, len(p.Function)),
+		locations: make(map[uint64]*profilev1.Location, len(p.Location)),
+	}
+	for _, fn := range p.Function {
+		s.functions[fn.Id] = fn
+	}
+	for _, loc := range p.Location {
+		s.locations[loc.Id] = loc
+	}
+	return s
+}
+
+// stack returns the frames of the sample ordered from the root, the slice
+// is only valid until the next call. The locations without lines are named
+// after their address.
+func (s *profileStacks) stack(sample *profilev1.Sample) []speedscopeFrame {
+	s.frames = s.frames[:0]
+	for i := len(sample.LocationId) - 1; i >= 0; i-- {
+		loc, ok := s.locations[sample.LocationId[i]]
+		if !ok {
+			continue
+		}
+		if len(loc.Line) == 0 {
+			s.frames = append(s.frames, speedscopeFrame{Name: fmt.Sprintf("0x%x", loc.Address)})
+			continue
+		}
+		// The last line is the caller the previous ones are inlined into.
+		for j := len(loc.Line) - 1; j >= 0; j-- {
+			fr := speedscopeFrame{Line: loc.Line[j].Line}
+			if fn, ok := s.functions[loc.Line[j].FunctionId]; ok {
+				fr.Name = s.p.StringTable[fn.Name]
+				fr.File = s.p.StringTable[fn.Filename]
+			}
+			s.frames = append(s.frames, fr)
+		}
+	}
+	return s.frames
+}
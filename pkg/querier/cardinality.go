@@ -0,0 +1,103 @@
+package querier
+
+import (
+	"context"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/grafana/dskit/ring"
+	"github.com/opentracing/opentracing-go"
+	"golang.org/x/sync/errgroup"
+
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/tenant"
+)
+
+// Cardinality analyses the series of the tenant known by the ingesters and
+// the store-gateways, to find out the labels contributing the most series.
+// Each instance computes the cardinality of its own series, the querier only
+// merges the aggregates: the result is an estimate.
+func (q *Querier) Cardinality(ctx context.Context, req *connect.Request[typesv1.CardinalityRequest]) (*connect.Response[typesv1.CardinalityResponse], error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Cardinality")
+	defer sp.Finish()
+	if err := phlaremodel.ValidateCardinalityRequest(req.Msg); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	// The series of the ingesters and of the store-gateways overlap, both are
+	// queried for the whole time range and the highest estimate is kept.
+	var ingesterResponse, storeGatewayResponse *typesv1.CardinalityResponse
+	g, gCtx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		ingesterResponse, err = q.cardinalityFromIngesters(gCtx, req.Msg)
+		return err
+	})
+	if q.storeGatewayQuerier != nil {
+		g.Go(func() error {
+			var err error
+			storeGatewayResponse, err = q.cardinalityFromStoreGateway(gCtx, req.Msg)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(phlaremodel.MaxCardinalityResponses(req.Msg.Limit, ingesterResponse, storeGatewayResponse)), nil
+}
+
+func (q *Querier) cardinalityFromIngesters(ctx context.Context, req *typesv1.CardinalityRequest) (*typesv1.CardinalityResponse, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Cardinality Ingesters")
+	defer sp.Finish()
+	responses, err := forAllIngesters(ctx, q.ingesterQuerier, func(ctx context.Context, ic IngesterQueryClient) (*typesv1.CardinalityResponse, error) {
+		res, err := ic.Cardinality(ctx, connect.NewRequest(req))
+		if err != nil {
+			return nil, err
+		}
+		return res.Msg, nil
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return sumCardinalityResponses(req, q.ingesterQuerier.ring, responses), nil
+}
+
+func (q *Querier) cardinalityFromStoreGateway(ctx context.Context, req *typesv1.CardinalityRequest) (*typesv1.CardinalityResponse, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "Cardinality StoreGateway")
+	defer sp.Finish()
+	tenantID, err := tenant.ExtractTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	responses, err := forAllStoreGateways(ctx, tenantID, q.storeGatewayQuerier, func(ctx context.Context, sc StoreGatewayQueryClient) (*typesv1.CardinalityResponse, error) {
+		res, err := sc.Cardinality(ctx, connect.NewRequest(req))
+		if err != nil {
+			return nil, err
+		}
+		return res.Msg, nil
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	subring := GetShuffleShardingSubring(q.storeGatewayQuerier.ring, tenantID, q.storeGatewayQuerier.limits)
+	return sumCardinalityResponses(req, subring, responses), nil
+}
+
+// sumCardinalityResponses merges the responses of the instances of the ring,
+// each series being held by min(replication factor, instances) of them.
+func sumCardinalityResponses(req *typesv1.CardinalityRequest, r ring.ReadRing, responses []ResponseFromReplica[*typesv1.CardinalityResponse]) *typesv1.CardinalityResponse {
+	results := make([]*typesv1.CardinalityResponse, 0, len(responses))
+	for _, resp := range responses {
+		results = append(results, resp.response)
+	}
+	replicas := 1.0
+	if instances := r.InstancesCount(); instances > 0 {
+		rf := r.ReplicationFactor()
+		if rf > instances {
+			rf = instances
+		}
+		// Only a quorum of the instances may respond.
+		replicas = float64(rf) * float64(len(responses)) / float64(instances)
+	}
+	return phlaremodel.SumCardinalityResponses(req.Limit, replicas, results...)
+}
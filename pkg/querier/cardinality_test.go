@@ -0,0 +1,72 @@
+package querier
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/ring/client"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+	"github.com/grafana/phlare/pkg/clientpool"
+	"github.com/grafana/phlare/pkg/tenant"
+	"github.com/grafana/phlare/pkg/testhelper"
+)
+
+func Test_Cardinality(t *testing.T) {
+	// Every ingester holds a replica of all the series, the sum of the
+	// responses of the quorum is divided by the number of replicas.
+	validated := mock.MatchedBy(func(req *connect.Request[typesv1.CardinalityRequest]) bool {
+		return req.Msg.Start == 1000 && req.Msg.End == 4999 && req.Msg.Limit == 10 && req.Msg.Step == 1000
+	})
+	querier, err := New(Config{
+		PoolConfig: clientpool.PoolConfig{ClientCleanupPeriod: 1 * time.Millisecond},
+	}, testhelper.NewMockRing([]ring.InstanceDesc{
+		{Addr: "1"},
+		{Addr: "2"},
+		{Addr: "3"},
+	}, 3), func(addr string) (client.PoolClient, error) {
+		q := newFakeQuerier()
+		q.On("Cardinality", mock.Anything, validated).Return(connect.NewResponse(&typesv1.CardinalityResponse{
+			Series: 3,
+			Labels: []*typesv1.LabelCardinality{
+				{Name: "job", Series: 3, Values: 2, TopValues: []*typesv1.LabelValueCardinality{{Value: "a", Series: 2}, {Value: "b", Series: 1}}},
+				{Name: "pod", Series: 3, Values: 3, TopValues: []*typesv1.LabelValueCardinality{{Value: "1", Series: 1}, {Value: "2", Series: 1}, {Value: "3", Series: 1}}},
+			},
+			Churn: []*typesv1.SeriesChurn{
+				{Timestamp: 1000, Active: 3, Created: 3, Ended: 0},
+				{Timestamp: 2000, Active: 3, Created: 0, Ended: 1},
+				{Timestamp: 3000, Active: 2, Created: 0, Ended: 0},
+				{Timestamp: 4000, Active: 2, Created: 0, Ended: 2},
+			},
+		}), nil)
+		return q, nil
+	}, nil, nil, log.NewLogfmtLogger(os.Stdout))
+	require.NoError(t, err)
+
+	ctx := tenant.InjectTenantID(context.Background(), "tenant")
+	res, err := querier.Cardinality(ctx, connect.NewRequest(&typesv1.CardinalityRequest{Start: 1000, End: 4999, Step: 1000}))
+	require.NoError(t, err)
+	require.Equal(t, &typesv1.CardinalityResponse{
+		Series: 3,
+		Labels: []*typesv1.LabelCardinality{
+			{Name: "job", Series: 3, Values: 2, TopValues: []*typesv1.LabelValueCardinality{{Value: "a", Series: 2}, {Value: "b", Series: 1}}},
+			{Name: "pod", Series: 3, Values: 3, TopValues: []*typesv1.LabelValueCardinality{{Value: "1", Series: 1}, {Value: "2", Series: 1}, {Value: "3", Series: 1}}},
+		},
+		Churn: []*typesv1.SeriesChurn{
+			{Timestamp: 1000, Active: 3, Created: 3, Ended: 0},
+			{Timestamp: 2000, Active: 3, Created: 0, Ended: 1},
+			{Timestamp: 3000, Active: 2, Created: 0, Ended: 0},
+			{Timestamp: 4000, Active: 2, Created: 0, Ended: 2},
+		},
+	}, res.Msg)
+
+	_, err = querier.Cardinality(ctx, connect.NewRequest(&typesv1.CardinalityRequest{Start: 2, End: 1}))
+	require.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+}
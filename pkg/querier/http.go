@@ -21,6 +21,8 @@ import (
 	"github.com/grafana/phlare/api/gen/proto/go/querier/v1/querierv1connect"
 	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
 	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/pprof"
+	"github.com/grafana/phlare/pkg/querier/partialresponse"
 	"github.com/grafana/phlare/pkg/querier/timeline"
 )
 
@@ -100,7 +102,7 @@ func (q *QueryHandlers) RenderDiff(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	res, err := q.client.Diff(req.Context(), connect.NewRequest(&querierv1.DiffRequest{
+	res, err := q.client.Diff(req.Context(), newRequest(req, &querierv1.DiffRequest{
 		Left:  leftSelectParams,
 		Right: rightSelectParams,
 	}))
@@ -108,6 +110,9 @@ func (q *QueryHandlers) RenderDiff(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	warnings := new(partialresponse.Warnings)
+	warnings.MergeHeader(res.Header())
+	warnings.SetHeader(w.Header())
 
 	w.Header().Add("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(phlaremodel.ExportDiffToFlamebearer(res.Msg.Flamegraph, leftProfileType)); err != nil {
@@ -126,13 +131,22 @@ func (q *QueryHandlers) Render(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	switch format := req.Form.Get("format"); format {
+	case "", RenderFormatJSON:
+	case RenderFormatSpeedscope, RenderFormatFirefox:
+		q.renderExport(w, req, format, selectParams, profileType)
+		return
+	default:
+		http.Error(w, fmt.Sprintf("unsupported format %q", format), http.StatusBadRequest)
+		return
+	}
 
 	groupBy := req.URL.Query()["groupBy"]
 
 	var resFlame *connect.Response[querierv1.SelectMergeStacktracesResponse]
 	g, ctx := errgroup.WithContext(req.Context())
 	g.Go(func() error {
-		resFlame, err = q.client.SelectMergeStacktraces(ctx, connect.NewRequest(selectParams))
+		resFlame, err = q.client.SelectMergeStacktraces(ctx, newRequest(req, selectParams))
 		return err
 	})
 
@@ -140,7 +154,7 @@ func (q *QueryHandlers) Render(w http.ResponseWriter, req *http.Request) {
 	var resSeries *connect.Response[querierv1.SelectSeriesResponse]
 	g.Go(func() error {
 		resSeries, err = q.client.SelectSeries(req.Context(),
-			connect.NewRequest(&querierv1.SelectSeriesRequest{
+			newRequest(req, &querierv1.SelectSeriesRequest{
 				ProfileTypeID: selectParams.ProfileTypeID,
 				LabelSelector: selectParams.LabelSelector,
 				Start:         selectParams.Start,
@@ -158,6 +172,11 @@ func (q *QueryHandlers) Render(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	warnings := new(partialresponse.Warnings)
+	warnings.MergeHeader(resFlame.Header())
+	warnings.MergeHeader(resSeries.Header())
+	warnings.SetHeader(w.Header())
+
 	seriesVal := &typesv1.Series{}
 	if len(resSeries.Msg.Series) == 1 {
 		seriesVal = resSeries.Msg.Series[0]
@@ -188,6 +207,55 @@ func (q *QueryHandlers) Render(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// The formats of the render handler, the flamebearer JSON being the default.
+const (
+	RenderFormatJSON       = "json"
+	RenderFormatSpeedscope = "speedscope"
+	RenderFormatFirefox    = "firefox"
+)
+
+// renderExport writes the merged profile of the selection in the Speedscope
+// or the Firefox Profiler format, to be opened in these tools.
+func (q *QueryHandlers) renderExport(w http.ResponseWriter, req *http.Request, format string, selectParams *querierv1.SelectMergeStacktracesRequest, profileType *typesv1.ProfileType) {
+	res, err := q.client.SelectMergeProfile(req.Context(), newRequest(req, &querierv1.SelectMergeProfileRequest{
+		ProfileTypeID: selectParams.ProfileTypeID,
+		LabelSelector: selectParams.LabelSelector,
+		Start:         selectParams.Start,
+		End:           selectParams.End,
+	}))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var data []byte
+	if format == RenderFormatSpeedscope {
+		data, err = pprof.MarshalSpeedscope(res.Msg, profileType.ID)
+	} else {
+		data, err = pprof.MarshalFirefoxProfile(res.Msg)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	warnings := new(partialresponse.Warnings)
+	warnings.MergeHeader(res.Header())
+	warnings.SetHeader(w.Header())
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=%q", profileType.Name+"."+format+".json"))
+	_, _ = w.Write(data)
+}
+
+// newRequest returns a new request with the message, in the partial response
+// mode if the partial_response parameter of the HTTP request is set.
+func newRequest[T any](req *http.Request, msg *T) *connect.Request[T] {
+	r := connect.NewRequest(msg)
+	if v, _ := strconv.ParseBool(req.Form.Get("partial_response")); v {
+		r.Header().Set(partialresponse.Header, "true")
+	}
+	return r
+}
+
 type renderRequestFieldNames struct {
 	query string
 	from  string
@@ -1,15 +1,21 @@
 package querier
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 	"time"
 
+	"github.com/bufbuild/connect-go"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 
+	profilev1 "github.com/grafana/phlare/api/gen/proto/go/google/v1"
+	querierv1 "github.com/grafana/phlare/api/gen/proto/go/querier/v1"
+	"github.com/grafana/phlare/api/gen/proto/go/querier/v1/querierv1connect"
 	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
 )
 
@@ -40,3 +46,42 @@ func Test_ParseQuery(t *testing.T) {
 
 	require.Equal(t, `{foo="bar",bar=~"buzz"}`, queryRequest.LabelSelector)
 }
+
+type selectMergeProfileClient struct {
+	querierv1connect.QuerierServiceClient
+	profile *profilev1.Profile
+}
+
+func (c *selectMergeProfileClient) SelectMergeProfile(context.Context, *connect.Request[querierv1.SelectMergeProfileRequest]) (*connect.Response[profilev1.Profile], error) {
+	return connect.NewResponse(c.profile), nil
+}
+
+func Test_RenderExport(t *testing.T) {
+	handlers := NewHTTPHandlers(&selectMergeProfileClient{profile: &profilev1.Profile{
+		SampleType:  []*profilev1.ValueType{{Type: 1, Unit: 2}},
+		Sample:      []*profilev1.Sample{{LocationId: []uint64{1}, Value: []int64{10}}},
+		Location:    []*profilev1.Location{{Id: 1, Line: []*profilev1.Line{{FunctionId: 1}}}},
+		Function:    []*profilev1.Function{{Id: 1, Name: 3}},
+		StringTable: []string{"", "cpu", "nanoseconds", "main"},
+	}})
+	for _, tc := range []struct {
+		format string
+		status int
+		body   string
+	}{
+		{format: "speedscope", status: http.StatusOK, body: `"$schema":"https://www.speedscope.app/file-format-schema.json"`},
+		{format: "firefox", status: http.StatusOK, body: `"preprocessedProfileVersion"`},
+		{format: "foo", status: http.StatusBadRequest, body: `unsupported format "foo"`},
+	} {
+		q := url.Values{
+			"query":  []string{`process_cpu:cpu:nanoseconds:cpu:nanoseconds{}`},
+			"from":   []string{"now-1h"},
+			"until":  []string{"now"},
+			"format": []string{tc.format},
+		}
+		rec := httptest.NewRecorder()
+		handlers.Render(rec, httptest.NewRequest("GET", "/pyroscope/render?"+q.Encode(), nil))
+		require.Equal(t, tc.status, rec.Code, tc.format)
+		require.Contains(t, rec.Body.String(), tc.body)
+	}
+}
@@ -8,7 +8,6 @@ import (
 	ring_client "github.com/grafana/dskit/ring/client"
 	"github.com/opentracing/opentracing-go"
 	"github.com/prometheus/prometheus/promql/parser"
-	"golang.org/x/sync/errgroup"
 
 	ingesterv1 "github.com/grafana/phlare/api/gen/proto/go/ingester/v1"
 	ingestv1 "github.com/grafana/phlare/api/gen/proto/go/ingester/v1"
@@ -16,7 +15,6 @@ import (
 	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
 	"github.com/grafana/phlare/pkg/clientpool"
 	phlaremodel "github.com/grafana/phlare/pkg/model"
-	"github.com/grafana/phlare/pkg/util"
 )
 
 type IngesterQueryClient interface {
@@ -27,6 +25,7 @@ type IngesterQueryClient interface {
 	MergeProfilesStacktraces(context.Context) clientpool.BidiClientMergeProfilesStacktraces
 	MergeProfilesLabels(ctx context.Context) clientpool.BidiClientMergeProfilesLabels
 	MergeProfilesPprof(ctx context.Context) clientpool.BidiClientMergeProfilesPprof
+	Cardinality(context.Context, *connect.Request[typesv1.CardinalityRequest]) (*connect.Response[typesv1.CardinalityResponse], error)
 }
 
 // IngesterQuerier helps with querying the ingesters.
@@ -78,28 +77,25 @@ func (q *Querier) selectTreeFromIngesters(ctx context.Context, req *querierv1.Se
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 	// send the first initial request to all ingesters.
-	g, gCtx := errgroup.WithContext(ctx)
-	for _, r := range responses {
-		r := r
-		g.Go(util.RecoverPanic(func() error {
-			return r.response.Send(&ingestv1.MergeProfilesStacktracesRequest{
-				Request: &ingestv1.SelectProfilesRequest{
-					LabelSelector: req.LabelSelector,
-					Start:         req.Start,
-					End:           req.End,
-					Type:          profileType,
-				},
-				MaxNodes: req.MaxNodes,
-				// TODO(kolesnikovae): Max stacks.
-			})
-		}))
-	}
-	if err = g.Wait(); err != nil {
+	responses, err = sendInitialRequests(ctx, responses, func(s clientpool.BidiClientMergeProfilesStacktraces) error {
+		requestTreeChunks(s, q.cfg.TreeMergeChunkSize)
+		return s.Send(&ingestv1.MergeProfilesStacktracesRequest{
+			Request: &ingestv1.SelectProfilesRequest{
+				LabelSelector: req.LabelSelector,
+				Start:         req.Start,
+				End:           req.End,
+				Type:          profileType,
+			},
+			MaxNodes: req.MaxNodes,
+			// TODO(kolesnikovae): Max stacks.
+		})
+	})
+	if err != nil {
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
 	// merge all profiles
-	return selectMergeTree(gCtx, responses)
+	return selectMergeTree(ctx, responses, q.newTreeMerger(req))
 }
 
 func (q *Querier) selectSeriesFromIngesters(ctx context.Context, req *ingesterv1.MergeProfilesLabelsRequest) ([]ResponseFromReplica[clientpool.BidiClientMergeProfilesLabels], error) {
@@ -112,14 +108,10 @@ func (q *Querier) selectSeriesFromIngesters(ctx context.Context, req *ingesterv1
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 	// send the first initial request to all ingesters.
-	g, _ := errgroup.WithContext(ctx)
-	for _, r := range responses {
-		r := r
-		g.Go(util.RecoverPanic(func() error {
-			return r.response.Send(req.CloneVT())
-		}))
-	}
-	if err := g.Wait(); err != nil {
+	responses, err = sendInitialRequests(ctx, responses, func(s clientpool.BidiClientMergeProfilesLabels) error {
+		return s.Send(req.CloneVT())
+	})
+	if err != nil {
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 	return responses, nil
@@ -0,0 +1,102 @@
+package partialresponse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// Header is the request header enabling the partial response mode of a
+	// query: when some of the ingesters or store-gateways fail, the results
+	// of the healthy ones are returned instead of an error.
+	Header = "X-Partial-Response"
+	// WarningsHeader is the response header describing the data missing
+	// from a partial response, one warning per value.
+	WarningsHeader = "X-Query-Warnings"
+)
+
+type contextKey int
+
+var warningsCtxKey = contextKey(1)
+
+// Enabled reports whether the partial response mode is requested.
+func Enabled(h http.Header) bool {
+	v, _ := strconv.ParseBool(h.Get(Header))
+	return v
+}
+
+// Warnings collects the failures tolerated by a query in the partial
+// response mode.
+type Warnings struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+// ContextWithWarnings returns a context enabling the partial response mode,
+// if it is requested in the header h. Otherwise, the context is returned as
+// is, along with nil Warnings.
+func ContextWithWarnings(ctx context.Context, h http.Header) (*Warnings, context.Context) {
+	if !Enabled(h) {
+		return nil, ctx
+	}
+	w := new(Warnings)
+	return w, context.WithValue(ctx, warningsCtxKey, w)
+}
+
+// FromContext returns the Warnings of the context, or nil if the partial
+// response mode is not enabled.
+func FromContext(ctx context.Context) *Warnings {
+	w, _ := ctx.Value(warningsCtxKey).(*Warnings)
+	return w
+}
+
+// Add records a warning, unless it has already been recorded.
+func (w *Warnings) Add(format string, args ...interface{}) {
+	if w == nil {
+		return
+	}
+	// Header values must not contain line breaks.
+	w.add(strings.Join(strings.Fields(fmt.Sprintf(format, args...)), " "))
+}
+
+func (w *Warnings) add(warning string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, x := range w.warnings {
+		if x == warning {
+			return
+		}
+	}
+	w.warnings = append(w.warnings, warning)
+}
+
+// List returns the warnings recorded.
+func (w *Warnings) List() []string {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.warnings...)
+}
+
+// SetHeader sets the warnings as the values of the WarningsHeader of h.
+func (w *Warnings) SetHeader(h http.Header) {
+	for _, warning := range w.List() {
+		h.Add(WarningsHeader, warning)
+	}
+}
+
+// MergeHeader records the warnings of the WarningsHeader of h, if any.
+func (w *Warnings) MergeHeader(h http.Header) {
+	if w == nil {
+		return
+	}
+	for _, warning := range h.Values(WarningsHeader) {
+		w.add(warning)
+	}
+}
@@ -0,0 +1,41 @@
+package partialresponse
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarnings(t *testing.T) {
+	w, ctx := ContextWithWarnings(context.Background(), http.Header{})
+	require.Nil(t, w)
+	require.Nil(t, FromContext(ctx))
+	w.Add("ignored")
+	require.Empty(t, w.List())
+
+	w, ctx = ContextWithWarnings(context.Background(), http.Header{Header: []string{"true"}})
+	require.NotNil(t, w)
+	require.Equal(t, w, FromContext(ctx))
+
+	w.Add("instance %s failed: %v", "a", "unavailable")
+	w.Add("instance %s failed: %v", "a", "unavailable")
+	w.Add("instance %s failed:\n%v", "b", "unavailable")
+	require.Equal(t, []string{
+		"instance a failed: unavailable",
+		"instance b failed: unavailable",
+	}, w.List())
+
+	h := http.Header{}
+	w.SetHeader(h)
+	merged := new(Warnings)
+	merged.Add("instance c failed: unavailable")
+	merged.MergeHeader(h)
+	merged.MergeHeader(h)
+	require.Equal(t, []string{
+		"instance c failed: unavailable",
+		"instance a failed: unavailable",
+		"instance b failed: unavailable",
+	}, merged.List())
+}
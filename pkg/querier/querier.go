@@ -31,19 +31,23 @@ import (
 	"github.com/grafana/phlare/pkg/clientpool"
 	"github.com/grafana/phlare/pkg/iter"
 	phlaremodel "github.com/grafana/phlare/pkg/model"
-	"github.com/grafana/phlare/pkg/util"
+	"github.com/grafana/phlare/pkg/querier/partialresponse"
 	"github.com/grafana/phlare/pkg/util/math"
 )
 
 type Config struct {
-	PoolConfig      clientpool.PoolConfig `yaml:"pool_config,omitempty"`
-	QueryStoreAfter time.Duration         `yaml:"query_store_after" category:"advanced"`
+	PoolConfig         clientpool.PoolConfig `yaml:"pool_config,omitempty"`
+	QueryStoreAfter    time.Duration         `yaml:"query_store_after" category:"advanced"`
+	TreeMergeChunkSize int                   `yaml:"tree_merge_chunk_size" category:"advanced"`
+	TreeMergeMaxSize   int                   `yaml:"tree_merge_max_size" category:"advanced"`
 }
 
 // RegisterFlags registers distributor-related flags.
 func (cfg *Config) RegisterFlags(fs *flag.FlagSet) {
 	cfg.PoolConfig.RegisterFlagsWithPrefix("querier", fs)
 	fs.DurationVar(&cfg.QueryStoreAfter, "querier.query-store-after", 4*time.Hour, "The time after which a metric should be queried from storage and not just ingesters. 0 means all queries are sent to store. If this option is enabled, the time range of the query sent to the store-gateway will be manipulated to ensure the query end is not more recent than 'now - query-store-after'.")
+	fs.IntVar(&cfg.TreeMergeChunkSize, "querier.tree-merge-chunk-size", 16384, "The maximum number of nodes of the flame graph tree chunks streamed by the ingesters and store-gateways, ordered by stack prefix and merged incrementally by the querier. 0 means the trees are sent in a single message.")
+	fs.IntVar(&cfg.TreeMergeMaxSize, "querier.tree-merge-max-size", 1<<20, "The maximum number of nodes of the flame graph tree merged by the querier. Once exceeded, the tree is truncated to the maximum number of nodes requested by the query, and the values of the truncated nodes are attributed to 'other' nodes. Only the nodes all the ingesters and store-gateways have streamed are truncated, so the limit may be exceeded while the streams are apart. 0 means no limit.")
 }
 
 type Querier struct {
@@ -255,6 +259,7 @@ func (q *Querier) Series(ctx context.Context, req *connect.Request[querierv1.Ser
 
 func (q *Querier) Diff(ctx context.Context, req *connect.Request[querierv1.DiffRequest]) (*connect.Response[querierv1.DiffResponse], error) {
 	sp, ctx := opentracing.StartSpanFromContext(ctx, "Diff")
+	warnings, ctx := partialresponse.ContextWithWarnings(ctx, req.Header())
 	defer func() {
 		sp.LogFields(
 			otlog.String("leftStart", model.Time(req.Msg.Left.Start).Time().String()),
@@ -297,13 +302,16 @@ func (q *Querier) Diff(ctx context.Context, req *connect.Request[querierv1.DiffR
 		return nil, connect.NewError(connect.CodeInvalidArgument, err)
 	}
 
-	return connect.NewResponse(&querierv1.DiffResponse{
+	resp := connect.NewResponse(&querierv1.DiffResponse{
 		Flamegraph: fd,
-	}), nil
+	})
+	warnings.SetHeader(resp.Header())
+	return resp, nil
 }
 
 func (q *Querier) SelectMergeStacktraces(ctx context.Context, req *connect.Request[querierv1.SelectMergeStacktracesRequest]) (*connect.Response[querierv1.SelectMergeStacktracesResponse], error) {
 	sp, ctx := opentracing.StartSpanFromContext(ctx, "SelectMergeStacktraces")
+	warnings, ctx := partialresponse.ContextWithWarnings(ctx, req.Header())
 	level.Info(spanlogger.FromContext(ctx, q.logger)).Log(
 		"start", model.Time(req.Msg.Start).Time().String(),
 		"end", model.Time(req.Msg.End).Time().String(),
@@ -324,9 +332,21 @@ func (q *Querier) SelectMergeStacktraces(ctx context.Context, req *connect.Reque
 		return nil, err
 	}
 
-	return connect.NewResponse(&querierv1.SelectMergeStacktracesResponse{
+	resp := connect.NewResponse(&querierv1.SelectMergeStacktracesResponse{
 		Flamegraph: phlaremodel.NewFlameGraph(t, req.Msg.GetMaxNodes()),
-	}), nil
+	})
+	warnings.SetHeader(resp.Header())
+	return resp, nil
+}
+
+// newTreeMerger returns the merger of the trees selected by the request,
+// limited to the configured number of nodes.
+func (q *Querier) newTreeMerger(req *querierv1.SelectMergeStacktracesRequest) *phlaremodel.TreeMerger {
+	maxNodes := req.GetMaxNodes()
+	if maxNodes == 0 {
+		maxNodes = maxNodesDefault
+	}
+	return phlaremodel.NewTreeMergerWithBudget(q.cfg.TreeMergeMaxSize, maxNodes)
 }
 
 func (q *Querier) selectTree(ctx context.Context, req *querierv1.SelectMergeStacktracesRequest) (*phlaremodel.Tree, error) {
@@ -351,29 +371,46 @@ func (q *Querier) selectTree(ctx context.Context, req *querierv1.SelectMergeStac
 
 	g, ctx := errgroup.WithContext(ctx)
 	var ingesterTree, storegatewayTree *phlaremodel.Tree
+	var ingesterErr, storegatewayErr error
 	g.Go(func() error {
-		var err error
-		ingesterTree, err = q.selectTreeFromIngesters(ctx, storeQueries.ingester.MergeStacktracesRequest(req))
-		if err != nil {
-			return err
-		}
-		return nil
+		ingesterTree, ingesterErr = q.selectTreeFromIngesters(ctx, storeQueries.ingester.MergeStacktracesRequest(req))
+		return storeFailure(ctx, "ingesters", storeQueries.ingester, ingesterErr)
 	})
 	g.Go(func() error {
-		var err error
-		storegatewayTree, err = q.selectTreeFromStoreGateway(ctx, storeQueries.storeGateway.MergeStacktracesRequest(req))
-		if err != nil {
-			return err
-		}
-		return nil
+		storegatewayTree, storegatewayErr = q.selectTreeFromStoreGateway(ctx, storeQueries.storeGateway.MergeStacktracesRequest(req))
+		return storeFailure(ctx, "store-gateways", storeQueries.storeGateway, storegatewayErr)
 	})
 	if err := g.Wait(); err != nil {
 		return nil, err
 	}
+	switch {
+	case ingesterErr != nil && storegatewayErr != nil:
+		return nil, ingesterErr
+	case ingesterErr != nil:
+		return storegatewayTree, nil
+	case storegatewayErr != nil:
+		return ingesterTree, nil
+	}
 	storegatewayTree.Merge(ingesterTree)
 	return storegatewayTree, nil
 }
 
+// storeFailure returns the error of the query of the ingesters or of the
+// store-gateways, unless the partial response mode is enabled, in which case
+// the time range missing from the results is reported as a warning.
+func storeFailure(ctx context.Context, store string, sq storeQuery, err error) error {
+	if err == nil {
+		return nil
+	}
+	w := partialresponse.FromContext(ctx)
+	if w == nil || isQueryError(err) {
+		return err
+	}
+	w.Add("%s failed, the time range from %s to %s is missing: %v", store,
+		sq.start.Time().UTC().Format(time.RFC3339), sq.end.Time().UTC().Format(time.RFC3339), err)
+	return nil
+}
+
 type storeQuery struct {
 	start, end  model.Time
 	shouldQuery bool
@@ -437,6 +474,7 @@ func splitQueryToStores(start, end model.Time, now model.Time, queryStoreAfter t
 
 func (q *Querier) SelectMergeProfile(ctx context.Context, req *connect.Request[querierv1.SelectMergeProfileRequest]) (*connect.Response[googlev1.Profile], error) {
 	sp, ctx := opentracing.StartSpanFromContext(ctx, "SelectMergeProfile")
+	warnings, ctx := partialresponse.ContextWithWarnings(ctx, req.Header())
 	defer func() {
 		sp.LogFields(
 			otlog.String("start", model.Time(req.Msg.Start).Time().String()),
@@ -465,36 +503,35 @@ func (q *Querier) SelectMergeProfile(ctx context.Context, req *connect.Request[q
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 	// send the first initial request to all ingesters.
-	g, gCtx := errgroup.WithContext(ctx)
-	for _, r := range responses {
-		r := r
-		g.Go(util.RecoverPanic(func() error {
-			return r.response.Send(&ingestv1.MergeProfilesPprofRequest{
-				Request: &ingestv1.SelectProfilesRequest{
-					LabelSelector: req.Msg.LabelSelector,
-					Start:         req.Msg.Start,
-					End:           req.Msg.End,
-					Type:          profileType,
-				},
-			})
-		}))
-	}
-	if err := g.Wait(); err != nil {
+	responses, err = sendInitialRequests(ctx, responses, func(s clientpool.BidiClientMergeProfilesPprof) error {
+		return s.Send(&ingestv1.MergeProfilesPprofRequest{
+			Request: &ingestv1.SelectProfilesRequest{
+				LabelSelector: req.Msg.LabelSelector,
+				Start:         req.Msg.Start,
+				End:           req.Msg.End,
+				Type:          profileType,
+			},
+		})
+	})
+	if err != nil {
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
 	// merge all profiles
-	profile, err := selectMergePprofProfile(gCtx, profileType, responses)
+	profile, err := selectMergePprofProfile(ctx, profileType, responses)
 	if err != nil {
 		return nil, err
 	}
 	profile.DurationNanos = model.Time(req.Msg.End).UnixNano() - model.Time(req.Msg.Start).UnixNano()
 	profile.TimeNanos = model.Time(req.Msg.End).UnixNano()
-	return connect.NewResponse(profile), nil
+	resp := connect.NewResponse(profile)
+	warnings.SetHeader(resp.Header())
+	return resp, nil
 }
 
 func (q *Querier) SelectSeries(ctx context.Context, req *connect.Request[querierv1.SelectSeriesRequest]) (*connect.Response[querierv1.SelectSeriesResponse], error) {
 	sp, ctx := opentracing.StartSpanFromContext(ctx, "SelectSeries")
+	warnings, ctx := partialresponse.ContextWithWarnings(ctx, req.Header())
 	defer func() {
 		sp.LogFields(
 			otlog.String("start", model.Time(req.Msg.Start).Time().String()),
@@ -531,7 +568,7 @@ func (q *Querier) SelectSeries(ctx context.Context, req *connect.Request[querier
 
 	it, err := selectMergeSeries(ctx, responses)
 	if err != nil {
-		return nil, connect.NewError(connect.CodeInternal, err)
+		return nil, err
 	}
 
 	result := rangeSeries(it, req.Msg.Start, req.Msg.End, stepMs)
@@ -539,9 +576,11 @@ func (q *Querier) SelectSeries(ctx context.Context, req *connect.Request[querier
 		return nil, connect.NewError(connect.CodeInternal, it.Err())
 	}
 
-	return connect.NewResponse(&querierv1.SelectSeriesResponse{
+	resp := connect.NewResponse(&querierv1.SelectSeriesResponse{
 		Series: result,
-	}), nil
+	})
+	warnings.SetHeader(resp.Header())
+	return resp, nil
 }
 
 func (q *Querier) selectSeries(ctx context.Context, req *connect.Request[querierv1.SelectSeriesRequest]) ([]ResponseFromReplica[clientpool.BidiClientMergeProfilesLabels], error) {
@@ -578,9 +617,11 @@ func (q *Querier) selectSeries(ctx context.Context, req *connect.Request[querier
 	}
 
 	// todo in parallel
+	var ingesterErr error
 	if storeQueries.ingester.shouldQuery {
-		ir, err := q.selectSeriesFromIngesters(ctx, storeQueries.ingester.MergeSeriesRequest(req.Msg, profileType))
-		if err != nil {
+		var ir []ResponseFromReplica[clientpool.BidiClientMergeProfilesLabels]
+		ir, ingesterErr = q.selectSeriesFromIngesters(ctx, storeQueries.ingester.MergeSeriesRequest(req.Msg, profileType))
+		if err := storeFailure(ctx, "ingesters", storeQueries.ingester, ingesterErr); err != nil {
 			return nil, err
 		}
 		responses = append(responses, ir...)
@@ -588,7 +629,10 @@ func (q *Querier) selectSeries(ctx context.Context, req *connect.Request[querier
 
 	if storeQueries.storeGateway.shouldQuery {
 		ir, err := q.selectSeriesFromStoreGateway(ctx, storeQueries.storeGateway.MergeSeriesRequest(req.Msg, profileType))
-		if err != nil {
+		if err != nil && (ingesterErr != nil || !storeQueries.ingester.shouldQuery) {
+			return nil, err
+		}
+		if err := storeFailure(ctx, "store-gateways", storeQueries.storeGateway, err); err != nil {
 			return nil, err
 		}
 		responses = append(responses, ir...)
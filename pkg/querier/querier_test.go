@@ -3,8 +3,11 @@ package querier
 import (
 	"bytes"
 	"context"
+	"io"
+	"net/http"
 	"os"
 	"sort"
+	"strconv"
 	"testing"
 	"time"
 
@@ -559,6 +562,21 @@ func (f *fakeQuerierIngester) Series(ctx context.Context, req *connect.Request[i
 	return res, err
 }
 
+func (f *fakeQuerierIngester) Cardinality(ctx context.Context, req *connect.Request[typesv1.CardinalityRequest]) (*connect.Response[typesv1.CardinalityResponse], error) {
+	var (
+		args = f.Called(ctx, req)
+		res  *connect.Response[typesv1.CardinalityResponse]
+		err  error
+	)
+	if args[0] != nil {
+		res = args[0].(*connect.Response[typesv1.CardinalityResponse])
+	}
+	if args[1] != nil {
+		err = args.Get(1).(error)
+	}
+	return res, err
+}
+
 type testProfile struct {
 	Ts     int64
 	Labels *typesv1.Labels
@@ -623,6 +641,62 @@ func (f *fakeBidiClientStacktraces) Receive() (*ingestv1.MergeProfilesStacktrace
 func (f *fakeBidiClientStacktraces) CloseRequest() error  { return nil }
 func (f *fakeBidiClientStacktraces) CloseResponse() error { return nil }
 
+// fakeBidiClientTreeChunks streams its tree in chunks, if requested.
+type fakeBidiClientTreeChunks struct {
+	*fakeBidiClientStacktraces
+	tree           *phlaremodel.Tree
+	requestHeader  http.Header
+	responseHeader http.Header
+	chunks         [][]byte
+	streaming      bool
+}
+
+func newFakeBidiClientTreeChunks(tree *phlaremodel.Tree, batches []*ingestv1.ProfileSets) *fakeBidiClientTreeChunks {
+	return &fakeBidiClientTreeChunks{
+		fakeBidiClientStacktraces: newFakeBidiClientStacktraces(batches),
+		tree:                      tree,
+		requestHeader:             http.Header{},
+		responseHeader:            http.Header{},
+	}
+}
+
+func (f *fakeBidiClientTreeChunks) RequestHeader() http.Header  { return f.requestHeader }
+func (f *fakeBidiClientTreeChunks) ResponseHeader() http.Header { return f.responseHeader }
+
+func (f *fakeBidiClientTreeChunks) Receive() (*ingestv1.MergeProfilesStacktracesResponse, error) {
+	if !f.streaming {
+		res, err := f.fakeBidiClientStacktraces.Receive()
+		if err != nil || res.Result == nil {
+			return res, err
+		}
+		f.streaming = true
+		chunkSize, _ := strconv.Atoi(f.requestHeader.Get(phlaremodel.TreeChunkSizeHeader))
+		if chunkSize > 0 {
+			f.responseHeader.Set(phlaremodel.TreeChunkSizeHeader, strconv.Itoa(chunkSize))
+		}
+		for _, c := range f.tree.Chunks(chunkSize) {
+			var buf bytes.Buffer
+			if err = c.MarshalTruncate(&buf, -1); err != nil {
+				return nil, err
+			}
+			f.chunks = append(f.chunks, buf.Bytes())
+		}
+		// Signals the end of the profile streaming.
+		return &ingestv1.MergeProfilesStacktracesResponse{}, nil
+	}
+	if len(f.chunks) == 0 {
+		return nil, io.EOF
+	}
+	c := f.chunks[0]
+	f.chunks = f.chunks[1:]
+	return &ingestv1.MergeProfilesStacktracesResponse{
+		Result: &ingestv1.MergeProfilesStacktracesResult{
+			Format:    ingestv1.StacktracesMergeFormat_MERGE_FORMAT_TREE,
+			TreeBytes: c,
+		},
+	}, nil
+}
+
 func requireFakeMergeProfilesStacktracesResultTree(t *testing.T, r *phlaremodel.Tree) {
 	flame := phlaremodel.NewFlameGraph(r, -1)
 	sort.Strings(flame.Names)
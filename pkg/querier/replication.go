@@ -2,10 +2,15 @@ package querier
 
 import (
 	"context"
+	"sync"
 
+	"github.com/bufbuild/connect-go"
 	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/multierror"
 	"github.com/grafana/dskit/ring"
+	"golang.org/x/sync/errgroup"
 
+	"github.com/grafana/phlare/pkg/querier/partialresponse"
 	"github.com/grafana/phlare/pkg/util"
 )
 
@@ -26,6 +31,9 @@ type ClientFactory[T any] func(addr string) (T, error)
 // forGivenReplicationSet runs f, in parallel, for given replica set.
 // Under the hood it returns only enough responses to satisfy the quorum.
 func forGivenReplicationSet[Result any, Querier any](ctx context.Context, clientFactory func(string) (Querier, error), replicationSet ring.ReplicationSet, f QueryReplicaFn[Result, Querier]) ([]ResponseFromReplica[Result], error) {
+	if w := partialresponse.FromContext(ctx); w != nil {
+		return forAllReplicasPartial(ctx, clientFactory, replicationSet, f, w)
+	}
 	results, err := ring.DoUntilQuorumWithoutSuccessfulContextCancellation(
 		ctx,
 		replicationSet,
@@ -47,21 +55,7 @@ func forGivenReplicationSet[Result any, Querier any](ctx context.Context, client
 			return ResponseFromReplica[Result]{ingester.Addr, resp}, nil
 		},
 		func(result ResponseFromReplica[Result]) {
-			// If the result was streamed, we need to close the request and response
-			if stream, ok := any(result.response).(interface {
-				CloseRequest() error
-			}); ok {
-				if err := stream.CloseRequest(); err != nil {
-					level.Warn(util.Logger).Log("msg", "failed to close request", "err", err)
-				}
-			}
-			if stream, ok := any(result.response).(interface {
-				CloseResponse() error
-			}); ok {
-				if err := stream.CloseResponse(); err != nil {
-					level.Warn(util.Logger).Log("msg", "failed to close response", "err", err)
-				}
-			}
+			closeStream(result.response)
 		})
 	if err != nil {
 		return nil, err
@@ -69,3 +63,129 @@ func forGivenReplicationSet[Result any, Querier any](ctx context.Context, client
 
 	return results, err
 }
+
+// forAllReplicasPartial runs f, in parallel, for all the instances of the
+// replica set. The instances failing are reported as warnings: an error is
+// only returned if all of them fail.
+func forAllReplicasPartial[Result any, Querier any](ctx context.Context, clientFactory func(string) (Querier, error), replicationSet ring.ReplicationSet, f QueryReplicaFn[Result, Querier], w *partialresponse.Warnings) ([]ResponseFromReplica[Result], error) {
+	var (
+		mu      sync.Mutex
+		results = make([]ResponseFromReplica[Result], 0, len(replicationSet.Instances))
+		errs    multierror.MultiError
+		fatal   bool
+		wg      sync.WaitGroup
+	)
+	for _, instance := range replicationSet.Instances {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			client, err := clientFactory(addr)
+			var resp Result
+			if err == nil {
+				resp, err = f(ctx, client)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs.Add(err)
+				if replicaFailure(ctx, addr, err) != nil {
+					fatal = true
+				}
+				return
+			}
+			results = append(results, ResponseFromReplica[Result]{addr, resp})
+		}(instance.Addr)
+	}
+	wg.Wait()
+	if fatal || (len(results) == 0 && len(errs) > 0) {
+		for _, r := range results {
+			closeStream(r.response)
+		}
+		return nil, errs.Err()
+	}
+	return results, nil
+}
+
+// closeStream closes the request and response of the result, if it was
+// streamed.
+func closeStream(response any) {
+	if stream, ok := response.(interface {
+		CloseRequest() error
+	}); ok {
+		if err := stream.CloseRequest(); err != nil {
+			level.Warn(util.Logger).Log("msg", "failed to close request", "err", err)
+		}
+	}
+	if stream, ok := response.(interface {
+		CloseResponse() error
+	}); ok {
+		if err := stream.CloseResponse(); err != nil {
+			level.Warn(util.Logger).Log("msg", "failed to close response", "err", err)
+		}
+	}
+}
+
+// sendInitialRequests sends the initial request of the streams to all the
+// replicas. In the partial response mode, the replicas failing are reported
+// as warnings and closed, and only the others are returned.
+func sendInitialRequests[T any](ctx context.Context, responses []ResponseFromReplica[T], send func(T) error) ([]ResponseFromReplica[T], error) {
+	w := partialresponse.FromContext(ctx)
+	errs := make([]error, len(responses))
+	g, _ := errgroup.WithContext(ctx)
+	for i, r := range responses {
+		i, r := i, r
+		g.Go(util.RecoverPanic(func() error {
+			errs[i] = send(r.response)
+			if w != nil {
+				return nil
+			}
+			return errs[i]
+		}))
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return responses, nil
+	}
+	healthy := make([]ResponseFromReplica[T], 0, len(responses))
+	for i, r := range responses {
+		if errs[i] != nil {
+			if err := replicaFailure(ctx, r.addr, errs[i]); err != nil {
+				return nil, err
+			}
+			closeStream(r.response)
+			continue
+		}
+		healthy = append(healthy, r)
+	}
+	if len(healthy) == 0 && len(responses) > 0 {
+		return nil, errs[0]
+	}
+	return healthy, nil
+}
+
+// replicaFailure returns the error of a replica, unless the partial response
+// mode is enabled, in which case it is reported as a warning. The errors of
+// the query itself, e.g. invalid or exceeding the limits, are not tolerated.
+func replicaFailure(ctx context.Context, addr string, err error) error {
+	if err == nil {
+		return nil
+	}
+	w := partialresponse.FromContext(ctx)
+	if w == nil || isQueryError(err) {
+		return err
+	}
+	w.Add("instance %s failed: %v", addr, err)
+	return nil
+}
+
+// isQueryError reports whether the error is caused by the query itself, e.g.
+// invalid or exceeding the limits, rather than by a failing instance.
+func isQueryError(err error) bool {
+	switch connect.CodeOf(err) {
+	case connect.CodeInvalidArgument, connect.CodeResourceExhausted, connect.CodeCanceled:
+		return true
+	}
+	return false
+}
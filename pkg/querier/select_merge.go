@@ -2,10 +2,15 @@ package querier
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"math"
+	"net/http"
+	"strconv"
 	"sync"
 
+	"github.com/bufbuild/connect-go"
 	"github.com/google/pprof/profile"
 	"github.com/grafana/dskit/multierror"
 	"github.com/opentracing/opentracing-go"
@@ -22,6 +27,8 @@ import (
 	"github.com/grafana/phlare/pkg/iter"
 	phlaremodel "github.com/grafana/phlare/pkg/model"
 	"github.com/grafana/phlare/pkg/pprof"
+	"github.com/grafana/phlare/pkg/querier/partialresponse"
+	"github.com/grafana/phlare/pkg/querier/stats"
 	"github.com/grafana/phlare/pkg/util"
 	"github.com/grafana/phlare/pkg/util/loser"
 )
@@ -49,6 +56,7 @@ type Response interface {
 
 type MergeResult[R any] interface {
 	Result() (R, error)
+	Results(fn func(R) error) error
 }
 type MergeIterator interface {
 	iter.Iterator[*ProfileWithLabels]
@@ -188,6 +196,44 @@ func (s *mergeIterator[R, Req, Res]) At() *ProfileWithLabels {
 }
 
 func (s *mergeIterator[R, Req, Res]) Result() (R, error) {
+	res, err := s.receiveResult()
+	if err != nil {
+		return res, err
+	}
+	s.collectStats()
+	return res, nil
+}
+
+// Results calls fn for each result sent by the server: the result may be
+// streamed in chunks, if the server acknowledged it in the response headers.
+func (s *mergeIterator[R, Req, Res]) Results(fn func(R) error) error {
+	h, ok := s.bidi.(responseHeader)
+	if !ok || h.ResponseHeader().Get(phlaremodel.TreeChunkSizeHeader) == "" {
+		res, err := s.Result()
+		if err != nil {
+			return err
+		}
+		return fn(res)
+	}
+	for {
+		res, err := s.receiveResult()
+		if errors.Is(err, io.EOF) {
+			s.err = nil
+			if t, ok := s.bidi.(responseTrailer); ok {
+				_ = stats.FromContext(s.ctx).MergeHeader(t.ResponseTrailer())
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = fn(res); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *mergeIterator[R, Req, Res]) receiveResult() (R, error) {
 	res, err := s.bidi.Receive()
 	if err != nil {
 		s.err = err
@@ -205,6 +251,46 @@ func (s *mergeIterator[R, Req, Res]) Result() (R, error) {
 	}
 }
 
+// requestHeader is implemented by the streams able to send request headers,
+// e.g. connect.BidiStreamForClient.
+type requestHeader interface {
+	RequestHeader() http.Header
+}
+
+// responseHeader is implemented by the streams able to return the response
+// headers of the server, e.g. connect.BidiStreamForClient.
+type responseHeader interface {
+	ResponseHeader() http.Header
+}
+
+// requestTreeChunks asks the server to stream the merged tree in chunks of at
+// most chunkSize nodes. It must be called before the first request is sent.
+func requestTreeChunks(stream any, chunkSize int) {
+	if h, ok := stream.(requestHeader); ok && chunkSize > 0 {
+		h.RequestHeader().Set(phlaremodel.TreeChunkSizeHeader, strconv.Itoa(chunkSize))
+	}
+}
+
+// responseTrailer is implemented by the streams able to return the response
+// trailers of the server, e.g. connect.BidiStreamForClient.
+type responseTrailer interface {
+	ResponseTrailer() http.Header
+}
+
+// collectStats drains the stream to receive the response trailers, and merges
+// the query stats they carry into the ones of the context, if any.
+func (s *mergeIterator[R, Req, Res]) collectStats() {
+	qs := stats.FromContext(s.ctx)
+	t, ok := s.bidi.(responseTrailer)
+	if qs == nil || !ok {
+		return
+	}
+	if _, err := s.bidi.Receive(); !errors.Is(err, io.EOF) {
+		return
+	}
+	_ = qs.MergeHeader(t.ResponseTrailer())
+}
+
 func (s *mergeIterator[R, Req, Res]) Err() error {
 	return s.err
 }
@@ -218,11 +304,30 @@ func (s *mergeIterator[R, Req, Res]) Close() error {
 	return errs.Err()
 }
 
+// partialMergeIterator ends the iteration of a replica which fails, instead
+// of the iteration of all the replicas, in the partial response mode.
+type partialMergeIterator struct {
+	MergeIterator
+	ctx  context.Context
+	addr string
+}
+
+func (it *partialMergeIterator) Err() error {
+	return replicaFailure(it.ctx, it.addr, it.MergeIterator.Err())
+}
+
 // skipDuplicates iterates through the iterator and skip duplicates.
 func skipDuplicates(ctx context.Context, its []MergeIterator) error {
 	span, _ := opentracing.StartSpanFromContext(ctx, "skipDuplicates")
 	defer span.Finish()
-	var errors multierror.MultiError
+	if w := partialresponse.FromContext(ctx); w != nil {
+		partial := make([]MergeIterator, len(its))
+		for i, it := range its {
+			partial[i] = &partialMergeIterator{MergeIterator: it, ctx: ctx, addr: it.At().IngesterAddr}
+		}
+		its = partial
+	}
+	var errs multierror.MultiError
 	tree := loser.New(its,
 		&ProfileWithLabels{
 			Timestamp: math.MaxInt64,
@@ -238,7 +343,7 @@ func skipDuplicates(ctx context.Context, its []MergeIterator) error {
 		},
 		func(s MergeIterator) {
 			if err := s.Close(); err != nil {
-				errors.Add(err)
+				errs.Add(err)
 			}
 		})
 
@@ -262,15 +367,24 @@ func skipDuplicates(ctx context.Context, its []MergeIterator) error {
 	span.LogFields(otlog.Int("duplicates", duplicates))
 	span.LogFields(otlog.Int("total", total))
 	if err := tree.Err(); err != nil {
-		errors.Add(err)
+		errs.Add(err)
 	}
 
-	return errors.Err()
+	// Return the first error with a code as is, so that the code is kept,
+	// e.g. when an ingester reports that a query limit is exceeded.
+	for _, err := range errs {
+		var connectErr *connect.Error
+		if errors.As(err, &connectErr) {
+			return err
+		}
+	}
+	return errs.Err()
 }
 
 // selectMergeTree selects the  profile from each ingester by deduping them and
-// returns merge of stacktrace samples represented as a tree.
-func selectMergeTree(ctx context.Context, responses []ResponseFromReplica[clientpool.BidiClientMergeProfilesStacktraces]) (*phlaremodel.Tree, error) {
+// returns merge of stacktrace samples represented as a tree. The results are
+// merged incrementally with m as they are received.
+func selectMergeTree(ctx context.Context, responses []ResponseFromReplica[clientpool.BidiClientMergeProfilesStacktraces], m *phlaremodel.TreeMerger) (*phlaremodel.Tree, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "selectMergeTree")
 	defer span.Finish()
 
@@ -299,24 +413,38 @@ func selectMergeTree(ctx context.Context, responses []ResponseFromReplica[client
 	// Collects the results in parallel.
 	span.LogFields(otlog.String("msg", "collecting merge results"))
 	g, _ := errgroup.WithContext(ctx)
-	m := phlaremodel.NewTreeMerger()
 	sm := phlaremodel.NewStackTraceMerger()
-	for _, iter := range mergeResults {
-		iter := iter
+	// The trees of each replica are merged with a stream of their own,
+	// so that only the nodes all the replicas are past are truncated.
+	streams := make([]*phlaremodel.TreeStream, len(mergeResults))
+	for i := range streams {
+		streams[i] = m.Stream()
+	}
+	// The streams of the replicas sending stacktraces are only closed once
+	// the stacktraces are merged.
+	stacktraces := make([]bool, len(mergeResults))
+	for i, iter := range mergeResults {
+		i, iter, addr := i, iter, responses[i].addr
 		g.Go(util.RecoverPanic(func() error {
-			result, err := iter.Result()
-			if err != nil || result == nil {
-				return err
-			}
-			switch result.Format {
-			default:
-				return fmt.Errorf("unknown merge result format")
-			case ingestv1.StacktracesMergeFormat_MERGE_FORMAT_STACKTRACES:
-				sm.MergeStackTraces(result.Stacktraces, result.FunctionNames)
-			case ingestv1.StacktracesMergeFormat_MERGE_FORMAT_TREE:
-				err = m.MergeTreeBytes(result.TreeBytes)
+			err := iter.Results(func(result *ingestv1.MergeProfilesStacktracesResult) error {
+				if result == nil {
+					return nil
+				}
+				switch result.Format {
+				default:
+					return fmt.Errorf("unknown merge result format")
+				case ingestv1.StacktracesMergeFormat_MERGE_FORMAT_STACKTRACES:
+					stacktraces[i] = true
+					sm.MergeStackTraces(result.Stacktraces, result.FunctionNames)
+				case ingestv1.StacktracesMergeFormat_MERGE_FORMAT_TREE:
+					return streams[i].MergeTreeBytes(result.TreeBytes)
+				}
+				return nil
+			})
+			if !stacktraces[i] {
+				streams[i].Close()
 			}
-			return err
+			return replicaFailure(ctx, addr, err)
 		}))
 	}
 	if err := g.Wait(); err != nil {
@@ -325,10 +453,26 @@ func selectMergeTree(ctx context.Context, responses []ResponseFromReplica[client
 	if sm.Size() > 0 {
 		// For backward compatibility: during a rollout, multiple formats
 		// may coexist for some period of time (efficiency is not a concern).
-		if err := m.MergeTreeBytes(sm.TreeBytes(-1)); err != nil {
+		var stream *phlaremodel.TreeStream
+		for i, s := range streams {
+			if stacktraces[i] {
+				stream = s
+				break
+			}
+		}
+		if err := stream.MergeTreeBytes(sm.TreeBytes(-1)); err != nil {
 			return nil, err
 		}
 	}
+	for i, s := range streams {
+		if stacktraces[i] {
+			s.Close()
+		}
+	}
+
+	if m.Truncated() {
+		span.LogFields(otlog.String("msg", "tree truncated: memory budget exceeded"))
+	}
 
 	span.LogFields(otlog.String("msg", "building tree"))
 	return m.Tree(), nil
@@ -362,12 +506,12 @@ func selectMergePprofProfile(ctx context.Context, ty *typesv1.ProfileType, respo
 	results := make([]*profile.Profile, 0, len(iters))
 	s := lo.Synchronize()
 	g, _ := errgroup.WithContext(ctx)
-	for _, iter := range mergeResults {
-		iter := iter
+	for i, iter := range mergeResults {
+		iter, addr := iter, responses[i].addr
 		g.Go(util.RecoverPanic(func() error {
 			result, err := iter.Result()
 			if err != nil || result == nil {
-				return err
+				return replicaFailure(ctx, addr, err)
 			}
 			p, err := profile.ParseUncompressed(result)
 			if err != nil {
@@ -438,12 +582,12 @@ func selectMergeSeries(ctx context.Context, responses []ResponseFromReplica[clie
 	results := make([][]*typesv1.Series, 0, len(iters))
 	s := lo.Synchronize()
 	g, _ := errgroup.WithContext(ctx)
-	for _, iter := range mergeResults {
-		iter := iter
+	for i, iter := range mergeResults {
+		iter, addr := iter, responses[i].addr
 		g.Go(util.RecoverPanic(func() error {
 			result, err := iter.Result()
 			if err != nil || result == nil {
-				return err
+				return replicaFailure(ctx, addr, err)
 			}
 			s.Do(func() {
 				results = append(results, result)
@@ -1,11 +1,18 @@
 package querier
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
 	"sort"
 	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/bufbuild/connect-go"
 	"github.com/stretchr/testify/require"
 
 	ingestv1 "github.com/grafana/phlare/api/gen/proto/go/ingester/v1"
@@ -14,6 +21,7 @@ import (
 	"github.com/grafana/phlare/pkg/iter"
 	"github.com/grafana/phlare/pkg/model"
 	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/querier/partialresponse"
 	"github.com/grafana/phlare/pkg/testhelper"
 )
 
@@ -76,7 +84,7 @@ func TestSelectMergeStacktraces(t *testing.T) {
 		{
 			response: resp3,
 		},
-	})
+	}, phlaremodel.NewTreeMerger())
 	require.NoError(t, err)
 	requireFakeMergeProfilesStacktracesResultTree(t, res)
 	all := []testProfile{}
@@ -112,11 +120,111 @@ func TestSelectMergeStacktraces(t *testing.T) {
 				},
 			}),
 		},
-	})
+	}, phlaremodel.NewTreeMerger())
 	require.NoError(t, err)
 	requireFakeMergeProfilesStacktracesResultTree(t, res)
 }
 
+func TestSelectMergeStacktraces_TreeChunks(t *testing.T) {
+	trees := make([]*phlaremodel.Tree, 3)
+	for i := range trees {
+		trees[i] = new(phlaremodel.Tree)
+		r := rand.New(rand.NewSource(int64(i)))
+		for j := 0; j < 500; j++ {
+			stack := make([]string, r.Intn(16)+1)
+			for k := range stack {
+				stack[k] = fmt.Sprintf("f%d", r.Intn(8))
+			}
+			trees[i].InsertStack(int64(r.Intn(100)+1), stack...)
+		}
+	}
+	// The current merge: each replica sends its tree in a single message.
+	expected := phlaremodel.NewTreeMerger()
+	for _, tree := range trees {
+		var buf bytes.Buffer
+		require.NoError(t, tree.MarshalTruncate(&buf, -1))
+		require.NoError(t, expected.MergeTreeBytes(buf.Bytes()))
+	}
+
+	selectMergeTreeChunks := func(t *testing.T, chunkSize int, m *phlaremodel.TreeMerger) *phlaremodel.Tree {
+		responses := make([]ResponseFromReplica[clientpool.BidiClientMergeProfilesStacktraces], len(trees))
+		for i, tree := range trees {
+			f := newFakeBidiClientTreeChunks(tree, []*ingestv1.ProfileSets{{
+				LabelsSets: []*typesv1.Labels{{Labels: foobarlabels}},
+				Profiles:   []*ingestv1.SeriesProfile{{LabelIndex: 0, Timestamp: int64(i)}},
+			}})
+			requestTreeChunks(f, chunkSize)
+			responses[i] = ResponseFromReplica[clientpool.BidiClientMergeProfilesStacktraces]{response: f}
+		}
+		res, err := selectMergeTree(context.Background(), responses, m)
+		require.NoError(t, err)
+		return res
+	}
+
+	for _, chunkSize := range []int{0, 1, 7, 1 << 20} {
+		t.Run(fmt.Sprintf("chunk size %d", chunkSize), func(t *testing.T) {
+			actual := selectMergeTreeChunks(t, chunkSize, phlaremodel.NewTreeMergerWithBudget(1<<20, maxNodesDefault))
+			require.Equal(t, expected.Tree().String(), actual.String())
+		})
+	}
+
+	t.Run("memory budget exceeded", func(t *testing.T) {
+		m := phlaremodel.NewTreeMergerWithBudget(128, 32)
+		actual := selectMergeTreeChunks(t, 16, m)
+		require.True(t, m.Truncated())
+		require.Equal(t, expected.Tree().Total(), actual.Total())
+		// The tree is truncated: it has less nodes, one per line.
+		require.Less(t, strings.Count(actual.String(), "\n"), strings.Count(expected.Tree().String(), "\n"))
+		// The flamegraph is the same as the one of the tree merged without budget.
+		require.Equal(t, phlaremodel.NewFlameGraph(expected.Tree(), 32), phlaremodel.NewFlameGraph(actual, 32))
+	})
+}
+
+func TestSelectMergeStacktraces_PartialResponse(t *testing.T) {
+	responses := func() []ResponseFromReplica[clientpool.BidiClientMergeProfilesStacktraces] {
+		return []ResponseFromReplica[clientpool.BidiClientMergeProfilesStacktraces]{
+			{
+				addr: "healthy",
+				response: newFakeBidiClientStacktraces([]*ingestv1.ProfileSets{{
+					LabelsSets: []*typesv1.Labels{{Labels: foobarlabels}},
+					Profiles:   []*ingestv1.SeriesProfile{{LabelIndex: 0, Timestamp: 1}},
+				}}),
+			},
+			{
+				addr:     "failing",
+				response: &fakeBidiClientFailing{err: errors.New("unavailable")},
+			},
+		}
+	}
+
+	_, err := selectMergeTree(context.Background(), responses(), phlaremodel.NewTreeMerger())
+	require.Error(t, err)
+
+	warnings, ctx := partialresponse.ContextWithWarnings(context.Background(),
+		http.Header{partialresponse.Header: []string{"true"}})
+	res, err := selectMergeTree(ctx, responses(), phlaremodel.NewTreeMerger())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), res.Total())
+	require.Equal(t, []string{"instance failing failed: unavailable"}, warnings.List())
+
+	// Errors caused by the query itself are never tolerated.
+	r := responses()
+	r[1].response = &fakeBidiClientFailing{err: connect.NewError(connect.CodeResourceExhausted, errors.New("limit exceeded"))}
+	_, ctx = partialresponse.ContextWithWarnings(context.Background(),
+		http.Header{partialresponse.Header: []string{"true"}})
+	_, err = selectMergeTree(ctx, r, phlaremodel.NewTreeMerger())
+	require.Error(t, err)
+}
+
+type fakeBidiClientFailing struct{ err error }
+
+func (f *fakeBidiClientFailing) Send(*ingestv1.MergeProfilesStacktracesRequest) error { return nil }
+func (f *fakeBidiClientFailing) Receive() (*ingestv1.MergeProfilesStacktracesResponse, error) {
+	return nil, f.err
+}
+func (f *fakeBidiClientFailing) CloseRequest() error  { return nil }
+func (f *fakeBidiClientFailing) CloseResponse() error { return nil }
+
 func TestSelectMergeByLabels(t *testing.T) {
 	resp1 := newFakeBidiClientSeries([]*ingestv1.ProfileSets{
 		{
@@ -254,7 +362,7 @@ func BenchmarkSelectMergeStacktraces(b *testing.B) {
 	b.ResetTimer()
 	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
-		_, err := selectMergeTree(context.Background(), responses)
+		_, err := selectMergeTree(context.Background(), responses, phlaremodel.NewTreeMerger())
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -7,12 +7,19 @@ package stats
 
 import (
 	"context"
+	"net/http"
 	"sync/atomic" //lint:ignore faillint we can't use go.uber.org/atomic with a protobuf struct without wrapping it.
 	"time"
 
+	"google.golang.org/protobuf/encoding/protojson"
+
 	"github.com/grafana/phlare/pkg/util/httpgrpc"
 )
 
+// Header is the response header, or trailer for the streams, carrying the
+// Stats of a request in their JSON encoding.
+const Header = "X-Query-Stats"
+
 type contextKey int
 
 var ctxKey = contextKey(0)
@@ -155,6 +162,118 @@ func (s *Stats) LoadSplitQueries() uint32 {
 	return atomic.LoadUint32(&s.SplitQueries)
 }
 
+func (s *Stats) AddBlocksQueried(blocks uint64) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint64(&s.BlocksQueried, blocks)
+}
+
+func (s *Stats) LoadBlocksQueried() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.BlocksQueried)
+}
+
+func (s *Stats) AddProfilesScanned(profiles uint64) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint64(&s.ProfilesScanned, profiles)
+}
+
+func (s *Stats) LoadProfilesScanned() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.ProfilesScanned)
+}
+
+func (s *Stats) AddStacktracesResolved(stacktraces uint64) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint64(&s.StacktracesResolved, stacktraces)
+}
+
+func (s *Stats) LoadStacktracesResolved() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.StacktracesResolved)
+}
+
+func (s *Stats) AddFetchedBlockBytes(bytes uint64) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint64(&s.FetchedBlockBytes, bytes)
+}
+
+func (s *Stats) LoadFetchedBlockBytes() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.FetchedBlockBytes)
+}
+
+func (s *Stats) AddSelectTime(t time.Duration) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddInt64(&s.SelectTime, int64(t))
+}
+
+func (s *Stats) LoadSelectTime() time.Duration {
+	if s == nil {
+		return 0
+	}
+
+	return time.Duration(atomic.LoadInt64(&s.SelectTime))
+}
+
+func (s *Stats) AddMergeTime(t time.Duration) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddInt64(&s.MergeTime, int64(t))
+}
+
+func (s *Stats) LoadMergeTime() time.Duration {
+	if s == nil {
+		return 0
+	}
+
+	return time.Duration(atomic.LoadInt64(&s.MergeTime))
+}
+
+func (s *Stats) AddResolveTime(t time.Duration) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddInt64(&s.ResolveTime, int64(t))
+}
+
+func (s *Stats) LoadResolveTime() time.Duration {
+	if s == nil {
+		return 0
+	}
+
+	return time.Duration(atomic.LoadInt64(&s.ResolveTime))
+}
+
 // Merge the provided Stats into this one.
 func (s *Stats) Merge(other *Stats) {
 	if s == nil || other == nil {
@@ -168,6 +287,60 @@ func (s *Stats) Merge(other *Stats) {
 	s.AddShardedQueries(other.LoadShardedQueries())
 	s.AddSplitQueries(other.LoadSplitQueries())
 	s.AddFetchedIndexBytes(other.LoadFetchedIndexBytes())
+	s.AddBlocksQueried(other.LoadBlocksQueried())
+	s.AddProfilesScanned(other.LoadProfilesScanned())
+	s.AddStacktracesResolved(other.LoadStacktracesResolved())
+	s.AddFetchedBlockBytes(other.LoadFetchedBlockBytes())
+	s.AddSelectTime(other.LoadSelectTime())
+	s.AddMergeTime(other.LoadMergeTime())
+	s.AddResolveTime(other.LoadResolveTime())
+}
+
+// SetHeader encodes the stats into the Header of h.
+func (s *Stats) SetHeader(h http.Header) {
+	if s == nil {
+		return
+	}
+
+	// Copy the stats, as they may still be updated.
+	snapshot := &Stats{}
+	snapshot.Merge(s)
+	b, err := protojson.Marshal(snapshot)
+	if err != nil {
+		return
+	}
+	h.Set(Header, string(b))
+}
+
+// MergeHeader decodes the stats from the Header of h, if any, and merges
+// them into this one.
+func (s *Stats) MergeHeader(h http.Header) error {
+	v := h.Get(Header)
+	if s == nil || v == "" {
+		return nil
+	}
+
+	var other Stats
+	if err := protojson.Unmarshal([]byte(v), &other); err != nil {
+		return err
+	}
+	s.Merge(&other)
+	return nil
+}
+
+// LogFields returns the stats collected by the ingesters and store-gateways
+// as key-value pairs for a structured log line.
+func (s *Stats) LogFields() []interface{} {
+	return []interface{}{
+		"blocks_queried", s.LoadBlocksQueried(),
+		"series_fetched", s.LoadFetchedSeries(),
+		"profiles_scanned", s.LoadProfilesScanned(),
+		"stacktraces_resolved", s.LoadStacktracesResolved(),
+		"fetched_block_bytes", s.LoadFetchedBlockBytes(),
+		"select_time", s.LoadSelectTime(),
+		"merge_time", s.LoadMergeTime(),
+		"resolve_time", s.LoadResolveTime(),
+	}
 }
 
 func ShouldTrackHTTPGRPCResponse(r *httpgrpc.HTTPResponse) bool {
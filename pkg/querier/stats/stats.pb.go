@@ -44,6 +44,22 @@ type Stats struct {
 	SplitQueries uint32 `protobuf:"varint,6,opt,name=split_queries,json=splitQueries,proto3" json:"split_queries,omitempty"`
 	// The number of index bytes fetched on the store-gateway for the query
 	FetchedIndexBytes uint64 `protobuf:"varint,7,opt,name=fetched_index_bytes,json=fetchedIndexBytes,proto3" json:"fetched_index_bytes,omitempty"`
+	// The number of blocks, including the heads, queried on the ingesters and store-gateways
+	BlocksQueried uint64 `protobuf:"varint,8,opt,name=blocks_queried,json=blocksQueried,proto3" json:"blocks_queried,omitempty"`
+	// The number of profiles selected and streamed to the queriers for deduplication
+	ProfilesScanned uint64 `protobuf:"varint,9,opt,name=profiles_scanned,json=profilesScanned,proto3" json:"profiles_scanned,omitempty"`
+	// The number of stacktraces symbolized
+	StacktracesResolved uint64 `protobuf:"varint,10,opt,name=stacktraces_resolved,json=stacktracesResolved,proto3" json:"stacktraces_resolved,omitempty"`
+	// The number of bytes fetched from the bucket by the store-gateways for the blocks
+	// queried, while the query was running
+	FetchedBlockBytes uint64 `protobuf:"varint,11,opt,name=fetched_block_bytes,json=fetchedBlockBytes,proto3" json:"fetched_block_bytes,omitempty"`
+	// The sum of the time spent selecting the profiles on the ingesters and store-gateways
+	SelectTime int64 `protobuf:"varint,12,opt,name=select_time,json=selectTime,proto3" json:"select_time,omitempty"`
+	// The sum of the time spent merging the profiles on the ingesters and store-gateways,
+	// including the symbols resolution
+	MergeTime int64 `protobuf:"varint,13,opt,name=merge_time,json=mergeTime,proto3" json:"merge_time,omitempty"`
+	// The sum of the time spent resolving the symbols on the ingesters and store-gateways
+	ResolveTime int64 `protobuf:"varint,14,opt,name=resolve_time,json=resolveTime,proto3" json:"resolve_time,omitempty"`
 }
 
 func (x *Stats) Reset() {
@@ -127,12 +143,61 @@ func (x *Stats) GetFetchedIndexBytes() uint64 {
 	return 0
 }
 
+func (x *Stats) GetBlocksQueried() uint64 {
+	if x != nil {
+		return x.BlocksQueried
+	}
+	return 0
+}
+
+func (x *Stats) GetProfilesScanned() uint64 {
+	if x != nil {
+		return x.ProfilesScanned
+	}
+	return 0
+}
+
+func (x *Stats) GetStacktracesResolved() uint64 {
+	if x != nil {
+		return x.StacktracesResolved
+	}
+	return 0
+}
+
+func (x *Stats) GetFetchedBlockBytes() uint64 {
+	if x != nil {
+		return x.FetchedBlockBytes
+	}
+	return 0
+}
+
+func (x *Stats) GetSelectTime() int64 {
+	if x != nil {
+		return x.SelectTime
+	}
+	return 0
+}
+
+func (x *Stats) GetMergeTime() int64 {
+	if x != nil {
+		return x.MergeTime
+	}
+	return 0
+}
+
+func (x *Stats) GetResolveTime() int64 {
+	if x != nil {
+		return x.ResolveTime
+	}
+	return 0
+}
+
 var File_querier_stats_stats_proto protoreflect.FileDescriptor
 
 var file_querier_stats_stats_proto_rawDesc = []byte{
 	0x0a, 0x19, 0x71, 0x75, 0x65, 0x72, 0x69, 0x65, 0x72, 0x2f, 0x73, 0x74, 0x61, 0x74, 0x73, 0x2f,
 	0x73, 0x74, 0x61, 0x74, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x05, 0x73, 0x74, 0x61,
-	0x74, 0x73, 0x22, 0xb6, 0x02, 0x0a, 0x05, 0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x1b, 0x0a, 0x09,
+	0x74, 0x73, 0x22, 0xce, 0x04, 0x0a, 0x05, 0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x1b, 0x0a, 0x09,
 	0x77, 0x61, 0x6c, 0x6c, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52,
 	0x08, 0x77, 0x61, 0x6c, 0x6c, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x30, 0x0a, 0x14, 0x66, 0x65, 0x74,
 	0x63, 0x68, 0x65, 0x64, 0x5f, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x5f, 0x63, 0x6f, 0x75, 0x6e,
@@ -151,15 +216,33 @@ var file_querier_stats_stats_proto_rawDesc = []byte{
 	0x70, 0x6c, 0x69, 0x74, 0x51, 0x75, 0x65, 0x72, 0x69, 0x65, 0x73, 0x12, 0x2e, 0x0a, 0x13, 0x66,
 	0x65, 0x74, 0x63, 0x68, 0x65, 0x64, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x5f, 0x62, 0x79, 0x74,
 	0x65, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x04, 0x52, 0x11, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65,
-	0x64, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x42, 0x79, 0x74, 0x65, 0x73, 0x42, 0x78, 0x0a, 0x09, 0x63,
-	0x6f, 0x6d, 0x2e, 0x73, 0x74, 0x61, 0x74, 0x73, 0x42, 0x0a, 0x53, 0x74, 0x61, 0x74, 0x73, 0x50,
-	0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01, 0x5a, 0x2b, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63,
-	0x6f, 0x6d, 0x2f, 0x67, 0x72, 0x61, 0x66, 0x61, 0x6e, 0x61, 0x2f, 0x70, 0x68, 0x6c, 0x61, 0x72,
-	0x65, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x71, 0x75, 0x65, 0x72, 0x69, 0x65, 0x72, 0x2f, 0x73, 0x74,
-	0x61, 0x74, 0x73, 0xa2, 0x02, 0x03, 0x53, 0x58, 0x58, 0xaa, 0x02, 0x05, 0x53, 0x74, 0x61, 0x74,
-	0x73, 0xca, 0x02, 0x05, 0x53, 0x74, 0x61, 0x74, 0x73, 0xe2, 0x02, 0x11, 0x53, 0x74, 0x61, 0x74,
-	0x73, 0x5c, 0x47, 0x50, 0x42, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0xea, 0x02, 0x05,
-	0x53, 0x74, 0x61, 0x74, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x64, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x62,
+	0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x5f, 0x71, 0x75, 0x65, 0x72, 0x69, 0x65, 0x64, 0x18, 0x08, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x0d, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x51, 0x75, 0x65, 0x72, 0x69,
+	0x65, 0x64, 0x12, 0x29, 0x0a, 0x10, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x5f, 0x73,
+	0x63, 0x61, 0x6e, 0x6e, 0x65, 0x64, 0x18, 0x09, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0f, 0x70, 0x72,
+	0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x53, 0x63, 0x61, 0x6e, 0x6e, 0x65, 0x64, 0x12, 0x31, 0x0a,
+	0x14, 0x73, 0x74, 0x61, 0x63, 0x6b, 0x74, 0x72, 0x61, 0x63, 0x65, 0x73, 0x5f, 0x72, 0x65, 0x73,
+	0x6f, 0x6c, 0x76, 0x65, 0x64, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x04, 0x52, 0x13, 0x73, 0x74, 0x61,
+	0x63, 0x6b, 0x74, 0x72, 0x61, 0x63, 0x65, 0x73, 0x52, 0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x64,
+	0x12, 0x2e, 0x0a, 0x13, 0x66, 0x65, 0x74, 0x63, 0x68, 0x65, 0x64, 0x5f, 0x62, 0x6c, 0x6f, 0x63,
+	0x6b, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x04, 0x52, 0x11, 0x66,
+	0x65, 0x74, 0x63, 0x68, 0x65, 0x64, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x42, 0x79, 0x74, 0x65, 0x73,
+	0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x65, 0x6c, 0x65, 0x63, 0x74, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18,
+	0x0c, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x73, 0x65, 0x6c, 0x65, 0x63, 0x74, 0x54, 0x69, 0x6d,
+	0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18,
+	0x0d, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x54, 0x69, 0x6d, 0x65,
+	0x12, 0x21, 0x0a, 0x0c, 0x72, 0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65,
+	0x18, 0x0e, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x72, 0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x54,
+	0x69, 0x6d, 0x65, 0x42, 0x78, 0x0a, 0x09, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x74, 0x61, 0x74, 0x73,
+	0x42, 0x0a, 0x53, 0x74, 0x61, 0x74, 0x73, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01, 0x5a, 0x2b,
+	0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x72, 0x61, 0x66, 0x61,
+	0x6e, 0x61, 0x2f, 0x70, 0x68, 0x6c, 0x61, 0x72, 0x65, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x71, 0x75,
+	0x65, 0x72, 0x69, 0x65, 0x72, 0x2f, 0x73, 0x74, 0x61, 0x74, 0x73, 0xa2, 0x02, 0x03, 0x53, 0x58,
+	0x58, 0xaa, 0x02, 0x05, 0x53, 0x74, 0x61, 0x74, 0x73, 0xca, 0x02, 0x05, 0x53, 0x74, 0x61, 0x74,
+	0x73, 0xe2, 0x02, 0x11, 0x53, 0x74, 0x61, 0x74, 0x73, 0x5c, 0x47, 0x50, 0x42, 0x4d, 0x65, 0x74,
+	0x61, 0x64, 0x61, 0x74, 0x61, 0xea, 0x02, 0x05, 0x53, 0x74, 0x61, 0x74, 0x73, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
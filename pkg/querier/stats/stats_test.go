@@ -7,10 +7,12 @@ package stats
 
 import (
 	"context"
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStats_WallTime(t *testing.T) {
@@ -124,6 +126,9 @@ func TestStats_Merge(t *testing.T) {
 		stats1.AddFetchedChunks(10)
 		stats1.AddShardedQueries(20)
 		stats1.AddSplitQueries(10)
+		stats1.AddBlocksQueried(2)
+		stats1.AddProfilesScanned(40)
+		stats1.AddSelectTime(time.Second)
 
 		stats2 := &Stats{}
 		stats2.AddWallTime(time.Second)
@@ -132,6 +137,11 @@ func TestStats_Merge(t *testing.T) {
 		stats2.AddFetchedChunks(11)
 		stats2.AddShardedQueries(21)
 		stats2.AddSplitQueries(11)
+		stats2.AddBlocksQueried(3)
+		stats2.AddStacktracesResolved(500)
+		stats2.AddFetchedBlockBytes(6000)
+		stats2.AddMergeTime(time.Second)
+		stats2.AddResolveTime(time.Millisecond)
 
 		stats1.Merge(stats2)
 
@@ -141,6 +151,13 @@ func TestStats_Merge(t *testing.T) {
 		assert.Equal(t, uint64(21), stats1.LoadFetchedChunks())
 		assert.Equal(t, uint32(41), stats1.LoadShardedQueries())
 		assert.Equal(t, uint32(21), stats1.LoadSplitQueries())
+		assert.Equal(t, uint64(5), stats1.LoadBlocksQueried())
+		assert.Equal(t, uint64(40), stats1.LoadProfilesScanned())
+		assert.Equal(t, uint64(500), stats1.LoadStacktracesResolved())
+		assert.Equal(t, uint64(6000), stats1.LoadFetchedBlockBytes())
+		assert.Equal(t, time.Second, stats1.LoadSelectTime())
+		assert.Equal(t, time.Second, stats1.LoadMergeTime())
+		assert.Equal(t, time.Millisecond, stats1.LoadResolveTime())
 	})
 
 	t.Run("merge two nil stats objects", func(t *testing.T) {
@@ -157,3 +174,39 @@ func TestStats_Merge(t *testing.T) {
 		assert.Equal(t, uint32(0), stats1.LoadSplitQueries())
 	})
 }
+
+func TestStats_MergeHeader(t *testing.T) {
+	t.Run("merge the stats of a response header", func(t *testing.T) {
+		ingester := &Stats{}
+		ingester.AddBlocksQueried(2)
+		ingester.AddFetchedSeries(3)
+		ingester.AddProfilesScanned(40)
+		ingester.AddStacktracesResolved(500)
+		ingester.AddSelectTime(time.Second)
+		ingester.AddMergeTime(2 * time.Second)
+		h := http.Header{}
+		ingester.SetHeader(h)
+
+		stats := &Stats{}
+		stats.AddBlocksQueried(1)
+		require.NoError(t, stats.MergeHeader(h))
+		require.NoError(t, stats.MergeHeader(http.Header{}))
+		assert.Equal(t, uint64(3), stats.LoadBlocksQueried())
+		assert.Equal(t, uint64(3), stats.LoadFetchedSeries())
+		assert.Equal(t, uint64(40), stats.LoadProfilesScanned())
+		assert.Equal(t, uint64(500), stats.LoadStacktracesResolved())
+		assert.Equal(t, time.Second, stats.LoadSelectTime())
+		assert.Equal(t, 2*time.Second, stats.LoadMergeTime())
+
+		assert.Error(t, stats.MergeHeader(http.Header{Header: []string{"invalid"}}))
+	})
+
+	t.Run("merge the stats of a response header nil receiver", func(t *testing.T) {
+		var stats *Stats
+		h := http.Header{}
+		stats.SetHeader(h)
+
+		assert.Empty(t, h)
+		assert.NoError(t, stats.MergeHeader(http.Header{Header: []string{"{}"}}))
+	})
+}
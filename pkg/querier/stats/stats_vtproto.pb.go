@@ -48,6 +48,41 @@ func (m *Stats) MarshalToSizedBufferVT(dAtA []byte) (int, error) {
 		i -= len(m.unknownFields)
 		copy(dAtA[i:], m.unknownFields)
 	}
+	if m.ResolveTime != 0 {
+		i = encodeVarint(dAtA, i, uint64(m.ResolveTime))
+		i--
+		dAtA[i] = 0x70
+	}
+	if m.MergeTime != 0 {
+		i = encodeVarint(dAtA, i, uint64(m.MergeTime))
+		i--
+		dAtA[i] = 0x68
+	}
+	if m.SelectTime != 0 {
+		i = encodeVarint(dAtA, i, uint64(m.SelectTime))
+		i--
+		dAtA[i] = 0x60
+	}
+	if m.FetchedBlockBytes != 0 {
+		i = encodeVarint(dAtA, i, uint64(m.FetchedBlockBytes))
+		i--
+		dAtA[i] = 0x58
+	}
+	if m.StacktracesResolved != 0 {
+		i = encodeVarint(dAtA, i, uint64(m.StacktracesResolved))
+		i--
+		dAtA[i] = 0x50
+	}
+	if m.ProfilesScanned != 0 {
+		i = encodeVarint(dAtA, i, uint64(m.ProfilesScanned))
+		i--
+		dAtA[i] = 0x48
+	}
+	if m.BlocksQueried != 0 {
+		i = encodeVarint(dAtA, i, uint64(m.BlocksQueried))
+		i--
+		dAtA[i] = 0x40
+	}
 	if m.FetchedIndexBytes != 0 {
 		i = encodeVarint(dAtA, i, uint64(m.FetchedIndexBytes))
 		i--
@@ -124,6 +159,27 @@ func (m *Stats) SizeVT() (n int) {
 	if m.FetchedIndexBytes != 0 {
 		n += 1 + sov(uint64(m.FetchedIndexBytes))
 	}
+	if m.BlocksQueried != 0 {
+		n += 1 + sov(uint64(m.BlocksQueried))
+	}
+	if m.ProfilesScanned != 0 {
+		n += 1 + sov(uint64(m.ProfilesScanned))
+	}
+	if m.StacktracesResolved != 0 {
+		n += 1 + sov(uint64(m.StacktracesResolved))
+	}
+	if m.FetchedBlockBytes != 0 {
+		n += 1 + sov(uint64(m.FetchedBlockBytes))
+	}
+	if m.SelectTime != 0 {
+		n += 1 + sov(uint64(m.SelectTime))
+	}
+	if m.MergeTime != 0 {
+		n += 1 + sov(uint64(m.MergeTime))
+	}
+	if m.ResolveTime != 0 {
+		n += 1 + sov(uint64(m.ResolveTime))
+	}
 	n += len(m.unknownFields)
 	return n
 }
@@ -296,6 +352,139 @@ func (m *Stats) UnmarshalVT(dAtA []byte) error {
 					break
 				}
 			}
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlocksQueried", wireType)
+			}
+			m.BlocksQueried = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.BlocksQueried |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProfilesScanned", wireType)
+			}
+			m.ProfilesScanned = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ProfilesScanned |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StacktracesResolved", wireType)
+			}
+			m.StacktracesResolved = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StacktracesResolved |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedBlockBytes", wireType)
+			}
+			m.FetchedBlockBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FetchedBlockBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SelectTime", wireType)
+			}
+			m.SelectTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SelectTime |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MergeTime", wireType)
+			}
+			m.MergeTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MergeTime |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 14:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ResolveTime", wireType)
+			}
+			m.ResolveTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ResolveTime |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skip(dAtA[iNdEx:])
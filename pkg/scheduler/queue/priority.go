@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package queue
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PriorityHeader is the header of the query requests carrying their
+	// priority, either "interactive" (the default) or "background".
+	PriorityHeader = "X-Query-Priority"
+	// CostHeader is the header of the query requests carrying their cost
+	// estimate, set by the query-frontend.
+	CostHeader = "X-Query-Cost"
+
+	// DefaultCost is the cost of requests with no cost estimate.
+	DefaultCost = 1
+)
+
+// Priority of a request. Requests with a lower value are dequeued first.
+type Priority int
+
+const (
+	// PriorityInteractive is the priority of requests a user is waiting
+	// for, e.g. dashboards and explore queries.
+	PriorityInteractive Priority = iota
+	// PriorityBackground is the priority of requests nobody is actively
+	// waiting for, e.g. recording rules. They are dequeued only when no
+	// interactive request is pending for the querier.
+	PriorityBackground
+
+	numPriorities = int(PriorityBackground) + 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityBackground:
+		return "background"
+	default:
+		return "unknown"
+	}
+}
+
+// ParsePriority returns the priority of the header value. Unknown or empty
+// values are interactive.
+func ParsePriority(s string) Priority {
+	if strings.EqualFold(strings.TrimSpace(s), PriorityBackground.String()) {
+		return PriorityBackground
+	}
+	return PriorityInteractive
+}
+
+// ParseCost returns the cost of the header value, or DefaultCost if the
+// value is not a positive number.
+func ParseCost(s string) float64 {
+	c, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || !(c > 0) || math.IsInf(c, 1) {
+		return DefaultCost
+	}
+	return c
+}
+
+// PrioritizedRequest is a request with a priority and a cost estimate.
+// Requests not implementing it are interactive and have the default cost.
+type PrioritizedRequest interface {
+	Priority() Priority
+	Cost() float64
+}
+
+func requestPriority(r Request) Priority {
+	if p, ok := r.(PrioritizedRequest); ok {
+		if p := p.Priority(); p >= PriorityInteractive && p <= PriorityBackground {
+			return p
+		}
+	}
+	return PriorityInteractive
+}
+
+func requestCost(r Request) float64 {
+	if p, ok := r.(PrioritizedRequest); ok {
+		if c := p.Cost(); c > 0 {
+			return c
+		}
+	}
+	return DefaultCost
+}
@@ -50,7 +50,7 @@ type Request interface{}
 
 // RequestQueue holds incoming requests in per-user queues. It also assigns each user specified number of queriers,
 // and when querier asks for next request to handle (using GetNextRequestForQuerier), it returns requests
-// in a fair fashion.
+// by priority, then in a fair fashion weighted by the cost of the requests and the weight of the users.
 type RequestQueue struct {
 	services.Service
 
@@ -80,11 +80,14 @@ func NewRequestQueue(maxOutstandingPerTenant int, forgetDelay time.Duration, que
 }
 
 // EnqueueRequest puts the request into the queue. MaxQueries is user-specific value that specifies how many queriers can
-// this user use (zero or negative = all queriers). It is passed to each EnqueueRequest, because it can change
-// between calls.
+// this user use (zero or negative = all queriers). Weight is the user-specific share of the queriers relative to other
+// users (zero or negative = 1). Both are passed to each EnqueueRequest, because they can change between calls.
+//
+// Requests implementing PrioritizedRequest are dequeued by priority, and their cost is charged to the user when
+// dequeued: users issuing expensive requests get less requests dequeued than users issuing cheap ones.
 //
 // If request is successfully enqueued, successFn is called with the lock held, before any querier can receive the request.
-func (q *RequestQueue) EnqueueRequest(userID string, req Request, maxQueriers int, successFn func()) error {
+func (q *RequestQueue) EnqueueRequest(userID string, req Request, maxQueriers int, weight float64, successFn func()) error {
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
 
@@ -98,19 +101,26 @@ func (q *RequestQueue) EnqueueRequest(userID string, req Request, maxQueriers in
 		return errors.New("no queue found")
 	}
 
-	select {
-	case queue <- req:
-		q.queueLength.WithLabelValues(userID).Inc()
-		q.cond.Broadcast()
-		// Call this function while holding a lock. This guarantees that no querier can fetch the request before function returns.
-		if successFn != nil {
-			successFn()
+	if queue.len() >= q.queues.maxUserQueueSize {
+		if queue.len() == 0 {
+			q.queues.deleteQueue(userID)
 		}
-		return nil
-	default:
 		q.discardedRequests.WithLabelValues(userID).Inc()
 		return ErrTooManyRequests
 	}
+
+	if weight <= 0 {
+		weight = 1
+	}
+	queue.weight = weight
+	queue.enqueue(req)
+	q.queueLength.WithLabelValues(userID).Inc()
+	q.cond.Broadcast()
+	// Call this function while holding a lock. This guarantees that no querier can fetch the request before function returns.
+	if successFn != nil {
+		successFn()
+	}
+	return nil
 }
 
 // GetNextRequestForQuerier find next user queue and takes the next request off of it. Will block if there are no requests.
@@ -137,33 +147,27 @@ FindQueue:
 		return nil, last, err
 	}
 
-	for {
-		queue, userID, idx := q.queues.getNextQueueForQuerier(last.last, querierID)
-		last.last = idx
-		if queue == nil {
-			break
-		}
-
-		// Pick next request from the queue.
-		for {
-			request := <-queue
-			if len(queue) == 0 {
-				q.queues.deleteQueue(userID)
-			}
+	queue, userID, idx := q.queues.getNextQueueForQuerier(last.last, querierID)
+	last.last = idx
+	if queue == nil {
+		// There are no requests for this querier, so we can get back
+		// and wait for more requests.
+		querierWait = true
+		goto FindQueue
+	}
 
-			q.queueLength.WithLabelValues(userID).Dec()
+	// Pick next request from the queue.
+	request := q.queues.dequeue(queue)
+	if queue.len() == 0 {
+		q.queues.deleteQueue(userID)
+	}
 
-			// Tell close() we've processed a request.
-			q.cond.Broadcast()
+	q.queueLength.WithLabelValues(userID).Dec()
 
-			return request, last, nil
-		}
-	}
+	// Tell close() we've processed a request.
+	q.cond.Broadcast()
 
-	// There are no unexpired requests, so we can get back
-	// and wait for more requests.
-	querierWait = true
-	goto FindQueue
+	return request, last, nil
 }
 
 func (q *RequestQueue) forgetDisconnectedQueriers(_ context.Context) error {
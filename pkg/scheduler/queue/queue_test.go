@@ -42,7 +42,7 @@ func BenchmarkGetNextRequest(b *testing.B) {
 			for j := 0; j < numTenants; j++ {
 				tenantID := strconv.Itoa(j)
 
-				err := queue.EnqueueRequest(tenantID, "request", 0, nil)
+				err := queue.EnqueueRequest(tenantID, "request", 0, 1, nil)
 				if err != nil {
 					b.Fatal(err)
 				}
@@ -106,7 +106,7 @@ func BenchmarkQueueRequest(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		for i := 0; i < maxOutstandingPerTenant; i++ {
 			for j := 0; j < numTenants; j++ {
-				err := queues[n].EnqueueRequest(users[j], requests[j], 0, nil)
+				err := queues[n].EnqueueRequest(users[j], requests[j], 0, 1, nil)
 				if err != nil {
 					b.Fatal(err)
 				}
@@ -147,7 +147,7 @@ func TestRequestQueue_GetNextRequestForQuerier_ShouldGetRequestAfterReshardingBe
 
 	// Enqueue a request from an user which would be assigned to querier-1.
 	// NOTE: "user-1" hash falls in the querier-1 shard.
-	require.NoError(t, queue.EnqueueRequest("user-1", "request", 1, nil))
+	require.NoError(t, queue.EnqueueRequest("user-1", "request", 1, 1, nil))
 
 	startTime := time.Now()
 	querier2wg.Wait()
@@ -157,6 +157,124 @@ func TestRequestQueue_GetNextRequestForQuerier_ShouldGetRequestAfterReshardingBe
 	assert.GreaterOrEqual(t, waitTime.Milliseconds(), forgetDelay.Milliseconds())
 }
 
+type prioritizedRequest struct {
+	name     string
+	priority Priority
+	cost     float64
+}
+
+func (r prioritizedRequest) Priority() Priority { return r.priority }
+func (r prioritizedRequest) Cost() float64      { return r.cost }
+
+func newTestRequestQueue() *RequestQueue {
+	return NewRequestQueue(100, 0,
+		promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
+		promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}))
+}
+
+func dequeueAll(t *testing.T, queue *RequestQueue, n int) []string {
+	t.Helper()
+	var (
+		res = make([]string, 0, n)
+		idx = FirstUser()
+		req Request
+		err error
+	)
+	for i := 0; i < n; i++ {
+		req, idx, err = queue.GetNextRequestForQuerier(context.Background(), idx, "querier-1")
+		require.NoError(t, err)
+		res = append(res, req.(prioritizedRequest).name)
+	}
+	return res
+}
+
+func TestRequestQueue_Priority(t *testing.T) {
+	queue := newTestRequestQueue()
+	queue.RegisterQuerierConnection("querier-1")
+
+	require.NoError(t, queue.EnqueueRequest("user-1", prioritizedRequest{name: "rule-1", priority: PriorityBackground}, 0, 1, nil))
+	require.NoError(t, queue.EnqueueRequest("user-1", prioritizedRequest{name: "dashboard-1"}, 0, 1, nil))
+	require.NoError(t, queue.EnqueueRequest("user-2", prioritizedRequest{name: "rule-2", priority: PriorityBackground}, 0, 1, nil))
+	require.NoError(t, queue.EnqueueRequest("user-2", prioritizedRequest{name: "dashboard-2"}, 0, 1, nil))
+
+	// Interactive requests of all users are dequeued before background ones.
+	require.Equal(t, []string{"dashboard-1", "dashboard-2", "rule-1", "rule-2"}, dequeueAll(t, queue, 4))
+}
+
+func TestRequestQueue_Cost(t *testing.T) {
+	queue := newTestRequestQueue()
+	queue.RegisterQuerierConnection("querier-1")
+
+	// user-1 issues expensive requests, user-2 cheap ones.
+	for i := 0; i < 3; i++ {
+		require.NoError(t, queue.EnqueueRequest("user-1", prioritizedRequest{name: "expensive", cost: 4}, 0, 1, nil))
+	}
+	for i := 0; i < 8; i++ {
+		require.NoError(t, queue.EnqueueRequest("user-2", prioritizedRequest{name: "cheap", cost: 1}, 0, 1, nil))
+	}
+
+	require.Equal(t, []string{
+		"expensive", "cheap", "cheap", "cheap", "cheap",
+		"expensive", "cheap", "cheap", "cheap", "cheap",
+		"expensive",
+	}, dequeueAll(t, queue, 11))
+}
+
+func TestRequestQueue_Weight(t *testing.T) {
+	queue := newTestRequestQueue()
+	queue.RegisterQuerierConnection("querier-1")
+
+	for i := 0; i < 6; i++ {
+		require.NoError(t, queue.EnqueueRequest("user-1", prioritizedRequest{name: "user-1", cost: 1}, 0, 2, nil))
+		require.NoError(t, queue.EnqueueRequest("user-2", prioritizedRequest{name: "user-2", cost: 1}, 0, 1, nil))
+	}
+
+	// user-1 gets twice the share of user-2.
+	require.Equal(t, []string{
+		"user-1", "user-2", "user-1", "user-2", "user-1", "user-1",
+	}, dequeueAll(t, queue, 6))
+}
+
+func TestRequestQueue_IdleUserDoesNotAccumulateCredit(t *testing.T) {
+	queue := newTestRequestQueue()
+	queue.RegisterQuerierConnection("querier-1")
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, queue.EnqueueRequest("user-1", prioritizedRequest{name: "user-1", cost: 1}, 0, 1, nil))
+	}
+	require.Equal(t, []string{"user-1", "user-1", "user-1"}, dequeueAll(t, queue, 3))
+
+	// user-2 was idle: it starts from the current virtual time instead of
+	// getting all the queriers until it catches up with user-1.
+	for i := 0; i < 2; i++ {
+		require.NoError(t, queue.EnqueueRequest("user-2", prioritizedRequest{name: "user-2", cost: 1}, 0, 1, nil))
+	}
+	require.NoError(t, queue.EnqueueRequest("user-1", prioritizedRequest{name: "user-1", cost: 1}, 0, 1, nil))
+	require.Equal(t, []string{"user-2", "user-1", "user-2"}, dequeueAll(t, queue, 3))
+}
+
+func TestRequestQueue_TooManyRequests(t *testing.T) {
+	queue := NewRequestQueue(1, 0,
+		promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
+		promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}))
+
+	require.NoError(t, queue.EnqueueRequest("user-1", prioritizedRequest{name: "dashboard"}, 0, 1, nil))
+	require.ErrorIs(t, queue.EnqueueRequest("user-1", prioritizedRequest{name: "rule", priority: PriorityBackground}, 0, 1, nil), ErrTooManyRequests)
+}
+
+func TestParsePriorityAndCost(t *testing.T) {
+	require.Equal(t, PriorityInteractive, ParsePriority(""))
+	require.Equal(t, PriorityInteractive, ParsePriority("interactive"))
+	require.Equal(t, PriorityBackground, ParsePriority("Background"))
+	require.Equal(t, PriorityInteractive, ParsePriority("urgent"))
+
+	require.Equal(t, 12.5, ParseCost("12.5"))
+	require.Equal(t, float64(DefaultCost), ParseCost(""))
+	require.Equal(t, float64(DefaultCost), ParseCost("-1"))
+	require.Equal(t, float64(DefaultCost), ParseCost("NaN"))
+	require.Equal(t, float64(DefaultCost), ParseCost("+Inf"))
+}
+
 func TestContextCond(t *testing.T) {
 	t.Run("wait until broadcast", func(t *testing.T) {
 		t.Parallel()
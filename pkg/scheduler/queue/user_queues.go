@@ -48,10 +48,24 @@ type queues struct {
 
 	// Sorted list of querier names, used when creating per-user shard.
 	sortedQueriers []string
+
+	// Virtual time of the last dequeued request. Users whose queue is created
+	// start from it, so that idle users do not accumulate credit.
+	vtime float64
 }
 
 type userQueue struct {
-	ch chan Request
+	// Pending requests, by priority.
+	requests [numPriorities][]Request
+
+	// Weight of the user in the fair share of the queriers.
+	weight float64
+
+	// Virtual time of the user: the total cost of the requests dequeued for the
+	// user, divided by its weight. Queriers pick the user with the lowest virtual
+	// time, so that the users get a share of the queriers proportional to their
+	// weight, regardless of the cost of their requests.
+	vtime float64
 
 	// If not nil, only these queriers can handle user requests. If nil, all queriers can.
 	// We set this to nil if number of available queriers <= maxQueriers.
@@ -66,6 +80,37 @@ type userQueue struct {
 	index int
 }
 
+func (uq *userQueue) len() int {
+	n := 0
+	for _, r := range uq.requests {
+		n += len(r)
+	}
+	return n
+}
+
+// priority returns the highest priority of the pending requests.
+func (uq *userQueue) priority() Priority {
+	for p, r := range uq.requests {
+		if len(r) > 0 {
+			return Priority(p)
+		}
+	}
+	return Priority(numPriorities)
+}
+
+func (uq *userQueue) enqueue(req Request) {
+	p := requestPriority(req)
+	uq.requests[p] = append(uq.requests[p], req)
+}
+
+// before returns true if the user has a request to dequeue before the other user.
+func (uq *userQueue) before(other *userQueue) bool {
+	if p, o := uq.priority(), other.priority(); p != o {
+		return p < o
+	}
+	return uq.vtime < other.vtime
+}
+
 func newUserQueues(maxUserQueueSize int, forgetDelay time.Duration) *queues {
 	return &queues{
 		userQueues:       map[string]*userQueue{},
@@ -100,7 +145,7 @@ func (q *queues) deleteQueue(userID string) {
 // MaxQueriers is used to compute which queriers should handle requests for this user.
 // If maxQueriers is <= 0, all queriers can handle this user's requests.
 // If maxQueriers has changed since the last call, queriers for this are recomputed.
-func (q *queues) getOrAddQueue(userID string, maxQueriers int) chan Request {
+func (q *queues) getOrAddQueue(userID string, maxQueriers int) *userQueue {
 	// Empty user is not allowed, as that would break our users list ("" is used for free spot).
 	if userID == "" {
 		return nil
@@ -114,9 +159,10 @@ func (q *queues) getOrAddQueue(userID string, maxQueriers int) chan Request {
 
 	if uq == nil {
 		uq = &userQueue{
-			ch:    make(chan Request, q.maxUserQueueSize),
-			seed:  util.ShuffleShardSeed(userID, ""),
-			index: -1,
+			weight: 1,
+			vtime:  q.vtime,
+			seed:   util.ShuffleShardSeed(userID, ""),
+			index:  -1,
 		}
 		q.userQueues[userID] = uq
 
@@ -141,13 +187,15 @@ func (q *queues) getOrAddQueue(userID string, maxQueriers int) chan Request {
 		uq.queriers = shuffleQueriersForUser(uq.seed, maxQueriers, q.sortedQueriers, nil)
 	}
 
-	return uq.ch
+	return uq
 }
 
-// Finds next queue for the querier. To support fair scheduling between users, client is expected
-// to pass last user index returned by this function as argument. Is there was no previous
-// last user index, use -1.
-func (q *queues) getNextQueueForQuerier(lastUserIndex int, querierID string) (chan Request, string, int) {
+// Finds next queue for the querier. Among the users handled by the querier, the one with the
+// highest priority request, then with the lowest virtual time is picked. To support fair scheduling
+// between users with the same virtual time, client is expected to pass last user index returned
+// by this function as argument, users are then iterated starting from the next one. Is there was
+// no previous last user index, use -1.
+func (q *queues) getNextQueueForQuerier(lastUserIndex int, querierID string) (*userQueue, string, int) {
 	uid := lastUserIndex
 
 	// Ensure the querier is not shutting down. If the querier is shutting down, we shouldn't forward
@@ -156,6 +204,11 @@ func (q *queues) getNextQueueForQuerier(lastUserIndex int, querierID string) (ch
 		return nil, "", uid
 	}
 
+	var (
+		next     *userQueue
+		nextUser string
+		nextUID  int
+	)
 	for iters := 0; iters < len(q.users); iters++ {
 		uid = uid + 1
 
@@ -170,18 +223,37 @@ func (q *queues) getNextQueueForQuerier(lastUserIndex int, querierID string) (ch
 			continue
 		}
 
-		q := q.userQueues[u]
+		uq := q.userQueues[u]
 
-		if q.queriers != nil {
-			if _, ok := q.queriers[querierID]; !ok {
+		if uq.queriers != nil {
+			if _, ok := uq.queriers[querierID]; !ok {
 				// This querier is not handling the user.
 				continue
 			}
 		}
 
-		return q.ch, u, uid
+		if next == nil || uq.before(next) {
+			next, nextUser, nextUID = uq, u, uid
+		}
+	}
+	if next == nil {
+		return nil, "", uid
+	}
+	return next, nextUser, nextUID
+}
+
+// dequeue takes the next request off the user queue and charges its cost to the user.
+func (q *queues) dequeue(uq *userQueue) Request {
+	p := uq.priority()
+	req := uq.requests[p][0]
+	uq.requests[p][0] = nil
+	uq.requests[p] = uq.requests[p][1:]
+
+	if uq.vtime > q.vtime {
+		q.vtime = uq.vtime
 	}
-	return nil, "", uid
+	uq.vtime += requestCost(req) / uq.weight
+	return req
 }
 
 func (q *queues) addQuerierConnection(querierID string) {
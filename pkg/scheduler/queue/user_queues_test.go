@@ -36,7 +36,7 @@ func TestQueues(t *testing.T) {
 
 	// [one two]
 	qTwo := getOrAdd(t, uq, "two", 0)
-	assert.NotEqual(t, qOne, qTwo)
+	assert.NotSame(t, qOne, qTwo)
 
 	lastUserIndex = confirmOrderForQuerier(t, uq, "querier-1", lastUserIndex, qTwo, qOne, qTwo, qOne)
 	confirmOrderForQuerier(t, uq, "querier-2", -1, qOne, qTwo, qOne)
@@ -398,19 +398,19 @@ func generateQuerier(r *rand.Rand) string {
 	return fmt.Sprint("querier-", r.Int()%5)
 }
 
-func getOrAdd(t *testing.T, uq *queues, tenant string, maxQueriers int) chan Request {
+func getOrAdd(t *testing.T, uq *queues, tenant string, maxQueriers int) *userQueue {
 	q := uq.getOrAddQueue(tenant, maxQueriers)
 	assert.NotNil(t, q)
 	assert.NoError(t, isConsistent(uq))
-	assert.Equal(t, q, uq.getOrAddQueue(tenant, maxQueriers))
+	assert.Same(t, q, uq.getOrAddQueue(tenant, maxQueriers))
 	return q
 }
 
-func confirmOrderForQuerier(t *testing.T, uq *queues, querier string, lastUserIndex int, qs ...chan Request) int {
-	var n chan Request
+func confirmOrderForQuerier(t *testing.T, uq *queues, querier string, lastUserIndex int, qs ...*userQueue) int {
+	var n *userQueue
 	for _, q := range qs {
 		n, _, lastUserIndex = uq.getNextQueueForQuerier(lastUserIndex, querier)
-		assert.Equal(t, q, n)
+		assert.Same(t, q, n)
 		assert.NoError(t, isConsistent(uq))
 	}
 	return lastUserIndex
@@ -187,6 +187,8 @@ func NewScheduler(cfg Config, limits Limits, log log.Logger, registerer promethe
 type Limits interface {
 	// MaxQueriersPerTenant returns max queriers to use per tenant, or 0 if shuffle sharding is disabled.
 	MaxQueriersPerTenant(tenant string) int
+	// QueryTenantWeight returns the weight of the tenant in the fair share of the queriers.
+	QueryTenantWeight(tenant string) float64
 }
 
 type schedulerRequest struct {
@@ -195,6 +197,8 @@ type schedulerRequest struct {
 	queryID         uint64
 	request         *httpgrpc.HTTPRequest
 	statsEnabled    bool
+	priority        queue.Priority
+	cost            float64
 
 	enqueueTime time.Time
 
@@ -206,6 +210,31 @@ type schedulerRequest struct {
 	parentSpanContext opentracing.SpanContext
 }
 
+func (r *schedulerRequest) Priority() queue.Priority { return r.priority }
+
+func (r *schedulerRequest) Cost() float64 { return r.cost }
+
+// requestPriorityAndCost returns the priority and the cost estimate of the
+// request, carried by its headers.
+func requestPriorityAndCost(req *httpgrpc.HTTPRequest) (queue.Priority, float64) {
+	var (
+		priority = queue.PriorityInteractive
+		cost     = float64(queue.DefaultCost)
+	)
+	for _, h := range req.GetHeaders() {
+		if len(h.Values) == 0 {
+			continue
+		}
+		switch http.CanonicalHeaderKey(h.Key) {
+		case queue.PriorityHeader:
+			priority = queue.ParsePriority(h.Values[0])
+		case queue.CostHeader:
+			cost = queue.ParseCost(h.Values[0])
+		}
+	}
+	return priority, cost
+}
+
 // FrontendLoop handles connection from frontend.
 func (s *Scheduler) FrontendLoop(ctx context.Context, frontend *connect.BidiStream[schedulerpb.FrontendToScheduler, schedulerpb.SchedulerToFrontend]) error {
 	frontendAddress, frontendCtx, err := s.frontendConnected(frontend)
@@ -335,6 +364,7 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 		request:         msg.HttpRequest,
 		statsEnabled:    msg.StatsEnabled,
 	}
+	req.priority, req.cost = requestPriorityAndCost(msg.HttpRequest)
 
 	now := time.Now()
 
@@ -349,9 +379,10 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 		return err
 	}
 	maxQueriers := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, s.limits.MaxQueriersPerTenant)
+	weight := validation.SmallestPositiveNonZeroFloat64PerTenant(tenantIDs, s.limits.QueryTenantWeight)
 
 	s.activeUsers.UpdateUserTimestamp(userID, now)
-	return s.requestQueue.EnqueueRequest(userID, req, maxQueriers, func() {
+	return s.requestQueue.EnqueueRequest(userID, req, maxQueriers, weight, func() {
 		shouldCancel = false
 
 		s.pendingRequestsMu.Lock()
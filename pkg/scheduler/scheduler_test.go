@@ -35,6 +35,7 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/grafana/phlare/pkg/frontend/frontendpb"
+	"github.com/grafana/phlare/pkg/scheduler/queue"
 	"github.com/grafana/phlare/pkg/scheduler/schedulerpb"
 	"github.com/grafana/phlare/pkg/scheduler/schedulerpb/schedulerpbconnect"
 	"github.com/grafana/phlare/pkg/util"
@@ -504,6 +505,19 @@ func verifyQuerierDoesntReceiveRequest(t *testing.T, querierLoop schedulerpb.Sch
 	}
 }
 
+func TestRequestPriorityAndCost(t *testing.T) {
+	priority, cost := requestPriorityAndCost(&httpgrpc.HTTPRequest{})
+	require.Equal(t, queue.PriorityInteractive, priority)
+	require.Equal(t, float64(queue.DefaultCost), cost)
+
+	priority, cost = requestPriorityAndCost(&httpgrpc.HTTPRequest{Headers: []*httpgrpc.Header{
+		{Key: "x-query-priority", Values: []string{"background"}},
+		{Key: queue.CostHeader, Values: []string{"720"}},
+	}})
+	require.Equal(t, queue.PriorityBackground, priority)
+	require.Equal(t, 720.0, cost)
+}
+
 func verifyNoPendingRequestsLeft(t *testing.T, scheduler *Scheduler) {
 	test.Poll(t, 1*time.Second, 0, func() interface{} {
 		scheduler.pendingRequestsMu.Lock()
@@ -520,6 +534,10 @@ func (l limits) MaxQueriersPerTenant(_ string) int {
 	return l.queriers
 }
 
+func (l limits) QueryTenantWeight(_ string) float64 {
+	return 1
+}
+
 type frontendMock struct {
 	mu   sync.Mutex
 	resp map[uint64]*httpgrpc.HTTPResponse
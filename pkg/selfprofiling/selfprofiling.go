@@ -0,0 +1,210 @@
+package selfprofiling
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/prometheus/model/labels"
+
+	pushv1 "github.com/grafana/phlare/api/gen/proto/go/push/v1"
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+	"github.com/grafana/phlare/pkg/agent"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/tenant"
+)
+
+const (
+	profileTypeCPU       = "process_cpu"
+	profileTypeMemory    = "memory"
+	profileTypeGoroutine = "goroutine"
+	profileTypeMutex     = "mutex"
+	profileTypeBlock     = "block"
+
+	// ServiceName is the service name of the profiles of Phlare itself.
+	ServiceName = "phlare"
+)
+
+// runtimeProfiles maps the profile types to the name of the Go runtime profiles.
+var runtimeProfiles = map[string]string{
+	profileTypeMemory:    "allocs",
+	profileTypeGoroutine: "goroutine",
+	profileTypeMutex:     "mutex",
+	profileTypeBlock:     "block",
+}
+
+type Config struct {
+	MutexProfileFraction int `yaml:"mutex_profile_fraction,omitempty"`
+	BlockProfileRate     int `yaml:"block_profile_rate,omitempty"`
+
+	Enabled      bool                   `yaml:"enabled,omitempty"`
+	TenantID     string                 `yaml:"tenant_id,omitempty"`
+	Interval     time.Duration          `yaml:"interval,omitempty"`
+	CPUDuration  time.Duration          `yaml:"cpu_duration,omitempty"`
+	ProfileTypes flagext.StringSliceCSV `yaml:"profile_types,omitempty"`
+}
+
+func (c *Config) RegisterFlags(f *flag.FlagSet) {
+	// these are values that worked well in OG Pyroscope Cloud without adding much overhead
+	f.IntVar(&c.MutexProfileFraction, "self-profiling.mutex-profile-fraction", 5, "")
+	f.IntVar(&c.BlockProfileRate, "self-profiling.block-profile-rate", 5, "")
+
+	// Only the All target depends on the self-profiling module, so it is
+	// enabled by default for it only.
+	f.BoolVar(&c.Enabled, "self-profiling.enabled", true, "Enable the self-profiling module pushing the profiles of Phlare itself, when the target is all. Only one CPU profile can be collected at a time, so the CPU profile is not collected while the embedded agent scrapes the CPU profile of the same process.")
+	c.ProfileTypes = []string{profileTypeCPU, profileTypeMemory, profileTypeGoroutine, profileTypeMutex, profileTypeBlock}
+	f.StringVar(&c.TenantID, "self-profiling.tenant-id", tenant.DefaultTenantID, "Tenant ID to push the profiles of Phlare itself to, when the self-profiling module is enabled.")
+	f.DurationVar(&c.Interval, "self-profiling.interval", 15*time.Second, "How often the profiles of Phlare itself are collected.")
+	f.DurationVar(&c.CPUDuration, "self-profiling.cpu-duration", 10*time.Second, "Duration of the CPU profile collected every interval. It must be lower than the interval.")
+	f.Var(&c.ProfileTypes, "self-profiling.profile-types", "Comma-separated list of the profile types collected. Supported values: process_cpu, memory, goroutine, mutex, block.")
+}
+
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("self-profiling: interval must be positive")
+	}
+	if c.CPUDuration >= c.Interval {
+		return fmt.Errorf("self-profiling: cpu duration must be lower than the interval")
+	}
+	for _, pt := range c.ProfileTypes {
+		if _, ok := runtimeProfiles[pt]; !ok && pt != profileTypeCPU {
+			return fmt.Errorf("self-profiling: unsupported profile type %q", pt)
+		}
+	}
+	return nil
+}
+
+// SelfProfiler periodically collects the profiles of the process and pushes
+// them, so that Phlare can be used to debug Phlare.
+type SelfProfiler struct {
+	services.Service
+
+	cfg    Config
+	logger log.Logger
+	pusher agent.PusherClientProvider
+	labels []*typesv1.LabelPair
+	deltas map[string]*agent.DeltaProfiler
+	// cpuScraped reports whether the CPU profile of the process is scraped.
+	cpuScraped func() bool
+}
+
+// New creates a self profiler. The profiles are labelled with the given
+// component name and the instance, which is the hostname of the process.
+// The CPU profile is not collected while cpuScraped, if not nil, reports
+// that it is scraped already, e.g. through the /debug/pprof/profile endpoint.
+func New(cfg Config, component string, pusher agent.PusherClientProvider, cpuScraped func() bool, logger log.Logger) (*SelfProfiler, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	instance, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	p := &SelfProfiler{
+		cfg:    cfg,
+		logger: logger,
+		pusher: pusher,
+		labels: []*typesv1.LabelPair{
+			{Name: phlaremodel.LabelNameServiceName, Value: ServiceName},
+			{Name: "component", Value: component},
+			{Name: "instance", Value: instance},
+		},
+		deltas:     make(map[string]*agent.DeltaProfiler),
+		cpuScraped: cpuScraped,
+	}
+	for _, pt := range cfg.ProfileTypes {
+		if agent.IsCumulative(pt) {
+			p.deltas[pt] = agent.NewDeltaProfiler(pt)
+		}
+	}
+	p.Service = services.NewTimerService(cfg.Interval, nil, p.iteration, nil)
+	return p, nil
+}
+
+func (p *SelfProfiler) iteration(ctx context.Context) error {
+	req := &pushv1.PushRequest{}
+	for _, pt := range p.cfg.ProfileTypes {
+		if pt == profileTypeCPU && p.cpuScraped != nil && p.cpuScraped() {
+			// Collecting it would make the scrapes fail.
+			level.Debug(p.logger).Log("msg", "skipping the CPU profile scraped by the agent")
+			continue
+		}
+		b, err := p.collect(ctx, pt)
+		if err != nil {
+			level.Warn(p.logger).Log("msg", "failed to collect profile", "profile_type", pt, "err", err)
+			continue
+		}
+		if len(b) == 0 {
+			continue
+		}
+		req.Series = append(req.Series, p.series(pt, b))
+	}
+	if ctx.Err() != nil || len(req.Series) == 0 {
+		return nil
+	}
+	if _, err := p.pusher().Push(tenant.InjectTenantID(ctx, p.cfg.TenantID), connect.NewRequest(req)); err != nil {
+		level.Warn(p.logger).Log("msg", "failed to push profiles", "err", err)
+	}
+	// Errors are not returned, as they would stop the service.
+	return nil
+}
+
+// collect returns the pprof profile of the given type. Deltas are returned
+// for cumulative profiles.
+func (p *SelfProfiler) collect(ctx context.Context, profileType string) ([]byte, error) {
+	var buf bytes.Buffer
+	if profileType == profileTypeCPU {
+		// This fails if a CPU profile is already being collected, e.g. through
+		// the /debug/pprof/profile endpoint.
+		if err := pprof.StartCPUProfile(&buf); err != nil {
+			return nil, err
+		}
+		select {
+		case <-time.After(p.cfg.CPUDuration):
+		case <-ctx.Done():
+		}
+		pprof.StopCPUProfile()
+		return buf.Bytes(), nil
+	}
+	if err := pprof.Lookup(runtimeProfiles[profileType]).WriteTo(&buf, 0); err != nil {
+		return nil, err
+	}
+	if d, ok := p.deltas[profileType]; ok {
+		return d.Delta(buf.Bytes())
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *SelfProfiler) series(profileType string, b []byte) *pushv1.RawProfileSeries {
+	series := &pushv1.RawProfileSeries{
+		Labels: make([]*typesv1.LabelPair, 0, len(p.labels)+2),
+		Samples: []*pushv1.RawSample{
+			{RawProfile: b},
+		},
+	}
+	series.Labels = append(series.Labels, p.labels...)
+	series.Labels = append(series.Labels, &typesv1.LabelPair{
+		Name:  labels.MetricName,
+		Value: profileType,
+	})
+	if _, ok := p.deltas[profileType]; ok {
+		// Deltas are already computed.
+		series.Labels = append(series.Labels, &typesv1.LabelPair{
+			Name:  phlaremodel.LabelNameDelta,
+			Value: "false",
+		})
+	}
+	return series
+}
@@ -0,0 +1,87 @@
+package selfprofiling
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+
+	pushv1 "github.com/grafana/phlare/api/gen/proto/go/push/v1"
+	"github.com/grafana/phlare/api/gen/proto/go/push/v1/pushv1connect"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+	"github.com/grafana/phlare/pkg/pprof"
+	"github.com/grafana/phlare/pkg/tenant"
+)
+
+type fakePusher struct {
+	pushv1connect.UnimplementedPusherServiceHandler
+
+	tenantID string
+	req      *pushv1.PushRequest
+}
+
+func (f *fakePusher) Push(ctx context.Context, req *connect.Request[pushv1.PushRequest]) (*connect.Response[pushv1.PushResponse], error) {
+	f.tenantID, _ = tenant.ExtractTenantIDFromContext(ctx)
+	f.req = req.Msg
+	return connect.NewResponse(&pushv1.PushResponse{}), nil
+}
+
+func TestSelfProfiler(t *testing.T) {
+	pusher := &fakePusher{}
+	p, err := New(Config{
+		Enabled:      true,
+		TenantID:     "phlare",
+		Interval:     time.Minute,
+		CPUDuration:  100 * time.Millisecond,
+		ProfileTypes: []string{profileTypeCPU, profileTypeGoroutine, profileTypeMemory},
+	}, "ingester", func() pushv1connect.PusherServiceClient { return pusher }, nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	require.NoError(t, p.iteration(context.Background()))
+	require.Equal(t, "phlare", pusher.tenantID)
+
+	// The first memory profile is used as the base of the delta and is
+	// expected to be empty.
+	names := map[string]phlaremodel.Labels{}
+	for _, s := range pusher.req.Series {
+		lbls := phlaremodel.Labels(s.Labels)
+		names[lbls.Get("__name__")] = lbls
+		require.Equal(t, ServiceName, lbls.Get(phlaremodel.LabelNameServiceName))
+		require.Equal(t, "ingester", lbls.Get("component"))
+		require.NotEmpty(t, lbls.Get("instance"))
+		require.Len(t, s.Samples, 1)
+		_, err := pprof.RawFromBytes(s.Samples[0].RawProfile)
+		require.NoError(t, err)
+	}
+	require.Contains(t, names, profileTypeCPU)
+	require.Contains(t, names, profileTypeGoroutine)
+	require.Equal(t, "", names[profileTypeGoroutine].Get(phlaremodel.LabelNameDelta))
+}
+
+func TestSelfProfiler_CPUScraped(t *testing.T) {
+	pusher := &fakePusher{}
+	p, err := New(Config{
+		Enabled:      true,
+		TenantID:     "phlare",
+		Interval:     time.Minute,
+		CPUDuration:  100 * time.Millisecond,
+		ProfileTypes: []string{profileTypeCPU, profileTypeGoroutine},
+	}, "all", func() pushv1connect.PusherServiceClient { return pusher }, func() bool { return true }, log.NewNopLogger())
+	require.NoError(t, err)
+
+	// Only the CPU profile is skipped.
+	require.NoError(t, p.iteration(context.Background()))
+	require.Len(t, pusher.req.Series, 1)
+	require.Equal(t, profileTypeGoroutine, phlaremodel.Labels(pusher.req.Series[0].Labels).Get("__name__"))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	require.Error(t, (&Config{Enabled: true, Interval: time.Second, CPUDuration: time.Second}).Validate())
+	require.Error(t, (&Config{Enabled: true, Interval: time.Minute, ProfileTypes: []string{"unknown"}}).Validate())
+	require.NoError(t, (&Config{Enabled: true, Interval: time.Minute, ProfileTypes: []string{profileTypeMutex}}).Validate())
+	// The configuration is not validated when the self-profiling is disabled.
+	require.NoError(t, (&Config{Interval: time.Second, CPUDuration: time.Second}).Validate())
+}
@@ -2,12 +2,17 @@ package storegateway
 
 import (
 	"context"
+	"io"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/pkg/errors"
+	"go.uber.org/atomic"
 
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
 	"github.com/grafana/phlare/pkg/phlaredb"
 	"github.com/grafana/phlare/pkg/phlaredb/block"
 )
@@ -19,8 +24,18 @@ type BlockCloser interface {
 
 type Block struct {
 	BlockCloser
-	meta   *block.Meta
-	logger log.Logger
+	meta    *block.Meta
+	logger  log.Logger
+	metrics *Metrics
+
+	mtx      sync.Mutex
+	loaded   bool
+	inflight int
+	lastUsed time.Time
+
+	// fetchedBytes is the number of bytes fetched from the bucket by the
+	// block readers.
+	fetchedBytes *atomic.Uint64
 }
 
 func (bs *BucketStore) createBlock(ctx context.Context, meta *block.Meta) (*Block, error) {
@@ -47,9 +62,139 @@ func (bs *BucketStore) createBlock(ctx context.Context, meta *block.Meta) (*Bloc
 		}
 	}
 
+	fetchedBytes := atomic.NewUint64(0)
+	var bkt phlareobj.Bucket = &countingBucket{Bucket: bs.bucket, fetched: fetchedBytes}
+	if bs.cfg.IndexHeaderLazyLoadingEnabled {
+		h, err := loadIndexHeader(ctx, blockLocalPath, phlareobj.NewPrefixedBucket(bs.bucket, meta.ULID.String()), meta)
+		if err != nil {
+			return nil, errors.Wrap(err, "load index-header")
+		}
+		bkt = newIndexHeaderBucket(bkt, meta, h)
+	}
+
 	return &Block{
-		meta:        meta,
-		logger:      bs.logger,
-		BlockCloser: phlaredb.NewSingleBlockQuerierFromMeta(ctx, bs.bucket, meta),
+		meta:         meta,
+		logger:       bs.logger,
+		metrics:      bs.metrics,
+		BlockCloser:  phlaredb.NewSingleBlockQuerierFromMeta(ctx, bkt, meta),
+		fetchedBytes: fetchedBytes,
 	}, nil
 }
+
+// countingBucket counts the bytes fetched from the bucket.
+type countingBucket struct {
+	phlareobj.Bucket
+	fetched *atomic.Uint64
+}
+
+func (b *countingBucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	r, err := b.Bucket.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &countingReader{ReadCloser: r, fetched: b.fetched}, nil
+}
+
+func (b *countingBucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	r, err := b.Bucket.GetRange(ctx, name, off, length)
+	if err != nil {
+		return nil, err
+	}
+	return &countingReader{ReadCloser: r, fetched: b.fetched}, nil
+}
+
+func (b *countingBucket) ReaderAt(ctx context.Context, name string) (phlareobj.ReaderAtCloser, error) {
+	r, err := b.Bucket.ReaderAt(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &countingReaderAt{ReaderAtCloser: r, fetched: b.fetched}, nil
+}
+
+type countingReader struct {
+	io.ReadCloser
+	fetched *atomic.Uint64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.fetched.Add(uint64(n))
+	return n, err
+}
+
+type countingReaderAt struct {
+	phlareobj.ReaderAtCloser
+	fetched *atomic.Uint64
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.ReaderAtCloser.ReadAt(p, off)
+	r.fetched.Add(uint64(n))
+	return n, err
+}
+
+// acquire opens the block for reading if it is not loaded yet. The block is
+// not unloaded until it is released.
+func (b *Block) acquire(ctx context.Context) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if !b.loaded {
+		start := time.Now()
+		b.metrics.blockLazyLoads.Inc()
+		if err := b.Open(ctx); err != nil {
+			b.metrics.blockLazyLoadFailures.Inc()
+			return errors.Wrapf(err, "open block %s", b.meta.ULID)
+		}
+		b.metrics.blockLazyLoadDuration.Observe(time.Since(start).Seconds())
+		b.loaded = true
+	}
+	b.inflight++
+	b.lastUsed = time.Now()
+	return nil
+}
+
+func (b *Block) release() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.inflight--
+	b.lastUsed = time.Now()
+}
+
+// load opens the block for reading, ahead of the first query.
+func (b *Block) load(ctx context.Context) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.loaded {
+		return nil
+	}
+	if err := b.Open(ctx); err != nil {
+		return err
+	}
+	b.loaded = true
+	b.lastUsed = time.Now()
+	return nil
+}
+
+// unloadIfIdle closes the block readers if the block has not been used for
+// the given idle timeout. It returns true if the block has been unloaded.
+func (b *Block) unloadIfIdle(idleTimeout time.Duration) (bool, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if !b.loaded || b.inflight > 0 || time.Since(b.lastUsed) < idleTimeout {
+		return false, nil
+	}
+	b.loaded = false
+	b.metrics.blockUnloads.Inc()
+	return true, b.BlockCloser.Close()
+}
+
+// Close closes the block readers if the block is loaded.
+func (b *Block) Close() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if !b.loaded {
+		return nil
+	}
+	b.loaded = false
+	return b.BlockCloser.Close()
+}
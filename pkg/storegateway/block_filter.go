@@ -2,6 +2,7 @@ package storegateway
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/go-kit/log"
@@ -13,7 +14,9 @@ import (
 	"github.com/oklog/ulid"
 	"github.com/pkg/errors"
 	"github.com/prometheus/prometheus/model/timestamp"
+	"golang.org/x/sync/errgroup"
 
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
 	"github.com/grafana/phlare/pkg/phlaredb/block"
 )
 
@@ -228,3 +231,77 @@ func (f *minTimeMetaFilter) Filter(_ context.Context, metas map[ulid.ULID]*block
 	}
 	return nil
 }
+
+const supersededExcludedMeta = "superseded"
+
+// supersededMetaFilter filters out the blocks replaced by another block, such
+// as the blocks rewritten by the delete requests: the new block lists the
+// blocks it replaces in its compaction parents, and both are in the bucket
+// until the replaced blocks are deleted. It must run before the sharding
+// filter, as the blocks may be owned by different store-gateways.
+type supersededMetaFilter struct{}
+
+func newSupersededMetaFilter() *supersededMetaFilter {
+	return &supersededMetaFilter{}
+}
+
+func (f *supersededMetaFilter) Filter(_ context.Context, metas map[ulid.ULID]*block.Meta, synced tsdb_block.GaugeVec) error {
+	superseded := make(map[ulid.ULID]struct{})
+	for id, m := range metas {
+		for _, p := range m.Compaction.Parents {
+			if p.ULID != id {
+				superseded[p.ULID] = struct{}{}
+			}
+		}
+	}
+	for id := range superseded {
+		if _, ok := metas[id]; !ok {
+			continue
+		}
+		synced.WithLabelValues(supersededExcludedMeta).Inc()
+		delete(metas, id)
+	}
+	return nil
+}
+
+const markedForDeletionMeta = "marked-for-deletion"
+
+// deletionMarkMetaFilter filters out the blocks marked for deletion, so that
+// they are unloaded before being removed from the bucket.
+type deletionMarkMetaFilter struct {
+	bucket phlareobj.Bucket
+}
+
+func newDeletionMarkMetaFilter(bkt phlareobj.Bucket) *deletionMarkMetaFilter {
+	return &deletionMarkMetaFilter{bucket: bkt}
+}
+
+func (f *deletionMarkMetaFilter) Filter(ctx context.Context, metas map[ulid.ULID]*block.Meta, synced tsdb_block.GaugeVec) error {
+	var (
+		marked []ulid.ULID
+		mtx    sync.Mutex
+	)
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(64)
+	for id := range metas {
+		id := id
+		g.Go(func() error {
+			mark, err := block.ReadDeletionMark(ctx, f.bucket, id)
+			if err != nil || mark == nil {
+				return err
+			}
+			mtx.Lock()
+			marked = append(marked, id)
+			mtx.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	for _, id := range marked {
+		synced.WithLabelValues(markedForDeletionMeta).Inc()
+		delete(metas, id)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package storegateway
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
+	"github.com/grafana/phlare/pkg/phlaredb"
+	"github.com/grafana/phlare/pkg/phlaredb/block"
+	"github.com/grafana/phlare/pkg/phlaredb/tombstones"
+)
+
+func Test_BlockFilters_RewrittenBlock(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	bkt := phlareobj.NewBucket(objstore.NewInMemBucket())
+	blocksBucket := phlareobj.NewPrefixedBucket(bkt, "foo/phlaredb")
+
+	// A copy of the test block with a recent ULID, so that it is synced.
+	dir := t.TempDir()
+	src := copyBlock(t, "../phlaredb/block/testdata/01H3YE0W63FNXM69N2WVTRBYYK", dir)
+	require.NoError(t, block.Upload(ctx, logger, blocksBucket, src.dir))
+
+	filters := []BlockMetaFilter{
+		newSupersededMetaFilter(),
+		newDeletionMarkMetaFilter(blocksBucket),
+	}
+	store, err := NewBucketStore(bkt, BucketStoreConfig{}, "foo", t.TempDir(), filters, logger, NewMetrics(prometheus.NewRegistry()))
+	require.NoError(t, err)
+	// The profiles read by a query of the block time range.
+	query := func() (profiles uint64) {
+		require.NoError(t, store.SyncBlocks(ctx))
+		for _, b := range store.blockSet.getFor(src.meta.MinTime, src.meta.MaxTime) {
+			profiles += b.meta.Stats.NumProfiles
+		}
+		return profiles
+	}
+	require.Equal(t, src.meta.Stats.NumProfiles, query())
+
+	ts, err := tombstones.New(`{__name__="memory"}`, 0, src.meta.MaxTime, time.Now())
+	require.NoError(t, err)
+	newMeta, deleted, err := phlaredb.RewriteBlock(ctx, logger, src.dir, filepath.Join(dir, "rewritten"), tombstones.Tombstones{ts})
+	require.NoError(t, err)
+	require.NotZero(t, deleted)
+	require.NotNil(t, newMeta)
+	expected := src.meta.Stats.NumProfiles - deleted
+
+	// Both blocks are in the bucket, the rewritten one is superseded.
+	require.NoError(t, block.Upload(ctx, logger, blocksBucket, filepath.Join(dir, "rewritten", newMeta.ULID.String())))
+	require.Equal(t, expected, query())
+	require.Nil(t, store.getBlock(src.meta.ULID))
+
+	require.NoError(t, block.MarkForDeletion(ctx, logger, blocksBucket, src.meta.ULID, "test"))
+	require.Equal(t, expected, query())
+
+	require.NoError(t, block.Delete(ctx, logger, blocksBucket, src.meta.ULID))
+	require.Equal(t, expected, query())
+
+	// A block marked for deletion without replacement.
+	require.NoError(t, block.MarkForDeletion(ctx, logger, blocksBucket, newMeta.ULID, "test"))
+	require.Zero(t, query())
+}
+
+type testBlock struct {
+	dir  string
+	meta *block.Meta
+}
+
+func copyBlock(t *testing.T, src, dst string) testBlock {
+	t.Helper()
+	meta, err := block.ReadFromDir(src)
+	require.NoError(t, err)
+	meta.ULID = ulid.MustNew(ulid.Now(), nil)
+	dir := filepath.Join(dst, meta.ULID.String())
+	for _, f := range meta.Files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, f.RelPath)), 0o755))
+		copyFile(t, filepath.Join(src, f.RelPath), filepath.Join(dir, f.RelPath))
+	}
+	_, err = meta.WriteToFile(log.NewNopLogger(), dir)
+	require.NoError(t, err)
+	return testBlock{dir: dir, meta: meta}
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	r, err := os.Open(src)
+	require.NoError(t, err)
+	defer r.Close()
+	w, err := os.Create(dst)
+	require.NoError(t, err)
+	defer w.Close()
+	_, err = io.Copy(w, r)
+	require.NoError(t, err)
+}
@@ -0,0 +1,49 @@
+package storegateway
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"go.uber.org/atomic"
+
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
+)
+
+func Test_CountingBucket(t *testing.T) {
+	ctx := context.Background()
+	bkt := phlareobj.NewBucket(objstore.NewInMemBucket())
+	require.NoError(t, bkt.Upload(ctx, "foo", bytes.NewReader(make([]byte, 100))))
+
+	fetched := atomic.NewUint64(0)
+	b := &countingBucket{Bucket: bkt, fetched: fetched}
+
+	r, err := b.Get(ctx, "foo")
+	require.NoError(t, err)
+	_, err = io.Copy(io.Discard, r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, uint64(100), fetched.Load())
+
+	r, err = b.GetRange(ctx, "foo", 10, 20)
+	require.NoError(t, err)
+	_, err = io.Copy(io.Discard, r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, uint64(120), fetched.Load())
+
+	ra, err := b.ReaderAt(ctx, "foo")
+	require.NoError(t, err)
+	_, err = ra.ReadAt(make([]byte, 5), 50)
+	require.NoError(t, err)
+	require.NoError(t, ra.Close())
+	require.Equal(t, uint64(125), fetched.Load())
+
+	// The objects not found are not counted.
+	_, err = b.Get(ctx, "bar")
+	require.True(t, b.IsObjNotFoundErr(err))
+	require.Equal(t, uint64(125), fetched.Load())
+}
@@ -27,6 +27,7 @@ const blockSyncConcurrency = 100
 
 type BucketStore struct {
 	bucket            phlareobj.Bucket
+	cfg               BucketStoreConfig
 	tenantID, syncDir string
 
 	logger log.Logger
@@ -40,9 +41,10 @@ type BucketStore struct {
 	stats   storegateway.BucketStoreStats
 }
 
-func NewBucketStore(bucket phlareobj.Bucket, tenantID string, syncDir string, filters []BlockMetaFilter, logger log.Logger, Metrics *Metrics) (*BucketStore, error) {
+func NewBucketStore(bucket phlareobj.Bucket, cfg BucketStoreConfig, tenantID string, syncDir string, filters []BlockMetaFilter, logger log.Logger, Metrics *Metrics) (*BucketStore, error) {
 	s := &BucketStore{
 		bucket:   phlareobj.NewPrefixedBucket(bucket, tenantID+"/phlaredb"),
+		cfg:      cfg,
 		tenantID: tenantID,
 		syncDir:  syncDir,
 		logger:   logger,
@@ -168,19 +170,18 @@ func (bs *BucketStore) addBlock(ctx context.Context, meta *block.Meta) (err erro
 
 	bs.metrics.blockLoads.Inc()
 
-	b, err := func() (*Block, error) {
-		bs.blocksMx.Lock()
-		defer bs.blocksMx.Unlock()
-		b, err := bs.createBlock(ctx, meta)
-		if err != nil {
-			return nil, errors.Wrap(err, "load block from disk")
-		}
-		bs.blockSet.add(b)
-		bs.blocks[meta.ULID] = b
-		return b, nil
-	}()
+	b, err := bs.createBlock(ctx, meta)
 	if err != nil {
-		return err
+		return errors.Wrap(err, "load block from disk")
+	}
+	bs.blocksMx.Lock()
+	bs.blockSet.add(b)
+	bs.blocks[meta.ULID] = b
+	bs.blocksMx.Unlock()
+
+	// With lazy loading, the block is opened for reading on first query.
+	if bs.cfg.IndexHeaderLazyLoadingEnabled {
+		return nil
 	}
 	// Load the block into memory if it's within the last 24 hours.
 	// Todo make this configurable
@@ -195,13 +196,35 @@ func (bs *BucketStore) addBlock(ctx context.Context, meta *block.Meta) (err erro
 		defer func() {
 			level.Info(bs.logger).Log("msg", "block opened", "duration", time.Since(start), "id", meta.ULID.String())
 		}()
-		if err := b.Open(ctx); err != nil {
+		if err := b.load(ctx); err != nil {
 			level.Error(bs.logger).Log("msg", "open block", "err", err)
 		}
 	}
 	return nil
 }
 
+// unloadIdleBlocks closes the readers of the blocks which have not been
+// queried for the given idle timeout.
+func (s *BucketStore) unloadIdleBlocks(idleTimeout time.Duration) {
+	s.blocksMx.RLock()
+	blocks := make([]*Block, 0, len(s.blocks))
+	for _, b := range s.blocks {
+		blocks = append(blocks, b)
+	}
+	s.blocksMx.RUnlock()
+
+	for _, b := range blocks {
+		unloaded, err := b.unloadIfIdle(idleTimeout)
+		if err != nil {
+			level.Warn(s.logger).Log("msg", "failed to unload idle block", "id", b.meta.ULID, "err", err)
+			continue
+		}
+		if unloaded {
+			level.Debug(s.logger).Log("msg", "unloaded idle block", "id", b.meta.ULID)
+		}
+	}
+}
+
 func (b *BucketStore) Stats() storegateway.BucketStoreStats {
 	return b.stats
 }
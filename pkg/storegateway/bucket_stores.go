@@ -28,20 +28,27 @@ type BucketStoreConfig struct {
 	SyncInterval          time.Duration `yaml:"sync_interval" category:"advanced"`
 	TenantSyncConcurrency int           `yaml:"tenant_sync_concurrency" category:"advanced"`
 	IgnoreBlocksWithin    time.Duration `yaml:"ignore_blocks_within" category:"advanced"`
+
+	ChunksCache phlareobj.CacheConfig `yaml:"chunks_cache"`
+
+	IndexHeaderLazyLoadingEnabled     bool          `yaml:"index_header_lazy_loading_enabled" category:"advanced"`
+	IndexHeaderLazyLoadingIdleTimeout time.Duration `yaml:"index_header_lazy_loading_idle_timeout" category:"advanced"`
 }
 
 // RegisterFlags registers the BucketStore flags
 func (cfg *BucketStoreConfig) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	// cfg.IndexCache.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.index-cache.")
-	// cfg.ChunksCache.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.chunks-cache.", logger)
 	// cfg.MetadataCache.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.metadata-cache.")
 	// cfg.BucketIndex.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.bucket-index.")
 	// cfg.IndexHeader.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.index-header.")
+	cfg.ChunksCache.RegisterFlagsWithPrefix("blocks-storage.bucket-store.chunks-cache.", f)
 
 	f.StringVar(&cfg.SyncDir, "blocks-storage.bucket-store.sync-dir", "./data/pyroscope-sync/", "Directory to store synchronized pyroscope block headers. This directory is not required to be persisted between restarts, but it's highly recommended in order to improve the store-gateway startup time.")
 	f.DurationVar(&cfg.SyncInterval, "blocks-storage.bucket-store.sync-interval", 15*time.Minute, "How frequently to scan the bucket, or to refresh the bucket index (if enabled), in order to look for changes (new blocks shipped by ingesters and blocks deleted by retention or compaction).")
 	f.IntVar(&cfg.TenantSyncConcurrency, "blocks-storage.bucket-store.tenant-sync-concurrency", 10, "Maximum number of concurrent tenants synching blocks.")
 	f.DurationVar(&cfg.IgnoreBlocksWithin, "blocks-storage.bucket-store.ignore-blocks-within", 2*time.Hour, "Blocks with minimum time within this duration are ignored, and not loaded by store-gateway. Useful when used together with -querier.query-store-after to prevent loading young blocks, because there are usually many of them (depending on number of ingesters) and they are not yet compacted. Negative values or 0 disable the filter.")
+	f.BoolVar(&cfg.IndexHeaderLazyLoadingEnabled, "blocks-storage.bucket-store.index-header-lazy-loading-enabled", true, "If enabled, store-gateway will keep only the index-header of the blocks on the local disk, and open the blocks for reading only once required by a query.")
+	f.DurationVar(&cfg.IndexHeaderLazyLoadingIdleTimeout, "blocks-storage.bucket-store.index-header-lazy-loading-idle-timeout", 60*time.Minute, "If index-header lazy loading is enabled and this setting is > 0, the store-gateway will close the blocks readers after 'idle timeout' inactivity.")
 
 	// f.Uint64Var(&cfg.MaxChunkPoolBytes, "blocks-storage.bucket-store.max-chunk-pool-bytes", uint64(2*units.Gibibyte), "Max size - in bytes - of a chunks pool, used to reduce memory allocations. The pool is shared across all tenants. 0 to disable the limit.")
 	// f.IntVar(&cfg.ChunkPoolMinBucketSizeBytes, "blocks-storage.bucket-store.chunk-pool-min-bucket-size-bytes", ChunkPoolDefaultMinBucketSize, "Size - in bytes - of the smallest chunks pool bucket.")
@@ -54,8 +61,6 @@ func (cfg *BucketStoreConfig) RegisterFlags(f *flag.FlagSet, logger log.Logger)
 	// f.DurationVar(&cfg.IgnoreDeletionMarksDelay, "blocks-storage.bucket-store.ignore-deletion-marks-delay", time.Hour*1, "Duration after which the blocks marked for deletion will be filtered out while fetching blocks. "+
 	// 	"The idea of ignore-deletion-marks-delay is to ignore blocks that are marked for deletion with some delay. This ensures store can still serve blocks that are meant to be deleted but do not have a replacement yet.")
 	// f.IntVar(&cfg.PostingOffsetsInMemSampling, "blocks-storage.bucket-store.posting-offsets-in-mem-sampling", DefaultPostingOffsetInMemorySampling, "Controls what is the ratio of postings offsets that the store will hold in memory.")
-	// f.BoolVar(&cfg.IndexHeaderLazyLoadingEnabled, "blocks-storage.bucket-store.index-header-lazy-loading-enabled", true, "If enabled, store-gateway will lazy load an index-header only once required by a query.")
-	// f.DurationVar(&cfg.IndexHeaderLazyLoadingIdleTimeout, "blocks-storage.bucket-store.index-header-lazy-loading-idle-timeout", 60*time.Minute, "If index-header lazy loading is enabled and this setting is > 0, the store-gateway will offload unused index-headers after 'idle timeout' inactivity.")
 	// f.Uint64Var(&cfg.PartitionerMaxGapBytes, "blocks-storage.bucket-store.partitioner-max-gap-bytes", DefaultPartitionerMaxGapSize, "Max size - in bytes - of a gap for which the partitioner aggregates together two bucket GET object requests.")
 	// f.IntVar(&cfg.StreamingBatchSize, "blocks-storage.bucket-store.batch-series-size", 5000, "This option controls how many series to fetch per batch. The batch size must be greater than 0.")
 	// f.IntVar(&cfg.ChunkRangesPerSeries, "blocks-storage.bucket-store.fine-grained-chunks-caching-ranges-per-series", 1, "This option controls into how many ranges the chunks of each series from each block are split. This value is effectively the number of chunks cache items per series per block when -blocks-storage.bucket-store.chunks-cache.fine-grained-chunks-caching-enabled is enabled.")
@@ -70,9 +75,9 @@ func (cfg *BucketStoreConfig) Validate(logger log.Logger) error {
 	// if err := cfg.IndexCache.Validate(); err != nil {
 	// 	return errors.Wrap(err, "index-cache configuration")
 	// }
-	// if err := cfg.ChunksCache.Validate(); err != nil {
-	// 	return errors.Wrap(err, "chunks-cache configuration")
-	// }
+	if err := cfg.ChunksCache.Validate(); err != nil {
+		return errors.Wrap(err, "chunks-cache configuration")
+	}
 	// if err := cfg.MetadataCache.Validate(); err != nil {
 	// 	return errors.Wrap(err, "metadata-cache configuration")
 	// }
@@ -107,6 +112,10 @@ type BucketStores struct {
 }
 
 func NewBucketStores(cfg BucketStoreConfig, shardingStrategy ShardingStrategy, storageBucket phlareobj.Bucket, limits Limits, logger log.Logger, reg prometheus.Registerer) (*BucketStores, error) {
+	storageBucket, err := phlareobj.NewCachingBucket(storageBucket, cfg.ChunksCache, "chunks-cache", logger, reg)
+	if err != nil {
+		return nil, errors.Wrap(err, "create chunks cache")
+	}
 	bs := &BucketStores{
 		storageBucket: storageBucket,
 		logger:        logger,
@@ -154,6 +163,21 @@ func (bs *BucketStores) SyncBlocks(ctx context.Context) error {
 	})
 }
 
+// UnloadIdleBlocks closes the readers of the blocks which have not been
+// queried for the configured idle timeout, for every user.
+func (bs *BucketStores) UnloadIdleBlocks() {
+	bs.storesMu.RLock()
+	stores := make([]*BucketStore, 0, len(bs.stores))
+	for _, s := range bs.stores {
+		stores = append(stores, s)
+	}
+	bs.storesMu.RUnlock()
+
+	for _, s := range stores {
+		s.unloadIdleBlocks(bs.cfg.IndexHeaderLazyLoadingIdleTimeout)
+	}
+}
+
 func (bs *BucketStores) InitialSync(ctx context.Context) error {
 	level.Info(bs.logger).Log("msg", "synchronizing Pyroscope blocks for all users")
 
@@ -306,15 +330,19 @@ func (bs *BucketStores) getOrCreateStore(userID string) (*BucketStore, error) {
 
 	level.Info(userLogger).Log("msg", "creating user bucket store")
 
-	// The sharding strategy filter MUST be before the ones we create here (order matters).
+	// The sharding strategy filter MUST be before the ones we create here (order matters),
+	// except the superseded blocks filter which needs all the blocks of the tenant.
 	filters := []BlockMetaFilter{
+		newSupersededMetaFilter(),
 		NewShardingMetadataFilterAdapter(userID, bs.shardingStrategy),
+		newDeletionMarkMetaFilter(phlareobj.NewPrefixedBucket(bs.storageBucket, userID+"/phlaredb")),
 		// block.NewConsistencyDelayMetaFilter(userLogger, u.cfg.BucketStore.DeprecatedConsistencyDelay, fetcherReg),
 		newMinTimeMetaFilter(bs.cfg.IgnoreBlocksWithin),
 	}
 
 	s, err := NewBucketStore(
 		bs.storageBucket,
+		bs.cfg,
 		userID,
 		bs.syncDirForUser(userID),
 		filters,
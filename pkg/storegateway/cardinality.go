@@ -0,0 +1,33 @@
+package storegateway
+
+import (
+	"context"
+
+	"github.com/bufbuild/connect-go"
+
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
+)
+
+// Cardinality returns the cardinality of the series of the blocks of the
+// tenant, the querier merges it with the ones of the other store-gateways.
+func (s *StoreGateway) Cardinality(ctx context.Context, req *connect.Request[typesv1.CardinalityRequest]) (*connect.Response[typesv1.CardinalityResponse], error) {
+	if err := phlaremodel.ValidateCardinalityRequest(req.Msg); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	var series []*phlaremodel.SeriesTimeRange
+	_, err := s.forBucketStore(ctx, func(bs *BucketStore) error {
+		ctx, err := s.queryContext(ctx, bs)
+		if err != nil {
+			return err
+		}
+		if series, err = bs.SeriesTimeRanges(ctx, req.Msg); err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(phlaremodel.NewCardinalityResponse(req.Msg, series)), nil
+}
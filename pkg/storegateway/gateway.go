@@ -16,6 +16,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 
 	phlareobj "github.com/grafana/phlare/pkg/objstore"
+	"github.com/grafana/phlare/pkg/phlaredb"
+	"github.com/grafana/phlare/pkg/phlaredb/tombstones"
 	"github.com/grafana/phlare/pkg/util"
 	"github.com/grafana/phlare/pkg/validation"
 )
@@ -35,6 +37,7 @@ var errInvalidTenantShardSize = errors.New("invalid tenant shard size, the value
 
 type Limits interface {
 	storegateway.ShardingLimits
+	phlaredb.QueryLimits
 }
 
 type StoreGateway struct {
@@ -43,6 +46,7 @@ type StoreGateway struct {
 
 	gatewayCfg Config
 	stores     *BucketStores
+	tombstones *tombstones.Cache
 
 	// Ring used for sharding blocks.
 	ringLifecycler *ring.BasicLifecycler
@@ -77,7 +81,7 @@ func (c *Config) Validate(limits validation.Limits) error {
 	return nil
 }
 
-func NewStoreGateway(gatewayCfg Config, storageBucket phlareobj.Bucket, limits Limits, logger log.Logger, reg prometheus.Registerer) (*StoreGateway, error) {
+func NewStoreGateway(gatewayCfg Config, storageBucket phlareobj.Bucket, limits Limits, tombstones *tombstones.Cache, logger log.Logger, reg prometheus.Registerer) (*StoreGateway, error) {
 	ringStore, err := kv.NewClient(
 		gatewayCfg.ShardingRing.KVStore,
 		ring.GetCodec(),
@@ -88,15 +92,16 @@ func NewStoreGateway(gatewayCfg Config, storageBucket phlareobj.Bucket, limits L
 		return nil, errors.Wrap(err, "create KV store client")
 	}
 
-	return newStoreGateway(gatewayCfg, storageBucket, ringStore, limits, logger, reg)
+	return newStoreGateway(gatewayCfg, storageBucket, ringStore, limits, tombstones, logger, reg)
 }
 
-func newStoreGateway(gatewayCfg Config, storageBucket phlareobj.Bucket, ringStore kv.Client, limits Limits, logger log.Logger, reg prometheus.Registerer) (*StoreGateway, error) {
+func newStoreGateway(gatewayCfg Config, storageBucket phlareobj.Bucket, ringStore kv.Client, limits Limits, tombstones *tombstones.Cache, logger log.Logger, reg prometheus.Registerer) (*StoreGateway, error) {
 	var err error
 
 	g := &StoreGateway{
 		gatewayCfg: gatewayCfg,
 		logger:     logger,
+		tombstones: tombstones,
 		bucketSync: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name: "pyroscope_storegateway_bucket_sync_total",
 			Help: "Total number of times the bucket sync operation triggered.",
@@ -234,8 +239,20 @@ func (g *StoreGateway) running(ctx context.Context) error {
 	ringTicker := time.NewTicker(util.DurationWithJitter(g.gatewayCfg.ShardingRing.RingCheckPeriod, 0.2))
 	defer ringTicker.Stop()
 
+	// Blocks are unloaded only if lazily loaded: they would not be
+	// loaded again ahead of queries otherwise.
+	var unloadTickerC <-chan time.Time
+	bucketStoreCfg := g.gatewayCfg.BucketStoreConfig
+	if bucketStoreCfg.IndexHeaderLazyLoadingEnabled && bucketStoreCfg.IndexHeaderLazyLoadingIdleTimeout > 0 {
+		unloadTicker := time.NewTicker(util.DurationWithJitter(bucketStoreCfg.IndexHeaderLazyLoadingIdleTimeout/10, 0.2))
+		defer unloadTicker.Stop()
+		unloadTickerC = unloadTicker.C
+	}
+
 	for {
 		select {
+		case <-unloadTickerC:
+			g.stores.UnloadIdleBlocks()
 		case <-syncTicker.C:
 			g.syncStores(ctx, syncReasonPeriodic)
 		case <-ringTicker.C:
@@ -0,0 +1,384 @@
+package storegateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
+	"github.com/grafana/phlare/pkg/phlaredb/block"
+	"github.com/grafana/phlare/pkg/phlaredb/tsdb/index"
+)
+
+const (
+	// indexHeaderFilename is the name of the block index-header in the local sync dir.
+	indexHeaderFilename = "index-header"
+
+	indexHeaderMagic   = 0x50484948 // "PHIH"
+	indexHeaderVersion = 1
+
+	// parquetFooterLen is the length of the footer length and magic
+	// number at the end of parquet files.
+	parquetFooterLen = 8
+)
+
+var (
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+	errIndexHeaderCorrupted = errors.New("index-header corrupted")
+)
+
+// indexHeader holds the sections of the block files read whenever the block
+// is opened: the TSDB index symbols, postings offset table and table of
+// contents, and the parquet files footers. It is kept on the local disk, so
+// that opening a block only reads the rest of the files from the bucket.
+type indexHeader struct {
+	files map[string]*fileHeader
+}
+
+type fileHeader struct {
+	size int64
+	// Sections are sorted by offset and do not overlap.
+	sections []fileSection
+}
+
+type fileSection struct {
+	off  int64
+	data []byte
+}
+
+func (s fileSection) end() int64 { return s.off + int64(len(s.data)) }
+
+// read returns the data of the range if it is fully covered by a section.
+func (f *fileHeader) read(off, length int64) ([]byte, bool) {
+	i := sort.Search(len(f.sections), func(i int) bool {
+		return f.sections[i].end() > off
+	})
+	if i == len(f.sections) {
+		return nil, false
+	}
+	s := f.sections[i]
+	if off < s.off || off+length > s.end() {
+		return nil, false
+	}
+	return s.data[off-s.off : off-s.off+length], true
+}
+
+// buildIndexHeader reads the index-header sections of the block files from
+// the bucket, with ranged reads.
+func buildIndexHeader(ctx context.Context, bkt phlareobj.BucketReader, meta *block.Meta) (*indexHeader, error) {
+	h := &indexHeader{files: make(map[string]*fileHeader)}
+	for _, f := range meta.Files {
+		var build func(context.Context, phlareobj.BucketReader, string, int64) (*fileHeader, error)
+		switch {
+		case f.RelPath == block.IndexFilename:
+			build = buildTSDBIndexHeader
+		case strings.HasSuffix(f.RelPath, block.ParquetSuffix):
+			build = buildParquetHeader
+		default:
+			continue
+		}
+		size := int64(f.SizeBytes)
+		if size == 0 {
+			attrs, err := bkt.Attributes(ctx, f.RelPath)
+			if err != nil {
+				return nil, errors.Wrapf(err, "getting attributes for '%s'", f.RelPath)
+			}
+			size = attrs.Size
+		}
+		fh, err := build(ctx, bkt, f.RelPath, size)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read '%s' header", f.RelPath)
+		}
+		h.files[f.RelPath] = fh
+	}
+	return h, nil
+}
+
+// buildTSDBIndexHeader reads the TSDB index sections up to the series, and
+// from the postings offset table to the end of the file.
+func buildTSDBIndexHeader(ctx context.Context, bkt phlareobj.BucketReader, name string, size int64) (*fileHeader, error) {
+	if size < index.TOCLen {
+		return nil, errIndexHeaderCorrupted
+	}
+	b, err := readRange(ctx, bkt, name, size-index.TOCLen, index.TOCLen)
+	if err != nil {
+		return nil, err
+	}
+	toc, err := index.NewTOCFromByteSlice(index.RealByteSlice(b))
+	if err != nil {
+		return nil, errors.Wrap(err, "read TOC")
+	}
+	if toc.Series > toc.PostingsTable || toc.PostingsTable > uint64(size) {
+		return nil, errIndexHeaderCorrupted
+	}
+	head, err := readRange(ctx, bkt, name, 0, int64(toc.Series))
+	if err != nil {
+		return nil, err
+	}
+	tail, err := readRange(ctx, bkt, name, int64(toc.PostingsTable), size-int64(toc.PostingsTable))
+	if err != nil {
+		return nil, err
+	}
+	return &fileHeader{
+		size: size,
+		sections: []fileSection{
+			{off: 0, data: head},
+			{off: int64(toc.PostingsTable), data: tail},
+		},
+	}, nil
+}
+
+// buildParquetHeader reads the parquet file footer.
+func buildParquetHeader(ctx context.Context, bkt phlareobj.BucketReader, name string, size int64) (*fileHeader, error) {
+	if size < parquetFooterLen {
+		return nil, errIndexHeaderCorrupted
+	}
+	b, err := readRange(ctx, bkt, name, size-parquetFooterLen, parquetFooterLen)
+	if err != nil {
+		return nil, err
+	}
+	footerLen := int64(binary.LittleEndian.Uint32(b[:4]))
+	if string(b[4:]) != "PAR1" || footerLen+parquetFooterLen > size {
+		return nil, errIndexHeaderCorrupted
+	}
+	off := size - parquetFooterLen - footerLen
+	footer, err := readRange(ctx, bkt, name, off, footerLen)
+	if err != nil {
+		return nil, err
+	}
+	return &fileHeader{
+		size:     size,
+		sections: []fileSection{{off: off, data: append(footer, b...)}},
+	}, nil
+}
+
+func readRange(ctx context.Context, bkt phlareobj.BucketReader, name string, off, length int64) ([]byte, error) {
+	if length == 0 {
+		return []byte{}, nil
+	}
+	rc, err := bkt.GetRange(ctx, name, off, length)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b := make([]byte, length)
+	if _, err = io.ReadFull(rc, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// loadIndexHeader reads the block index-header from the local dir, or builds
+// it from the bucket and writes it to the local dir if it is missing or
+// corrupted.
+func loadIndexHeader(ctx context.Context, dir string, bkt phlareobj.BucketReader, meta *block.Meta) (*indexHeader, error) {
+	path := filepath.Join(dir, indexHeaderFilename)
+	if b, err := os.ReadFile(path); err == nil {
+		if h, err := decodeIndexHeader(b); err == nil {
+			return h, nil
+		}
+	}
+	h, err := buildIndexHeader(ctx, bkt, meta)
+	if err != nil {
+		return nil, err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, h.encode(), 0o640); err != nil {
+		return nil, errors.Wrap(err, "write index-header")
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return nil, errors.Wrap(err, "write index-header")
+	}
+	return h, nil
+}
+
+// encode returns the index-header binary representation:
+//
+//	magic (4b) | version (1b) | files count (uvarint)
+//	for each file: name length (uvarint) | name | size (uvarint) | sections count (uvarint)
+//	  for each section: offset (uvarint) | length (uvarint) | data
+//	CRC32 of the above (4b)
+func (h *indexHeader) encode() []byte {
+	names := make([]string, 0, len(h.files))
+	for name := range h.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := binary.BigEndian.AppendUint32(nil, indexHeaderMagic)
+	b = append(b, indexHeaderVersion)
+	b = binary.AppendUvarint(b, uint64(len(names)))
+	for _, name := range names {
+		f := h.files[name]
+		b = binary.AppendUvarint(b, uint64(len(name)))
+		b = append(b, name...)
+		b = binary.AppendUvarint(b, uint64(f.size))
+		b = binary.AppendUvarint(b, uint64(len(f.sections)))
+		for _, s := range f.sections {
+			b = binary.AppendUvarint(b, uint64(s.off))
+			b = binary.AppendUvarint(b, uint64(len(s.data)))
+			b = append(b, s.data...)
+		}
+	}
+	return binary.BigEndian.AppendUint32(b, crc32.Checksum(b, castagnoli))
+}
+
+func decodeIndexHeader(b []byte) (*indexHeader, error) {
+	if len(b) < 9 {
+		return nil, errIndexHeaderCorrupted
+	}
+	crc := binary.BigEndian.Uint32(b[len(b)-4:])
+	b = b[:len(b)-4]
+	if crc32.Checksum(b, castagnoli) != crc {
+		return nil, errIndexHeaderCorrupted
+	}
+	if binary.BigEndian.Uint32(b) != indexHeaderMagic || b[4] != indexHeaderVersion {
+		return nil, errIndexHeaderCorrupted
+	}
+	d := indexHeaderDecoder{b: b[5:]}
+	n := d.uvarint()
+	h := &indexHeader{files: make(map[string]*fileHeader, n)}
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		name := string(d.bytes(d.uvarint()))
+		f := &fileHeader{size: int64(d.uvarint())}
+		sections := d.uvarint()
+		for j := uint64(0); j < sections && d.err == nil; j++ {
+			off := int64(d.uvarint())
+			f.sections = append(f.sections, fileSection{off: off, data: d.bytes(d.uvarint())})
+		}
+		h.files[name] = f
+	}
+	if d.err != nil || len(d.b) > 0 {
+		return nil, errIndexHeaderCorrupted
+	}
+	return h, nil
+}
+
+type indexHeaderDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *indexHeaderDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errIndexHeaderCorrupted
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *indexHeaderDecoder) bytes(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.b)) {
+		d.err = errIndexHeaderCorrupted
+		return nil
+	}
+	v := d.b[:n:n]
+	d.b = d.b[n:]
+	return v
+}
+
+// indexHeaderBucket serves the reads of the block files covered by the
+// index-header locally, and the rest from the bucket.
+type indexHeaderBucket struct {
+	phlareobj.Bucket
+	// prefix of the block files names.
+	prefix string
+	header *indexHeader
+}
+
+func newIndexHeaderBucket(bkt phlareobj.Bucket, meta *block.Meta, h *indexHeader) phlareobj.Bucket {
+	return &indexHeaderBucket{
+		Bucket: bkt,
+		prefix: meta.ULID.String() + "/",
+		header: h,
+	}
+}
+
+func (b *indexHeaderBucket) file(name string) *fileHeader {
+	if !strings.HasPrefix(name, b.prefix) {
+		return nil
+	}
+	return b.header.files[strings.TrimPrefix(name, b.prefix)]
+}
+
+// Get reads the sections of the file missing from the index-header with
+// ranged reads.
+func (b *indexHeaderBucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	f := b.file(name)
+	if f == nil {
+		return b.Bucket.Get(ctx, name)
+	}
+	buf := make([]byte, f.size)
+	var off int64
+	fill := func(end int64) error {
+		if off >= end {
+			return nil
+		}
+		data, err := readRange(ctx, b.Bucket, name, off, end-off)
+		if err != nil {
+			return err
+		}
+		copy(buf[off:], data)
+		return nil
+	}
+	for _, s := range f.sections {
+		if err := fill(s.off); err != nil {
+			return nil, err
+		}
+		copy(buf[s.off:], s.data)
+		off = s.end()
+	}
+	if err := fill(f.size); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(buf)), nil
+}
+
+func (b *indexHeaderBucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	if f := b.file(name); f != nil {
+		if data, ok := f.read(off, length); ok {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+	return b.Bucket.GetRange(ctx, name, off, length)
+}
+
+func (b *indexHeaderBucket) ReaderAt(ctx context.Context, name string) (phlareobj.ReaderAtCloser, error) {
+	r, err := b.Bucket.ReaderAt(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if f := b.file(name); f != nil {
+		return &indexHeaderReaderAt{ReaderAtCloser: r, file: f}, nil
+	}
+	return r, nil
+}
+
+type indexHeaderReaderAt struct {
+	phlareobj.ReaderAtCloser
+	file *fileHeader
+}
+
+func (r *indexHeaderReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if data, ok := r.file.read(off, int64(len(p))); ok {
+		return copy(p, data), nil
+	}
+	return r.ReaderAtCloser.ReadAt(p, off)
+}
@@ -0,0 +1,191 @@
+package storegateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
+	"github.com/grafana/phlare/pkg/objstore/providers/filesystem"
+	"github.com/grafana/phlare/pkg/phlaredb"
+	"github.com/grafana/phlare/pkg/phlaredb/block"
+)
+
+const testdataDir = "../phlaredb/block/testdata/"
+
+var errRemoteRead = errors.New("remote read")
+
+// remoteReadsFailBucket fails all ranged reads of the files.
+type remoteReadsFailBucket struct {
+	phlareobj.Bucket
+}
+
+func (b *remoteReadsFailBucket) GetRange(context.Context, string, int64, int64) (io.ReadCloser, error) {
+	return nil, errRemoteRead
+}
+
+func (b *remoteReadsFailBucket) ReaderAt(context.Context, string) (phlareobj.ReaderAtCloser, error) {
+	return &remoteReadsFailReaderAt{}, nil
+}
+
+type remoteReadsFailReaderAt struct{}
+
+func (remoteReadsFailReaderAt) ReadAt([]byte, int64) (int, error) { return 0, errRemoteRead }
+func (remoteReadsFailReaderAt) Close() error                      { return nil }
+
+func testBlockMetas(t *testing.T) (phlareobj.Bucket, []*block.Meta) {
+	t.Helper()
+	bkt, err := filesystem.NewBucket(testdataDir)
+	require.NoError(t, err)
+	ctx := context.Background()
+	metas, err := phlaredb.NewBlockQuerier(ctx, bkt).BlockMetas(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, metas)
+	return bkt, metas
+}
+
+func Test_IndexHeader_BuildEncodeDecode(t *testing.T) {
+	bkt, metas := testBlockMetas(t)
+	ctx := context.Background()
+
+	for _, meta := range metas {
+		meta := meta
+		t.Run(meta.ULID.String(), func(t *testing.T) {
+			h, err := buildIndexHeader(ctx, phlareobj.NewPrefixedBucket(bkt, meta.ULID.String()), meta)
+			require.NoError(t, err)
+			require.Contains(t, h.files, block.IndexFilename)
+			require.Contains(t, h.files, "profiles.parquet")
+
+			decoded, err := decodeIndexHeader(h.encode())
+			require.NoError(t, err)
+			require.Equal(t, h, decoded)
+
+			b := h.encode()
+			b[len(b)/2]++
+			_, err = decodeIndexHeader(b)
+			require.ErrorIs(t, err, errIndexHeaderCorrupted)
+		})
+	}
+}
+
+func Test_IndexHeader_Load(t *testing.T) {
+	bkt, metas := testBlockMetas(t)
+	meta := metas[0]
+	ctx := context.Background()
+	dir := t.TempDir()
+	blockBkt := phlareobj.NewPrefixedBucket(bkt, meta.ULID.String())
+
+	h, err := loadIndexHeader(ctx, dir, blockBkt, meta)
+	require.NoError(t, err)
+	path := filepath.Join(dir, indexHeaderFilename)
+	require.FileExists(t, path)
+
+	// The local index-header is used without reading the bucket.
+	loaded, err := loadIndexHeader(ctx, dir, &remoteReadsFailBucket{Bucket: blockBkt}, meta)
+	require.NoError(t, err)
+	require.Equal(t, h, loaded)
+
+	// A corrupted index-header is built again.
+	require.NoError(t, os.WriteFile(path, []byte("corrupted"), 0o640))
+	loaded, err = loadIndexHeader(ctx, dir, blockBkt, meta)
+	require.NoError(t, err)
+	require.Equal(t, h, loaded)
+}
+
+func Test_IndexHeaderBucket(t *testing.T) {
+	bkt, metas := testBlockMetas(t)
+	ctx := context.Background()
+
+	for _, meta := range metas {
+		meta := meta
+		t.Run(meta.ULID.String(), func(t *testing.T) {
+			h, err := buildIndexHeader(ctx, phlareobj.NewPrefixedBucket(bkt, meta.ULID.String()), meta)
+			require.NoError(t, err)
+
+			// The TSDB index is assembled from the index-header and the bucket.
+			rc, err := newIndexHeaderBucket(bkt, meta, h).Get(ctx, meta.ULID.String()+"/"+block.IndexFilename)
+			require.NoError(t, err)
+			actual, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			expected, err := os.ReadFile(filepath.Join(testdataDir, meta.ULID.String(), block.IndexFilename))
+			require.NoError(t, err)
+			require.True(t, bytes.Equal(expected, actual))
+
+			// The parquet footers are read locally.
+			hb := newIndexHeaderBucket(&remoteReadsFailBucket{Bucket: bkt}, meta, h)
+			name := meta.ULID.String() + "/profiles.parquet"
+			f := h.files["profiles.parquet"]
+			r, err := hb.ReaderAt(ctx, name)
+			require.NoError(t, err)
+			footer := make([]byte, parquetFooterLen)
+			_, err = r.ReadAt(footer, f.size-parquetFooterLen)
+			require.NoError(t, err)
+			require.Equal(t, "PAR1", string(footer[4:]))
+			_, err = r.ReadAt(footer, 0)
+			require.ErrorIs(t, err, errRemoteRead)
+			_, err = hb.GetRange(ctx, name, f.size-parquetFooterLen, parquetFooterLen)
+			require.NoError(t, err)
+
+			// The block can be opened through the index-header bucket.
+			q := phlaredb.NewSingleBlockQuerierFromMeta(ctx, newIndexHeaderBucket(bkt, meta, h), meta)
+			require.NoError(t, q.Open(ctx))
+			require.NoError(t, q.Close())
+		})
+	}
+}
+
+type fakeBlockCloser struct {
+	BlockCloser
+	opened, closed int
+}
+
+func (b *fakeBlockCloser) Open(context.Context) error { b.opened++; return nil }
+func (b *fakeBlockCloser) Close() error               { b.closed++; return nil }
+
+func Test_Block_LazyLoading(t *testing.T) {
+	q := new(fakeBlockCloser)
+	b := &Block{
+		BlockCloser: q,
+		meta:        &block.Meta{},
+		metrics:     NewMetrics(prometheus.NewRegistry()),
+	}
+	ctx := context.Background()
+
+	// Closing a block never loaded is a no-op.
+	require.NoError(t, b.Close())
+	require.Equal(t, 0, q.closed)
+
+	require.NoError(t, b.acquire(ctx))
+	require.NoError(t, b.acquire(ctx))
+	require.Equal(t, 1, q.opened)
+
+	// In-flight blocks are not unloaded.
+	unloaded, err := b.unloadIfIdle(0)
+	require.NoError(t, err)
+	require.False(t, unloaded)
+
+	b.release()
+	b.release()
+	unloaded, err = b.unloadIfIdle(time.Hour)
+	require.NoError(t, err)
+	require.False(t, unloaded)
+	unloaded, err = b.unloadIfIdle(0)
+	require.NoError(t, err)
+	require.True(t, unloaded)
+	require.Equal(t, 1, q.closed)
+
+	// The block is loaded again on the next query.
+	require.NoError(t, b.acquire(ctx))
+	require.Equal(t, 2, q.opened)
+	b.release()
+	require.NoError(t, b.Close())
+	require.Equal(t, 2, q.closed)
+}
@@ -11,6 +11,11 @@ type Metrics struct {
 	blockLoadFailures prometheus.Counter
 	blockDrops        prometheus.Counter
 	blockDropFailures prometheus.Counter
+
+	blockLazyLoads        prometheus.Counter
+	blockLazyLoadFailures prometheus.Counter
+	blockLazyLoadDuration prometheus.Histogram
+	blockUnloads          prometheus.Counter
 }
 
 func NewMetrics(reg prometheus.Registerer) *Metrics {
@@ -39,6 +44,24 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 		Name: "pyroscope_bucket_store_block_drop_failures_total",
 		Help: "Total number of local blocks that failed to be dropped.",
 	})
-	reg.MustRegister(m.Synced, m.blockDropFailures, m.blockDrops, m.blockLoadFailures, m.blockLoads)
+	m.blockLazyLoads = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "pyroscope_bucket_store_block_lazy_loads_total",
+		Help: "Total number of blocks opened for reading on first query.",
+	})
+	m.blockLazyLoadFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "pyroscope_bucket_store_block_lazy_load_failures_total",
+		Help: "Total number of blocks that failed to be opened for reading on first query.",
+	})
+	m.blockLazyLoadDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "pyroscope_bucket_store_block_lazy_load_duration_seconds",
+		Help:    "Time spent opening blocks for reading on first query.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+	})
+	m.blockUnloads = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "pyroscope_bucket_store_block_unloads_total",
+		Help: "Total number of blocks unloaded after being idle.",
+	})
+	reg.MustRegister(m.Synced, m.blockDropFailures, m.blockDrops, m.blockLoadFailures, m.blockLoads,
+		m.blockLazyLoads, m.blockLazyLoadFailures, m.blockLazyLoadDuration, m.blockUnloads)
 	return &m
 }
@@ -3,18 +3,27 @@ package storegateway
 import (
 	"context"
 	"io"
+	"sync"
 
 	"github.com/bufbuild/connect-go"
 	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
+	"golang.org/x/sync/errgroup"
 
 	ingestv1 "github.com/grafana/phlare/api/gen/proto/go/ingester/v1"
+	typesv1 "github.com/grafana/phlare/api/gen/proto/go/types/v1"
+	phlaremodel "github.com/grafana/phlare/pkg/model"
 	"github.com/grafana/phlare/pkg/phlaredb"
+	"github.com/grafana/phlare/pkg/querier/stats"
 	"github.com/grafana/phlare/pkg/tenant"
 )
 
 func (s *StoreGateway) MergeProfilesStacktraces(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesStacktracesRequest, ingestv1.MergeProfilesStacktracesResponse]) error {
 	found, err := s.forBucketStore(ctx, func(bs *BucketStore) error {
+		ctx, err := s.queryContext(ctx, bs)
+		if err != nil {
+			return err
+		}
 		return bs.MergeProfilesStacktraces(ctx, stream)
 	})
 	if err != nil || found {
@@ -25,6 +34,10 @@ func (s *StoreGateway) MergeProfilesStacktraces(ctx context.Context, stream *con
 
 func (s *StoreGateway) MergeProfilesLabels(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesLabelsRequest, ingestv1.MergeProfilesLabelsResponse]) error {
 	found, err := s.forBucketStore(ctx, func(bs *BucketStore) error {
+		ctx, err := s.queryContext(ctx, bs)
+		if err != nil {
+			return err
+		}
 		return bs.MergeProfilesLabels(ctx, stream)
 	})
 	if err != nil || found {
@@ -35,6 +48,10 @@ func (s *StoreGateway) MergeProfilesLabels(ctx context.Context, stream *connect.
 
 func (s *StoreGateway) MergeProfilesPprof(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesPprofRequest, ingestv1.MergeProfilesPprofResponse]) error {
 	found, err := s.forBucketStore(ctx, func(bs *BucketStore) error {
+		ctx, err := s.queryContext(ctx, bs)
+		if err != nil {
+			return err
+		}
 		return bs.MergeProfilesPprof(ctx, stream)
 	})
 	if err != nil || found {
@@ -43,6 +60,16 @@ func (s *StoreGateway) MergeProfilesPprof(ctx context.Context, stream *connect.B
 	return terminateStream(stream)
 }
 
+// queryContext returns the context of the queries of the bucket store,
+// enforcing the query limits and the tombstones of the tenant.
+func (s *StoreGateway) queryContext(ctx context.Context, bs *BucketStore) (context.Context, error) {
+	ts, err := s.tombstones.Get(ctx, bs.tenantID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return phlaredb.ContextWithTombstones(phlaredb.ContextWithQueryLimiter(ctx, s.stores.limits, bs.tenantID), ts), nil
+}
+
 func terminateStream[Req, Resp any](stream *connect.BidiStream[Req, Resp]) (err error) {
 	if _, err = stream.Receive(); err != nil {
 		if errors.Is(err, io.EOF) {
@@ -69,26 +96,83 @@ func (s *StoreGateway) forBucketStore(ctx context.Context, f func(*BucketStore)
 	return false, nil
 }
 
-func (s *BucketStore) openBlocksForReading(ctx context.Context, minT, maxT model.Time) (phlaredb.Queriers, error) {
-	blks := s.blockSet.getFor(minT, maxT)
-	querier := make(phlaredb.Queriers, 0, len(blks))
-	for _, b := range blks {
-		querier = append(querier, b)
+// blockGetter returns a phlaredb.BlockGetter opening the blocks for reading,
+// and a function releasing them once the query is done. The bytes fetched
+// from the bucket by the blocks while they are acquired are added to the
+// stats of the query, if any: the reads of the concurrent queries of the
+// same blocks are included.
+func (s *BucketStore) blockGetter() (phlaredb.BlockGetter, func()) {
+	var (
+		mtx      sync.Mutex
+		acquired = make(map[*Block]uint64)
+		qs       *stats.Stats
+	)
+	get := func(ctx context.Context, minT, maxT model.Time) (phlaredb.Queriers, error) {
+		mtx.Lock()
+		qs = stats.FromContext(ctx)
+		mtx.Unlock()
+		blks := s.blockSet.getFor(minT, maxT)
+		g, ctx := errgroup.WithContext(ctx)
+		g.SetLimit(128)
+		for _, b := range blks {
+			b := b
+			g.Go(func() error {
+				if err := b.acquire(ctx); err != nil {
+					return err
+				}
+				mtx.Lock()
+				acquired[b] = b.fetchedBytes.Load()
+				mtx.Unlock()
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		querier := make(phlaredb.Queriers, 0, len(blks))
+		for _, b := range blks {
+			querier = append(querier, b)
+		}
+		return querier, nil
 	}
-	if err := querier.Open(ctx); err != nil {
-		return nil, err
+	release := func() {
+		mtx.Lock()
+		defer mtx.Unlock()
+		for b, fetched := range acquired {
+			qs.AddFetchedBlockBytes(b.fetchedBytes.Load() - fetched)
+			b.release()
+		}
+		acquired = nil
 	}
-	return querier, nil
+	return get, release
 }
 
 func (store *BucketStore) MergeProfilesStacktraces(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesStacktracesRequest, ingestv1.MergeProfilesStacktracesResponse]) error {
-	return phlaredb.MergeProfilesStacktraces(ctx, stream, store.openBlocksForReading)
+	qs, ctx := stats.ContextWithEmptyStats(ctx)
+	defer qs.SetHeader(stream.ResponseTrailer())
+	blockGetter, release := store.blockGetter()
+	defer release()
+	return phlaredb.MergeProfilesStacktraces(ctx, stream, blockGetter)
 }
 
 func (store *BucketStore) MergeProfilesLabels(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesLabelsRequest, ingestv1.MergeProfilesLabelsResponse]) error {
-	return phlaredb.MergeProfilesLabels(ctx, stream, store.openBlocksForReading)
+	qs, ctx := stats.ContextWithEmptyStats(ctx)
+	defer qs.SetHeader(stream.ResponseTrailer())
+	blockGetter, release := store.blockGetter()
+	defer release()
+	return phlaredb.MergeProfilesLabels(ctx, stream, blockGetter)
 }
 
 func (store *BucketStore) MergeProfilesPprof(ctx context.Context, stream *connect.BidiStream[ingestv1.MergeProfilesPprofRequest, ingestv1.MergeProfilesPprofResponse]) error {
-	return phlaredb.MergeProfilesPprof(ctx, stream, store.openBlocksForReading)
+	qs, ctx := stats.ContextWithEmptyStats(ctx)
+	defer qs.SetHeader(stream.ResponseTrailer())
+	blockGetter, release := store.blockGetter()
+	defer release()
+	return phlaredb.MergeProfilesPprof(ctx, stream, blockGetter)
+}
+
+func (store *BucketStore) SeriesTimeRanges(ctx context.Context, req *typesv1.CardinalityRequest) ([]*phlaremodel.SeriesTimeRange, error) {
+	blockGetter, release := store.blockGetter()
+	defer release()
+	return phlaredb.SeriesTimeRanges(ctx, req, blockGetter)
 }
@@ -0,0 +1,96 @@
+package tenantdeletion
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
+	"github.com/grafana/phlare/pkg/phlaredb/bucket"
+)
+
+// Marks keeps track of the tenants marked for deletion, by periodically
+// syncing the deletion marks from the bucket.
+type Marks struct {
+	services.Service
+
+	bucket phlareobj.Bucket
+	logger log.Logger
+
+	mu       sync.RWMutex
+	marked   map[string]*bucket.TenantDeletionMark
+	handlers []*markHandler
+}
+
+type markHandler struct {
+	fn func(ctx context.Context, tenantID string) error
+	// Tenants the handler has been successfully invoked for.
+	done map[string]struct{}
+}
+
+func NewMarks(bkt phlareobj.Bucket, syncInterval time.Duration, logger log.Logger) *Marks {
+	m := &Marks{
+		bucket: bkt,
+		logger: logger,
+		marked: make(map[string]*bucket.TenantDeletionMark),
+	}
+	m.Service = services.NewTimerService(syncInterval, m.iteration, m.iteration, nil)
+	return m
+}
+
+// IsMarked reports whether the tenant is marked for deletion.
+// The call is safe for a nil receiver.
+func (m *Marks) IsMarked(tenantID string) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.marked[tenantID]
+	return ok
+}
+
+// OnMarked registers the function to be invoked for every tenant marked
+// for deletion, once the tenant is known to be marked: IsMarked returns
+// true before the function is called. The function is retried at the next
+// sync if it fails. Handlers must be registered before the service starts.
+func (m *Marks) OnMarked(fn func(ctx context.Context, tenantID string) error) {
+	m.handlers = append(m.handlers, &markHandler{
+		fn:   fn,
+		done: make(map[string]struct{}),
+	})
+}
+
+func (m *Marks) iteration(ctx context.Context) error {
+	if err := m.sync(ctx); err != nil {
+		level.Error(m.logger).Log("msg", "failed to sync tenant deletion marks", "err", err)
+	}
+	return nil
+}
+
+func (m *Marks) sync(ctx context.Context) error {
+	marked, err := bucket.ListTenantDeletionMarks(ctx, m.bucket)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.marked = marked
+	m.mu.Unlock()
+	for tenantID := range marked {
+		for _, h := range m.handlers {
+			if _, ok := h.done[tenantID]; ok {
+				continue
+			}
+			if err = h.fn(ctx, tenantID); err != nil {
+				level.Error(m.logger).Log("msg", "failed to handle tenant deletion", "tenant", tenantID, "err", err)
+				continue
+			}
+			h.done[tenantID] = struct{}{}
+		}
+	}
+	return nil
+}
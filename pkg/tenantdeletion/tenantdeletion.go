@@ -0,0 +1,177 @@
+package tenantdeletion
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
+	"github.com/grafana/phlare/pkg/phlaredb/bucket"
+	"github.com/grafana/phlare/pkg/tenant"
+	"github.com/grafana/phlare/pkg/util"
+)
+
+type Config struct {
+	MarksSyncInterval time.Duration `yaml:"marks_sync_interval" category:"advanced"`
+	CleanupInterval   time.Duration `yaml:"cleanup_interval" category:"advanced"`
+	DeletionDelay     time.Duration `yaml:"deletion_delay" category:"advanced"`
+}
+
+// RegisterFlags registers the flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.DurationVar(&cfg.MarksSyncInterval, "tenant-deletion.marks-sync-interval", time.Minute, "How frequently distributors and ingesters sync the tenant deletion marks from the object storage.")
+	f.DurationVar(&cfg.CleanupInterval, "tenant-deletion.cleanup-interval", 15*time.Minute, "How frequently the data of the tenants marked for deletion is removed from the object storage.")
+	f.DurationVar(&cfg.DeletionDelay, "tenant-deletion.deletion-delay", 10*time.Minute, "Time to wait after a tenant is marked for deletion, before its data is removed from the object storage. It must be long enough for ingesters to drop the tenant data, instead of shipping it.")
+}
+
+// Deleter marks tenants for deletion and asynchronously removes the data of
+// the marked tenants from the object storage.
+type Deleter struct {
+	services.Service
+
+	cfg    Config
+	bucket phlareobj.Bucket
+	logger log.Logger
+
+	deletedBlocks prometheus.Counter
+	failures      prometheus.Counter
+}
+
+func New(cfg Config, bkt phlareobj.Bucket, logger log.Logger, reg prometheus.Registerer) *Deleter {
+	d := &Deleter{
+		cfg:    cfg,
+		bucket: bkt,
+		logger: logger,
+		deletedBlocks: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "pyroscope_tenant_deletion_deleted_blocks_total",
+			Help: "Total number of blocks removed from the object storage by tenant deletions.",
+		}),
+		failures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "pyroscope_tenant_deletion_failures_total",
+			Help: "Total number of tenant deletions that failed.",
+		}),
+	}
+	d.Service = services.NewTimerService(cfg.CleanupInterval, nil, d.iteration, nil)
+	return d
+}
+
+func (d *Deleter) iteration(ctx context.Context) error {
+	marks, err := bucket.ListTenantDeletionMarks(ctx, d.bucket)
+	if err != nil {
+		level.Error(d.logger).Log("msg", "failed to list tenant deletion marks", "err", err)
+		return nil
+	}
+	for tenantID, mark := range marks {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if mark.FinishedTime > 0 || time.Since(time.Unix(mark.DeletionTime, 0)) < d.cfg.DeletionDelay {
+			continue
+		}
+		if err = d.deleteTenant(ctx, tenantID, mark); err != nil {
+			d.failures.Inc()
+			level.Error(d.logger).Log("msg", "failed to delete tenant", "tenant", tenantID, "err", err)
+		}
+	}
+	return nil
+}
+
+func (d *Deleter) deleteTenant(ctx context.Context, tenantID string, mark *bucket.TenantDeletionMark) error {
+	level.Info(d.logger).Log("msg", "deleting tenant data from the object storage", "tenant", tenantID)
+	deleted, err := bucket.DeleteTenant(ctx, d.bucket, tenantID, d.logger)
+	d.deletedBlocks.Add(float64(deleted))
+	if err != nil {
+		return err
+	}
+	mark.FinishedTime = time.Now().Unix()
+	if err = bucket.WriteTenantDeletionMark(ctx, d.bucket, tenantID, mark); err != nil {
+		return err
+	}
+	level.Info(d.logger).Log("msg", "tenant data deleted from the object storage", "tenant", tenantID, "deleted_blocks", deleted)
+	return nil
+}
+
+// DeleteTenantHandler marks the tenant of the request for deletion.
+func (d *Deleter) DeleteTenantHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := tenant.ExtractTenantIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	mark, err := bucket.ReadTenantDeletionMark(r.Context(), d.bucket, tenantID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if mark == nil {
+		mark = bucket.NewTenantDeletionMark(time.Now())
+		if err = bucket.WriteTenantDeletionMark(r.Context(), d.bucket, tenantID, mark); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// The audit log of the tenant deletions.
+		level.Info(d.logger).Log("msg", "tenant marked for deletion", "tenant", tenantID, "remote_addr", r.RemoteAddr, "user_agent", r.UserAgent())
+	}
+	util.WriteJSONResponse(w, newStatus(tenantID, mark))
+}
+
+// DeleteTenantStatusHandler reports the deletion status of the tenant
+// of the request.
+func (d *Deleter) DeleteTenantStatusHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := tenant.ExtractTenantIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	mark, err := bucket.ReadTenantDeletionMark(r.Context(), d.bucket, tenantID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.WriteJSONResponse(w, newStatus(tenantID, mark))
+}
+
+const (
+	StateActive   = "active"
+	StateMarked   = "marked"
+	StateFinished = "finished"
+)
+
+// Status is the deletion status of a tenant.
+type Status struct {
+	TenantID string `json:"tenant_id"`
+	// State is one of StateActive, StateMarked and StateFinished.
+	State        string `json:"state"`
+	DeletionTime string `json:"deletion_time,omitempty"`
+	FinishedTime string `json:"finished_time,omitempty"`
+}
+
+func newStatus(tenantID string, mark *bucket.TenantDeletionMark) Status {
+	s := Status{TenantID: tenantID, State: StateActive}
+	if mark == nil {
+		return s
+	}
+	s.State = StateMarked
+	s.DeletionTime = formatUnix(mark.DeletionTime)
+	if mark.FinishedTime > 0 {
+		s.State = StateFinished
+		s.FinishedTime = formatUnix(mark.FinishedTime)
+	}
+	return s
+}
+
+func formatUnix(t int64) string {
+	return time.Unix(t, 0).UTC().Format(time.RFC3339)
+}
+
+// ErrTenantMarked is returned when the tenant is not allowed to ingest
+// data, because it is marked for deletion.
+var ErrTenantMarked = errors.New("tenant is marked for deletion")
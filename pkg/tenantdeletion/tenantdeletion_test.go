@@ -0,0 +1,100 @@
+package tenantdeletion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	phlareobj "github.com/grafana/phlare/pkg/objstore"
+	"github.com/grafana/phlare/pkg/phlaredb/bucket"
+	"github.com/grafana/phlare/pkg/tenant"
+)
+
+func Test_Deleter(t *testing.T) {
+	ctx := context.Background()
+	bkt := phlareobj.NewBucket(objstore.NewInMemBucket())
+	for _, name := range []string{
+		"foo/phlaredb/" + ulid.MustNew(1, nil).String() + "/meta.json",
+		"foo/phlaredb/" + ulid.MustNew(1, nil).String() + "/index.tsdb",
+		"foo/phlaredb/" + ulid.MustNew(2, nil).String() + "/meta.json",
+		"bar/phlaredb/" + ulid.MustNew(3, nil).String() + "/meta.json",
+	} {
+		require.NoError(t, bkt.Upload(ctx, name, bytes.NewReader([]byte("{}"))))
+	}
+	// Marks with invalid tenant IDs are ignored.
+	require.NoError(t, bkt.Upload(ctx, bucket.TenantDeletionMarksPrefix+"/..json", bytes.NewReader([]byte("{}"))))
+
+	d := New(Config{}, bkt, log.NewNopLogger(), prometheus.NewRegistry())
+	status := func(method string, handler http.HandlerFunc) Status {
+		req := httptest.NewRequest(method, "/", nil).WithContext(tenant.InjectTenantID(ctx, "foo"))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var s Status
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &s))
+		require.Equal(t, "foo", s.TenantID)
+		return s
+	}
+
+	require.Equal(t, StateActive, status(http.MethodGet, d.DeleteTenantStatusHandler).State)
+	marked := status(http.MethodPost, d.DeleteTenantHandler)
+	require.Equal(t, StateMarked, marked.State)
+	require.NotEmpty(t, marked.DeletionTime)
+	// Marking the tenant again does not change the deletion time.
+	require.Equal(t, marked, status(http.MethodPost, d.DeleteTenantHandler))
+
+	marks, err := bucket.ListTenantDeletionMarks(ctx, bkt)
+	require.NoError(t, err)
+	require.Len(t, marks, 1)
+	require.Contains(t, marks, "foo")
+
+	require.NoError(t, d.iteration(ctx))
+	finished := status(http.MethodGet, d.DeleteTenantStatusHandler)
+	require.Equal(t, StateFinished, finished.State)
+	require.NotEmpty(t, finished.FinishedTime)
+
+	var objects []string
+	require.NoError(t, bkt.Iter(ctx, "", func(name string) error {
+		objects = append(objects, name)
+		return nil
+	}, objstore.WithRecursiveIter))
+	require.Equal(t, []string{
+		"__pyroscope_cluster/tenant-deletion-marks/..json",
+		"__pyroscope_cluster/tenant-deletion-marks/foo.json",
+		"bar/phlaredb/" + ulid.MustNew(3, nil).String() + "/meta.json",
+	}, objects)
+}
+
+func Test_Marks(t *testing.T) {
+	ctx := context.Background()
+	bkt := phlareobj.NewBucket(objstore.NewInMemBucket())
+	m := NewMarks(bkt, 0, log.NewNopLogger())
+	var handled []string
+	m.OnMarked(func(_ context.Context, tenantID string) error {
+		require.True(t, m.IsMarked(tenantID))
+		handled = append(handled, tenantID)
+		return nil
+	})
+
+	require.NoError(t, m.sync(ctx))
+	require.False(t, m.IsMarked("foo"))
+	require.NoError(t, bucket.WriteTenantDeletionMark(ctx, bkt, "foo", bucket.NewTenantDeletionMark(time.Now())))
+	require.NoError(t, m.sync(ctx))
+	require.NoError(t, m.sync(ctx))
+	require.True(t, m.IsMarked("foo"))
+	require.False(t, m.IsMarked("bar"))
+	require.Equal(t, []string{"foo"}, handled)
+
+	var nilMarks *Marks
+	require.False(t, nilMarks.IsMarked("foo"))
+}
@@ -52,7 +52,15 @@ type GRPCHandler interface {
 }
 
 func RoundTripUnary[Req any, Res any](ctx context.Context, rt GRPCRoundTripper, in *connect.Request[Req]) (*connect.Response[Res], error) {
-	req, err := encodeRequest(in)
+	return RoundTripUnaryProcedure[Req, Res](ctx, rt, in.Spec().Procedure, in)
+}
+
+// RoundTripUnaryProcedure is like RoundTripUnary, but the request is sent to
+// the given procedure rather than to the one of the request spec. It allows
+// issuing requests which have not been received as such, e.g. to collect
+// information about a query before executing it.
+func RoundTripUnaryProcedure[Req any, Res any](ctx context.Context, rt GRPCRoundTripper, procedure string, in *connect.Request[Req]) (*connect.Response[Res], error) {
+	req, err := encodeRequest(in, procedure)
 	if err != nil {
 		return nil, err
 	}
@@ -115,8 +123,8 @@ func decodeRequest[Req any](req *httpgrpc.HTTPRequest) (*connect.Request[Req], e
 	return result, nil
 }
 
-func encodeRequest[Req any](req *connect.Request[Req]) (*httpgrpc.HTTPRequest, error) {
-	if req.Spec().Procedure == "" {
+func encodeRequest[Req any](req *connect.Request[Req], procedure string) (*httpgrpc.HTTPRequest, error) {
+	if procedure == "" {
 		return nil, errors.New("cannot encode a request with empty procedure")
 	}
 	// The original Content-* headers could be invalidated,
@@ -125,7 +133,7 @@ func encodeRequest[Req any](req *connect.Request[Req]) (*httpgrpc.HTTPRequest, e
 	h.Set("Content-Type", "application/proto")
 	out := &httpgrpc.HTTPRequest{
 		Method:  http.MethodPost,
-		Url:     req.Spec().Procedure,
+		Url:     procedure,
 		Headers: connectHeaderToHTTPGRPCHeader(h),
 	}
 	var err error
@@ -154,6 +162,10 @@ func decodeResponse[Resp any](r *httpgrpc.HTTPResponse) (*connect.Response[Resp]
 	if err := proto.Unmarshal(r.Body, resp.Any().(proto.Message)); err != nil {
 		return nil, err
 	}
+	// The Content-* headers describe the encoded body, not the message.
+	for k, v := range removeContentHeaders(httpgrpcHeaderToConnectHeader(r.Headers)) {
+		resp.Header()[k] = v
+	}
 	return resp, nil
 }
 
@@ -341,3 +353,14 @@ func (g *httpgrpcClient) Do(req *http.Request) (*http.Response, error) {
 	}, nil
 
 }
+
+// WriteHTTPError writes the error as a plain text HTTP response, with the
+// HTTP status of its code if it is a connect error.
+func WriteHTTPError(w http.ResponseWriter, err error) {
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		http.Error(w, connectErr.Message(), int(CodeToHTTP(connectErr.Code())))
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
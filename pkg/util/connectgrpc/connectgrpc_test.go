@@ -45,7 +45,7 @@ func Test_DecodeGRPC(t *testing.T) {
 	}
 	_, _ = client.LabelValues(context.Background(), connect.NewRequest(req))
 
-	encoded, err := encodeRequest(f.req)
+	encoded, err := encodeRequest(f.req, f.req.Spec().Procedure)
 	require.NoError(t, err)
 	require.Equal(t, "POST", encoded.Method)
 	require.Equal(t, "/querier.v1.QuerierService/LabelValues", encoded.Url)
@@ -55,3 +55,18 @@ func Test_DecodeGRPC(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, req.Name, decoded.Msg.Name)
 }
+
+func Test_DecodeResponseHeaders(t *testing.T) {
+	resp := connect.NewResponse(&typesv1.LabelValuesResponse{Names: []string{"foo"}})
+	resp.Header().Set("X-Query-Stats", "{}")
+	resp.Header().Set("Content-Type", "application/proto")
+
+	encoded, err := encodeResponse(resp)
+	require.NoError(t, err)
+
+	decoded, err := decodeResponse[typesv1.LabelValuesResponse](encoded)
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo"}, decoded.Msg.Names)
+	require.Equal(t, "{}", decoded.Header().Get("X-Query-Stats"))
+	require.Empty(t, decoded.Header().Get("Content-Type"))
+}
@@ -20,6 +20,23 @@ func SmallestPositiveNonZeroIntPerTenant(tenantIDs []string, f func(string) int)
 	return *result
 }
 
+// SmallestPositiveNonZeroFloat64PerTenant is returning the minimal positive
+// and non-zero value of the supplied limit function for all given tenants. It
+// returns 0 only if all inputs have a limit of 0 or an empty tenant list is given.
+func SmallestPositiveNonZeroFloat64PerTenant(tenantIDs []string, f func(string) float64) float64 {
+	var result *float64
+	for _, tenantID := range tenantIDs {
+		v := f(tenantID)
+		if v > 0 && (result == nil || v < *result) {
+			result = &v
+		}
+	}
+	if result == nil {
+		return 0
+	}
+	return *result
+}
+
 // MaxDurationOrZeroPerTenant is returning the maximum duration per tenant or zero if one tenant has time.Duration(0).
 func MaxDurationOrZeroPerTenant(tenantIDs []string, f func(string) time.Duration) time.Duration {
 	var result *time.Duration
@@ -41,11 +41,21 @@ type Limits struct {
 	MaxQueryLength      model.Duration `yaml:"max_query_length" json:"max_query_length"`
 	MaxQueryParallelism int            `yaml:"max_query_parallelism" json:"max_query_parallelism"`
 
+	// Ingester and store-gateway enforced query limits.
+	MaxQuerySeries              int `yaml:"max_query_series" json:"max_query_series"`
+	MaxQueryProfiles            int `yaml:"max_query_profiles" json:"max_query_profiles"`
+	MaxQueryStacktracesPerBlock int `yaml:"max_query_stacktraces_per_block" json:"max_query_stacktraces_per_block"`
+
 	// Store-gateway.
 	StoreGatewayTenantShardSize int `yaml:"store_gateway_tenant_shard_size" json:"store_gateway_tenant_shard_size"`
 
 	// Query frontend.
-	QuerySplitDuration model.Duration `yaml:"split_queries_by_interval" json:"split_queries_by_interval"`
+	QuerySplitDuration       model.Duration `yaml:"split_queries_by_interval" json:"split_queries_by_interval"`
+	QueryShardingTotalShards int            `yaml:"query_sharding_total_shards" json:"query_sharding_total_shards"`
+
+	// Query scheduler.
+	MaxQueriersPerTenant int     `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
+	QueryTenantWeight    float64 `yaml:"query_tenant_weight" json:"query_tenant_weight"`
 }
 
 // LimitError are errors that do not comply with the limits specified.
@@ -75,12 +85,21 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	_ = l.MaxQueryLookback.Set("7d")
 	f.Var(&l.MaxQueryLookback, "querier.max-query-lookback", "Limit how far back in profiling data can be queried, up until lookback duration ago. This limit is enforced in the query frontend. If the requested time range is outside the allowed range, the request will not fail, but will be modified to only query data within the allowed time range. 0 to disable, default to 7d.")
 
+	f.IntVar(&l.MaxQuerySeries, "querier.max-query-series", 0, "Maximum number of series a query can match, enforced by each ingester and store-gateway on the data it holds. The query fails with a resource exhausted error when the limit is exceeded. 0 to disable.")
+	f.IntVar(&l.MaxQueryProfiles, "querier.max-query-profiles", 0, "Maximum number of profiles a query can scan, enforced by each ingester and store-gateway on the data it holds. The query fails with a resource exhausted error when the limit is exceeded. 0 to disable.")
+	f.IntVar(&l.MaxQueryStacktracesPerBlock, "querier.max-query-stacktraces-per-block", 0, "Maximum number of stacktraces a query can resolve in a single block, enforced by each ingester and store-gateway on each block it reads: a stacktrace present in several blocks is counted in each of them. The query fails with a resource exhausted error when the limit is exceeded. 0 to disable.")
+
 	f.IntVar(&l.StoreGatewayTenantShardSize, "store-gateway.tenant-shard-size", 0, "The tenant's shard size, used when store-gateway sharding is enabled. Value of 0 disables shuffle sharding for the tenant, that is all tenant blocks are sharded across all store-gateway replicas.")
 
 	_ = l.QuerySplitDuration.Set("0s")
 	f.Var(&l.QuerySplitDuration, "querier.split-queries-by-interval", "Split queries by a time interval and execute in parallel. The value 0 disables splitting by time")
 
+	f.IntVar(&l.QueryShardingTotalShards, "querier.query-sharding-total-shards", 0, "Split the queries of each time interval into this number of shards by series fingerprint, executed in parallel. The value 0 or 1 disables sharding.")
+
 	f.IntVar(&l.MaxQueryParallelism, "querier.max-query-parallelism", 0, "Maximum number of queries that will be scheduled in parallel by the frontend.")
+
+	f.IntVar(&l.MaxQueriersPerTenant, "query-scheduler.max-queriers-per-tenant", 0, "Maximum number of queriers that can handle requests for a single tenant. If set to 0 or value higher than number of available queriers, *all* queriers will handle requests for the tenant. Each frontend (or query-scheduler, if used) will select the same set of queriers for the same tenant (given that all queriers are connected to all frontends / query-schedulers). This option only works with queriers connecting to the query-frontend / query-scheduler, not when using downstream URL.")
+	f.Float64Var(&l.QueryTenantWeight, "query-scheduler.tenant-weight", 1, "Weight of the tenant in the fair share of the queriers. A tenant with a weight of 2 gets twice the querier time of a tenant with a weight of 1 when both have pending queries. The cost of a query is estimated from its time range and the number of series it selects.")
 }
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
@@ -105,6 +124,9 @@ func (l *Limits) UnmarshalYAML(unmarshal func(interface{}) error) error {
 
 // Validate validates that this limits config is valid.
 func (l *Limits) Validate() error {
+	if l.QueryTenantWeight <= 0 {
+		return errors.New("the query tenant weight must be greater than 0")
+	}
 	return nil
 }
 
@@ -210,6 +232,22 @@ func (o *Overrides) MaxQueryLookback(tenantID string) time.Duration {
 	return time.Duration(o.getOverridesForTenant(tenantID).MaxQueryLookback)
 }
 
+// MaxQuerySeries returns the limit to the number of series a query can match.
+func (o *Overrides) MaxQuerySeries(tenantID string) int {
+	return o.getOverridesForTenant(tenantID).MaxQuerySeries
+}
+
+// MaxQueryProfiles returns the limit to the number of profiles a query can scan.
+func (o *Overrides) MaxQueryProfiles(tenantID string) int {
+	return o.getOverridesForTenant(tenantID).MaxQueryProfiles
+}
+
+// MaxQueryStacktracesPerBlock returns the limit to the number of stacktraces a
+// query can resolve in a single block.
+func (o *Overrides) MaxQueryStacktracesPerBlock(tenantID string) int {
+	return o.getOverridesForTenant(tenantID).MaxQueryStacktracesPerBlock
+}
+
 // StoreGatewayTenantShardSize returns the store-gateway shard size for a given user.
 func (o *Overrides) StoreGatewayTenantShardSize(userID string) int {
 	return o.getOverridesForTenant(userID).StoreGatewayTenantShardSize
@@ -220,10 +258,23 @@ func (o *Overrides) QuerySplitDuration(tenantID string) time.Duration {
 	return time.Duration(o.getOverridesForTenant(tenantID).QuerySplitDuration)
 }
 
+// QueryShardingTotalShards returns the tenant specific number of shards by
+// series applied to each query interval in the query frontend.
+func (o *Overrides) QueryShardingTotalShards(tenantID string) int {
+	return o.getOverridesForTenant(tenantID).QueryShardingTotalShards
+}
+
 // MaxQueriersPerTenant returns the limit to the number of queriers that can be used
 // Shuffle sharding will be used to distribute queries across queriers.
-// 0 means no limit. Currently disabled.
-func (o *Overrides) MaxQueriersPerTenant(tenant string) int { return 0 }
+// 0 means no limit.
+func (o *Overrides) MaxQueriersPerTenant(tenantID string) int {
+	return o.getOverridesForTenant(tenantID).MaxQueriersPerTenant
+}
+
+// QueryTenantWeight returns the weight of the tenant in the fair share of the queriers.
+func (o *Overrides) QueryTenantWeight(tenantID string) float64 {
+	return o.getOverridesForTenant(tenantID).QueryTenantWeight
+}
 
 func (o *Overrides) DefaultLimits() *Limits {
 	return o.defaultLimits